@@ -1,7 +1,6 @@
 package commands
 
 import (
-	"encoding/json"
 	"io"
 
 	"code.cloudfoundry.org/bbs"
@@ -69,7 +68,7 @@ func ActualLRPGroups(stdout, stderr io.Writer, bbsClient bbs.Client, domain, cel
 	traceID := trace.GenerateTraceID()
 	logger := trace.LoggerWithTraceInfo(globalLogger.Session("actual-lrp-groups"), traceID)
 
-	encoder := json.NewEncoder(stdout)
+	encoder := newOutputEncoder(stdout)
 
 	actualLRPFilter := models.ActualLRPFilter{
 		CellID: cellID,
@@ -88,5 +87,5 @@ func ActualLRPGroups(stdout, stderr io.Writer, bbsClient bbs.Client, domain, cel
 		}
 	}
 
-	return nil
+	return encoder.Flush()
 }
@@ -1,7 +1,6 @@
 package commands
 
 import (
-	"encoding/json"
 	"fmt"
 	"io"
 	"os"
@@ -84,7 +83,7 @@ func ActualLRPGroupsForGuid(stdout, stderr io.Writer, bbsClient bbs.Client, proc
 	traceID := trace.GenerateTraceID()
 	logger := trace.LoggerWithTraceInfo(globalLogger.Session("actual-lrp-groups-for-guid"), traceID)
 
-	encoder := json.NewEncoder(stdout)
+	encoder := newOutputEncoder(stdout)
 	if index < 0 {
 		actualLRPGroups, err := bbsClient.ActualLRPGroupsByProcessGuid(logger, traceID, processGuid)
 		if err != nil {
@@ -98,13 +97,18 @@ func ActualLRPGroupsForGuid(stdout, stderr io.Writer, bbsClient bbs.Client, proc
 			}
 		}
 
-		return nil
+		return encoder.Flush()
 	} else {
 		actualLRPGroup, err := bbsClient.ActualLRPGroupByProcessGuidAndIndex(logger, traceID, processGuid, index)
 		if err != nil {
 			return err
 		}
 
-		return encoder.Encode(actualLRPGroup)
+		err = encoder.Encode(actualLRPGroup)
+		if err != nil {
+			return err
+		}
+
+		return encoder.Flush()
 	}
 }
@@ -1,7 +1,6 @@
 package commands
 
 import (
-	"encoding/json"
 	"io"
 
 	"code.cloudfoundry.org/bbs"
@@ -78,7 +77,7 @@ func ActualLRPs(stdout, stderr io.Writer, bbsClient bbs.Client, domain, cellID,
 	traceID := trace.GenerateTraceID()
 	logger := trace.LoggerWithTraceInfo(globalLogger.Session("actual-lrps"), traceID)
 
-	encoder := json.NewEncoder(stdout)
+	encoder := newOutputEncoder(stdout)
 
 	actualLRPFilter := models.ActualLRPFilter{
 		CellID:      cellID,
@@ -99,5 +98,5 @@ func ActualLRPs(stdout, stderr io.Writer, bbsClient bbs.Client, domain, cellID,
 		}
 	}
 
-	return nil
+	return encoder.Flush()
 }
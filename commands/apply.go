@@ -0,0 +1,542 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"code.cloudfoundry.org/bbs"
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/bbs/trace"
+	"code.cloudfoundry.org/cfdot/commands/helpers"
+	"code.cloudfoundry.org/lager/v3"
+	"github.com/spf13/cobra"
+	yaml "gopkg.in/yaml.v3"
+)
+
+const (
+	ApplyKindDesiredLRP = "desired_lrp"
+	ApplyKindTask       = "task"
+
+	ApplyActionCreate    = "create"
+	ApplyActionUpdate    = "update"
+	ApplyActionRecreate  = "recreate"
+	ApplyActionUnchanged = "unchanged"
+)
+
+// desiredLRPUpdateFields are the desired LRP fields that can be changed
+// with a models.DesiredLRPUpdate. Changes to any other field require the
+// desired LRP to be recreated.
+var desiredLRPUpdateFields = map[string]bool{
+	"instances":   true,
+	"routes":      true,
+	"annotation":  true,
+	"metric_tags": true,
+}
+
+// desiredLRPServerFields are set by the BBS and never compared.
+var desiredLRPServerFields = map[string]bool{
+	"modification_tag": true,
+}
+
+// ApplySpec is a desired LRP or a task read from a spec file.
+type ApplySpec struct {
+	File       string
+	DesiredLRP *models.DesiredLRP
+	Task       *models.Task
+
+	// fields are the top level fields set in the spec file. Only they are
+	// compared, so that fields populated by the BBS do not show up as
+	// changes.
+	fields map[string]bool
+}
+
+// ApplyChange is printed by apply for every spec before any change is made.
+type ApplyChange struct {
+	Kind   string         `json:"kind"`
+	Guid   string         `json:"guid"`
+	File   string         `json:"file"`
+	Action string         `json:"action"`
+	Fields []*FieldChange `json:"fields,omitempty"`
+
+	spec   *ApplySpec
+	update *models.DesiredLRPUpdate
+}
+
+// FieldChange is a difference between the current and the desired value of
+// a top level field of a spec.
+type FieldChange struct {
+	Field            string      `json:"field"`
+	Current          interface{} `json:"current"`
+	Desired          interface{} `json:"desired"`
+	RequiresRecreate bool        `json:"requires_recreate,omitempty"`
+}
+
+// errors
+var (
+	errMissingApplyFilename = errors.New("-f/--filename is required")
+	errInvalidTaskGuid      = errors.New("Task guid should be non empty string")
+	errApplyAborted         = errors.New("Apply aborted")
+)
+
+// flags
+var (
+	applyFilenameFlag string
+	applyYesFlag      bool
+)
+
+var applyCmd = &cobra.Command{
+	Use:   "apply -f (FILE|DIR)",
+	Short: "Apply desired LRP and task specs",
+	Long:  "Compare the desired LRP and task specs in the given json or yaml file, or in the files of the given directory, with the BBS and print the differences. Then create the missing desired LRPs and tasks and update the instances, routes, annotation and metric tags of the existing desired LRPs. Desired LRPs with other changes are reported as requiring a recreate and left untouched. Fields left out of a spec are not compared. Nothing is applied when one of the changes fails the validation of the BBS",
+	RunE:  apply,
+}
+
+func init() {
+	AddBBSAndTimeoutFlags(applyCmd)
+	AddDryRunFlag(applyCmd)
+
+	applyCmd.Flags().StringVarP(&applyFilenameFlag, "filename", "f", "", "spec file, or directory containing .json, .yml and .yaml spec files")
+	applyCmd.Flags().BoolVarP(&applyYesFlag, "yes", "y", false, "apply the changes without asking for confirmation")
+
+	RootCmd.AddCommand(applyCmd)
+}
+
+func apply(cmd *cobra.Command, args []string) error {
+	specs, err := ValidateApplyArguments(args)
+	if err != nil {
+		return NewCFDotValidationError(cmd, err)
+	}
+
+	bbsClient, err := helpers.NewBBSClient(cmd, Config)
+	if err != nil {
+		return NewCFDotError(cmd, err)
+	}
+
+	bbsClient = mutatingBBSClient(cmd, bbsClient)
+
+	err = Apply(cmd.OutOrStdout(), cmd.OutOrStderr(), cmd.InOrStdin(), bbsClient, specs, applyYesFlag || DryRun)
+	if err != nil {
+		return NewCFDotError(cmd, err)
+	}
+
+	return nil
+}
+
+func ValidateApplyArguments(args []string) ([]*ApplySpec, error) {
+	switch {
+	case len(args) > 0:
+		return nil, errExtraArguments
+	case applyFilenameFlag == "":
+		return nil, errMissingApplyFilename
+	}
+	return ReadApplySpecs(applyFilenameFlag)
+}
+
+// ReadApplySpecs reads the specs in the given file, or in the .json, .yml
+// and .yaml files of the given directory and its subdirectories. A file may
+// contain several specs, as a json stream or as yaml documents.
+func ReadApplySpecs(path string) ([]*ApplySpec, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []string{path}
+	if info.IsDir() {
+		files = []string{}
+		err = filepath.WalkDir(path, func(file string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			switch strings.ToLower(filepath.Ext(file)) {
+			case ".json", ".yml", ".yaml":
+				if !entry.IsDir() {
+					files = append(files, file)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	specs := []*ApplySpec{}
+	seen := map[string]string{}
+	for _, file := range files {
+		documents, err := readSpecDocuments(file)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", file, err)
+		}
+
+		for _, document := range documents {
+			spec, err := parseApplySpec(file, document)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s", file, err)
+			}
+
+			kind, guid := spec.kindAndGuid()
+			id := kind + "/" + guid
+			if other, ok := seen[id]; ok {
+				return nil, fmt.Errorf("%s: %s %s is also specified in %s", file, kind, guid, other)
+			}
+			seen[id] = file
+			specs = append(specs, spec)
+		}
+	}
+
+	return specs, nil
+}
+
+func readSpecDocuments(file string) ([]json.RawMessage, error) {
+	contents, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	documents := []json.RawMessage{}
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yml", ".yaml":
+		decoder := yaml.NewDecoder(bytes.NewReader(contents))
+		for {
+			var document interface{}
+			err := decoder.Decode(&document)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("Invalid YAML: %s", err)
+			}
+			if document == nil {
+				continue
+			}
+
+			encoded, err := json.Marshal(document)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid YAML: %s", err)
+			}
+			documents = append(documents, encoded)
+		}
+	default:
+		decoder := json.NewDecoder(bytes.NewReader(contents))
+		for decoder.More() {
+			var document json.RawMessage
+			err := decoder.Decode(&document)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid JSON: %s", err)
+			}
+			documents = append(documents, document)
+		}
+	}
+
+	return documents, nil
+}
+
+func parseApplySpec(file string, document json.RawMessage) (*ApplySpec, error) {
+	var fields map[string]interface{}
+	err := json.Unmarshal(document, &fields)
+	if err != nil {
+		return nil, fmt.Errorf("spec must be an object: %s", err)
+	}
+
+	spec := &ApplySpec{File: file, fields: map[string]bool{}}
+	for name := range fields {
+		spec.fields[name] = true
+	}
+	switch {
+	case fields["process_guid"] != nil:
+		err = json.Unmarshal(document, &spec.DesiredLRP)
+		if err == nil && spec.DesiredLRP.ProcessGuid == "" {
+			err = errInvalidProcessGuid
+		}
+	case fields["task_guid"] != nil:
+		err = json.Unmarshal(document, &spec.Task)
+		if err == nil && spec.Task.TaskGuid == "" {
+			err = errInvalidTaskGuid
+		}
+	default:
+		err = errors.New("spec has neither a process_guid nor a task_guid")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return spec, nil
+}
+
+func (s *ApplySpec) kindAndGuid() (string, string) {
+	if s.DesiredLRP != nil {
+		return ApplyKindDesiredLRP, s.DesiredLRP.ProcessGuid
+	}
+	return ApplyKindTask, s.Task.TaskGuid
+}
+
+// Apply prints the changes needed to converge the BBS on the specs and, once
+// confirmed, makes them. Desired LRPs that need to be recreated are only
+// reported and cause an error once the other changes have been made.
+func Apply(stdout, stderr io.Writer, stdin io.Reader, bbsClient bbs.Client, specs []*ApplySpec, assumeYes bool) error {
+	traceID := trace.GenerateTraceID()
+	logger := trace.LoggerWithTraceInfo(globalLogger.Session("apply"), traceID)
+
+	changes, err := PlanApply(bbsClient, specs)
+	if err != nil {
+		return err
+	}
+
+	encoder := newOutputEncoder(stdout)
+	pending, recreates := 0, 0
+	for _, change := range changes {
+		switch change.Action {
+		case ApplyActionCreate, ApplyActionUpdate:
+			pending++
+		case ApplyActionRecreate:
+			recreates++
+		}
+
+		err = encoder.Encode(change)
+		if err != nil {
+			return err
+		}
+	}
+	err = encoder.Flush()
+	if err != nil {
+		return err
+	}
+
+	err = validateApplyChanges(changes)
+	if err != nil {
+		return err
+	}
+
+	if pending > 0 {
+		if !assumeYes && !confirm(stdin, stderr, fmt.Sprintf("Apply %d changes?", pending)) {
+			return errApplyAborted
+		}
+
+		for _, change := range changes {
+			err = applyChange(logger, traceID, bbsClient, change)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if recreates > 0 {
+		return fmt.Errorf("%d desired LRPs have changes that require a recreate", recreates)
+	}
+	return nil
+}
+
+// PlanApply compares the specs with the desired LRPs and the tasks of the
+// BBS.
+func PlanApply(bbsClient bbs.Client, specs []*ApplySpec) ([]*ApplyChange, error) {
+	traceID := trace.GenerateTraceID()
+	logger := trace.LoggerWithTraceInfo(globalLogger.Session("plan-apply"), traceID)
+
+	changes := []*ApplyChange{}
+	for _, spec := range specs {
+		kind, guid := spec.kindAndGuid()
+		change := &ApplyChange{Kind: kind, Guid: guid, File: spec.File, spec: spec}
+
+		var err error
+		if spec.DesiredLRP != nil {
+			err = planDesiredLRP(logger, traceID, bbsClient, change)
+		} else {
+			err = planTask(logger, traceID, bbsClient, change)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		changes = append(changes, change)
+	}
+
+	return changes, nil
+}
+
+func planDesiredLRP(logger lager.Logger, traceID string, bbsClient bbs.Client, change *ApplyChange) error {
+	desired := change.spec.DesiredLRP
+
+	current, err := bbsClient.DesiredLRPByProcessGuid(logger, traceID, desired.ProcessGuid)
+	if isResourceNotFound(err) {
+		change.Action = ApplyActionCreate
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	fields, err := diffFields(current, desired)
+	if err != nil {
+		return err
+	}
+
+	change.Action = ApplyActionUnchanged
+	for _, field := range fields {
+		if desiredLRPServerFields[field.Field] || !change.spec.fields[field.Field] {
+			continue
+		}
+
+		change.Fields = append(change.Fields, field)
+		if !desiredLRPUpdateFields[field.Field] {
+			field.RequiresRecreate = true
+			change.Action = ApplyActionRecreate
+		} else if change.Action == ApplyActionUnchanged {
+			change.Action = ApplyActionUpdate
+		}
+	}
+
+	if change.Action == ApplyActionUpdate {
+		change.update = newDesiredLRPUpdate(desired, change.Fields)
+	}
+	return nil
+}
+
+// newDesiredLRPUpdate returns the update setting the changed fields that
+// can be updated to their value in desired.
+func newDesiredLRPUpdate(desired *models.DesiredLRP, fields []*FieldChange) *models.DesiredLRPUpdate {
+	update := &models.DesiredLRPUpdate{}
+	for _, field := range fields {
+		switch field.Field {
+		case "instances":
+			update.SetInstances(desired.Instances)
+		case "routes":
+			routes := desired.Routes
+			if routes == nil {
+				routes = &models.Routes{}
+			}
+			update.Routes = routes
+		case "annotation":
+			update.SetAnnotation(desired.Annotation)
+		case "metric_tags":
+			metricTags := desired.MetricTags
+			if metricTags == nil {
+				metricTags = map[string]*models.MetricTagValue{}
+			}
+			update.MetricTags = metricTags
+		}
+	}
+	return update
+}
+
+// planTask only checks whether the task exists, as tasks cannot be changed
+// once desired.
+func planTask(logger lager.Logger, traceID string, bbsClient bbs.Client, change *ApplyChange) error {
+	_, err := bbsClient.TaskByGuid(logger, traceID, change.Guid)
+	if isResourceNotFound(err) {
+		change.Action = ApplyActionCreate
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	change.Action = ApplyActionUnchanged
+	return nil
+}
+
+// validateApplyChanges runs the BBS validation on every create and update,
+// so that an invalid spec is reported before any change is made.
+func validateApplyChanges(changes []*ApplyChange) error {
+	invalid := []string{}
+	for _, change := range changes {
+		var model validatable
+		switch {
+		case change.Action == ApplyActionCreate && change.spec.DesiredLRP != nil:
+			model = change.spec.DesiredLRP
+		case change.Action == ApplyActionCreate:
+			model = change.spec.Task
+		case change.Action == ApplyActionUpdate:
+			model = change.update
+		default:
+			continue
+		}
+
+		for _, err := range flattenValidationError(model.Validate()) {
+			violation := newViolation("$", err)
+			invalid = append(invalid, fmt.Sprintf("%s: %s %s: %s: %s", change.File, change.Kind, change.Guid, violation.Path, violation.Error))
+		}
+	}
+
+	if len(invalid) > 0 {
+		return fmt.Errorf("Invalid specs, nothing was applied:\n%s", strings.Join(invalid, "\n"))
+	}
+	return nil
+}
+
+func applyChange(logger lager.Logger, traceID string, bbsClient bbs.Client, change *ApplyChange) error {
+	spec := change.spec
+
+	switch {
+	case change.Action == ApplyActionCreate && spec.DesiredLRP != nil:
+		return bbsClient.DesireLRP(logger, traceID, spec.DesiredLRP)
+	case change.Action == ApplyActionCreate:
+		return bbsClient.DesireTask(logger, traceID, spec.Task.TaskGuid, spec.Task.Domain, spec.Task.TaskDefinition)
+	case change.Action == ApplyActionUpdate:
+		return bbsClient.UpdateDesiredLRP(logger, traceID, spec.DesiredLRP.ProcessGuid, change.update)
+	}
+	return nil
+}
+
+// diffFields compares the top level json fields of current and desired.
+// Both values are encoded the same way, so fields left out of a spec compare
+// equal to their zero value.
+func diffFields(current, desired interface{}) ([]*FieldChange, error) {
+	currentFields, err := jsonFields(current)
+	if err != nil {
+		return nil, err
+	}
+	desiredFields, err := jsonFields(desired)
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for name := range currentFields {
+		names = append(names, name)
+	}
+	for name := range desiredFields {
+		if _, ok := currentFields[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	fields := []*FieldChange{}
+	for _, name := range names {
+		if reflect.DeepEqual(currentFields[name], desiredFields[name]) {
+			continue
+		}
+		fields = append(fields, &FieldChange{
+			Field:   name,
+			Current: currentFields[name],
+			Desired: desiredFields[name],
+		})
+	}
+	return fields, nil
+}
+
+func jsonFields(v interface{}) (map[string]interface{}, error) {
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := map[string]interface{}{}
+	err = json.Unmarshal(encoded, &fields)
+	return fields, err
+}
+
+func isResourceNotFound(err error) bool {
+	modelErr, ok := err.(*models.Error)
+	return ok && modelErr.Equal(models.ErrResourceNotFound)
+}
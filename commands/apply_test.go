@@ -0,0 +1,335 @@
+package commands_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"code.cloudfoundry.org/bbs/fake_bbs"
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/cfdot/commands"
+	"code.cloudfoundry.org/lager/v3"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("Apply", func() {
+	var (
+		fakeBBSClient  *fake_bbs.FakeClient
+		stdout, stderr *gbytes.Buffer
+		dir            string
+		current        map[string]*models.DesiredLRP
+	)
+
+	writeFile := func(name, contents string) {
+		path := filepath.Join(dir, name)
+		Expect(os.MkdirAll(filepath.Dir(path), 0700)).To(Succeed())
+		Expect(os.WriteFile(path, []byte(contents), 0600)).To(Succeed())
+	}
+
+	readSpecs := func() []*commands.ApplySpec {
+		specs, err := commands.ReadApplySpecs(dir)
+		Expect(err).NotTo(HaveOccurred())
+		return specs
+	}
+
+	changes := func() []commands.ApplyChange {
+		changes := []commands.ApplyChange{}
+		decoder := json.NewDecoder(stdout)
+		for decoder.More() {
+			var change commands.ApplyChange
+			Expect(decoder.Decode(&change)).To(Succeed())
+			changes = append(changes, change)
+		}
+		return changes
+	}
+
+	BeforeEach(func() {
+		fakeBBSClient = &fake_bbs.FakeClient{}
+		stdout = gbytes.NewBuffer()
+		stderr = gbytes.NewBuffer()
+
+		var err error
+		dir, err = os.MkdirTemp("", "apply")
+		Expect(err).NotTo(HaveOccurred())
+
+		current = map[string]*models.DesiredLRP{}
+		fakeBBSClient.DesiredLRPByProcessGuidStub = func(_ lager.Logger, _ string, processGuid string) (*models.DesiredLRP, error) {
+			desiredLRP, ok := current[processGuid]
+			if !ok {
+				return nil, models.ErrResourceNotFound
+			}
+			return desiredLRP, nil
+		}
+		fakeBBSClient.TaskByGuidReturns(nil, models.ErrResourceNotFound)
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	Describe("ReadApplySpecs", func() {
+		It("reads json streams and yaml documents from the directory and its subdirectories", func() {
+			writeFile("lrps.json", `{"process_guid":"guid-1","instances":1}
+{"process_guid":"guid-2","instances":2}`)
+			writeFile("canary/lrp.yml", "process_guid: guid-3\ninstances: 3\n---\ntask_guid: task-1\ndomain: canary\n")
+			writeFile("README.md", "not a spec")
+
+			specs := readSpecs()
+			Expect(specs).To(HaveLen(4))
+			Expect(specs[0].File).To(Equal(filepath.Join(dir, "canary/lrp.yml")))
+			Expect(specs[0].DesiredLRP.ProcessGuid).To(Equal("guid-3"))
+			Expect(specs[0].DesiredLRP.Instances).To(BeEquivalentTo(3))
+			Expect(specs[1].Task.TaskGuid).To(Equal("task-1"))
+			Expect(specs[2].DesiredLRP.ProcessGuid).To(Equal("guid-1"))
+			Expect(specs[3].DesiredLRP.ProcessGuid).To(Equal("guid-2"))
+		})
+
+		It("reads a single file", func() {
+			writeFile("lrp.json", `{"process_guid":"guid-1"}`)
+
+			specs, err := commands.ReadApplySpecs(filepath.Join(dir, "lrp.json"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(specs).To(HaveLen(1))
+		})
+
+		It("rejects specs specified twice", func() {
+			writeFile("a.json", `{"process_guid":"guid-1"}`)
+			writeFile("b.yaml", `process_guid: guid-1`)
+
+			_, err := commands.ReadApplySpecs(dir)
+			Expect(err).To(MatchError(filepath.Join(dir, "b.yaml") + ": desired_lrp guid-1 is also specified in " + filepath.Join(dir, "a.json")))
+		})
+
+		It("rejects specs that are neither desired lrps nor tasks", func() {
+			writeFile("a.json", `{"domain":"canary"}`)
+
+			_, err := commands.ReadApplySpecs(dir)
+			Expect(err).To(MatchError(ContainSubstring("spec has neither a process_guid nor a task_guid")))
+		})
+
+		It("rejects invalid files", func() {
+			writeFile("a.json", `{"process_guid":`)
+
+			_, err := commands.ReadApplySpecs(dir)
+			Expect(err).To(MatchError(ContainSubstring("Invalid JSON")))
+		})
+	})
+
+	Context("when the specs are new", func() {
+		BeforeEach(func() {
+			writeFile("lrp.json", `{"process_guid":"guid-1","domain":"canary","rootfs":"preloaded:cflinuxfs4","instances":1,"action":{"run":{"path":"/bin/app","user":"vcap"}}}`)
+			writeFile("task.json", `{"task_guid":"task-1","domain":"canary","rootfs":"preloaded:cflinuxfs4","action":{"run":{"path":"/bin/task","user":"vcap"}}}`)
+		})
+
+		It("creates them", func() {
+			err := commands.Apply(stdout, stderr, nil, fakeBBSClient, readSpecs(), true)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fakeBBSClient.DesireLRPCallCount()).To(Equal(1))
+			_, _, desiredLRP := fakeBBSClient.DesireLRPArgsForCall(0)
+			Expect(desiredLRP.ProcessGuid).To(Equal("guid-1"))
+
+			Expect(fakeBBSClient.DesireTaskCallCount()).To(Equal(1))
+			_, _, guid, domain, definition := fakeBBSClient.DesireTaskArgsForCall(0)
+			Expect(guid).To(Equal("task-1"))
+			Expect(domain).To(Equal("canary"))
+			Expect(definition.RootFs).To(Equal("preloaded:cflinuxfs4"))
+
+			Expect(changes()).To(Equal([]commands.ApplyChange{
+				{Kind: "desired_lrp", Guid: "guid-1", File: filepath.Join(dir, "lrp.json"), Action: "create"},
+				{Kind: "task", Guid: "task-1", File: filepath.Join(dir, "task.json"), Action: "create"},
+			}))
+		})
+
+		It("asks for confirmation", func() {
+			err := commands.Apply(stdout, stderr, strings.NewReader("y\n"), fakeBBSClient, readSpecs(), false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stderr).To(gbytes.Say(`Apply 2 changes\? \[y/N\] `))
+			Expect(fakeBBSClient.DesireLRPCallCount()).To(Equal(1))
+		})
+
+		It("does not apply the changes when the confirmation is declined", func() {
+			err := commands.Apply(stdout, stderr, strings.NewReader("\n"), fakeBBSClient, readSpecs(), false)
+			Expect(err).To(MatchError("Apply aborted"))
+			Expect(changes()).To(HaveLen(2))
+			Expect(fakeBBSClient.DesireLRPCallCount()).To(Equal(0))
+			Expect(fakeBBSClient.DesireTaskCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("when a spec is invalid", func() {
+		BeforeEach(func() {
+			current["guid-2"] = &models.DesiredLRP{ProcessGuid: "guid-2", Instances: 1}
+			writeFile("a.json", `{"process_guid":"guid-1","domain":"canary","rootfs":"preloaded:cflinuxfs4","instances":1}`)
+			writeFile("b.json", `{"process_guid":"guid-2","instances":-1}`)
+			writeFile("c.json", `{"task_guid":"task-1","domain":"canary","rootfs":"preloaded:cflinuxfs4","action":{"run":{"path":"/bin/task","user":"vcap"}}}`)
+		})
+
+		It("reports every violation without applying anything", func() {
+			err := commands.Apply(stdout, stderr, nil, fakeBBSClient, readSpecs(), true)
+			Expect(err).To(MatchError(ContainSubstring("Invalid specs, nothing was applied")))
+			Expect(err).To(MatchError(ContainSubstring(filepath.Join(dir, "a.json") + ": desired_lrp guid-1: $.action: Invalid field: action")))
+			Expect(err).To(MatchError(ContainSubstring(filepath.Join(dir, "b.json") + ": desired_lrp guid-2: $.instances: Invalid field: instances")))
+			Expect(err.Error()).NotTo(ContainSubstring("c.json"))
+
+			Expect(changes()).To(HaveLen(3))
+			Expect(fakeBBSClient.DesireLRPCallCount()).To(Equal(0))
+			Expect(fakeBBSClient.DesireTaskCallCount()).To(Equal(0))
+			Expect(fakeBBSClient.UpdateDesiredLRPCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("when a desired lrp differs in fields that can be updated", func() {
+		BeforeEach(func() {
+			current["guid-1"] = &models.DesiredLRP{
+				ProcessGuid:     "guid-1",
+				Domain:          "canary",
+				Instances:       1,
+				Annotation:      "old",
+				ModificationTag: &models.ModificationTag{Epoch: "epoch", Index: 2},
+			}
+			writeFile("lrp.json", `{"process_guid":"guid-1","domain":"canary","instances":3,"annotation":"new"}`)
+		})
+
+		It("updates the desired lrp", func() {
+			err := commands.Apply(stdout, stderr, nil, fakeBBSClient, readSpecs(), true)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fakeBBSClient.UpdateDesiredLRPCallCount()).To(Equal(1))
+			_, _, processGuid, update := fakeBBSClient.UpdateDesiredLRPArgsForCall(0)
+			Expect(processGuid).To(Equal("guid-1"))
+			Expect(update.GetInstances()).To(BeEquivalentTo(3))
+			Expect(update.GetAnnotation()).To(Equal("new"))
+			Expect(update.Routes).To(BeNil())
+			Expect(update.MetricTags).To(BeNil())
+
+			change := changes()[0]
+			Expect(change.Action).To(Equal("update"))
+			Expect(change.Fields).To(Equal([]*commands.FieldChange{
+				{Field: "annotation", Current: "old", Desired: "new"},
+				{Field: "instances", Current: float64(1), Desired: float64(3)},
+			}))
+		})
+	})
+
+	Context("when a desired lrp differs in fields that cannot be updated", func() {
+		BeforeEach(func() {
+			current["guid-1"] = &models.DesiredLRP{ProcessGuid: "guid-1", RootFs: "preloaded:cflinuxfs3", Instances: 1}
+			current["guid-2"] = &models.DesiredLRP{ProcessGuid: "guid-2", Instances: 1}
+			writeFile("a.json", `{"process_guid":"guid-1","rootfs":"preloaded:cflinuxfs4","instances":2}`)
+			writeFile("b.json", `{"process_guid":"guid-2","instances":2}`)
+		})
+
+		It("reports the changes that require a recreate and applies the others", func() {
+			err := commands.Apply(stdout, stderr, nil, fakeBBSClient, readSpecs(), true)
+			Expect(err).To(MatchError("1 desired LRPs have changes that require a recreate"))
+
+			Expect(fakeBBSClient.UpdateDesiredLRPCallCount()).To(Equal(1))
+			_, _, processGuid, _ := fakeBBSClient.UpdateDesiredLRPArgsForCall(0)
+			Expect(processGuid).To(Equal("guid-2"))
+
+			change := changes()[0]
+			Expect(change.Action).To(Equal("recreate"))
+			Expect(change.Fields).To(ConsistOf(
+				&commands.FieldChange{Field: "instances", Current: float64(1), Desired: float64(2)},
+				&commands.FieldChange{Field: "rootfs", Current: "preloaded:cflinuxfs3", Desired: "preloaded:cflinuxfs4", RequiresRecreate: true},
+			))
+		})
+	})
+
+	Context("when the specs match the bbs", func() {
+		BeforeEach(func() {
+			current["guid-1"] = &models.DesiredLRP{
+				ProcessGuid:     "guid-1",
+				Instances:       1,
+				ModificationTag: &models.ModificationTag{Epoch: "epoch", Index: 2},
+			}
+			fakeBBSClient.TaskByGuidReturns(&models.Task{TaskGuid: "task-1"}, nil)
+			writeFile("lrp.json", `{"process_guid":"guid-1","instances":1}`)
+			writeFile("task.json", `{"task_guid":"task-1"}`)
+		})
+
+		It("does nothing", func() {
+			err := commands.Apply(stdout, stderr, nil, fakeBBSClient, readSpecs(), false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(stderr.Contents())).To(BeEmpty())
+
+			for _, change := range changes() {
+				Expect(change.Action).To(Equal("unchanged"))
+			}
+			Expect(fakeBBSClient.DesireLRPCallCount()).To(Equal(0))
+			Expect(fakeBBSClient.DesireTaskCallCount()).To(Equal(0))
+			Expect(fakeBBSClient.UpdateDesiredLRPCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("when the spec leaves out fields set by the bbs", func() {
+		BeforeEach(func() {
+			current["guid-1"] = &models.DesiredLRP{
+				ProcessGuid:     "guid-1",
+				Domain:          "cf-apps",
+				RootFs:          "preloaded:cflinuxfs4",
+				Instances:       1,
+				Annotation:      "annotation",
+				ModificationTag: &models.ModificationTag{Epoch: "epoch", Index: 2},
+			}
+			writeFile("lrp.json", `{"process_guid":"guid-1","instances":1}`)
+		})
+
+		It("does not compare them", func() {
+			err := commands.Apply(stdout, stderr, nil, fakeBBSClient, readSpecs(), true)
+			Expect(err).NotTo(HaveOccurred())
+
+			change := changes()[0]
+			Expect(change.Action).To(Equal("unchanged"))
+			Expect(change.Fields).To(BeEmpty())
+			Expect(fakeBBSClient.UpdateDesiredLRPCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("when an exported desired lrp is applied back unchanged", func() {
+		BeforeEach(func() {
+			current["guid-1"] = &models.DesiredLRP{
+				ProcessGuid:     "guid-1",
+				Domain:          "cf-apps",
+				RootFs:          "preloaded:cflinuxfs4",
+				Instances:       2,
+				Annotation:      "annotation",
+				MetricTags:      map[string]*models.MetricTagValue{"app_name": {Static: "app"}},
+				ModificationTag: &models.ModificationTag{Epoch: "epoch", Index: 2},
+			}
+			exported, err := json.Marshal(current["guid-1"])
+			Expect(err).NotTo(HaveOccurred())
+			writeFile("lrp.json", string(exported))
+		})
+
+		It("reports no changes", func() {
+			err := commands.Apply(stdout, stderr, nil, fakeBBSClient, readSpecs(), true)
+			Expect(err).NotTo(HaveOccurred())
+
+			change := changes()[0]
+			Expect(change.Action).To(Equal("unchanged"))
+			Expect(change.Fields).To(BeEmpty())
+			Expect(fakeBBSClient.DesireLRPCallCount()).To(Equal(0))
+			Expect(fakeBBSClient.UpdateDesiredLRPCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("when the bbs errors", func() {
+		BeforeEach(func() {
+			fakeBBSClient.DesiredLRPByProcessGuidStub = nil
+			fakeBBSClient.DesiredLRPByProcessGuidReturns(nil, models.ErrUnknownError)
+			writeFile("lrp.json", `{"process_guid":"guid-1"}`)
+		})
+
+		It("returns the error without applying anything", func() {
+			err := commands.Apply(stdout, stderr, nil, fakeBBSClient, readSpecs(), true)
+			Expect(err).To(Equal(models.ErrUnknownError))
+			Expect(fakeBBSClient.DesireLRPCallCount()).To(Equal(0))
+		})
+	})
+})
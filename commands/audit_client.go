@@ -0,0 +1,103 @@
+package commands
+
+import (
+	"context"
+	"time"
+
+	"code.cloudfoundry.org/bbs"
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/lager/v3"
+	locketmodels "code.cloudfoundry.org/locket/models"
+	"google.golang.org/grpc"
+)
+
+type AuditBBSClient struct {
+	bbs.Client
+	auditLog *AuditLog
+	target   string
+}
+
+// NewAuditBBSClient wraps a BBS client so that every request that changes
+// the BBS is recorded in the audit log with its outcome.
+func NewAuditBBSClient(auditLog *AuditLog, bbsClient bbs.Client, target string) *AuditBBSClient {
+	return &AuditBBSClient{Client: bbsClient, auditLog: auditLog, target: target}
+}
+
+func (c *AuditBBSClient) DesireLRP(logger lager.Logger, traceID string, desiredLRP *models.DesiredLRP) error {
+	err := c.Client.DesireLRP(logger, traceID, desiredLRP)
+	c.auditLog.Record(traceID, c.target, "DesireLRP", &models.DesireLRPRequest{DesiredLrp: desiredLRP}, err)
+	return err
+}
+
+func (c *AuditBBSClient) UpdateDesiredLRP(logger lager.Logger, traceID string, processGuid string, update *models.DesiredLRPUpdate) error {
+	err := c.Client.UpdateDesiredLRP(logger, traceID, processGuid, update)
+	c.auditLog.Record(traceID, c.target, "UpdateDesiredLRP", &models.UpdateDesiredLRPRequest{ProcessGuid: processGuid, Update: update}, err)
+	return err
+}
+
+func (c *AuditBBSClient) RemoveDesiredLRP(logger lager.Logger, traceID string, processGuid string) error {
+	err := c.Client.RemoveDesiredLRP(logger, traceID, processGuid)
+	c.auditLog.Record(traceID, c.target, "RemoveDesiredLRP", &models.RemoveDesiredLRPRequest{ProcessGuid: processGuid}, err)
+	return err
+}
+
+func (c *AuditBBSClient) DesireTask(logger lager.Logger, traceID string, taskGuid, domain string, taskDefinition *models.TaskDefinition) error {
+	err := c.Client.DesireTask(logger, traceID, taskGuid, domain, taskDefinition)
+	c.auditLog.Record(traceID, c.target, "DesireTask", &models.DesireTaskRequest{TaskDefinition: taskDefinition, TaskGuid: taskGuid, Domain: domain}, err)
+	return err
+}
+
+func (c *AuditBBSClient) CancelTask(logger lager.Logger, traceID string, taskGuid string) error {
+	err := c.Client.CancelTask(logger, traceID, taskGuid)
+	c.auditLog.Record(traceID, c.target, "CancelTask", &models.TaskGuidRequest{TaskGuid: taskGuid}, err)
+	return err
+}
+
+func (c *AuditBBSClient) ResolvingTask(logger lager.Logger, traceID string, taskGuid string) error {
+	err := c.Client.ResolvingTask(logger, traceID, taskGuid)
+	c.auditLog.Record(traceID, c.target, "ResolvingTask", &models.TaskGuidRequest{TaskGuid: taskGuid}, err)
+	return err
+}
+
+func (c *AuditBBSClient) DeleteTask(logger lager.Logger, traceID string, taskGuid string) error {
+	err := c.Client.DeleteTask(logger, traceID, taskGuid)
+	c.auditLog.Record(traceID, c.target, "DeleteTask", &models.TaskGuidRequest{TaskGuid: taskGuid}, err)
+	return err
+}
+
+func (c *AuditBBSClient) RetireActualLRP(logger lager.Logger, traceID string, key *models.ActualLRPKey) error {
+	err := c.Client.RetireActualLRP(logger, traceID, key)
+	c.auditLog.Record(traceID, c.target, "RetireActualLRP", &models.RetireActualLRPRequest{ActualLrpKey: key}, err)
+	return err
+}
+
+func (c *AuditBBSClient) UpsertDomain(logger lager.Logger, traceID string, domain string, ttl time.Duration) error {
+	err := c.Client.UpsertDomain(logger, traceID, domain, ttl)
+	c.auditLog.Record(traceID, c.target, "UpsertDomain", &models.UpsertDomainRequest{Domain: domain, Ttl: uint32(ttl.Seconds())}, err)
+	return err
+}
+
+type AuditLocketClient struct {
+	locketmodels.LocketClient
+	auditLog *AuditLog
+	target   string
+}
+
+// NewAuditLocketClient wraps a Locket client so that every Lock and Release
+// is recorded in the audit log with its outcome. Locket requests carry no
+// trace ID.
+func NewAuditLocketClient(auditLog *AuditLog, locketClient locketmodels.LocketClient, target string) *AuditLocketClient {
+	return &AuditLocketClient{LocketClient: locketClient, auditLog: auditLog, target: target}
+}
+
+func (c *AuditLocketClient) Lock(ctx context.Context, req *locketmodels.LockRequest, opts ...grpc.CallOption) (*locketmodels.LockResponse, error) {
+	resp, err := c.LocketClient.Lock(ctx, req, opts...)
+	c.auditLog.Record("", c.target, "Lock", req, err)
+	return resp, err
+}
+
+func (c *AuditLocketClient) Release(ctx context.Context, req *locketmodels.ReleaseRequest, opts ...grpc.CallOption) (*locketmodels.ReleaseResponse, error) {
+	resp, err := c.LocketClient.Release(ctx, req, opts...)
+	c.auditLog.Record("", c.target, "Release", req, err)
+	return resp, err
+}
@@ -0,0 +1,152 @@
+package commands
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// AuditLogQuery selects audit log entries. Empty fields match every entry.
+type AuditLogQuery struct {
+	User    string
+	Command string
+	TraceID string
+	Since   time.Time
+}
+
+// errors
+var (
+	errInvalidAuditLogSince = errors.New("--since must be a duration, e.g. 24h, or an RFC3339 timestamp")
+)
+
+// flags
+var (
+	auditLogUserFlag, auditLogCommandFlag  string
+	auditLogTraceIDFlag, auditLogSinceFlag string
+)
+
+var auditLogCmd = &cobra.Command{
+	Use:   "audit-log",
+	Short: "Query the audit log",
+	Long:  "List the requests recorded in the audit log (~/.cfdot/audit.jsonl, --audit-log or CFDOT_AUDIT_LOG) by the commands that change the BBS or Locket, oldest first",
+	RunE:  auditLog,
+}
+
+func init() {
+	auditLogCmd.Flags().StringVarP(&auditLogUserFlag, "user", "u", "", "only list the requests sent by the given OS user")
+	auditLogCmd.Flags().StringVarP(&auditLogCommandFlag, "command", "c", "", "only list the requests sent by the given command, e.g. retire-actual-lrp")
+	auditLogCmd.Flags().StringVar(&auditLogTraceIDFlag, "trace-id", "", "only list the requests with the given trace id")
+	auditLogCmd.Flags().StringVar(&auditLogSinceFlag, "since", "", "only list the requests sent since the given time, either a duration such as 24h or an RFC3339 timestamp")
+
+	RootCmd.AddCommand(auditLogCmd)
+}
+
+func auditLog(cmd *cobra.Command, args []string) error {
+	query, err := ValidateAuditLogArguments(args, time.Now())
+	if err != nil {
+		return NewCFDotValidationError(cmd, err)
+	}
+
+	path, err := AuditLogPath()
+	if err != nil {
+		return NewCFDotValidationError(cmd, err)
+	}
+
+	err = QueryAuditLog(cmd.OutOrStdout(), cmd.OutOrStderr(), path, query)
+	if err != nil {
+		return NewCFDotError(cmd, err)
+	}
+
+	return nil
+}
+
+func ValidateAuditLogArguments(args []string, now time.Time) (AuditLogQuery, error) {
+	query := AuditLogQuery{
+		User:    auditLogUserFlag,
+		Command: auditLogCommandFlag,
+		TraceID: auditLogTraceIDFlag,
+	}
+
+	if len(args) > 0 {
+		return query, errExtraArguments
+	}
+
+	if auditLogSinceFlag != "" {
+		since, err := parseSince(auditLogSinceFlag, now)
+		if err != nil {
+			return query, err
+		}
+		query.Since = since
+	}
+
+	return query, nil
+}
+
+func parseSince(value string, now time.Time) (time.Time, error) {
+	duration, err := time.ParseDuration(value)
+	if err == nil && duration >= 0 {
+		return now.Add(-duration), nil
+	}
+
+	since, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, errInvalidAuditLogSince
+	}
+	return since, nil
+}
+
+// QueryAuditLog prints the entries of the audit log at path that match the
+// query. A missing audit log has no entries.
+func QueryAuditLog(stdout, stderr io.Writer, path string, query AuditLogQuery) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	encoder := newOutputEncoder(stdout)
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		entry := &AuditEntry{}
+		err = json.Unmarshal(scanner.Bytes(), entry)
+		if err != nil {
+			return fmt.Errorf("Invalid audit log entry on line %d of '%s': %s", line, path, err.Error())
+		}
+
+		if !query.matches(entry) {
+			continue
+		}
+
+		err = encoder.Encode(entry)
+		if err != nil {
+			return err
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return err
+	}
+
+	return encoder.Flush()
+}
+
+func (q AuditLogQuery) matches(entry *AuditEntry) bool {
+	return (q.User == "" || entry.User == q.User) &&
+		(q.Command == "" || entry.Command == q.Command) &&
+		(q.TraceID == "" || entry.TraceID == q.TraceID) &&
+		!entry.Timestamp.Before(q.Since)
+}
@@ -0,0 +1,215 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"code.cloudfoundry.org/bbs"
+	locketmodels "code.cloudfoundry.org/locket/models"
+	"github.com/spf13/cobra"
+)
+
+const (
+	AuditOutcomeSuccess = "success"
+	AuditOutcomeFailure = "failure"
+)
+
+// AuditEntry is one line of the audit log, written for every request sent by
+// a command that changes the BBS or Locket.
+type AuditEntry struct {
+	Timestamp   time.Time   `json:"timestamp"`
+	User        string      `json:"user"`
+	Command     string      `json:"command"`
+	CommandLine []string    `json:"command_line"`
+	TraceID     string      `json:"trace_id,omitempty"`
+	Target      string      `json:"target"`
+	Method      string      `json:"method"`
+	Request     interface{} `json:"request"`
+	Outcome     string      `json:"outcome"`
+	Error       string      `json:"error,omitempty"`
+}
+
+var (
+	auditLogPath string
+)
+
+// errors
+var (
+	errMissingAuditLogHomeDir = errors.New("Unable to determine the audit log location. Please specify one with --audit-log or the 'CFDOT_AUDIT_LOG' environment variable.")
+)
+
+func init() {
+	AddAuditLogFlags(RootCmd)
+}
+
+func AddAuditLogFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().StringVar(&auditLogPath, "audit-log", "", "path of the audit log of the commands that change the BBS or Locket, defaults to ~/.cfdot/audit.jsonl [environment variable equivalent: CFDOT_AUDIT_LOG]")
+}
+
+// AuditLogPath returns the location of the audit log, which defaults to
+// ~/.cfdot/audit.jsonl.
+func AuditLogPath() (string, error) {
+	if auditLogPath != "" {
+		return expandHomeDir(auditLogPath), nil
+	}
+
+	if path := os.Getenv("CFDOT_AUDIT_LOG"); path != "" {
+		return expandHomeDir(path), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", errMissingAuditLogHomeDir
+	}
+
+	return filepath.Join(home, ".cfdot", "audit.jsonl"), nil
+}
+
+// mutatingBBSClient returns the BBS client a command that changes the BBS
+// should use. Its requests are recorded in the audit log, or only printed
+// when --dry-run is given.
+func mutatingBBSClient(cmd *cobra.Command, bbsClient bbs.Client) bbs.Client {
+	if DryRun {
+		return NewDryRunBBSClient(cmd.OutOrStdout(), bbsClient, Config.BBSUrl)
+	}
+
+	auditLog := OpenCommandAuditLog(cmd.OutOrStderr(), cmd.Name(), os.Args)
+	if auditLog == nil {
+		return bbsClient
+	}
+	return NewAuditBBSClient(auditLog, bbsClient, Config.BBSUrl)
+}
+
+// mutatingLocketClient is the Locket equivalent of mutatingBBSClient.
+func mutatingLocketClient(cmd *cobra.Command, locketClient locketmodels.LocketClient) locketmodels.LocketClient {
+	if DryRun {
+		return NewDryRunLocketClient(cmd.OutOrStdout(), locketClient, Config.LocketApiLocation)
+	}
+
+	auditLog := OpenCommandAuditLog(cmd.OutOrStderr(), cmd.Name(), os.Args)
+	if auditLog == nil {
+		return locketClient
+	}
+	return NewAuditLocketClient(auditLog, locketClient, Config.LocketApiLocation)
+}
+
+// OpenCommandAuditLog opens the audit log at AuditLogPath for a command. An
+// audit log that cannot be written, e.g. in a read-only home directory, must
+// not keep operators from fixing a deployment, so it returns nil after a
+// warning on stderr and the command runs without recording its requests.
+func OpenCommandAuditLog(stderr io.Writer, command string, commandLine []string) *AuditLog {
+	path, err := AuditLogPath()
+	var auditLog *AuditLog
+	if err == nil {
+		auditLog, err = OpenAuditLog(stderr, path, command, commandLine)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "Warning: the requests of this command are not recorded in the audit log. %s\n", err.Error())
+		return nil
+	}
+	return auditLog
+}
+
+// AuditLog appends AuditEntries to a JSONL file. Every entry is written with
+// a single append, so that concurrent cfdot processes can share the file.
+type AuditLog struct {
+	stderr      io.Writer
+	path        string
+	user        string
+	command     string
+	commandLine []string
+}
+
+// OpenAuditLog creates the audit log at path if needed and makes sure it can
+// be appended to, so that a command knows before it changes anything whether
+// its requests can be recorded.
+func OpenAuditLog(stderr io.Writer, path, command string, commandLine []string) (*AuditLog, error) {
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if err == nil {
+		var f *os.File
+		f, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+		if err == nil {
+			err = f.Close()
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Unable to open audit log '%s': %s", path, err.Error())
+	}
+
+	return &AuditLog{
+		stderr:      stderr,
+		path:        path,
+		user:        currentUser(),
+		command:     command,
+		commandLine: commandLine,
+	}, nil
+}
+
+// Record appends an entry for a request and the error it returned. The
+// request has already been sent, so a failure to write the entry is only
+// reported on stderr.
+func (l *AuditLog) Record(traceID, target, method string, request interface{}, requestErr error) {
+	entry := &AuditEntry{
+		Timestamp:   time.Now().UTC(),
+		User:        l.user,
+		Command:     l.command,
+		CommandLine: l.commandLine,
+		TraceID:     traceID,
+		Target:      target,
+		Method:      method,
+		Request:     request,
+		Outcome:     AuditOutcomeSuccess,
+	}
+	if requestErr != nil {
+		entry.Outcome = AuditOutcomeFailure
+		entry.Error = requestErr.Error()
+	}
+
+	err := l.append(entry)
+	if err != nil {
+		fmt.Fprintf(l.stderr, "Failed to write to audit log '%s': %s\n", l.path, err.Error())
+	}
+}
+
+func (l *AuditLog) append(entry *AuditEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(append(line, '\n'))
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// currentUser is the operator running cfdot. Under sudo that is SUDO_USER
+// rather than root.
+func currentUser() string {
+	if name := os.Getenv("SUDO_USER"); name != "" {
+		return name
+	}
+
+	u, err := user.Current()
+	if err == nil {
+		return u.Username
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	return strconv.Itoa(os.Getuid())
+}
@@ -0,0 +1,291 @@
+package commands_test
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"code.cloudfoundry.org/bbs/fake_bbs"
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/cfdot/commands"
+	locketmodels "code.cloudfoundry.org/locket/models"
+	"code.cloudfoundry.org/locket/models/modelsfakes"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/spf13/cobra"
+)
+
+var _ = Describe("AuditLog", func() {
+	var (
+		stdout, stderr *gbytes.Buffer
+		path           string
+		auditLog       *commands.AuditLog
+	)
+
+	entries := func() []commands.AuditEntry {
+		contents, err := os.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+
+		result := []commands.AuditEntry{}
+		decoder := json.NewDecoder(gbytes.BufferWithBytes(contents))
+		for decoder.More() {
+			var entry commands.AuditEntry
+			Expect(decoder.Decode(&entry)).To(Succeed())
+			result = append(result, entry)
+		}
+		return result
+	}
+
+	BeforeEach(func() {
+		stdout = gbytes.NewBuffer()
+		stderr = gbytes.NewBuffer()
+		path = filepath.Join(GinkgoT().TempDir(), "logs", "audit.jsonl")
+
+		var err error
+		auditLog, err = commands.OpenAuditLog(stderr, path, "retire-actual-lrp", []string{"cfdot", "retire-actual-lrp", "process-guid", "1"})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	Describe("OpenAuditLog", func() {
+		It("creates the audit log so that only the owner can read it", func() {
+			info, err := os.Stat(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(info.Mode().Perm()).To(Equal(os.FileMode(0600)))
+		})
+
+		It("fails when the audit log cannot be written", func() {
+			_, err := commands.OpenAuditLog(stderr, filepath.Dir(path), "retire-actual-lrp", nil)
+			Expect(err).To(MatchError(HavePrefix("Unable to open audit log '" + filepath.Dir(path) + "'")))
+		})
+
+		Context("when cfdot runs under sudo", func() {
+			BeforeEach(func() {
+				os.Setenv("SUDO_USER", "some-operator")
+			})
+
+			AfterEach(func() {
+				os.Unsetenv("SUDO_USER")
+			})
+
+			It("records the user who ran sudo", func() {
+				auditLog, err := commands.OpenAuditLog(stderr, path, "set-domain", nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				auditLog.Record("", "https://bbs.example.com:8889", "UpsertDomain", nil, nil)
+				Expect(entries()).To(HaveLen(1))
+				Expect(entries()[0].User).To(Equal("some-operator"))
+			})
+		})
+	})
+
+	Describe("OpenCommandAuditLog", func() {
+		It("opens the audit log at CFDOT_AUDIT_LOG", func() {
+			os.Setenv("CFDOT_AUDIT_LOG", path)
+			Expect(commands.OpenCommandAuditLog(stderr, "set-domain", nil)).NotTo(BeNil())
+			Expect(stderr.Contents()).To(BeEmpty())
+		})
+
+		Context("when the audit log cannot be written", func() {
+			It("warns on stderr instead of failing", func() {
+				os.Setenv("CFDOT_AUDIT_LOG", filepath.Dir(path))
+				Expect(commands.OpenCommandAuditLog(stderr, "set-domain", nil)).To(BeNil())
+				Expect(stderr).To(gbytes.Say("Warning: the requests of this command are not recorded in the audit log. Unable to open audit log '" + filepath.Dir(path) + "'"))
+			})
+		})
+	})
+
+	Describe("AuditBBSClient", func() {
+		var fakeBBSClient *fake_bbs.FakeClient
+
+		BeforeEach(func() {
+			fakeBBSClient = &fake_bbs.FakeClient{}
+			fakeBBSClient.DesiredLRPByProcessGuidReturns(&models.DesiredLRP{ProcessGuid: "process-guid", Domain: "domain"}, nil)
+		})
+
+		It("sends the request and records it", func() {
+			bbsClient := commands.NewAuditBBSClient(auditLog, fakeBBSClient, "https://bbs.example.com:8889")
+
+			err := commands.RetireActualLRP(stdout, stderr, bbsClient, "process-guid", 1)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fakeBBSClient.RetireActualLRPCallCount()).To(Equal(1))
+			_, traceID, _ := fakeBBSClient.RetireActualLRPArgsForCall(0)
+
+			logged := entries()
+			Expect(logged).To(HaveLen(1))
+			Expect(logged[0].Timestamp).To(BeTemporally("~", time.Now(), time.Minute))
+			Expect(logged[0].User).NotTo(BeEmpty())
+			Expect(logged[0].Command).To(Equal("retire-actual-lrp"))
+			Expect(logged[0].CommandLine).To(Equal([]string{"cfdot", "retire-actual-lrp", "process-guid", "1"}))
+			Expect(logged[0].TraceID).To(Equal(traceID))
+			Expect(logged[0].Target).To(Equal("https://bbs.example.com:8889"))
+			Expect(logged[0].Method).To(Equal("RetireActualLRP"))
+			Expect(logged[0].Request).To(Equal(map[string]interface{}{
+				"actual_lrp_key": map[string]interface{}{"process_guid": "process-guid", "index": 1.0, "domain": "domain"},
+			}))
+			Expect(logged[0].Outcome).To(Equal("success"))
+			Expect(logged[0].Error).To(BeEmpty())
+		})
+
+		It("records failed requests", func() {
+			fakeBBSClient.RetireActualLRPReturns(models.ErrResourceNotFound)
+			bbsClient := commands.NewAuditBBSClient(auditLog, fakeBBSClient, "https://bbs.example.com:8889")
+
+			err := commands.RetireActualLRP(stdout, stderr, bbsClient, "process-guid", 1)
+			Expect(err).To(Equal(models.ErrResourceNotFound))
+
+			logged := entries()
+			Expect(logged).To(HaveLen(1))
+			Expect(logged[0].Outcome).To(Equal("failure"))
+			Expect(logged[0].Error).To(Equal(models.ErrResourceNotFound.Error()))
+		})
+
+		It("does not record reads", func() {
+			bbsClient := commands.NewAuditBBSClient(auditLog, fakeBBSClient, "https://bbs.example.com:8889")
+
+			_, err := bbsClient.DesiredLRPByProcessGuid(nil, "trace-id", "process-guid")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(entries()).To(BeEmpty())
+		})
+
+		Context("when the entry cannot be written", func() {
+			It("reports it on stderr without failing the request", func() {
+				bbsClient := commands.NewAuditBBSClient(auditLog, fakeBBSClient, "https://bbs.example.com:8889")
+				Expect(os.Remove(path)).To(Succeed())
+				Expect(os.Mkdir(path, 0700)).To(Succeed())
+
+				err := commands.SetDomain(stdout, stderr, bbsClient, "domain", time.Minute)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(fakeBBSClient.UpsertDomainCallCount()).To(Equal(1))
+				Expect(stderr).To(gbytes.Say("Failed to write to audit log '" + path + "'"))
+			})
+		})
+	})
+
+	Describe("AuditLocketClient", func() {
+		It("records locks and releases", func() {
+			fakeLocketClient := &modelsfakes.FakeLocketClient{}
+			fakeLocketClient.ReleaseReturns(nil, errors.New("boom"))
+			locketClient := commands.NewAuditLocketClient(auditLog, fakeLocketClient, "locket.example.com:8891")
+
+			Expect(commands.ClaimLock(stdout, stderr, locketClient, "key", "owner", "value", 60)).To(Succeed())
+			Expect(commands.ReleaseLock(stdout, stderr, locketClient, "key", "owner")).NotTo(Succeed())
+			Expect(fakeLocketClient.LockCallCount()).To(Equal(1))
+			Expect(fakeLocketClient.ReleaseCallCount()).To(Equal(1))
+
+			logged := entries()
+			Expect(logged).To(HaveLen(2))
+			Expect(logged[0].Target).To(Equal("locket.example.com:8891"))
+			Expect(logged[0].Method).To(Equal("Lock"))
+			Expect(logged[0].Request).To(HaveKeyWithValue("ttl_in_seconds", 60.0))
+			Expect(logged[0].Outcome).To(Equal("success"))
+			Expect(logged[1].Method).To(Equal("Release"))
+			Expect(logged[1].Outcome).To(Equal("failure"))
+			Expect(logged[1].Error).To(Equal("boom"))
+		})
+	})
+
+	Describe("QueryAuditLog", func() {
+		BeforeEach(func() {
+			auditLog.Record("trace-1", "https://bbs.example.com:8889", "UpsertDomain", &models.UpsertDomainRequest{Domain: "domain"}, nil)
+			other, err := commands.OpenAuditLog(stderr, path, "release-lock", []string{"cfdot", "release-lock"})
+			Expect(err).NotTo(HaveOccurred())
+			other.Record("", "locket.example.com:8891", "Release", &locketmodels.ReleaseRequest{}, nil)
+		})
+
+		It("prints all the entries", func() {
+			err := commands.QueryAuditLog(stdout, stderr, path, commands.AuditLogQuery{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stdout).To(gbytes.Say(`"command":"retire-actual-lrp".*"trace_id":"trace-1"`))
+			Expect(stdout).To(gbytes.Say(`"command":"release-lock"`))
+		})
+
+		It("prints the entries matching the query", func() {
+			err := commands.QueryAuditLog(stdout, stderr, path, commands.AuditLogQuery{Command: "release-lock"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stdout).NotTo(gbytes.Say(`retire-actual-lrp`))
+
+			stdout = gbytes.NewBuffer()
+			err = commands.QueryAuditLog(stdout, stderr, path, commands.AuditLogQuery{TraceID: "trace-1", Since: time.Now().Add(-time.Hour)})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stdout).To(gbytes.Say(`"method":"UpsertDomain"`))
+			Expect(stdout).NotTo(gbytes.Say(`"method":"Release"`))
+
+			stdout = gbytes.NewBuffer()
+			err = commands.QueryAuditLog(stdout, stderr, path, commands.AuditLogQuery{Since: time.Now().Add(time.Hour)})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stdout.Contents()).To(BeEmpty())
+		})
+
+		It("prints nothing when there is no audit log", func() {
+			err := commands.QueryAuditLog(stdout, stderr, filepath.Join(filepath.Dir(path), "missing.jsonl"), commands.AuditLogQuery{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stdout.Contents()).To(BeEmpty())
+		})
+
+		It("fails on invalid entries", func() {
+			f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = f.WriteString("{\n")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(f.Close()).To(Succeed())
+
+			err = commands.QueryAuditLog(stdout, stderr, path, commands.AuditLogQuery{})
+			Expect(err).To(MatchError(HavePrefix("Invalid audit log entry on line 3 of '" + path + "'")))
+		})
+	})
+
+	Describe("ValidateAuditLogArguments", func() {
+		var auditLogCmd *cobra.Command
+
+		BeforeEach(func() {
+			var err error
+			auditLogCmd, _, err = commands.RootCmd.Find([]string{"audit-log"})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			Expect(auditLogCmd.Flags().Set("since", "")).To(Succeed())
+		})
+
+		It("parses --since as a duration or a timestamp", func() {
+			now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+
+			Expect(auditLogCmd.Flags().Set("since", "24h")).To(Succeed())
+			query, err := commands.ValidateAuditLogArguments([]string{}, now)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(query.Since).To(Equal(now.Add(-24 * time.Hour)))
+
+			Expect(auditLogCmd.Flags().Set("since", "2026-10-16T08:00:00Z")).To(Succeed())
+			query, err = commands.ValidateAuditLogArguments([]string{}, now)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(query.Since).To(Equal(time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC)))
+		})
+
+		It("rejects an invalid --since", func() {
+			Expect(auditLogCmd.Flags().Set("since", "yesterday")).To(Succeed())
+			_, err := commands.ValidateAuditLogArguments([]string{}, time.Now())
+			Expect(err).To(MatchError("--since must be a duration, e.g. 24h, or an RFC3339 timestamp"))
+		})
+
+		It("rejects arguments", func() {
+			_, err := commands.ValidateAuditLogArguments([]string{"extra"}, time.Now())
+			Expect(err).To(MatchError("Too many arguments specified"))
+		})
+	})
+
+	Describe("AuditLogPath", func() {
+		It("prefers --audit-log over CFDOT_AUDIT_LOG", func() {
+			envPath := os.Getenv("CFDOT_AUDIT_LOG")
+			Expect(commands.AuditLogPath()).To(Equal(envPath))
+
+			cmd := &cobra.Command{}
+			commands.AddAuditLogFlags(cmd)
+			Expect(cmd.ParseFlags([]string{"--audit-log", "/var/log/cfdot/audit.jsonl"})).To(Succeed())
+			Expect(commands.AuditLogPath()).To(Equal("/var/log/cfdot/audit.jsonl"))
+		})
+	})
+})
@@ -35,8 +35,12 @@ func BBSPrehook(cmd *cobra.Command, args []string) error {
 func setBBSFlags(cmd *cobra.Command, args []string) error {
 	var err, returnErr error
 
-	if bbsUrl == "" {
-		bbsUrl = os.Getenv("BBS_URL")
+	if !setFromFlagOrEnv(cmd, &bbsUrl, "bbsURL", "BBS_URL") {
+		profile, err := activeProfile(cmd)
+		if err != nil {
+			return err
+		}
+		bbsUrl = profile.BBSURL
 	}
 
 	if bbsUrl == "" {
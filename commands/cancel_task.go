@@ -19,6 +19,7 @@ var cancelTaskCmd = &cobra.Command{
 
 func init() {
 	AddBBSAndTimeoutFlags(cancelTaskCmd)
+	AddDryRunFlag(cancelTaskCmd)
 	RootCmd.AddCommand(cancelTaskCmd)
 }
 
@@ -33,6 +34,8 @@ func cancelTask(cmd *cobra.Command, args []string) error {
 		return NewCFDotError(cmd, err)
 	}
 
+	bbsClient = mutatingBBSClient(cmd, bbsClient)
+
 	if err := CancelTaskByGuid(cmd.OutOrStdout(), cmd.OutOrStderr(), bbsClient, guid); err != nil {
 		return NewCFDotError(cmd, err)
 	}
@@ -1,7 +1,6 @@
 package commands
 
 import (
-	"encoding/json"
 	"errors"
 	"io"
 
@@ -63,7 +62,7 @@ func Cell(stdout, stderr io.Writer, bbsClient bbs.Client, cellId string) error {
 	traceID := trace.GenerateTraceID()
 	logger := trace.LoggerWithTraceInfo(globalLogger.Session("cell-presence"), traceID)
 
-	encoder := json.NewEncoder(stdout)
+	encoder := newOutputEncoder(stdout)
 
 	cells, err := bbsClient.Cells(logger, traceID)
 	if err != nil {
@@ -75,9 +74,10 @@ func Cell(stdout, stderr io.Writer, bbsClient bbs.Client, cellId string) error {
 			err = encoder.Encode(cell)
 			if err != nil {
 				logger.Error("failed-to-marshal", err)
+				return err
 			}
 
-			return err
+			return encoder.Flush()
 		}
 	}
 
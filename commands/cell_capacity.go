@@ -0,0 +1,262 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+
+	"code.cloudfoundry.org/bbs"
+	"code.cloudfoundry.org/bbs/trace"
+	"code.cloudfoundry.org/cfdot/commands/helpers"
+	"code.cloudfoundry.org/rep"
+	"github.com/spf13/cobra"
+)
+
+const (
+	CapacityGroupZone             = "zone"
+	CapacityGroupIsolationSegment = "isolation_segment"
+
+	// SharedIsolationSegment names the cells without placement tags, which
+	// run the work of the shared isolation segment.
+	SharedIsolationSegment = "(shared)"
+)
+
+// CapacityTotals are the resources of one cell or a group of cells.
+// HeadroomPercent is the smallest share of memory, disk or containers that
+// is still available.
+type CapacityTotals struct {
+	TotalMemoryMB       int     `json:"total_memory_mb"`
+	AvailableMemoryMB   int     `json:"available_memory_mb"`
+	TotalDiskMB         int     `json:"total_disk_mb"`
+	AvailableDiskMB     int     `json:"available_disk_mb"`
+	TotalContainers     int     `json:"total_containers"`
+	AvailableContainers int     `json:"available_containers"`
+	LRPs                int     `json:"lrps"`
+	Tasks               int     `json:"tasks"`
+	HeadroomPercent     float64 `json:"headroom_percent"`
+}
+
+type CellCapacity struct {
+	CellID           string   `json:"cell_id"`
+	Zone             string   `json:"zone"`
+	IsolationSegment string   `json:"isolation_segment"`
+	PlacementTags    []string `json:"placement_tags"`
+	Evacuating       bool     `json:"evacuating"`
+	CapacityTotals
+}
+
+// CapacityRollup sums the cells of a zone or an isolation segment. The
+// available resources of evacuating cells are left out, since no new work
+// is placed on them.
+type CapacityRollup struct {
+	Group           string `json:"group"`
+	Name            string `json:"name"`
+	Cells           int    `json:"cells"`
+	EvacuatingCells int    `json:"evacuating_cells"`
+	CapacityTotals
+}
+
+type CellCapacityReport struct {
+	Cells             []*CellCapacity   `json:"cells"`
+	Zones             []*CapacityRollup `json:"zones"`
+	IsolationSegments []*CapacityRollup `json:"isolation_segments"`
+}
+
+var cellCapacityCmd = &cobra.Command{
+	Use:   "cell-capacity",
+	Short: "Show cell capacity and utilization",
+	Long:  "Show the total and available resources of every cell, sorted by headroom, with rollups per zone and per isolation segment",
+	RunE:  cellCapacity,
+}
+
+func init() {
+	AddBBSAndTimeoutFlags(cellCapacityCmd)
+	addCellStatesFetchFlags(cellCapacityCmd)
+	RootCmd.AddCommand(cellCapacityCmd)
+}
+
+func cellCapacity(cmd *cobra.Command, args []string) error {
+	err := ValidateCellCapacityArguments(args)
+	if err != nil {
+		return NewCFDotValidationError(cmd, err)
+	}
+
+	bbsClient, err := helpers.NewBBSClient(cmd, Config)
+	if err != nil {
+		return NewCFDotError(cmd, err)
+	}
+
+	repClientFactory, err := newRepClientFactory(cellStatesCellTimeoutFlag)
+	if err != nil {
+		return NewCFDotComponentError(cmd, fmt.Errorf("Failed creating rep client factory: %s", err))
+	}
+
+	return FetchCellCapacity(cmd, cmd.OutOrStdout(), cmd.OutOrStderr(), repClientFactory, bbsClient, cellStatesConcurrencyFlag)
+}
+
+func ValidateCellCapacityArguments(args []string) error {
+	if len(args) > 0 {
+		return errExtraArguments
+	}
+	return validateCellStatesFetchFlags()
+}
+
+func FetchCellCapacity(cmd *cobra.Command, stdout, stderr io.Writer, clientFactory rep.ClientFactory, bbsClient bbs.Client, concurrency int) error {
+	traceID := trace.GenerateTraceID()
+	logger := trace.LoggerWithTraceInfo(globalLogger.Session("cell-capacity"), traceID)
+
+	registrations, err := bbsClient.Cells(logger, traceID)
+	if err != nil {
+		return NewCFDotComponentError(cmd, fmt.Errorf("BBS error: Failed to get cell registrations from BBS: %s", err))
+	}
+
+	states := []rep.CellState{}
+	errorEncoder := json.NewEncoder(stderr)
+	failed := 0
+	fetchCellStates(logger, clientFactory, registrations, traceID, concurrency, false, func(result cellStateResult) {
+		if result.err != nil {
+			failed++
+			errorEncoder.Encode(newCellStateError(result))
+			return
+		}
+		states = append(states, result.state)
+	})
+
+	encoder := newOutputEncoder(stdout)
+	err = encoder.Encode(BuildCellCapacityReport(states))
+	if err != nil {
+		return err
+	}
+
+	err = encoder.Flush()
+	if err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return NewCFDotComponentError(cmd, cellStatesFailedError(failed, len(registrations)))
+	}
+	return nil
+}
+
+func BuildCellCapacityReport(states []rep.CellState) *CellCapacityReport {
+	report := &CellCapacityReport{
+		Cells:             []*CellCapacity{},
+		Zones:             []*CapacityRollup{},
+		IsolationSegments: []*CapacityRollup{},
+	}
+
+	zones := map[string]*CapacityRollup{}
+	segments := map[string]*CapacityRollup{}
+	rollupFor := func(rollups map[string]*CapacityRollup, group, name string) *CapacityRollup {
+		rollup, ok := rollups[name]
+		if !ok {
+			rollup = &CapacityRollup{Group: group, Name: name}
+			rollups[name] = rollup
+		}
+		return rollup
+	}
+
+	for _, state := range states {
+		// Like Diego, the first placement tag of a cell is its isolation
+		// segment.
+		isolationSegment := SharedIsolationSegment
+		if len(state.PlacementTags) > 0 {
+			isolationSegment = state.PlacementTags[0]
+		}
+
+		placementTags := append([]string{}, state.PlacementTags...)
+		sort.Strings(placementTags)
+
+		cell := &CellCapacity{
+			CellID:           state.CellID,
+			Zone:             state.Zone,
+			IsolationSegment: isolationSegment,
+			PlacementTags:    placementTags,
+			Evacuating:       state.Evacuating,
+		}
+		cell.add(state, true)
+		cell.computeHeadroom()
+		report.Cells = append(report.Cells, cell)
+
+		for _, rollup := range []*CapacityRollup{
+			rollupFor(zones, CapacityGroupZone, cell.Zone),
+			rollupFor(segments, CapacityGroupIsolationSegment, cell.IsolationSegment),
+		} {
+			rollup.Cells++
+			if state.Evacuating {
+				rollup.EvacuatingCells++
+			}
+			rollup.add(state, !state.Evacuating)
+		}
+	}
+
+	sort.Slice(report.Cells, func(i, j int) bool {
+		if report.Cells[i].HeadroomPercent != report.Cells[j].HeadroomPercent {
+			return report.Cells[i].HeadroomPercent < report.Cells[j].HeadroomPercent
+		}
+		return report.Cells[i].CellID < report.Cells[j].CellID
+	})
+
+	report.Zones = sortedRollups(zones)
+	report.IsolationSegments = sortedRollups(segments)
+
+	return report
+}
+
+func sortedRollups(rollups map[string]*CapacityRollup) []*CapacityRollup {
+	sorted := []*CapacityRollup{}
+	for _, rollup := range rollups {
+		rollup.computeHeadroom()
+		sorted = append(sorted, rollup)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+	return sorted
+}
+
+func (t *CapacityTotals) add(state rep.CellState, includeAvailable bool) {
+	t.TotalMemoryMB += int(state.TotalResources.MemoryMB)
+	t.TotalDiskMB += int(state.TotalResources.DiskMB)
+	t.TotalContainers += state.TotalResources.Containers
+	t.LRPs += len(state.LRPs)
+	t.Tasks += len(state.Tasks)
+
+	if includeAvailable {
+		t.AvailableMemoryMB += int(state.AvailableResources.MemoryMB)
+		t.AvailableDiskMB += int(state.AvailableResources.DiskMB)
+		t.AvailableContainers += state.AvailableResources.Containers
+	}
+}
+
+func (t *CapacityTotals) computeHeadroom() {
+	headroom := 1.0
+	for _, resource := range [][2]int{
+		{t.AvailableMemoryMB, t.TotalMemoryMB},
+		{t.AvailableDiskMB, t.TotalDiskMB},
+		{t.AvailableContainers, t.TotalContainers},
+	} {
+		if resource[1] <= 0 {
+			continue
+		}
+		headroom = math.Min(headroom, float64(resource[0])/float64(resource[1]))
+	}
+	t.HeadroomPercent = math.Round(headroom*1000) / 10
+}
+
+func (r *CellCapacityReport) TableRows() []interface{} {
+	rows := []interface{}{}
+	for _, cell := range r.Cells {
+		rows = append(rows, cell)
+	}
+	for _, zone := range r.Zones {
+		rows = append(rows, zone)
+	}
+	for _, segment := range r.IsolationSegments {
+		rows = append(rows, segment)
+	}
+	return rows
+}
@@ -0,0 +1,180 @@
+package commands_test
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"code.cloudfoundry.org/bbs/fake_bbs"
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/cfdot/commands"
+	"code.cloudfoundry.org/rep"
+	"code.cloudfoundry.org/rep/repfakes"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/spf13/cobra"
+)
+
+var _ = Describe("CellCapacity", func() {
+	var (
+		cmd                            *cobra.Command
+		fakeRepClient1, fakeRepClient2 *repfakes.FakeClient
+		fakeRepClientFactory           *repfakes.FakeClientFactory
+		fakeBBSClient                  *fake_bbs.FakeClient
+		stdout, stderr                 *gbytes.Buffer
+		state1, state2                 rep.CellState
+	)
+
+	BeforeEach(func() {
+		cmd = &cobra.Command{}
+		stdout = gbytes.NewBuffer()
+		stderr = gbytes.NewBuffer()
+
+		state1 = rep.CellState{
+			CellID:             "cell-id1",
+			Zone:               "z1",
+			TotalResources:     rep.Resources{MemoryMB: 1000, DiskMB: 2000, Containers: 100},
+			AvailableResources: rep.Resources{MemoryMB: 500, DiskMB: 1000, Containers: 90},
+			LRPs:               []rep.LRP{{}, {}},
+			Tasks:              []rep.Task{{}},
+		}
+		state2 = rep.CellState{
+			CellID:             "cell-id2",
+			Zone:               "z1",
+			TotalResources:     rep.Resources{MemoryMB: 1000, DiskMB: 2000, Containers: 100},
+			AvailableResources: rep.Resources{MemoryMB: 900, DiskMB: 100, Containers: 99},
+			LRPs:               []rep.LRP{{}},
+			PlacementTags:      []string{"segment-b", "segment-a"},
+			Evacuating:         true,
+		}
+
+		fakeBBSClient = &fake_bbs.FakeClient{}
+		fakeBBSClient.CellsReturns([]*models.CellPresence{
+			{CellId: "cell-id1", RepUrl: "rep-url-1", RepAddress: "rep-address-1"},
+			{CellId: "cell-id2", RepUrl: "rep-url-2", RepAddress: "rep-address-2"},
+		}, nil)
+
+		fakeRepClient1 = &repfakes.FakeClient{}
+		fakeRepClient1.StateReturns(state1, nil)
+		fakeRepClient2 = &repfakes.FakeClient{}
+		fakeRepClient2.StateReturns(state2, nil)
+
+		fakeRepClientFactory = &repfakes.FakeClientFactory{}
+		fakeRepClientFactory.CreateClientStub = func(address, url, traceID string) (rep.Client, error) {
+			if address == "rep-address-1" {
+				return fakeRepClient1, nil
+			}
+			return fakeRepClient2, nil
+		}
+	})
+
+	It("reports each cell sorted by headroom with rollups", func() {
+		err := commands.FetchCellCapacity(cmd, stdout, stderr, fakeRepClientFactory, fakeBBSClient, 10)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(fakeRepClientFactory.CreateClientCallCount()).To(Equal(2))
+
+		var report commands.CellCapacityReport
+		Expect(json.Unmarshal(stdout.Contents(), &report)).To(Succeed())
+
+		Expect(report.Cells).To(Equal([]*commands.CellCapacity{
+			{
+				CellID:           "cell-id2",
+				Zone:             "z1",
+				IsolationSegment: "segment-b",
+				PlacementTags:    []string{"segment-a", "segment-b"},
+				Evacuating:       true,
+				CapacityTotals: commands.CapacityTotals{
+					TotalMemoryMB: 1000, AvailableMemoryMB: 900,
+					TotalDiskMB: 2000, AvailableDiskMB: 100,
+					TotalContainers: 100, AvailableContainers: 99,
+					LRPs: 1, HeadroomPercent: 5,
+				},
+			},
+			{
+				CellID:           "cell-id1",
+				Zone:             "z1",
+				IsolationSegment: "(shared)",
+				PlacementTags:    []string{},
+				CapacityTotals: commands.CapacityTotals{
+					TotalMemoryMB: 1000, AvailableMemoryMB: 500,
+					TotalDiskMB: 2000, AvailableDiskMB: 1000,
+					TotalContainers: 100, AvailableContainers: 90,
+					LRPs: 2, Tasks: 1, HeadroomPercent: 50,
+				},
+			},
+		}))
+
+		Expect(report.Zones).To(Equal([]*commands.CapacityRollup{
+			{
+				Group: "zone", Name: "z1", Cells: 2, EvacuatingCells: 1,
+				CapacityTotals: commands.CapacityTotals{
+					TotalMemoryMB: 2000, AvailableMemoryMB: 500,
+					TotalDiskMB: 4000, AvailableDiskMB: 1000,
+					TotalContainers: 200, AvailableContainers: 90,
+					LRPs: 3, Tasks: 1, HeadroomPercent: 25,
+				},
+			},
+		}))
+
+		Expect(report.IsolationSegments).To(HaveLen(2))
+		Expect(report.IsolationSegments[0].Name).To(Equal("(shared)"))
+		Expect(report.IsolationSegments[0].Cells).To(Equal(1))
+		Expect(report.IsolationSegments[1].Name).To(Equal("segment-b"))
+		Expect(report.IsolationSegments[1].EvacuatingCells).To(Equal(1))
+		Expect(report.IsolationSegments[1].HeadroomPercent).To(Equal(0.0))
+	})
+
+	It("prints the cells and rollups as tables", func() {
+		commands.Output.Format = "table"
+
+		err := commands.FetchCellCapacity(cmd, stdout, stderr, fakeRepClientFactory, fakeBBSClient, 10)
+		Expect(err).NotTo(HaveOccurred())
+
+		tables := strings.Split(strings.TrimSpace(string(stdout.Contents())), "\n\n")
+		Expect(tables).To(HaveLen(2))
+		Expect(tables[0]).To(HavePrefix("CELL ID"))
+		Expect(tables[1]).To(HavePrefix("GROUP"))
+		Expect(strings.Split(tables[1], "\n")).To(HaveLen(4))
+	})
+
+	Context("when the bbs fails to return cell registrations", func() {
+		BeforeEach(func() {
+			fakeBBSClient.CellsReturns(nil, errors.New("boom"))
+		})
+
+		It("returns a component error", func() {
+			err := commands.FetchCellCapacity(cmd, stdout, stderr, fakeRepClientFactory, fakeBBSClient, 10)
+			Expect(err).To(MatchError("BBS error: Failed to get cell registrations from BBS: boom"))
+			Expect(err.(commands.CFDotError).ExitCode()).To(Equal(4))
+		})
+	})
+
+	Context("when one of the reps fails to respond", func() {
+		BeforeEach(func() {
+			fakeRepClient1.StateReturns(rep.CellState{}, errors.New("boom"))
+		})
+
+		It("reports the other cells and returns an error", func() {
+			err := commands.FetchCellCapacity(cmd, stdout, stderr, fakeRepClientFactory, fakeBBSClient, 10)
+			Expect(err).To(MatchError("Rep error: Failed to get cell state for 1 of 2 cells"))
+
+			var cellStateError commands.CellStateError
+			Expect(json.Unmarshal(stderr.Contents(), &cellStateError)).To(Succeed())
+			Expect(cellStateError.CellID).To(Equal("cell-id1"))
+
+			var report commands.CellCapacityReport
+			Expect(json.Unmarshal(stdout.Contents(), &report)).To(Succeed())
+			Expect(report.Cells).To(HaveLen(1))
+			Expect(report.Cells[0].CellID).To(Equal("cell-id2"))
+		})
+	})
+
+	Describe("ValidateCellCapacityArguments", func() {
+		It("rejects arguments", func() {
+			Expect(commands.ValidateCellCapacityArguments([]string{"foo"})).To(MatchError("Too many arguments specified"))
+			Expect(commands.ValidateCellCapacityArguments([]string{})).To(Succeed())
+		})
+	})
+})
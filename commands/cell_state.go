@@ -1,7 +1,6 @@
 package commands
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -103,13 +102,22 @@ func FetchCellRegistration(bbsClient bbs.Client, traceID string, cellId string)
 }
 
 func FetchCellState(stdout, stderr io.Writer, clientFactory rep.ClientFactory, registration *models.CellPresence, traceID string) error {
+	encoder := newOutputEncoder(stdout)
+	err := fetchCellState(encoder, clientFactory, registration, traceID)
+	if err != nil {
+		return err
+	}
+
+	return encoder.Flush()
+}
+
+func fetchCellState(encoder Encoder, clientFactory rep.ClientFactory, registration *models.CellPresence, traceID string) error {
 	repClient, err := clientFactory.CreateClient(registration.RepAddress, registration.RepUrl, traceID)
 	if err != nil {
 		return err
 	}
 
 	logger := trace.LoggerWithTraceInfo(globalLogger.Session("cell-state"), traceID)
-	encoder := json.NewEncoder(stdout)
 
 	state, err := repClient.State(logger)
 	if err != nil {
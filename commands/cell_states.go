@@ -1,19 +1,55 @@
 package commands
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"time"
 
 	"code.cloudfoundry.org/bbs"
+	"code.cloudfoundry.org/bbs/models"
 	"code.cloudfoundry.org/bbs/trace"
 	"code.cloudfoundry.org/cfdot/commands/helpers"
 	cfhttp "code.cloudfoundry.org/cfhttp/v2"
+	"code.cloudfoundry.org/lager/v3"
 	"code.cloudfoundry.org/rep"
 	"github.com/spf13/cobra"
 )
 
+const (
+	defaultCellStatesConcurrency = 10
+	defaultCellStatesTimeout     = 10 * time.Second
+)
+
+// CellStateError is written to stderr as one JSON object per line for every
+// cell whose rep could not be queried.
+type CellStateError struct {
+	CellID     string `json:"cell_id"`
+	RepAddress string `json:"rep_address"`
+	RepURL     string `json:"rep_url"`
+	Error      string `json:"error"`
+}
+
+type cellStateResult struct {
+	registration *models.CellPresence
+	state        rep.CellState
+	err          error
+}
+
+// errors
+var (
+	errInvalidConcurrency = errors.New("--concurrency must be a positive integer")
+	errInvalidCellTimeout = errors.New("--cell-timeout must be a positive duration")
+)
+
+// flags
+var (
+	cellStatesConcurrencyFlag     int
+	cellStatesCellTimeoutFlag     time.Duration
+	cellStatesCompletionOrderFlag bool
+)
+
 var cellStatesCmd = &cobra.Command{
 	Use:   "cell-states",
 	Short: "Show cell states for all cells",
@@ -23,9 +59,16 @@ var cellStatesCmd = &cobra.Command{
 
 func init() {
 	AddBBSAndTimeoutFlags(cellStatesCmd)
+	addCellStatesFetchFlags(cellStatesCmd)
+	cellStatesCmd.Flags().BoolVar(&cellStatesCompletionOrderFlag, "completion-order", false, "print each cell state as soon as it is received instead of in registration order")
 	RootCmd.AddCommand(cellStatesCmd)
 }
 
+func addCellStatesFetchFlags(cmd *cobra.Command) {
+	cmd.Flags().IntVar(&cellStatesConcurrencyFlag, "concurrency", defaultCellStatesConcurrency, "number of reps to query at the same time")
+	cmd.Flags().DurationVar(&cellStatesCellTimeoutFlag, "cell-timeout", defaultCellStatesTimeout, "timeout for the state request to each rep")
+}
+
 func cellStates(cmd *cobra.Command, args []string) error {
 	err := ValidateCellStatesArguments(args)
 	if err != nil {
@@ -37,22 +80,20 @@ func cellStates(cmd *cobra.Command, args []string) error {
 		return NewCFDotError(cmd, err)
 	}
 
-	httpClient := cfhttp.NewClient()
-	stateClient := cfhttp.NewClient(
-		cfhttp.WithRequestTimeout(10 * time.Second),
-	)
-
-	repTLSConfig := &rep.TLSConfig{
-		CaCertFile: Config.CACertFile,
-		CertFile:   Config.CertFile,
-		KeyFile:    Config.KeyFile,
-	}
-	repClientFactory, err := rep.NewClientFactory(httpClient, stateClient, repTLSConfig)
+	repClientFactory, err := newRepClientFactory(cellStatesCellTimeoutFlag)
 	if err != nil {
 		return NewCFDotComponentError(cmd, fmt.Errorf("Failed creating rep client factory: %s", err))
 	}
 
-	return FetchCellStates(cmd, cmd.OutOrStdout(), cmd.OutOrStderr(), repClientFactory, bbsClient)
+	return FetchCellStates(
+		cmd,
+		cmd.OutOrStdout(),
+		cmd.OutOrStderr(),
+		repClientFactory,
+		bbsClient,
+		cellStatesConcurrencyFlag,
+		cellStatesCompletionOrderFlag,
+	)
 }
 
 func ValidateCellStatesArguments(args []string) error {
@@ -60,11 +101,35 @@ func ValidateCellStatesArguments(args []string) error {
 	case len(args) > 0:
 		return errExtraArguments
 	default:
-		return nil
+		return validateCellStatesFetchFlags()
+	}
+}
+
+func validateCellStatesFetchFlags() error {
+	if cellStatesConcurrencyFlag < 1 {
+		return errInvalidConcurrency
+	}
+	if cellStatesCellTimeoutFlag <= 0 {
+		return errInvalidCellTimeout
+	}
+	return nil
+}
+
+func newRepClientFactory(stateTimeout time.Duration) (rep.ClientFactory, error) {
+	httpClient := cfhttp.NewClient()
+	stateClient := cfhttp.NewClient(
+		cfhttp.WithRequestTimeout(stateTimeout),
+	)
+
+	repTLSConfig := &rep.TLSConfig{
+		CaCertFile: Config.CACertFile,
+		CertFile:   Config.CertFile,
+		KeyFile:    Config.KeyFile,
 	}
+	return rep.NewClientFactory(httpClient, stateClient, repTLSConfig)
 }
 
-func FetchCellStates(cmd *cobra.Command, stdout, stderr io.Writer, clientFactory rep.ClientFactory, bbsClient bbs.Client) error {
+func FetchCellStates(cmd *cobra.Command, stdout, stderr io.Writer, clientFactory rep.ClientFactory, bbsClient bbs.Client, concurrency int, completionOrder bool) error {
 	traceID := trace.GenerateTraceID()
 	logger := trace.LoggerWithTraceInfo(globalLogger.Session("cell-states"), traceID)
 
@@ -72,16 +137,92 @@ func FetchCellStates(cmd *cobra.Command, stdout, stderr io.Writer, clientFactory
 	if err != nil {
 		return NewCFDotComponentError(cmd, fmt.Errorf("BBS error: Failed to get cell registrations from BBS: %s", err))
 	}
-	errs := ""
-	for _, registration := range registrations {
-		err := FetchCellState(stdout, stderr, clientFactory, registration, traceID)
-		if err != nil {
-			errs += fmt.Sprintf("Rep error: Failed to get cell state for cell %s: %s\n", registration.CellId, err)
+
+	encoder := newOutputEncoder(stdout)
+	errorEncoder := json.NewEncoder(stderr)
+	failed := 0
+
+	fetchCellStates(logger, clientFactory, registrations, traceID, concurrency, completionOrder, func(result cellStateResult) {
+		if result.err == nil {
+			result.err = encoder.Encode(result.state)
+			if result.err != nil {
+				logger.Error("failed-to-marshal", result.err)
+			}
 		}
+		if result.err != nil {
+			failed++
+			errorEncoder.Encode(newCellStateError(result))
+		}
+	})
+
+	err = encoder.Flush()
+	if err != nil {
+		return err
 	}
 
-	if errs != "" {
-		return NewCFDotComponentError(cmd, errors.New(errs))
+	if failed > 0 {
+		return NewCFDotComponentError(cmd, cellStatesFailedError(failed, len(registrations)))
 	}
 	return nil
 }
+
+// fetchCellStates queries the reps of the given cells, at most concurrency
+// at a time, and calls handle with every result. Results are handled in
+// registration order unless completionOrder is set; handle is never called
+// concurrently.
+func fetchCellStates(logger lager.Logger, clientFactory rep.ClientFactory, registrations []*models.CellPresence, traceID string, concurrency int, completionOrder bool, handle func(cellStateResult)) {
+	semaphore := make(chan struct{}, concurrency)
+	completed := make(chan cellStateResult, len(registrations))
+	ordered := make([]chan cellStateResult, len(registrations))
+
+	for i, registration := range registrations {
+		results := completed
+		if !completionOrder {
+			ordered[i] = make(chan cellStateResult, 1)
+			results = ordered[i]
+		}
+
+		go func(registration *models.CellPresence, results chan<- cellStateResult) {
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+			results <- fetchCellStateResult(logger, clientFactory, registration, traceID)
+		}(registration, results)
+	}
+
+	for i := range registrations {
+		if completionOrder {
+			handle(<-completed)
+		} else {
+			handle(<-ordered[i])
+		}
+	}
+}
+
+func fetchCellStateResult(logger lager.Logger, clientFactory rep.ClientFactory, registration *models.CellPresence, traceID string) cellStateResult {
+	result := cellStateResult{registration: registration}
+
+	repClient, err := clientFactory.CreateClient(registration.RepAddress, registration.RepUrl, traceID)
+	if err != nil {
+		result.err = err
+		return result
+	}
+
+	result.state, result.err = repClient.State(logger)
+	if result.err != nil {
+		logger.Error("failed-to-fetch-cell-state", result.err, lager.Data{"cell-id": registration.CellId})
+	}
+	return result
+}
+
+func newCellStateError(result cellStateResult) *CellStateError {
+	return &CellStateError{
+		CellID:     result.registration.CellId,
+		RepAddress: result.registration.RepAddress,
+		RepURL:     result.registration.RepUrl,
+		Error:      result.err.Error(),
+	}
+}
+
+func cellStatesFailedError(failed, total int) error {
+	return fmt.Errorf("Rep error: Failed to get cell state for %d of %d cells", failed, total)
+}
@@ -3,10 +3,14 @@ package commands_test
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
 
 	"code.cloudfoundry.org/bbs/fake_bbs"
 	"code.cloudfoundry.org/bbs/models"
 	"code.cloudfoundry.org/cfdot/commands"
+	"code.cloudfoundry.org/lager/v3"
 	"code.cloudfoundry.org/rep"
 	"code.cloudfoundry.org/rep/repfakes"
 	. "github.com/onsi/ginkgo/v2"
@@ -83,17 +87,24 @@ var _ = Describe("CellState", func() {
 			fakeRepClient2.StateReturns(state2, nil)
 
 			fakeRepClientFactory = &repfakes.FakeClientFactory{}
-			fakeRepClientFactory.CreateClientReturnsOnCall(0, fakeRepClient1, nil)
-			fakeRepClientFactory.CreateClientReturnsOnCall(1, fakeRepClient2, nil)
+			fakeRepClientFactory.CreateClientStub = func(address, url, traceID string) (rep.Client, error) {
+				switch address {
+				case "rep-address-1":
+					return fakeRepClient1, nil
+				case "rep-address-2":
+					return fakeRepClient2, nil
+				}
+				return nil, errors.New("unknown rep")
+			}
 		})
 
 		It("retrieves the cell registrations", func() {
-			commands.FetchCellStates(cmd, stdout, stderr, fakeRepClientFactory, fakeBBSClient)
+			commands.FetchCellStates(cmd, stdout, stderr, fakeRepClientFactory, fakeBBSClient, 10, false)
 			Expect(fakeBBSClient.CellsCallCount()).To(Equal(1))
 		})
 
 		It("outputs the cell state to stdout", func() {
-			commands.FetchCellStates(cmd, stdout, stderr, fakeRepClientFactory, fakeBBSClient)
+			commands.FetchCellStates(cmd, stdout, stderr, fakeRepClientFactory, fakeBBSClient, 10, false)
 			Expect(fakeRepClient1.StateCallCount()).To(Equal(1))
 			Expect(fakeRepClient2.StateCallCount()).To(Equal(1))
 
@@ -109,13 +120,88 @@ var _ = Describe("CellState", func() {
 			Expect(receivedState).To(Equal(state2))
 		})
 
+		Context("when the first rep is slower than the second", func() {
+			BeforeEach(func() {
+				secondDone := make(chan struct{})
+				fakeRepClient1.StateStub = func(lager.Logger) (rep.CellState, error) {
+					<-secondDone
+					return state1, nil
+				}
+				fakeRepClient2.StateStub = func(lager.Logger) (rep.CellState, error) {
+					close(secondDone)
+					return state2, nil
+				}
+			})
+
+			It("still outputs the cell states in registration order", func() {
+				err := commands.FetchCellStates(cmd, stdout, stderr, fakeRepClientFactory, fakeBBSClient, 2, false)
+				Expect(err).NotTo(HaveOccurred())
+
+				decoder := json.NewDecoder(stdout)
+				var receivedState rep.CellState
+				Expect(decoder.Decode(&receivedState)).To(Succeed())
+				Expect(receivedState.CellID).To(Equal("cell-id1"))
+				Expect(decoder.Decode(&receivedState)).To(Succeed())
+				Expect(receivedState.CellID).To(Equal("cell-id2"))
+			})
+
+			It("outputs the cell states in completion order when asked to", func() {
+				err := commands.FetchCellStates(cmd, stdout, stderr, fakeRepClientFactory, fakeBBSClient, 2, true)
+				Expect(err).NotTo(HaveOccurred())
+
+				decoder := json.NewDecoder(stdout)
+				var receivedState rep.CellState
+				Expect(decoder.Decode(&receivedState)).To(Succeed())
+				Expect(receivedState.CellID).To(Equal("cell-id2"))
+				Expect(decoder.Decode(&receivedState)).To(Succeed())
+				Expect(receivedState.CellID).To(Equal("cell-id1"))
+			})
+		})
+
+		Context("when there are more cells than the concurrency limit", func() {
+			var inFlight, maxInFlight int32
+
+			BeforeEach(func() {
+				inFlight, maxInFlight = 0, 0
+
+				registrations := []*models.CellPresence{}
+				for i := 0; i < 20; i++ {
+					registrations = append(registrations, &models.CellPresence{
+						CellId:     fmt.Sprintf("cell-id%d", i),
+						RepAddress: "rep-address-1",
+					})
+				}
+				fakeBBSClient.CellsReturns(registrations, nil)
+
+				fakeRepClient1.StateStub = func(lager.Logger) (rep.CellState, error) {
+					current := atomic.AddInt32(&inFlight, 1)
+					defer atomic.AddInt32(&inFlight, -1)
+					for {
+						max := atomic.LoadInt32(&maxInFlight)
+						if current <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, current) {
+							break
+						}
+					}
+					time.Sleep(5 * time.Millisecond)
+					return state1, nil
+				}
+			})
+
+			It("queries at most that many reps at the same time", func() {
+				err := commands.FetchCellStates(cmd, stdout, stderr, fakeRepClientFactory, fakeBBSClient, 3, false)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(fakeRepClient1.StateCallCount()).To(Equal(20))
+				Expect(atomic.LoadInt32(&maxInFlight)).To(BeNumerically("<=", 3))
+			})
+		})
+
 		Context("when the bbs fail to return cell registrations", func() {
 			BeforeEach(func() {
 				fakeBBSClient.CellsReturns(nil, errors.New("boom"))
 			})
 
 			It("prints an error", func() {
-				err := commands.FetchCellStates(cmd, stdout, stderr, fakeRepClientFactory, fakeBBSClient)
+				err := commands.FetchCellStates(cmd, stdout, stderr, fakeRepClientFactory, fakeBBSClient, 10, false)
 				Expect(err).To(MatchError("BBS error: Failed to get cell registrations from BBS: boom"))
 			})
 		})
@@ -125,14 +211,28 @@ var _ = Describe("CellState", func() {
 				fakeRepClient1.StateReturns(rep.CellState{}, errors.New("boom"))
 			})
 
-			It("prints an error", func() {
-				err := commands.FetchCellStates(cmd, stdout, stderr, fakeRepClientFactory, fakeBBSClient)
+			It("returns a component error with the number of failed cells", func() {
+				err := commands.FetchCellStates(cmd, stdout, stderr, fakeRepClientFactory, fakeBBSClient, 10, false)
 				Expect(fakeRepClient2.StateCallCount()).To(Equal(1))
-				Expect(err).To(MatchError(ContainSubstring("Rep error: Failed to get cell state for cell cell-id1: boom")))
+				Expect(err).To(MatchError("Rep error: Failed to get cell state for 1 of 2 cells"))
+				Expect(err.(commands.CFDotError).ExitCode()).To(Equal(4))
+			})
+
+			It("writes the cell error as json to stderr", func() {
+				commands.FetchCellStates(cmd, stdout, stderr, fakeRepClientFactory, fakeBBSClient, 10, false)
+
+				var cellStateError commands.CellStateError
+				Expect(json.Unmarshal(stderr.Contents(), &cellStateError)).To(Succeed())
+				Expect(cellStateError).To(Equal(commands.CellStateError{
+					CellID:     "cell-id1",
+					RepAddress: "rep-address-1",
+					RepURL:     "rep-url-1",
+					Error:      "boom",
+				}))
 			})
 
 			It("prints the cell stats of the other cells", func() {
-				commands.FetchCellStates(cmd, stdout, stderr, fakeRepClientFactory, fakeBBSClient)
+				commands.FetchCellStates(cmd, stdout, stderr, fakeRepClientFactory, fakeBBSClient, 10, false)
 				var receivedState rep.CellState
 				err := json.NewDecoder(stdout).Decode(&receivedState)
 				Expect(err).NotTo(HaveOccurred())
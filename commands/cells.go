@@ -1,7 +1,6 @@
 package commands
 
 import (
-	"encoding/json"
 	"io"
 
 	"code.cloudfoundry.org/bbs"
@@ -58,7 +57,7 @@ func Cells(stdout, stderr io.Writer, bbsClient bbs.Client) error {
 	traceID := trace.GenerateTraceID()
 	logger := trace.LoggerWithTraceInfo(globalLogger.Session("cell-presences"), traceID)
 
-	encoder := json.NewEncoder(stdout)
+	encoder := newOutputEncoder(stdout)
 
 	cellPresences, err := bbsClient.Cells(logger, traceID)
 	if err != nil {
@@ -72,5 +71,5 @@ func Cells(stdout, stderr io.Writer, bbsClient bbs.Client) error {
 		}
 	}
 
-	return nil
+	return encoder.Flush()
 }
@@ -27,6 +27,7 @@ var claimLockCmd = &cobra.Command{
 
 func init() {
 	AddLocketFlags(claimLockCmd)
+	AddDryRunFlag(claimLockCmd)
 	claimLockCmd.Flags().StringVarP(&lockKey, "key", "k", "", "the key of the lock being claimed")
 	claimLockCmd.Flags().StringVarP(&lockOwner, "owner", "o", "", "the lock owner")
 	claimLockCmd.Flags().StringVarP(&lockValue, "value", "v", "", "the value associated with the key")
@@ -46,6 +47,8 @@ func claimLock(cmd *cobra.Command, args []string) error {
 		return NewCFDotComponentError(cmd, err)
 	}
 
+	locketClient = mutatingLocketClient(cmd, locketClient)
+
 	err = ClaimLock(
 		cmd.OutOrStdout(),
 		cmd.OutOrStderr(),
@@ -19,6 +19,7 @@ var claimPresenceCmd = &cobra.Command{
 
 func init() {
 	AddLocketFlags(claimPresenceCmd)
+	AddDryRunFlag(claimPresenceCmd)
 	claimPresenceCmd.Flags().StringVarP(&lockKey, "key", "k", "", "the key of the presence being claimed")
 	claimPresenceCmd.Flags().StringVarP(&lockOwner, "owner", "o", "", "the presence owner")
 	claimPresenceCmd.Flags().StringVarP(&lockValue, "value", "v", "", "the value associated with the presence")
@@ -38,6 +39,8 @@ func claimPresence(cmd *cobra.Command, args []string) error {
 		return NewCFDotComponentError(cmd, err)
 	}
 
+	locketClient = mutatingLocketClient(cmd, locketClient)
+
 	err = ClaimPresence(
 		cmd.OutOrStdout(),
 		cmd.OutOrStderr(),
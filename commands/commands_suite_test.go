@@ -1,12 +1,16 @@
 package commands_test
 
 import (
+	"errors"
+	"io"
 	"os"
+	"path/filepath"
 
 	"code.cloudfoundry.org/cfdot/commands"
 	"code.cloudfoundry.org/cfdot/commands/helpers"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/spf13/cobra"
 
 	"testing"
 )
@@ -26,6 +30,16 @@ var _ = SynchronizedAfterSuite(func() {
 
 var _ = BeforeEach(func() {
 	commands.Config = helpers.TLSConfig{}
+	commands.Output = commands.OutputConfig{}
+
+	// Keep the developer's ~/.cfdot/config.yml out of the tests, and reset
+	// --profile by registering it again.
+	os.Setenv("CFDOT_CONFIG", filepath.Join(GinkgoT().TempDir(), "config.yml"))
+	commands.AddProfileFlags(&cobra.Command{})
+
+	// Likewise for ~/.cfdot/audit.jsonl and --audit-log.
+	os.Setenv("CFDOT_AUDIT_LOG", filepath.Join(GinkgoT().TempDir(), "audit.jsonl"))
+	commands.AddAuditLogFlags(&cobra.Command{})
 })
 
 func TestCommands(t *testing.T) {
@@ -61,3 +75,20 @@ func buildArgList(flags map[string]string) []string {
 
 	return list
 }
+
+// limitedWriter fails every write after the first limit writes, which ends
+// commands that stream until their output is closed.
+type limitedWriter struct {
+	io.Writer
+	limit int
+}
+
+var errOutputClosed = errors.New("output closed")
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if w.limit == 0 {
+		return 0, errOutputClosed
+	}
+	w.limit--
+	return w.Writer.Write(p)
+}
@@ -0,0 +1,219 @@
+package commands
+
+import (
+	"io"
+	"sort"
+
+	"code.cloudfoundry.org/bbs"
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/bbs/trace"
+	"code.cloudfoundry.org/cfdot/commands/helpers"
+	"github.com/spf13/cobra"
+)
+
+const (
+	ConvergenceIssueUnderRunning   = "under_running"
+	ConvergenceIssueMissingIndices = "missing_indices"
+	ConvergenceIssueExtraIndices   = "extra_indices"
+	ConvergenceIssueOrphaned       = "orphaned"
+)
+
+var convergenceIssueOrder = map[string]int{
+	ConvergenceIssueUnderRunning:   0,
+	ConvergenceIssueMissingIndices: 1,
+	ConvergenceIssueExtraIndices:   2,
+	ConvergenceIssueOrphaned:       3,
+}
+
+// ConvergenceIssue is one difference between a desired LRP and its actual
+// LRPs. Indices lists the affected indices for missing, extra and orphaned
+// issues.
+type ConvergenceIssue struct {
+	Type             string  `json:"type"`
+	ProcessGuid      string  `json:"process_guid"`
+	Domain           string  `json:"domain"`
+	DesiredInstances int32   `json:"desired_instances"`
+	RunningInstances int32   `json:"running_instances"`
+	Indices          []int32 `json:"indices,omitempty"`
+}
+
+// flags
+var (
+	convergenceReportDomainFlag, convergenceReportProcessGuidFlag string
+)
+
+var convergenceReportCmd = &cobra.Command{
+	Use:   "convergence-report",
+	Short: "Report differences between desired and actual LRPs",
+	Long:  "Report processes with fewer running instances than desired, missing and extra indices, and actual LRPs without a desired LRP",
+	RunE:  convergenceReport,
+}
+
+func init() {
+	AddBBSAndTimeoutFlags(convergenceReportCmd)
+
+	convergenceReportCmd.Flags().StringVarP(&convergenceReportDomainFlag, "domain", "d", "", "report only on LRPs in the given domain")
+	convergenceReportCmd.Flags().StringVarP(&convergenceReportProcessGuidFlag, "process-guid", "p", "", "report only on LRPs with the given process guid")
+
+	RootCmd.AddCommand(convergenceReportCmd)
+}
+
+func convergenceReport(cmd *cobra.Command, args []string) error {
+	err := ValidateConvergenceReportArguments(args)
+	if err != nil {
+		return NewCFDotValidationError(cmd, err)
+	}
+
+	bbsClient, err := helpers.NewBBSClient(cmd, Config)
+	if err != nil {
+		return NewCFDotError(cmd, err)
+	}
+
+	err = ConvergenceReport(
+		cmd.OutOrStdout(),
+		cmd.OutOrStderr(),
+		bbsClient,
+		convergenceReportDomainFlag,
+		convergenceReportProcessGuidFlag,
+	)
+	if err != nil {
+		return NewCFDotError(cmd, err)
+	}
+
+	return nil
+}
+
+func ValidateConvergenceReportArguments(args []string) error {
+	if len(args) > 0 {
+		return errExtraArguments
+	}
+	return nil
+}
+
+func ConvergenceReport(stdout, stderr io.Writer, bbsClient bbs.Client, domain, processGuid string) error {
+	traceID := trace.GenerateTraceID()
+	logger := trace.LoggerWithTraceInfo(globalLogger.Session("convergence-report"), traceID)
+
+	desiredLRPFilter := models.DesiredLRPFilter{Domain: domain}
+	if processGuid != "" {
+		desiredLRPFilter.ProcessGuids = []string{processGuid}
+	}
+
+	schedulingInfos, err := bbsClient.DesiredLRPSchedulingInfos(logger, traceID, desiredLRPFilter)
+	if err != nil {
+		return err
+	}
+
+	actualLRPs, err := bbsClient.ActualLRPs(logger, traceID, models.ActualLRPFilter{Domain: domain, ProcessGuid: processGuid})
+	if err != nil {
+		return err
+	}
+
+	encoder := newOutputEncoder(stdout)
+	for _, issue := range FindConvergenceIssues(schedulingInfos, actualLRPs) {
+		err = encoder.Encode(issue)
+		if err != nil {
+			logger.Error("failed-to-marshal", err)
+		}
+	}
+
+	return encoder.Flush()
+}
+
+func FindConvergenceIssues(schedulingInfos []*models.DesiredLRPSchedulingInfo, actualLRPs []*models.ActualLRP) []*ConvergenceIssue {
+	type actualIndex struct {
+		domain  string
+		running bool
+	}
+
+	actualsByGuid := map[string]map[int32]*actualIndex{}
+	for _, actualLRP := range actualLRPs {
+		indices, ok := actualsByGuid[actualLRP.ProcessGuid]
+		if !ok {
+			indices = map[int32]*actualIndex{}
+			actualsByGuid[actualLRP.ProcessGuid] = indices
+		}
+
+		index, ok := indices[actualLRP.Index]
+		if !ok {
+			index = &actualIndex{domain: actualLRP.Domain}
+			indices[actualLRP.Index] = index
+		}
+		if actualLRP.State == models.ActualLRPStateRunning {
+			index.running = true
+		}
+	}
+
+	issues := []*ConvergenceIssue{}
+	for _, schedulingInfo := range schedulingInfos {
+		indices := actualsByGuid[schedulingInfo.ProcessGuid]
+		delete(actualsByGuid, schedulingInfo.ProcessGuid)
+
+		var running int32
+		var missing, extra []int32
+		for i := int32(0); i < schedulingInfo.Instances; i++ {
+			index, ok := indices[i]
+			if !ok {
+				missing = append(missing, i)
+			} else if index.running {
+				running++
+			}
+		}
+		for i := range indices {
+			if i >= schedulingInfo.Instances || i < 0 {
+				extra = append(extra, i)
+			}
+		}
+
+		newIssue := func(issueType string, indices []int32) *ConvergenceIssue {
+			sortIndices(indices)
+			return &ConvergenceIssue{
+				Type:             issueType,
+				ProcessGuid:      schedulingInfo.ProcessGuid,
+				Domain:           schedulingInfo.Domain,
+				DesiredInstances: schedulingInfo.Instances,
+				RunningInstances: running,
+				Indices:          indices,
+			}
+		}
+
+		if running < schedulingInfo.Instances {
+			issues = append(issues, newIssue(ConvergenceIssueUnderRunning, nil))
+		}
+		if len(missing) > 0 {
+			issues = append(issues, newIssue(ConvergenceIssueMissingIndices, missing))
+		}
+		if len(extra) > 0 {
+			issues = append(issues, newIssue(ConvergenceIssueExtraIndices, extra))
+		}
+	}
+
+	for processGuid, indices := range actualsByGuid {
+		issue := &ConvergenceIssue{
+			Type:        ConvergenceIssueOrphaned,
+			ProcessGuid: processGuid,
+		}
+		for i, index := range indices {
+			issue.Domain = index.domain
+			issue.Indices = append(issue.Indices, i)
+			if index.running {
+				issue.RunningInstances++
+			}
+		}
+		sortIndices(issue.Indices)
+		issues = append(issues, issue)
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool {
+		if issues[i].ProcessGuid != issues[j].ProcessGuid {
+			return issues[i].ProcessGuid < issues[j].ProcessGuid
+		}
+		return convergenceIssueOrder[issues[i].Type] < convergenceIssueOrder[issues[j].Type]
+	})
+
+	return issues
+}
+
+func sortIndices(indices []int32) {
+	sort.Slice(indices, func(i, j int) bool { return indices[i] < indices[j] })
+}
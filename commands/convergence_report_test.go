@@ -0,0 +1,122 @@
+package commands_test
+
+import (
+	"encoding/json"
+	"strings"
+
+	"code.cloudfoundry.org/bbs/fake_bbs"
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/cfdot/commands"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("ConvergenceReport", func() {
+	var (
+		fakeBBSClient   *fake_bbs.FakeClient
+		schedulingInfos []*models.DesiredLRPSchedulingInfo
+		actualLRPs      []*models.ActualLRP
+		stdout, stderr  *gbytes.Buffer
+	)
+
+	actualLRP := func(processGuid string, index int32, state string) *models.ActualLRP {
+		return &models.ActualLRP{
+			ActualLRPKey: models.NewActualLRPKey(processGuid, index, "domain-1"),
+			State:        state,
+		}
+	}
+
+	BeforeEach(func() {
+		stdout = gbytes.NewBuffer()
+		stderr = gbytes.NewBuffer()
+		fakeBBSClient = &fake_bbs.FakeClient{}
+
+		schedulingInfos = []*models.DesiredLRPSchedulingInfo{
+			{DesiredLRPKey: models.DesiredLRPKey{ProcessGuid: "healthy", Domain: "domain-1"}, Instances: 2},
+			{DesiredLRPKey: models.DesiredLRPKey{ProcessGuid: "unhealthy", Domain: "domain-1"}, Instances: 3},
+		}
+		actualLRPs = []*models.ActualLRP{
+			actualLRP("healthy", 0, models.ActualLRPStateRunning),
+			actualLRP("healthy", 1, models.ActualLRPStateRunning),
+			actualLRP("unhealthy", 0, models.ActualLRPStateRunning),
+			actualLRP("unhealthy", 2, models.ActualLRPStateCrashed),
+			actualLRP("unhealthy", 4, models.ActualLRPStateRunning),
+			actualLRP("unhealthy", 3, models.ActualLRPStateRunning),
+			actualLRP("orphan", 0, models.ActualLRPStateRunning),
+		}
+	})
+
+	JustBeforeEach(func() {
+		fakeBBSClient.DesiredLRPSchedulingInfosReturns(schedulingInfos, nil)
+		fakeBBSClient.ActualLRPsReturns(actualLRPs, nil)
+	})
+
+	issues := func() []commands.ConvergenceIssue {
+		issues := []commands.ConvergenceIssue{}
+		for _, line := range strings.Split(strings.TrimSpace(string(stdout.Contents())), "\n") {
+			if line == "" {
+				continue
+			}
+			var issue commands.ConvergenceIssue
+			Expect(json.Unmarshal([]byte(line), &issue)).To(Succeed())
+			issues = append(issues, issue)
+		}
+		return issues
+	}
+
+	It("reports under-running processes, missing and extra indices and orphans", func() {
+		err := commands.ConvergenceReport(stdout, stderr, fakeBBSClient, "", "")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(issues()).To(Equal([]commands.ConvergenceIssue{
+			{Type: "orphaned", ProcessGuid: "orphan", Domain: "domain-1", RunningInstances: 1, Indices: []int32{0}},
+			{Type: "under_running", ProcessGuid: "unhealthy", Domain: "domain-1", DesiredInstances: 3, RunningInstances: 1},
+			{Type: "missing_indices", ProcessGuid: "unhealthy", Domain: "domain-1", DesiredInstances: 3, RunningInstances: 1, Indices: []int32{1}},
+			{Type: "extra_indices", ProcessGuid: "unhealthy", Domain: "domain-1", DesiredInstances: 3, RunningInstances: 1, Indices: []int32{3, 4}},
+		}))
+	})
+
+	It("passes the domain and process guid to the bbs", func() {
+		err := commands.ConvergenceReport(stdout, stderr, fakeBBSClient, "domain-1", "unhealthy")
+		Expect(err).NotTo(HaveOccurred())
+
+		_, _, desiredFilter := fakeBBSClient.DesiredLRPSchedulingInfosArgsForCall(0)
+		Expect(desiredFilter).To(Equal(models.DesiredLRPFilter{Domain: "domain-1", ProcessGuids: []string{"unhealthy"}}))
+
+		_, _, actualFilter := fakeBBSClient.ActualLRPsArgsForCall(0)
+		Expect(actualFilter).To(Equal(models.ActualLRPFilter{Domain: "domain-1", ProcessGuid: "unhealthy"}))
+	})
+
+	Context("when everything has converged", func() {
+		BeforeEach(func() {
+			actualLRPs = actualLRPs[:2]
+			schedulingInfos = schedulingInfos[:1]
+		})
+
+		It("prints nothing", func() {
+			err := commands.ConvergenceReport(stdout, stderr, fakeBBSClient, "", "")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stdout.Contents()).To(BeEmpty())
+		})
+	})
+
+	Context("when the bbs errors", func() {
+		JustBeforeEach(func() {
+			fakeBBSClient.ActualLRPsReturns(nil, models.ErrUnknownError)
+		})
+
+		It("fails with a relevant error", func() {
+			err := commands.ConvergenceReport(stdout, stderr, fakeBBSClient, "", "")
+			Expect(err).To(Equal(models.ErrUnknownError))
+		})
+	})
+
+	Describe("ValidateConvergenceReportArguments", func() {
+		It("rejects arguments", func() {
+			Expect(commands.ValidateConvergenceReportArguments([]string{"foo"})).To(MatchError("Too many arguments specified"))
+			Expect(commands.ValidateConvergenceReportArguments([]string{})).To(Succeed())
+		})
+	})
+})
@@ -0,0 +1,406 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"code.cloudfoundry.org/bbs"
+	"code.cloudfoundry.org/bbs/events"
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/bbs/trace"
+	"code.cloudfoundry.org/cfdot/commands/helpers"
+	"code.cloudfoundry.org/lager/v3"
+	"github.com/spf13/cobra"
+)
+
+// CrashReport ranks the processes and cells with the most crashing
+// instances.
+type CrashReport struct {
+	Processes []*CrashingProcess `json:"processes"`
+	Cells     []*CrashingCell    `json:"cells"`
+}
+
+type CrashingProcess struct {
+	ProcessGuid       string `json:"process_guid"`
+	Domain            string `json:"domain"`
+	CrashedInstances  int    `json:"crashed_instances"`
+	CrashingInstances int    `json:"crashing_instances"`
+	TotalCrashCount   int    `json:"total_crash_count"`
+	MaxCrashCount     int32  `json:"max_crash_count"`
+	LastCrashReason   string `json:"last_crash_reason"`
+	lastCrashedAt     int64
+}
+
+// CrashingCell only counts instances that are still placed on the cell;
+// CRASHED instances are no longer associated with one.
+type CrashingCell struct {
+	CellID            string `json:"cell_id"`
+	CrashingInstances int    `json:"crashing_instances"`
+	TotalCrashCount   int    `json:"total_crash_count"`
+	Processes         int    `json:"processes"`
+	processGuids      map[string]bool
+}
+
+// CrashLoop is reported by --watch when an instance crashes at least
+// --min-crashes times within --window.
+type CrashLoop struct {
+	ProcessGuid     string `json:"process_guid"`
+	Index           int32  `json:"index"`
+	Domain          string `json:"domain"`
+	CellID          string `json:"cell_id"`
+	CrashCount      int32  `json:"crash_count"`
+	CrashesInWindow int    `json:"crashes_in_window"`
+	Window          string `json:"window"`
+	CrashReason     string `json:"crash_reason"`
+	Since           int64  `json:"since"`
+}
+
+// errors
+var (
+	errInvalidMinCrashCount = errors.New("--min-crash-count must be a positive integer")
+	errInvalidTop           = errors.New("--top must be a non-negative integer")
+	errInvalidWindow        = errors.New("--window must be a positive duration")
+	errInvalidMinCrashes    = errors.New("--min-crashes must be a positive integer")
+	errWatchWithTimeout     = errors.New("--timeout cannot be used with --watch")
+	errReplayWithoutWatch   = errors.New("--replay can only be used with --watch")
+)
+
+// flags
+var (
+	crashingDomainFlag, crashingCellIdFlag string
+	crashingMinCrashCountFlag              int
+	crashingTopFlag                        int
+	crashingWatchFlag                      bool
+	crashingWindowFlag                     time.Duration
+	crashingMinCrashesFlag                 int
+	crashingReplayFlag                     string
+)
+
+var crashingCmd = &cobra.Command{
+	Use:   "crashing",
+	Short: "Show crashing actual LRPs",
+	Long:  "Rank the processes and cells with the most crashed or crashing actual LRPs, or watch the instance event stream for crash loops",
+	RunE:  crashing,
+}
+
+func init() {
+	AddBBSAndTimeoutFlags(crashingCmd)
+
+	crashingCmd.Flags().StringVarP(&crashingDomainFlag, "domain", "d", "", "consider only actual lrps for the given domain")
+	crashingCmd.Flags().StringVarP(&crashingCellIdFlag, "cell-id", "c", "", "consider only actual lrps for the given cell id")
+	crashingCmd.Flags().IntVar(&crashingMinCrashCountFlag, "min-crash-count", 1, "include non-crashed actual lrps with at least this crash count")
+	crashingCmd.Flags().IntVar(&crashingTopFlag, "top", 10, "show only this many processes and cells, 0 shows all")
+	crashingCmd.Flags().BoolVarP(&crashingWatchFlag, "watch", "w", false, "watch the instance event stream and report instances that crash repeatedly")
+	crashingCmd.Flags().DurationVar(&crashingWindowFlag, "window", 5*time.Minute, "with --watch, the window in which crashes are counted")
+	crashingCmd.Flags().IntVar(&crashingMinCrashesFlag, "min-crashes", 2, "with --watch, the number of crashes within the window that is reported")
+	crashingCmd.Flags().StringVar(&crashingReplayFlag, "replay", "", "with --watch, read the instance events from a file recorded by lrp-events --record instead of the BBS")
+
+	// A replay does not need a BBS.
+	bbsPrehook := crashingCmd.PreRunE
+	crashingCmd.PreRunE = func(cmd *cobra.Command, args []string) error {
+		if crashingReplayFlag != "" {
+			return nil
+		}
+		return bbsPrehook(cmd, args)
+	}
+
+	RootCmd.AddCommand(crashingCmd)
+}
+
+func crashing(cmd *cobra.Command, args []string) error {
+	err := ValidateCrashingArguments(args)
+	if err != nil {
+		return NewCFDotValidationError(cmd, err)
+	}
+
+	if crashingWatchFlag && cmd.Flags().Changed("timeout") {
+		return NewCFDotValidationError(cmd, errWatchWithTimeout)
+	}
+
+	if crashingWatchFlag {
+		var subscriber InstanceEventSubscriber
+		if crashingReplayFlag != "" {
+			subscriber, err = NewReplayBBSClient(crashingReplayFlag)
+			if err != nil {
+				return NewCFDotValidationError(cmd, err)
+			}
+		} else {
+			// A timeout from CFDOT_TIMEOUT or the profile would end the event
+			// stream, so the watch does not use one.
+			config := Config
+			config.Timeout = 0
+			subscriber, err = helpers.NewBBSClient(cmd, config)
+			if err != nil {
+				return NewCFDotError(cmd, err)
+			}
+		}
+
+		err = WatchCrashes(
+			cmd.OutOrStdout(),
+			cmd.OutOrStderr(),
+			subscriber,
+			crashingDomainFlag,
+			crashingCellIdFlag,
+			crashingWindowFlag,
+			crashingMinCrashesFlag,
+		)
+	} else {
+		var bbsClient bbs.Client
+		bbsClient, err = helpers.NewBBSClient(cmd, Config)
+		if err != nil {
+			return NewCFDotError(cmd, err)
+		}
+
+		err = Crashing(
+			cmd.OutOrStdout(),
+			cmd.OutOrStderr(),
+			bbsClient,
+			crashingDomainFlag,
+			crashingCellIdFlag,
+			int32(crashingMinCrashCountFlag),
+			crashingTopFlag,
+		)
+	}
+	if err != nil {
+		return NewCFDotError(cmd, err)
+	}
+
+	return nil
+}
+
+func ValidateCrashingArguments(args []string) error {
+	if len(args) > 0 {
+		return errExtraArguments
+	}
+
+	if crashingMinCrashCountFlag < 1 {
+		return errInvalidMinCrashCount
+	}
+
+	if crashingTopFlag < 0 {
+		return errInvalidTop
+	}
+
+	if crashingWindowFlag <= 0 {
+		return errInvalidWindow
+	}
+
+	if crashingMinCrashesFlag < 1 {
+		return errInvalidMinCrashes
+	}
+
+	if crashingReplayFlag != "" && !crashingWatchFlag {
+		return errReplayWithoutWatch
+	}
+
+	return nil
+}
+
+func Crashing(stdout, stderr io.Writer, bbsClient bbs.Client, domain, cellID string, minCrashCount int32, top int) error {
+	traceID := trace.GenerateTraceID()
+	logger := trace.LoggerWithTraceInfo(globalLogger.Session("crashing"), traceID)
+
+	actualLRPs, err := bbsClient.ActualLRPs(logger, traceID, models.ActualLRPFilter{Domain: domain, CellID: cellID})
+	if err != nil {
+		return err
+	}
+
+	encoder := newOutputEncoder(stdout)
+	err = encoder.Encode(BuildCrashReport(actualLRPs, minCrashCount, top))
+	if err != nil {
+		return err
+	}
+
+	return encoder.Flush()
+}
+
+func BuildCrashReport(actualLRPs []*models.ActualLRP, minCrashCount int32, top int) *CrashReport {
+	processes := map[string]*CrashingProcess{}
+	cells := map[string]*CrashingCell{}
+
+	for _, actualLRP := range actualLRPs {
+		crashed := actualLRP.State == models.ActualLRPStateCrashed
+		if !crashed && actualLRP.CrashCount < minCrashCount {
+			continue
+		}
+
+		process, ok := processes[actualLRP.ProcessGuid]
+		if !ok {
+			process = &CrashingProcess{ProcessGuid: actualLRP.ProcessGuid, Domain: actualLRP.Domain}
+			processes[actualLRP.ProcessGuid] = process
+		}
+
+		if crashed {
+			process.CrashedInstances++
+		} else {
+			process.CrashingInstances++
+		}
+		process.TotalCrashCount += int(actualLRP.CrashCount)
+		if actualLRP.CrashCount > process.MaxCrashCount {
+			process.MaxCrashCount = actualLRP.CrashCount
+		}
+		if actualLRP.CrashReason != "" && actualLRP.Since >= process.lastCrashedAt {
+			process.LastCrashReason = actualLRP.CrashReason
+			process.lastCrashedAt = actualLRP.Since
+		}
+
+		if actualLRP.CellId == "" {
+			continue
+		}
+
+		cell, ok := cells[actualLRP.CellId]
+		if !ok {
+			cell = &CrashingCell{CellID: actualLRP.CellId, processGuids: map[string]bool{}}
+			cells[actualLRP.CellId] = cell
+		}
+		cell.CrashingInstances++
+		cell.TotalCrashCount += int(actualLRP.CrashCount)
+		cell.processGuids[actualLRP.ProcessGuid] = true
+		cell.Processes = len(cell.processGuids)
+	}
+
+	report := &CrashReport{
+		Processes: []*CrashingProcess{},
+		Cells:     []*CrashingCell{},
+	}
+
+	for _, process := range processes {
+		report.Processes = append(report.Processes, process)
+	}
+	sort.Slice(report.Processes, func(i, j int) bool {
+		a, b := report.Processes[i], report.Processes[j]
+		if a.CrashedInstances != b.CrashedInstances {
+			return a.CrashedInstances > b.CrashedInstances
+		}
+		if a.TotalCrashCount != b.TotalCrashCount {
+			return a.TotalCrashCount > b.TotalCrashCount
+		}
+		return a.ProcessGuid < b.ProcessGuid
+	})
+
+	for _, cell := range cells {
+		report.Cells = append(report.Cells, cell)
+	}
+	sort.Slice(report.Cells, func(i, j int) bool {
+		a, b := report.Cells[i], report.Cells[j]
+		if a.TotalCrashCount != b.TotalCrashCount {
+			return a.TotalCrashCount > b.TotalCrashCount
+		}
+		if a.CrashingInstances != b.CrashingInstances {
+			return a.CrashingInstances > b.CrashingInstances
+		}
+		return a.CellID < b.CellID
+	})
+
+	if top > 0 {
+		if len(report.Processes) > top {
+			report.Processes = report.Processes[:top]
+		}
+		if len(report.Cells) > top {
+			report.Cells = report.Cells[:top]
+		}
+	}
+
+	return report
+}
+
+func (r *CrashReport) TableRows() []interface{} {
+	rows := []interface{}{}
+	for _, process := range r.Processes {
+		rows = append(rows, process)
+	}
+	for _, cell := range r.Cells {
+		rows = append(rows, cell)
+	}
+	return rows
+}
+
+// InstanceEventSubscriber is the part of the BBS client WatchCrashes needs,
+// which a ReplayBBSClient also implements.
+type InstanceEventSubscriber interface {
+	SubscribeToInstanceEventsByCellID(logger lager.Logger, cellID string) (events.EventSource, error)
+}
+
+// WatchCrashes reports instances that crash at least minCrashes times within
+// window. Crashes are timed by the Since of each actual_lrp_crashed event.
+func WatchCrashes(stdout, stderr io.Writer, bbsClient InstanceEventSubscriber, domain, cellID string, window time.Duration, minCrashes int) error {
+	logger := globalLogger.Session("crashing")
+
+	es, err := bbsClient.SubscribeToInstanceEventsByCellID(logger, cellID)
+	if err != nil {
+		return models.ConvertError(err)
+	}
+	defer es.Close()
+
+	encoder := newOutputEncoder(stdout)
+	crashes := map[string][]int64{}
+	var lastSweep int64
+
+	for {
+		event, err := es.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		crashedEvent, ok := event.(*models.ActualLRPCrashedEvent)
+		if !ok {
+			continue
+		}
+		if domain != "" && crashedEvent.Domain != domain {
+			continue
+		}
+
+		key := fmt.Sprintf("%s/%d", crashedEvent.ProcessGuid, crashedEvent.Index)
+		windowStart := crashedEvent.Since - window.Nanoseconds()
+
+		// Forget the instances without a crash in the window once per window,
+		// so that a long watch does not keep every instance that ever crashed.
+		if crashedEvent.Since-lastSweep > window.Nanoseconds() {
+			for key, sinces := range crashes {
+				if sinces[len(sinces)-1] <= windowStart {
+					delete(crashes, key)
+				}
+			}
+			lastSweep = crashedEvent.Since
+		}
+
+		recent := []int64{}
+		for _, since := range crashes[key] {
+			if since > windowStart {
+				recent = append(recent, since)
+			}
+		}
+		recent = append(recent, crashedEvent.Since)
+		crashes[key] = recent
+
+		if len(recent) < minCrashes {
+			continue
+		}
+
+		err = encoder.Encode(&CrashLoop{
+			ProcessGuid:     crashedEvent.ProcessGuid,
+			Index:           crashedEvent.Index,
+			Domain:          crashedEvent.Domain,
+			CellID:          crashedEvent.CellId,
+			CrashCount:      crashedEvent.CrashCount,
+			CrashesInWindow: len(recent),
+			Window:          window.String(),
+			CrashReason:     crashedEvent.CrashReason,
+			Since:           crashedEvent.Since,
+		})
+		if err != nil {
+			logger.Error("failed-to-marshal", err)
+			continue
+		}
+		err = encoder.Flush()
+		if err != nil {
+			return err
+		}
+	}
+}
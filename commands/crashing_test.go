@@ -0,0 +1,238 @@
+package commands_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/bbs/events/eventfakes"
+	"code.cloudfoundry.org/bbs/fake_bbs"
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/cfdot/commands"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("Crashing", func() {
+	var (
+		fakeBBSClient  *fake_bbs.FakeClient
+		stdout, stderr *gbytes.Buffer
+	)
+
+	actualLRP := func(processGuid string, index int32, cellID, state string, crashCount int32, crashReason string, since int64) *models.ActualLRP {
+		return &models.ActualLRP{
+			ActualLRPKey:         models.NewActualLRPKey(processGuid, index, "domain-1"),
+			ActualLRPInstanceKey: models.ActualLRPInstanceKey{CellId: cellID},
+			State:                state,
+			CrashCount:           crashCount,
+			CrashReason:          crashReason,
+			Since:                since,
+		}
+	}
+
+	BeforeEach(func() {
+		stdout = gbytes.NewBuffer()
+		stderr = gbytes.NewBuffer()
+		fakeBBSClient = &fake_bbs.FakeClient{}
+	})
+
+	Describe("Crashing", func() {
+		BeforeEach(func() {
+			fakeBBSClient.ActualLRPsReturns([]*models.ActualLRP{
+				actualLRP("healthy", 0, "cell-1", models.ActualLRPStateRunning, 0, "", 1),
+				actualLRP("flaky", 0, "cell-1", models.ActualLRPStateRunning, 3, "oom", 1),
+				actualLRP("flaky", 1, "cell-2", models.ActualLRPStateRunning, 1, "exit 1", 2),
+				actualLRP("broken", 0, "", models.ActualLRPStateCrashed, 5, "old reason", 1),
+				actualLRP("broken", 1, "", models.ActualLRPStateCrashed, 4, "new reason", 2),
+			}, nil)
+		})
+
+		It("ranks the crashing processes and cells", func() {
+			err := commands.Crashing(stdout, stderr, fakeBBSClient, "domain-1", "cell-1", 1, 0)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, _, filter := fakeBBSClient.ActualLRPsArgsForCall(0)
+			Expect(filter).To(Equal(models.ActualLRPFilter{Domain: "domain-1", CellID: "cell-1"}))
+
+			var report commands.CrashReport
+			Expect(json.Unmarshal(stdout.Contents(), &report)).To(Succeed())
+
+			Expect(report.Processes).To(Equal([]*commands.CrashingProcess{
+				{ProcessGuid: "broken", Domain: "domain-1", CrashedInstances: 2, TotalCrashCount: 9, MaxCrashCount: 5, LastCrashReason: "new reason"},
+				{ProcessGuid: "flaky", Domain: "domain-1", CrashingInstances: 2, TotalCrashCount: 4, MaxCrashCount: 3, LastCrashReason: "exit 1"},
+			}))
+			Expect(report.Cells).To(Equal([]*commands.CrashingCell{
+				{CellID: "cell-1", CrashingInstances: 1, TotalCrashCount: 3, Processes: 1},
+				{CellID: "cell-2", CrashingInstances: 1, TotalCrashCount: 1, Processes: 1},
+			}))
+		})
+
+		It("only includes non-crashed instances above the minimum crash count", func() {
+			err := commands.Crashing(stdout, stderr, fakeBBSClient, "", "", 2, 0)
+			Expect(err).NotTo(HaveOccurred())
+
+			var report commands.CrashReport
+			Expect(json.Unmarshal(stdout.Contents(), &report)).To(Succeed())
+			Expect(report.Processes[1].CrashingInstances).To(Equal(1))
+			Expect(report.Cells).To(HaveLen(1))
+		})
+
+		It("limits the report to the top offenders", func() {
+			err := commands.Crashing(stdout, stderr, fakeBBSClient, "", "", 1, 1)
+			Expect(err).NotTo(HaveOccurred())
+
+			var report commands.CrashReport
+			Expect(json.Unmarshal(stdout.Contents(), &report)).To(Succeed())
+			Expect(report.Processes).To(HaveLen(1))
+			Expect(report.Processes[0].ProcessGuid).To(Equal("broken"))
+			Expect(report.Cells).To(HaveLen(1))
+		})
+
+		It("prints the processes and cells as tables", func() {
+			commands.Output.Format = "table"
+
+			err := commands.Crashing(stdout, stderr, fakeBBSClient, "", "", 1, 0)
+			Expect(err).NotTo(HaveOccurred())
+
+			tables := strings.Split(strings.TrimSpace(string(stdout.Contents())), "\n\n")
+			Expect(tables).To(HaveLen(2))
+			Expect(tables[0]).To(HavePrefix("PROCESS GUID"))
+			Expect(tables[1]).To(HavePrefix("CELL ID"))
+		})
+
+		Context("when the bbs errors", func() {
+			BeforeEach(func() {
+				fakeBBSClient.ActualLRPsReturns(nil, models.ErrUnknownError)
+			})
+
+			It("fails with a relevant error", func() {
+				err := commands.Crashing(stdout, stderr, fakeBBSClient, "", "", 1, 0)
+				Expect(err).To(Equal(models.ErrUnknownError))
+			})
+		})
+	})
+
+	Describe("WatchCrashes", func() {
+		var fakeEventSource *eventfakes.FakeEventSource
+
+		crashedEvent := func(processGuid string, index int32, domain string, crashCount int32, since time.Duration) models.Event {
+			return &models.ActualLRPCrashedEvent{
+				ActualLRPKey:         models.NewActualLRPKey(processGuid, index, domain),
+				ActualLRPInstanceKey: models.ActualLRPInstanceKey{InstanceGuid: "instance-guid", CellId: "cell-1"},
+				CrashCount:           crashCount,
+				CrashReason:          "oom",
+				Since:                int64(since),
+			}
+		}
+
+		BeforeEach(func() {
+			fakeEventSource = &eventfakes.FakeEventSource{}
+			fakeBBSClient.SubscribeToInstanceEventsByCellIDReturns(fakeEventSource, nil)
+
+			events := []models.Event{
+				crashedEvent("process-guid-1", 0, "domain-1", 1, 0),
+				models.NewActualLRPInstanceRemovedEvent(&models.ActualLRP{}, "trace-id"),
+				crashedEvent("process-guid-2", 0, "domain-2", 1, time.Second),
+				crashedEvent("process-guid-1", 0, "domain-1", 2, 30*time.Second),
+				crashedEvent("process-guid-2", 0, "domain-2", 2, 40*time.Second),
+				crashedEvent("process-guid-1", 0, "domain-1", 3, 2*time.Minute),
+			}
+			for i, event := range events {
+				fakeEventSource.NextReturnsOnCall(i, event, nil)
+			}
+			fakeEventSource.NextReturnsOnCall(len(events), nil, io.EOF)
+		})
+
+		crashLoops := func() []commands.CrashLoop {
+			crashLoops := []commands.CrashLoop{}
+			for _, line := range strings.Split(strings.TrimSpace(string(stdout.Contents())), "\n") {
+				if line == "" {
+					continue
+				}
+				var crashLoop commands.CrashLoop
+				Expect(json.Unmarshal([]byte(line), &crashLoop)).To(Succeed())
+				crashLoops = append(crashLoops, crashLoop)
+			}
+			return crashLoops
+		}
+
+		It("reports instances that crash repeatedly within the window", func() {
+			err := commands.WatchCrashes(stdout, stderr, fakeBBSClient, "", "cell-1", time.Minute, 2)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, cellID := fakeBBSClient.SubscribeToInstanceEventsByCellIDArgsForCall(0)
+			Expect(cellID).To(Equal("cell-1"))
+			Expect(fakeEventSource.CloseCallCount()).To(Equal(1))
+
+			Expect(crashLoops()).To(Equal([]commands.CrashLoop{
+				{ProcessGuid: "process-guid-1", Index: 0, Domain: "domain-1", CellID: "cell-1", CrashCount: 2, CrashesInWindow: 2, Window: "1m0s", CrashReason: "oom", Since: int64(30 * time.Second)},
+				{ProcessGuid: "process-guid-2", Index: 0, Domain: "domain-2", CellID: "cell-1", CrashCount: 2, CrashesInWindow: 2, Window: "1m0s", CrashReason: "oom", Since: int64(40 * time.Second)},
+			}))
+		})
+
+		It("only reports instances in the given domain", func() {
+			err := commands.WatchCrashes(stdout, stderr, fakeBBSClient, "domain-2", "", time.Minute, 2)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(crashLoops()).To(HaveLen(1))
+			Expect(crashLoops()[0].ProcessGuid).To(Equal("process-guid-2"))
+		})
+
+		It("counts every crash within a wider window", func() {
+			err := commands.WatchCrashes(stdout, stderr, fakeBBSClient, "domain-1", "", 5*time.Minute, 3)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(crashLoops()).To(HaveLen(1))
+			Expect(crashLoops()[0].CrashesInWindow).To(Equal(3))
+		})
+
+		Context("when instances stop crashing for longer than the window", func() {
+			BeforeEach(func() {
+				fakeEventSource = &eventfakes.FakeEventSource{}
+				fakeBBSClient.SubscribeToInstanceEventsByCellIDReturns(fakeEventSource, nil)
+
+				events := []models.Event{
+					crashedEvent("process-guid-1", 0, "domain-1", 1, 0),
+					crashedEvent("process-guid-2", 0, "domain-2", 1, 5*time.Minute),
+					crashedEvent("process-guid-1", 0, "domain-1", 2, 6*time.Minute),
+					crashedEvent("process-guid-1", 0, "domain-1", 3, 6*time.Minute+10*time.Second),
+				}
+				for i, event := range events {
+					fakeEventSource.NextReturnsOnCall(i, event, nil)
+				}
+				fakeEventSource.NextReturnsOnCall(len(events), nil, io.EOF)
+			})
+
+			It("only counts the crashes since they started crashing again", func() {
+				err := commands.WatchCrashes(stdout, stderr, fakeBBSClient, "", "", time.Minute, 2)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(crashLoops()).To(HaveLen(1))
+				Expect(crashLoops()[0].CrashCount).To(BeEquivalentTo(3))
+				Expect(crashLoops()[0].CrashesInWindow).To(Equal(2))
+			})
+		})
+
+		Context("when subscribing fails", func() {
+			BeforeEach(func() {
+				fakeBBSClient.SubscribeToInstanceEventsByCellIDReturns(nil, errors.New("boom"))
+			})
+
+			It("returns the error", func() {
+				err := commands.WatchCrashes(stdout, stderr, fakeBBSClient, "", "", time.Minute, 2)
+				Expect(err).To(HaveOccurred())
+			})
+		})
+	})
+
+	Describe("ValidateCrashingArguments", func() {
+		It("rejects arguments", func() {
+			Expect(commands.ValidateCrashingArguments([]string{"foo"})).To(MatchError("Too many arguments specified"))
+			Expect(commands.ValidateCrashingArguments([]string{})).To(Succeed())
+		})
+	})
+})
@@ -23,6 +23,7 @@ var createDesiredLRPCmd = &cobra.Command{
 
 func init() {
 	AddBBSAndTimeoutFlags(createDesiredLRPCmd)
+	AddDryRunFlag(createDesiredLRPCmd)
 	RootCmd.AddCommand(createDesiredLRPCmd)
 }
 
@@ -41,6 +42,8 @@ func createDesiredLRP(cmd *cobra.Command, args []string) error {
 		return NewCFDotError(cmd, err)
 	}
 
+	bbsClient = mutatingBBSClient(cmd, bbsClient)
+
 	err = CreateDesiredLRP(cmd.OutOrStdout(), cmd.OutOrStderr(), bbsClient, spec)
 	if err != nil {
 		return NewCFDotError(cmd, err)
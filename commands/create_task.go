@@ -23,6 +23,7 @@ var createTaskCmd = &cobra.Command{
 
 func init() {
 	AddBBSAndTimeoutFlags(createTaskCmd)
+	AddDryRunFlag(createTaskCmd)
 	RootCmd.AddCommand(createTaskCmd)
 }
 
@@ -41,6 +42,8 @@ func createTask(cmd *cobra.Command, args []string) error {
 		return NewCFDotError(cmd, err)
 	}
 
+	bbsClient = mutatingBBSClient(cmd, bbsClient)
+
 	err = CreateTask(cmd.OutOrStdout(), cmd.OutOrStderr(), bbsClient, spec)
 	if err != nil {
 		return NewCFDotError(cmd, err)
@@ -18,6 +18,7 @@ var deleteDesiredLRPCmd = &cobra.Command{
 
 func init() {
 	AddBBSAndTimeoutFlags(deleteDesiredLRPCmd)
+	AddDryRunFlag(deleteDesiredLRPCmd)
 	RootCmd.AddCommand(deleteDesiredLRPCmd)
 }
 
@@ -32,6 +33,8 @@ func deleteDesiredLRP(cmd *cobra.Command, args []string) error {
 		return NewCFDotError(cmd, err)
 	}
 
+	bbsClient = mutatingBBSClient(cmd, bbsClient)
+
 	err = DeleteDesiredLRP(cmd.OutOrStdout(), cmd.OutOrStderr(), bbsClient, processGuid)
 	if err != nil {
 		return NewCFDotError(cmd, err)
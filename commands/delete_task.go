@@ -18,6 +18,7 @@ var deleteTaskCmd = &cobra.Command{
 
 func init() {
 	AddBBSAndTimeoutFlags(deleteTaskCmd)
+	AddDryRunFlag(deleteTaskCmd)
 	RootCmd.AddCommand(deleteTaskCmd)
 }
 
@@ -32,6 +33,8 @@ func deleteTask(cmd *cobra.Command, args []string) error {
 		return NewCFDotError(cmd, err)
 	}
 
+	bbsClient = mutatingBBSClient(cmd, bbsClient)
+
 	err = DeleteTask(cmd.OutOrStdout(), cmd.OutOrStderr(), bbsClient, taskGuid)
 	if err != nil {
 		return NewCFDotError(cmd, err)
@@ -1,7 +1,6 @@
 package commands
 
 import (
-	"encoding/json"
 	"io"
 
 	"code.cloudfoundry.org/bbs/trace"
@@ -67,6 +66,11 @@ func DesiredLRP(stdout, stderr io.Writer, bbsClient bbs.Client, processGuid stri
 		return err
 	}
 
-	encoder := json.NewEncoder(stdout)
-	return encoder.Encode(desiredLRP)
+	encoder := newOutputEncoder(stdout)
+	err = encoder.Encode(desiredLRP)
+	if err != nil {
+		return err
+	}
+
+	return encoder.Flush()
 }
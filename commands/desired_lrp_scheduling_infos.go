@@ -1,7 +1,6 @@
 package commands
 
 import (
-	"encoding/json"
 	"io"
 
 	"code.cloudfoundry.org/bbs"
@@ -64,7 +63,7 @@ func DesiredLRPSchedulingInfos(stdout, stderr io.Writer, bbsClient bbs.Client, d
 	traceID := trace.GenerateTraceID()
 	logger := trace.LoggerWithTraceInfo(globalLogger.Session("desired-lrp-scheduling-infos"), traceID)
 
-	encoder := json.NewEncoder(stdout)
+	encoder := newOutputEncoder(stdout)
 	desiredLRPFilter := models.DesiredLRPFilter{
 		Domain: domain,
 	}
@@ -81,5 +80,5 @@ func DesiredLRPSchedulingInfos(stdout, stderr io.Writer, bbsClient bbs.Client, d
 		}
 	}
 
-	return nil
+	return encoder.Flush()
 }
@@ -1,7 +1,6 @@
 package commands
 
 import (
-	"encoding/json"
 	"io"
 
 	"code.cloudfoundry.org/bbs"
@@ -71,7 +70,7 @@ func DesiredLRPs(stdout, stderr io.Writer, bbsClient bbs.Client, domain string)
 		return err
 	}
 
-	encoder := json.NewEncoder(stdout)
+	encoder := newOutputEncoder(stdout)
 	for _, lrp := range desiredLRPs {
 		err = encoder.Encode(lrp)
 		if err != nil {
@@ -79,5 +78,5 @@ func DesiredLRPs(stdout, stderr io.Writer, bbsClient bbs.Client, domain string)
 		}
 	}
 
-	return nil
+	return encoder.Flush()
 }
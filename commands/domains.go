@@ -1,7 +1,6 @@
 package commands
 
 import (
-	"encoding/json"
 	"io"
 
 	"code.cloudfoundry.org/bbs"
@@ -53,7 +52,7 @@ func Domains(stdout, stderr io.Writer, bbsClient bbs.Client) error {
 	traceID := trace.GenerateTraceID()
 	logger := trace.LoggerWithTraceInfo(globalLogger.Session("domains"), traceID)
 
-	encoder := json.NewEncoder(stdout)
+	encoder := newOutputEncoder(stdout)
 
 	domains, err := bbsClient.Domains(logger, traceID)
 	if err != nil {
@@ -67,5 +66,5 @@ func Domains(stdout, stderr io.Writer, bbsClient bbs.Client) error {
 		}
 	}
 
-	return nil
+	return encoder.Flush()
 }
@@ -0,0 +1,310 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"code.cloudfoundry.org/bbs"
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/bbs/trace"
+	"code.cloudfoundry.org/cfdot/commands/helpers"
+	"github.com/spf13/cobra"
+)
+
+const (
+	DrainEventTaskRunning = "task_running"
+	// DrainEventRetiredUnreplaced is printed for instances that the BBS will
+	// not replace, because their desired LRP is gone or has fewer instances.
+	DrainEventRetiredUnreplaced = "retired_unreplaced"
+	// DrainEventWouldRetireUnreplaced replaces DrainEventRetiredUnreplaced
+	// with --dry-run.
+	DrainEventWouldRetireUnreplaced = "would_retire_unreplaced"
+	// DrainEventLeftOnCell is printed for every instance still on the cell
+	// when the drain stops early.
+	DrainEventLeftOnCell = "left_on_cell"
+)
+
+// DrainTask is printed by drain-cell for every task still running on the
+// drained cell.
+type DrainTask struct {
+	Event    string `json:"event"`
+	TaskGuid string `json:"task_guid"`
+	Domain   string `json:"domain"`
+	State    string `json:"state"`
+	CellID   string `json:"cell_id"`
+}
+
+// errors
+var (
+	errInvalidCellId     = errors.New("Cell id should be non empty string")
+	errInvalidMaxRetries = errors.New("--max-retries must not be negative")
+)
+
+// flags
+var (
+	drainCellMaxInFlightFlag  int
+	drainCellMaxRetriesFlag   int
+	drainCellPollIntervalFlag time.Duration
+	drainCellWaitTimeoutFlag  time.Duration
+)
+
+var drainCellCmd = &cobra.Command{
+	Use:   "drain-cell CELL_ID",
+	Short: "Move the actual LRPs of a cell to other cells",
+	Long:  "Retire the actual LRPs running on the given cell in batches, waiting for each batch to be running on other cells before retiring the next one, then report the tasks still running on the cell. The cell is neither evacuated nor excluded from placement, so the auctioneer may place replacements on it again, often as it is the emptiest cell. Such replacements are retired up to --max-retries times, after which the drain stops and reports the instances left on the cell. Evacuate the cell through its rep to drain it reliably",
+	RunE:  drainCell,
+}
+
+func init() {
+	AddBBSAndTimeoutFlags(drainCellCmd)
+	AddDryRunFlag(drainCellCmd)
+
+	drainCellCmd.Flags().IntVar(&drainCellMaxInFlightFlag, "max-in-flight", 5, "number of instances moved at the same time")
+	drainCellCmd.Flags().IntVar(&drainCellMaxRetriesFlag, "max-retries", 3, "number of times a replacement placed on the drained cell is retired again")
+	drainCellCmd.Flags().DurationVar(&drainCellPollIntervalFlag, "poll-interval", 2*time.Second, "interval at which the actual lrps are polled while waiting for replacements")
+	drainCellCmd.Flags().DurationVar(&drainCellWaitTimeoutFlag, "wait-timeout", 5*time.Minute, "maximum time to wait for the replacements of a batch to be running")
+
+	RootCmd.AddCommand(drainCellCmd)
+}
+
+func drainCell(cmd *cobra.Command, args []string) error {
+	cellID, err := ValidateDrainCellArguments(args)
+	if err != nil {
+		return NewCFDotValidationError(cmd, err)
+	}
+
+	bbsClient, err := helpers.NewBBSClient(cmd, Config)
+	if err != nil {
+		return NewCFDotError(cmd, err)
+	}
+
+	bbsClient = mutatingBBSClient(cmd, bbsClient)
+
+	err = DrainCell(
+		cmd.OutOrStdout(),
+		cmd.OutOrStderr(),
+		bbsClient,
+		cellID,
+		drainCellMaxInFlightFlag,
+		drainCellMaxRetriesFlag,
+		drainCellPollIntervalFlag,
+		drainCellWaitTimeoutFlag,
+		DryRun,
+	)
+	if err != nil {
+		return NewCFDotError(cmd, err)
+	}
+
+	return nil
+}
+
+func ValidateDrainCellArguments(args []string) (string, error) {
+	switch {
+	case len(args) < 1:
+		return "", errMissingArguments
+	case len(args) > 1:
+		return "", errExtraArguments
+	case args[0] == "":
+		return "", errInvalidCellId
+	case drainCellMaxInFlightFlag < 1:
+		return "", errInvalidMaxInFlight
+	case drainCellMaxRetriesFlag < 0:
+		return "", errInvalidMaxRetries
+	case drainCellPollIntervalFlag <= 0:
+		return "", errInvalidPollInterval
+	case drainCellWaitTimeoutFlag <= 0:
+		return "", errInvalidWaitTimeout
+	}
+	return args[0], nil
+}
+
+// DrainCell retires the actual LRPs of the cell maxInFlight at a time and
+// waits for the replacements of each batch to be RUNNING on another cell
+// before retiring the next batch. Orphaned instances and instances at or
+// above the desired instance count are retired first without waiting. Tasks
+// still running on the cell are reported once all the actual LRPs have been
+// moved. When the drain stops early, the instances still on the cell are
+// reported as left_on_cell before the error is returned. Nothing is retired
+// with dryRun, so the instances are reported as would_retire and
+// would_retire_unreplaced and there is nothing to wait for.
+func DrainCell(stdout, stderr io.Writer, bbsClient bbs.Client, cellID string, maxInFlight, maxRetries int, pollInterval, waitTimeout time.Duration, dryRun bool) error {
+	traceID := trace.GenerateTraceID()
+	logger := trace.LoggerWithTraceInfo(globalLogger.Session("drain-cell"), traceID)
+
+	_, err := FetchCellRegistration(bbsClient, traceID, cellID)
+	if err != nil {
+		return err
+	}
+
+	actualLRPs, err := bbsClient.ActualLRPs(logger, traceID, models.ActualLRPFilter{CellID: cellID})
+	if err != nil {
+		return err
+	}
+
+	instances := []*models.ActualLRP{}
+	for _, actualLRP := range actualLRPs {
+		if actualLRP.Presence == models.ActualLRP_Evacuating {
+			continue
+		}
+		instances = append(instances, actualLRP)
+	}
+	sort.Slice(instances, func(i, j int) bool {
+		if instances[i].ProcessGuid != instances[j].ProcessGuid {
+			return instances[i].ProcessGuid < instances[j].ProcessGuid
+		}
+		return instances[i].Index < instances[j].Index
+	})
+
+	desiredInstances := map[string]int32{}
+	if len(instances) > 0 {
+		processGuids := []string{}
+		for _, actualLRP := range instances {
+			if !contains(processGuids, actualLRP.ProcessGuid) {
+				processGuids = append(processGuids, actualLRP.ProcessGuid)
+			}
+		}
+		schedulingInfos, err := bbsClient.DesiredLRPSchedulingInfos(logger, traceID, models.DesiredLRPFilter{ProcessGuids: processGuids})
+		if err != nil {
+			return err
+		}
+		for _, schedulingInfo := range schedulingInfos {
+			desiredInstances[schedulingInfo.ProcessGuid] = schedulingInfo.Instances
+		}
+	}
+
+	replaced := []*models.ActualLRP{}
+	unreplaced := []*models.ActualLRP{}
+	for _, actualLRP := range instances {
+		desired, ok := desiredInstances[actualLRP.ProcessGuid]
+		if ok && actualLRP.Index < desired {
+			replaced = append(replaced, actualLRP)
+		} else {
+			unreplaced = append(unreplaced, actualLRP)
+		}
+	}
+
+	retiredEvent, retiredUnreplacedEvent := InstanceEventRetired, DrainEventRetiredUnreplaced
+	if dryRun {
+		retiredEvent, retiredUnreplacedEvent = InstanceEventWouldRetire, DrainEventWouldRetireUnreplaced
+	}
+
+	encoder := newOutputEncoder(stdout)
+	report := func(value interface{}) error {
+		err := encoder.Encode(value)
+		if err != nil {
+			return err
+		}
+		return encoder.Flush()
+	}
+	retire := func(event string, actualLRP *models.ActualLRP) error {
+		key := actualLRP.ActualLRPKey
+		err := bbsClient.RetireActualLRP(logger, traceID, &key)
+		if err != nil {
+			return err
+		}
+		return report(newInstanceProgress(event, actualLRP))
+	}
+
+	reportLeftOnCell := func() error {
+		actualLRPs, err := bbsClient.ActualLRPs(logger, traceID, models.ActualLRPFilter{CellID: cellID})
+		if err != nil {
+			return err
+		}
+		for _, actualLRP := range actualLRPs {
+			if actualLRP.Presence == models.ActualLRP_Evacuating {
+				continue
+			}
+			err = report(newInstanceProgress(DrainEventLeftOnCell, actualLRP))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	moveInstances := func() error {
+		// Nothing replaces these instances, so there is nothing to wait for.
+		for _, actualLRP := range unreplaced {
+			err := retire(retiredUnreplacedEvent, actualLRP)
+			if err != nil {
+				return err
+			}
+		}
+
+		for first := 0; first < len(replaced); first += maxInFlight {
+			last := first + maxInFlight
+			if last > len(replaced) {
+				last = len(replaced)
+			}
+
+			pending := []*pendingReplacement{}
+			for _, actualLRP := range replaced[first:last] {
+				err := retire(retiredEvent, actualLRP)
+				if err != nil {
+					return err
+				}
+				pending = append(pending, &pendingReplacement{key: actualLRP.ActualLRPKey, retired: actualLRP})
+			}
+
+			if dryRun {
+				continue
+			}
+
+			err := waitForReplacements(logger, traceID, bbsClient, pending, pollInterval, waitTimeout, func(p *pendingReplacement, replacement *models.ActualLRP) (bool, error) {
+				if replacement.State != models.ActualLRPStateRunning {
+					return false, nil
+				}
+
+				if replacement.CellId == cellID {
+					if p.attempts >= maxRetries {
+						return false, fmt.Errorf("Replacement for index %d of %s was placed on cell %s again after %d retries", replacement.Index, replacement.ProcessGuid, cellID, maxRetries)
+					}
+					p.attempts++
+					p.retired = replacement
+					return false, retire(InstanceEventRetired, replacement)
+				}
+
+				return true, report(newInstanceProgress(InstanceEventRescheduled, replacement))
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	err = moveInstances()
+	if err != nil {
+		reportErr := reportLeftOnCell()
+		if reportErr != nil {
+			logger.Error("failed-to-report-instances-left-on-cell", reportErr)
+		}
+		return err
+	}
+
+	tasks, err := bbsClient.TasksWithFilter(logger, traceID, models.TaskFilter{CellID: cellID})
+	if err != nil {
+		return err
+	}
+
+	for _, task := range tasks {
+		if task.State != models.Task_Running {
+			continue
+		}
+		err = report(&DrainTask{
+			Event:    DrainEventTaskRunning,
+			TaskGuid: task.TaskGuid,
+			Domain:   task.Domain,
+			State:    task.State.String(),
+			CellID:   task.CellId,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
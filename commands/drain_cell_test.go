@@ -0,0 +1,306 @@
+package commands_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/bbs/fake_bbs"
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/cfdot/commands"
+	"code.cloudfoundry.org/lager/v3"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("DrainCell", func() {
+	var (
+		fakeBBSClient  *fake_bbs.FakeClient
+		stdout, stderr *gbytes.Buffer
+		lock           sync.Mutex
+		instances      map[string]*models.ActualLRP
+		placements     []string
+		placed         int
+	)
+
+	actualLRP := func(processGuid string, index int32, instanceGuid, cellID, state string) *models.ActualLRP {
+		return &models.ActualLRP{
+			ActualLRPKey:         models.NewActualLRPKey(processGuid, index, "domain"),
+			ActualLRPInstanceKey: models.ActualLRPInstanceKey{InstanceGuid: instanceGuid, CellId: cellID},
+			State:                state,
+			Since:                int64(len(instanceGuid)),
+		}
+	}
+
+	BeforeEach(func() {
+		stdout = gbytes.NewBuffer()
+		stderr = gbytes.NewBuffer()
+		placements = []string{"cell-2"}
+		placed = 0
+
+		instances = map[string]*models.ActualLRP{
+			"guid-b/0": actualLRP("guid-b", 0, "b-0", "cell-1", models.ActualLRPStateRunning),
+			"guid-a/1": actualLRP("guid-a", 1, "a-1", "cell-1", models.ActualLRPStateRunning),
+			"guid-a/0": actualLRP("guid-a", 0, "a-0", "cell-1", models.ActualLRPStateRunning),
+			"guid-c/0": actualLRP("guid-c", 0, "c-0", "cell-3", models.ActualLRPStateRunning),
+		}
+
+		fakeBBSClient = &fake_bbs.FakeClient{}
+		fakeBBSClient.CellsReturns([]*models.CellPresence{
+			{CellId: "cell-1"},
+			{CellId: "cell-2"},
+		}, nil)
+
+		// retired instances are replaced by an UNCLAIMED instance, which is
+		// RUNNING on the next entry of placements on the next poll
+		fakeBBSClient.RetireActualLRPStub = func(_ lager.Logger, _ string, key *models.ActualLRPKey) error {
+			lock.Lock()
+			defer lock.Unlock()
+
+			id := fmt.Sprintf("%s/%d", key.ProcessGuid, key.Index)
+			instances[id] = actualLRP(key.ProcessGuid, key.Index, "", "", models.ActualLRPStateUnclaimed)
+			return nil
+		}
+		fakeBBSClient.ActualLRPsStub = func(_ lager.Logger, _ string, filter models.ActualLRPFilter) ([]*models.ActualLRP, error) {
+			lock.Lock()
+			defer lock.Unlock()
+
+			actualLRPs := []*models.ActualLRP{}
+			for id, instance := range instances {
+				if instance.State == models.ActualLRPStateUnclaimed {
+					cellID := placements[placed%len(placements)]
+					placed++
+					instance = actualLRP(instance.ProcessGuid, instance.Index, fmt.Sprintf("new-%s-%d", id, placed), cellID, models.ActualLRPStateRunning)
+					instances[id] = instance
+				}
+				if filter.CellID != "" && instance.CellId != filter.CellID {
+					continue
+				}
+				if filter.ProcessGuid != "" && instance.ProcessGuid != filter.ProcessGuid {
+					continue
+				}
+				actualLRPs = append(actualLRPs, instance)
+			}
+			return actualLRPs, nil
+		}
+
+		fakeBBSClient.DesiredLRPSchedulingInfosReturns([]*models.DesiredLRPSchedulingInfo{
+			{DesiredLRPKey: models.NewDesiredLRPKey("guid-a", "domain", ""), Instances: 2},
+			{DesiredLRPKey: models.NewDesiredLRPKey("guid-b", "domain", ""), Instances: 1},
+			{DesiredLRPKey: models.NewDesiredLRPKey("guid-c", "domain", ""), Instances: 1},
+		}, nil)
+
+		fakeBBSClient.TasksWithFilterReturns([]*models.Task{
+			{TaskGuid: "task-running", Domain: "domain", State: models.Task_Running, CellId: "cell-1"},
+			{TaskGuid: "task-completed", Domain: "domain", State: models.Task_Completed, CellId: "cell-1"},
+		}, nil)
+	})
+
+	output := func() []map[string]interface{} {
+		events := []map[string]interface{}{}
+		decoder := json.NewDecoder(stdout)
+		for decoder.More() {
+			var event map[string]interface{}
+			Expect(decoder.Decode(&event)).To(Succeed())
+			events = append(events, event)
+		}
+		return events
+	}
+
+	It("moves the actual LRPs of the cell to other cells", func() {
+		err := commands.DrainCell(stdout, stderr, fakeBBSClient, "cell-1", 5, 3, time.Millisecond, time.Second, false)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, _, filter := fakeBBSClient.ActualLRPsArgsForCall(0)
+		Expect(filter).To(Equal(models.ActualLRPFilter{CellID: "cell-1"}))
+
+		Expect(fakeBBSClient.RetireActualLRPCallCount()).To(Equal(3))
+		for i, guid := range []string{"guid-a", "guid-a", "guid-b"} {
+			_, _, key := fakeBBSClient.RetireActualLRPArgsForCall(i)
+			Expect(key.ProcessGuid).To(Equal(guid))
+		}
+
+		events := output()
+		Expect(events).To(HaveLen(7))
+		for _, event := range events[:3] {
+			Expect(event).To(HaveKeyWithValue("event", "retired"))
+			Expect(event).To(HaveKeyWithValue("cell_id", "cell-1"))
+		}
+		for _, event := range events[3:6] {
+			Expect(event).To(HaveKeyWithValue("event", "rescheduled"))
+			Expect(event).To(HaveKeyWithValue("cell_id", "cell-2"))
+		}
+	})
+
+	It("reports the tasks still running on the cell", func() {
+		err := commands.DrainCell(stdout, stderr, fakeBBSClient, "cell-1", 5, 3, time.Millisecond, time.Second, false)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, _, filter := fakeBBSClient.TasksWithFilterArgsForCall(0)
+		Expect(filter).To(Equal(models.TaskFilter{CellID: "cell-1"}))
+
+		events := output()
+		Expect(events[len(events)-1]).To(Equal(map[string]interface{}{
+			"event":     "task_running",
+			"task_guid": "task-running",
+			"domain":    "domain",
+			"state":     "Running",
+			"cell_id":   "cell-1",
+		}))
+	})
+
+	It("moves up to max-in-flight instances at a time", func() {
+		err := commands.DrainCell(stdout, stderr, fakeBBSClient, "cell-1", 1, 3, time.Millisecond, time.Second, false)
+		Expect(err).NotTo(HaveOccurred())
+
+		events := output()
+		for i := 0; i < 6; i += 2 {
+			Expect(events[i]).To(HaveKeyWithValue("event", "retired"))
+			Expect(events[i+1]).To(HaveKeyWithValue("event", "rescheduled"))
+		}
+	})
+
+	Context("when a replacement is placed on the drained cell", func() {
+		BeforeEach(func() {
+			placements = []string{"cell-1", "cell-2"}
+		})
+
+		It("retires the replacement again", func() {
+			err := commands.DrainCell(stdout, stderr, fakeBBSClient, "cell-1", 1, 3, time.Millisecond, time.Second, false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fakeBBSClient.RetireActualLRPCallCount()).To(Equal(6))
+		})
+
+		Context("more than max-retries times", func() {
+			BeforeEach(func() {
+				placements = []string{"cell-1"}
+			})
+
+			It("stops the drain and reports the instances left on the cell", func() {
+				err := commands.DrainCell(stdout, stderr, fakeBBSClient, "cell-1", 1, 2, time.Millisecond, time.Second, false)
+				Expect(err).To(MatchError("Replacement for index 0 of guid-a was placed on cell cell-1 again after 2 retries"))
+				Expect(fakeBBSClient.RetireActualLRPCallCount()).To(Equal(3))
+				Expect(fakeBBSClient.TasksWithFilterCallCount()).To(Equal(0))
+
+				left := []string{}
+				for _, event := range output() {
+					if event["event"] == "left_on_cell" {
+						Expect(event).To(HaveKeyWithValue("cell_id", "cell-1"))
+						left = append(left, fmt.Sprintf("%s/%v", event["process_guid"], event["index"]))
+					}
+				}
+				Expect(left).To(ConsistOf("guid-a/0", "guid-a/1", "guid-b/0"))
+			})
+		})
+	})
+
+	Context("when an instance has no desired LRP", func() {
+		BeforeEach(func() {
+			instances["guid-orphan/0"] = actualLRP("guid-orphan", 0, "orphan-0", "cell-1", models.ActualLRPStateRunning)
+		})
+
+		It("retires it without waiting for a replacement", func() {
+			err := commands.DrainCell(stdout, stderr, fakeBBSClient, "cell-1", 5, 3, time.Millisecond, time.Second, false)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, _, filter := fakeBBSClient.DesiredLRPSchedulingInfosArgsForCall(0)
+			Expect(filter.ProcessGuids).To(ConsistOf("guid-a", "guid-b", "guid-orphan"))
+
+			Expect(fakeBBSClient.RetireActualLRPCallCount()).To(Equal(4))
+			_, _, key := fakeBBSClient.RetireActualLRPArgsForCall(0)
+			Expect(key.ProcessGuid).To(Equal("guid-orphan"))
+
+			events := output()
+			Expect(events[0]).To(HaveKeyWithValue("event", "retired_unreplaced"))
+			Expect(events[0]).To(HaveKeyWithValue("process_guid", "guid-orphan"))
+			Expect(events).To(HaveLen(8))
+		})
+	})
+
+	Context("when an instance is at or above the desired instance count", func() {
+		BeforeEach(func() {
+			instances["guid-b/1"] = actualLRP("guid-b", 1, "b-1", "cell-1", models.ActualLRPStateRunning)
+		})
+
+		It("retires it without waiting for a replacement", func() {
+			err := commands.DrainCell(stdout, stderr, fakeBBSClient, "cell-1", 5, 3, time.Millisecond, time.Second, false)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fakeBBSClient.RetireActualLRPCallCount()).To(Equal(4))
+			_, _, key := fakeBBSClient.RetireActualLRPArgsForCall(0)
+			Expect(key.ProcessGuid).To(Equal("guid-b"))
+			Expect(key.Index).To(BeEquivalentTo(1))
+
+			events := output()
+			Expect(events[0]).To(HaveKeyWithValue("event", "retired_unreplaced"))
+			Expect(events[0]).To(HaveKeyWithValue("index", BeEquivalentTo(1)))
+			for _, event := range events[1:] {
+				Expect(event).NotTo(HaveKeyWithValue("event", "retired_unreplaced"))
+			}
+		})
+	})
+
+	Context("with dry-run", func() {
+		BeforeEach(func() {
+			instances["guid-orphan/0"] = actualLRP("guid-orphan", 0, "orphan-0", "cell-1", models.ActualLRPStateRunning)
+		})
+
+		It("reports the instances that would be retired without waiting", func() {
+			bbsClient := commands.NewDryRunBBSClient(gbytes.NewBuffer(), fakeBBSClient, "https://bbs.example.com:8889")
+			err := commands.DrainCell(stdout, stderr, bbsClient, "cell-1", 1, 3, time.Millisecond, 20*time.Millisecond, true)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fakeBBSClient.RetireActualLRPCallCount()).To(Equal(0))
+
+			events := output()
+			Expect(events).To(HaveLen(5))
+			Expect(events[0]).To(HaveKeyWithValue("event", "would_retire_unreplaced"))
+			Expect(events[0]).To(HaveKeyWithValue("process_guid", "guid-orphan"))
+			for _, event := range events[1:4] {
+				Expect(event).To(HaveKeyWithValue("event", "would_retire"))
+				Expect(event).To(HaveKeyWithValue("cell_id", "cell-1"))
+			}
+			Expect(events[4]).To(HaveKeyWithValue("event", "task_running"))
+		})
+	})
+
+	Context("when the cell is not registered", func() {
+		It("returns an error", func() {
+			err := commands.DrainCell(stdout, stderr, fakeBBSClient, "cell-9", 1, 3, time.Millisecond, time.Second, false)
+			Expect(err).To(MatchError("Cell not found"))
+			Expect(fakeBBSClient.RetireActualLRPCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("when fetching the tasks fails", func() {
+		BeforeEach(func() {
+			fakeBBSClient.TasksWithFilterReturns(nil, models.ErrUnknownError)
+		})
+
+		It("returns the error", func() {
+			err := commands.DrainCell(stdout, stderr, fakeBBSClient, "cell-1", 5, 3, time.Millisecond, time.Second, false)
+			Expect(err).To(Equal(models.ErrUnknownError))
+		})
+	})
+
+	Describe("ValidateDrainCellArguments", func() {
+		It("returns the cell id", func() {
+			cellID, err := commands.ValidateDrainCellArguments([]string{"cell-1"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cellID).To(Equal("cell-1"))
+		})
+
+		It("rejects missing, extra and empty arguments", func() {
+			_, err := commands.ValidateDrainCellArguments([]string{})
+			Expect(err).To(MatchError("Missing arguments"))
+
+			_, err = commands.ValidateDrainCellArguments([]string{"a", "b"})
+			Expect(err).To(MatchError("Too many arguments specified"))
+
+			_, err = commands.ValidateDrainCellArguments([]string{""})
+			Expect(err).To(MatchError("Cell id should be non empty string"))
+		})
+	})
+})
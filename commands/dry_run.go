@@ -0,0 +1,202 @@
+package commands
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"code.cloudfoundry.org/bbs"
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/lager/v3"
+	locketmodels "code.cloudfoundry.org/locket/models"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+)
+
+// DryRunRequest is printed instead of sending a mutating request when
+// --dry-run is given. Current is the state of the target record before the
+// request, when there is one.
+type DryRunRequest struct {
+	Target  string      `json:"target"`
+	Method  string      `json:"method"`
+	Request interface{} `json:"request"`
+	Current interface{} `json:"current,omitempty"`
+}
+
+var DryRun bool
+
+// AddDryRunFlag registers --dry-run on a command that changes the BBS or
+// Locket. Such a command must build its clients with mutatingBBSClient or
+// mutatingLocketClient.
+func AddDryRunFlag(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&DryRun, "dry-run", false, "print the requests that would change the BBS or Locket without sending them")
+}
+
+type DryRunBBSClient struct {
+	bbs.Client
+	stdout io.Writer
+	target string
+}
+
+// NewDryRunBBSClient wraps a BBS client so that the methods that change the
+// BBS print a DryRunRequest to stdout and return without calling it. Reads
+// are passed through.
+func NewDryRunBBSClient(stdout io.Writer, bbsClient bbs.Client, target string) *DryRunBBSClient {
+	return &DryRunBBSClient{Client: bbsClient, stdout: stdout, target: target}
+}
+
+func (c *DryRunBBSClient) DesireLRP(logger lager.Logger, traceID string, desiredLRP *models.DesiredLRP) error {
+	current, err := c.currentDesiredLRP(logger, traceID, desiredLRP.GetProcessGuid())
+	if err != nil {
+		return err
+	}
+	return c.print("DesireLRP", &models.DesireLRPRequest{DesiredLrp: desiredLRP}, current)
+}
+
+func (c *DryRunBBSClient) UpdateDesiredLRP(logger lager.Logger, traceID string, processGuid string, update *models.DesiredLRPUpdate) error {
+	current, err := c.currentDesiredLRP(logger, traceID, processGuid)
+	if err != nil {
+		return err
+	}
+	return c.print("UpdateDesiredLRP", &models.UpdateDesiredLRPRequest{ProcessGuid: processGuid, Update: update}, current)
+}
+
+func (c *DryRunBBSClient) RemoveDesiredLRP(logger lager.Logger, traceID string, processGuid string) error {
+	current, err := c.currentDesiredLRP(logger, traceID, processGuid)
+	if err != nil {
+		return err
+	}
+	return c.print("RemoveDesiredLRP", &models.RemoveDesiredLRPRequest{ProcessGuid: processGuid}, current)
+}
+
+func (c *DryRunBBSClient) DesireTask(logger lager.Logger, traceID string, taskGuid, domain string, taskDefinition *models.TaskDefinition) error {
+	current, err := c.currentTask(logger, traceID, taskGuid)
+	if err != nil {
+		return err
+	}
+	return c.print("DesireTask", &models.DesireTaskRequest{TaskDefinition: taskDefinition, TaskGuid: taskGuid, Domain: domain}, current)
+}
+
+func (c *DryRunBBSClient) CancelTask(logger lager.Logger, traceID string, taskGuid string) error {
+	return c.printTaskGuidRequest(logger, traceID, "CancelTask", taskGuid)
+}
+
+func (c *DryRunBBSClient) ResolvingTask(logger lager.Logger, traceID string, taskGuid string) error {
+	return c.printTaskGuidRequest(logger, traceID, "ResolvingTask", taskGuid)
+}
+
+func (c *DryRunBBSClient) DeleteTask(logger lager.Logger, traceID string, taskGuid string) error {
+	return c.printTaskGuidRequest(logger, traceID, "DeleteTask", taskGuid)
+}
+
+func (c *DryRunBBSClient) RetireActualLRP(logger lager.Logger, traceID string, key *models.ActualLRPKey) error {
+	index := key.Index
+	current, err := c.Client.ActualLRPs(logger, traceID, models.ActualLRPFilter{ProcessGuid: key.ProcessGuid, Index: &index})
+	if err != nil {
+		return err
+	}
+	return c.print("RetireActualLRP", &models.RetireActualLRPRequest{ActualLrpKey: key}, current)
+}
+
+func (c *DryRunBBSClient) UpsertDomain(logger lager.Logger, traceID string, domain string, ttl time.Duration) error {
+	current, err := c.Client.Domains(logger, traceID)
+	if err != nil {
+		return err
+	}
+	return c.print("UpsertDomain", &models.UpsertDomainRequest{Domain: domain, Ttl: uint32(ttl.Seconds())}, current)
+}
+
+func (c *DryRunBBSClient) printTaskGuidRequest(logger lager.Logger, traceID, method, taskGuid string) error {
+	current, err := c.currentTask(logger, traceID, taskGuid)
+	if err != nil {
+		return err
+	}
+	return c.print(method, &models.TaskGuidRequest{TaskGuid: taskGuid}, current)
+}
+
+// currentDesiredLRP returns nil without an error when the desired LRP does
+// not exist yet.
+func (c *DryRunBBSClient) currentDesiredLRP(logger lager.Logger, traceID, processGuid string) (interface{}, error) {
+	desiredLRP, err := c.Client.DesiredLRPByProcessGuid(logger, traceID, processGuid)
+	if isResourceNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return desiredLRP, nil
+}
+
+func (c *DryRunBBSClient) currentTask(logger lager.Logger, traceID, taskGuid string) (interface{}, error) {
+	task, err := c.Client.TaskByGuid(logger, traceID, taskGuid)
+	if isResourceNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+func (c *DryRunBBSClient) print(method string, request, current interface{}) error {
+	return printDryRunRequest(c.stdout, &DryRunRequest{
+		Target:  c.target,
+		Method:  method,
+		Request: request,
+		Current: current,
+	})
+}
+
+type DryRunLocketClient struct {
+	locketmodels.LocketClient
+	stdout io.Writer
+	target string
+}
+
+// NewDryRunLocketClient wraps a Locket client so that Lock and Release print
+// a DryRunRequest to stdout and return without calling it.
+func NewDryRunLocketClient(stdout io.Writer, locketClient locketmodels.LocketClient, target string) *DryRunLocketClient {
+	return &DryRunLocketClient{LocketClient: locketClient, stdout: stdout, target: target}
+}
+
+func (c *DryRunLocketClient) Lock(ctx context.Context, req *locketmodels.LockRequest, opts ...grpc.CallOption) (*locketmodels.LockResponse, error) {
+	err := c.print(ctx, "Lock", req, req.GetResource().GetKey())
+	if err != nil {
+		return nil, err
+	}
+	return &locketmodels.LockResponse{}, nil
+}
+
+func (c *DryRunLocketClient) Release(ctx context.Context, req *locketmodels.ReleaseRequest, opts ...grpc.CallOption) (*locketmodels.ReleaseResponse, error) {
+	err := c.print(ctx, "Release", req, req.GetResource().GetKey())
+	if err != nil {
+		return nil, err
+	}
+	return &locketmodels.ReleaseResponse{}, nil
+}
+
+// print looks up the resource currently stored under the key. Locket reports
+// a missing resource as an error, so any error is treated as no resource.
+func (c *DryRunLocketClient) print(ctx context.Context, method string, request interface{}, key string) error {
+	dryRunRequest := &DryRunRequest{
+		Target:  c.target,
+		Method:  method,
+		Request: request,
+	}
+
+	resp, err := c.LocketClient.Fetch(ctx, &locketmodels.FetchRequest{Key: key})
+	if err == nil && resp.GetResource() != nil {
+		dryRunRequest.Current = resp.GetResource()
+	}
+
+	return printDryRunRequest(c.stdout, dryRunRequest)
+}
+
+func printDryRunRequest(stdout io.Writer, request *DryRunRequest) error {
+	encoder := newOutputEncoder(stdout)
+	err := encoder.Encode(request)
+	if err != nil {
+		return err
+	}
+	return encoder.Flush()
+}
@@ -0,0 +1,199 @@
+package commands_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"code.cloudfoundry.org/bbs/fake_bbs"
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/cfdot/commands"
+	locketmodels "code.cloudfoundry.org/locket/models"
+	"code.cloudfoundry.org/locket/models/modelsfakes"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("DryRun", func() {
+	var stdout, stderr *gbytes.Buffer
+
+	printed := func() map[string]interface{} {
+		var request map[string]interface{}
+		Expect(json.Unmarshal(stdout.Contents(), &request)).To(Succeed())
+		return request
+	}
+
+	BeforeEach(func() {
+		stdout = gbytes.NewBuffer()
+		stderr = gbytes.NewBuffer()
+	})
+
+	Describe("DryRunBBSClient", func() {
+		var (
+			fakeBBSClient *fake_bbs.FakeClient
+			bbsClient     *commands.DryRunBBSClient
+		)
+
+		BeforeEach(func() {
+			fakeBBSClient = &fake_bbs.FakeClient{}
+			bbsClient = commands.NewDryRunBBSClient(stdout, fakeBBSClient, "https://bbs.example.com:8889")
+		})
+
+		It("prints the update with the current desired lrp instead of sending it", func() {
+			fakeBBSClient.DesiredLRPByProcessGuidReturns(&models.DesiredLRP{ProcessGuid: "process-guid", Instances: 2}, nil)
+
+			err := commands.Scale(stdout, stderr, bbsClient, "process-guid", 3)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fakeBBSClient.UpdateDesiredLRPCallCount()).To(Equal(0))
+
+			request := printed()
+			Expect(request["target"]).To(Equal("https://bbs.example.com:8889"))
+			Expect(request["method"]).To(Equal("UpdateDesiredLRP"))
+			Expect(request["request"]).To(Equal(map[string]interface{}{
+				"process_guid": "process-guid",
+				"update":       map[string]interface{}{"instances": 3.0},
+			}))
+			Expect(request["current"]).To(HaveKeyWithValue("instances", 2.0))
+		})
+
+		It("omits the current desired lrp when there is none", func() {
+			fakeBBSClient.DesiredLRPByProcessGuidReturns(nil, models.ErrResourceNotFound)
+
+			err := commands.CreateDesiredLRP(stdout, stderr, bbsClient, []byte(`{"process_guid":"process-guid"}`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fakeBBSClient.DesireLRPCallCount()).To(Equal(0))
+
+			request := printed()
+			Expect(request["method"]).To(Equal("DesireLRP"))
+			Expect(request).NotTo(HaveKey("current"))
+		})
+
+		It("prints both requests of delete-task", func() {
+			fakeBBSClient.TaskByGuidReturns(&models.Task{TaskGuid: "task-guid", State: models.Task_Completed}, nil)
+
+			err := commands.DeleteTask(stdout, stderr, bbsClient, "task-guid")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fakeBBSClient.ResolvingTaskCallCount()).To(Equal(0))
+			Expect(fakeBBSClient.DeleteTaskCallCount()).To(Equal(0))
+
+			Expect(stdout).To(gbytes.Say(`"method":"ResolvingTask","request":{"task_guid":"task-guid"}`))
+			Expect(stdout).To(gbytes.Say(`"method":"DeleteTask","request":{"task_guid":"task-guid"}`))
+		})
+
+		It("prints the retirement with the current actual lrp", func() {
+			fakeBBSClient.DesiredLRPByProcessGuidReturns(&models.DesiredLRP{ProcessGuid: "process-guid", Domain: "domain"}, nil)
+			fakeBBSClient.ActualLRPsReturns([]*models.ActualLRP{
+				{ActualLRPKey: models.NewActualLRPKey("process-guid", 1, "domain"), State: models.ActualLRPStateRunning},
+			}, nil)
+
+			err := commands.RetireActualLRP(stdout, stderr, bbsClient, "process-guid", 1)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fakeBBSClient.RetireActualLRPCallCount()).To(Equal(0))
+
+			_, _, filter := fakeBBSClient.ActualLRPsArgsForCall(0)
+			Expect(filter.ProcessGuid).To(Equal("process-guid"))
+			Expect(*filter.Index).To(BeEquivalentTo(1))
+
+			request := printed()
+			Expect(request["method"]).To(Equal("RetireActualLRP"))
+			Expect(request["request"]).To(Equal(map[string]interface{}{
+				"actual_lrp_key": map[string]interface{}{"process_guid": "process-guid", "index": 1.0, "domain": "domain"},
+			}))
+			Expect(request["current"]).To(HaveLen(1))
+		})
+
+		It("prints the domain upsert with the current domains", func() {
+			fakeBBSClient.DomainsReturns([]string{"other-domain"}, nil)
+
+			err := commands.SetDomain(stdout, stderr, bbsClient, "domain", 40*time.Second)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fakeBBSClient.UpsertDomainCallCount()).To(Equal(0))
+
+			request := printed()
+			Expect(request["request"]).To(Equal(map[string]interface{}{"domain": "domain", "ttl": 40.0}))
+			Expect(request["current"]).To(Equal([]interface{}{"other-domain"}))
+		})
+
+		Context("when the current state cannot be fetched", func() {
+			BeforeEach(func() {
+				fakeBBSClient.TaskByGuidReturns(nil, models.ErrUnknownError)
+			})
+
+			It("returns the error", func() {
+				err := commands.CancelTaskByGuid(stdout, stderr, bbsClient, "task-guid")
+				Expect(err).To(Equal(models.ErrUnknownError))
+				Expect(fakeBBSClient.CancelTaskCallCount()).To(Equal(0))
+			})
+		})
+	})
+
+	Describe("DryRunLocketClient", func() {
+		var (
+			fakeLocketClient *modelsfakes.FakeLocketClient
+			locketClient     *commands.DryRunLocketClient
+		)
+
+		BeforeEach(func() {
+			fakeLocketClient = &modelsfakes.FakeLocketClient{}
+			locketClient = commands.NewDryRunLocketClient(stdout, fakeLocketClient, "locket.example.com:8891")
+		})
+
+		It("prints the lock request with the current resource instead of sending it", func() {
+			fakeLocketClient.FetchReturns(&locketmodels.FetchResponse{
+				Resource: &locketmodels.Resource{Key: "key", Owner: "other-owner"},
+			}, nil)
+
+			err := commands.ClaimLock(stdout, stderr, locketClient, "key", "owner", "value", 60)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fakeLocketClient.LockCallCount()).To(Equal(0))
+
+			_, fetchRequest, _ := fakeLocketClient.FetchArgsForCall(0)
+			Expect(fetchRequest.Key).To(Equal("key"))
+
+			request := printed()
+			Expect(request["target"]).To(Equal("locket.example.com:8891"))
+			Expect(request["method"]).To(Equal("Lock"))
+			Expect(request["request"]).To(HaveKeyWithValue("ttl_in_seconds", 60.0))
+			Expect(request["current"]).To(HaveKeyWithValue("owner", "other-owner"))
+		})
+
+		It("prints the release request without a current resource when there is none", func() {
+			fakeLocketClient.FetchReturns(nil, errors.New("resource-not-found"))
+
+			err := commands.ReleaseLock(stdout, stderr, locketClient, "key", "owner")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fakeLocketClient.ReleaseCallCount()).To(Equal(0))
+
+			request := printed()
+			Expect(request["method"]).To(Equal("Release"))
+			Expect(request).NotTo(HaveKey("current"))
+		})
+
+		It("passes reads through", func() {
+			fakeLocketClient.FetchAllReturns(&locketmodels.FetchAllResponse{}, nil)
+
+			_, err := locketClient.FetchAll(context.Background(), &locketmodels.FetchAllRequest{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fakeLocketClient.FetchAllCallCount()).To(Equal(1))
+		})
+	})
+
+	Describe("--dry-run", func() {
+		It("is only accepted by the commands that change the BBS or Locket", func() {
+			for _, name := range []string{"set-domain", "retire-actual-lrp", "claim-lock", "restore", "drain-cell"} {
+				cmd, _, err := commands.RootCmd.Find([]string{name})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(cmd.Flags().Lookup("dry-run")).NotTo(BeNil(), name)
+			}
+
+			for _, name := range []string{"actual-lrps", "cell-states", "lrp-events", "validate"} {
+				cmd, _, err := commands.RootCmd.Find([]string{name})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(cmd.Flags().Lookup("dry-run")).To(BeNil(), name)
+			}
+		})
+	})
+})
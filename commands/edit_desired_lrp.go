@@ -0,0 +1,230 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"code.cloudfoundry.org/bbs"
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/bbs/trace"
+	"code.cloudfoundry.org/cfdot/commands/helpers"
+	"github.com/spf13/cobra"
+)
+
+const defaultEditor = "vi"
+
+// EditableDesiredLRP holds the fields of a desired LRP that can be changed
+// with a models.DesiredLRPUpdate. It is the document opened in the editor.
+type EditableDesiredLRP struct {
+	Instances  int32                             `json:"instances"`
+	Routes     *models.Routes                    `json:"routes"`
+	Annotation string                            `json:"annotation"`
+	MetricTags map[string]*models.MetricTagValue `json:"metric_tags"`
+}
+
+// Editor edits the file at the given path in place.
+type Editor func(path string) error
+
+var errEditCancelled = errors.New("Edit cancelled, no changes made")
+
+var editDesiredLRPCmd = &cobra.Command{
+	Use:   "edit-desired-lrp PROCESS_GUID",
+	Short: "Edit a desired LRP in $EDITOR",
+	Long:  "Open the instances, routes, annotation and metric tags of the desired LRP in $EDITOR, then print the changes and update the desired LRP. Fails without updating it when the desired LRP was modified while it was being edited",
+	RunE:  editDesiredLRP,
+}
+
+func init() {
+	AddBBSAndTimeoutFlags(editDesiredLRPCmd)
+	AddDryRunFlag(editDesiredLRPCmd)
+	RootCmd.AddCommand(editDesiredLRPCmd)
+}
+
+func editDesiredLRP(cmd *cobra.Command, args []string) error {
+	processGuid, err := ValidateEditDesiredLRPArguments(args)
+	if err != nil {
+		return NewCFDotValidationError(cmd, err)
+	}
+
+	bbsClient, err := helpers.NewBBSClient(cmd, Config)
+	if err != nil {
+		return NewCFDotError(cmd, err)
+	}
+
+	bbsClient = mutatingBBSClient(cmd, bbsClient)
+
+	err = EditDesiredLRP(cmd.OutOrStdout(), cmd.OutOrStderr(), bbsClient, processGuid, runEditor)
+	if err == errEditCancelled {
+		fmt.Fprintln(cmd.OutOrStderr(), err.Error())
+		return nil
+	}
+	if err != nil {
+		return NewCFDotError(cmd, err)
+	}
+
+	return nil
+}
+
+func ValidateEditDesiredLRPArguments(args []string) (string, error) {
+	switch {
+	case len(args) < 1:
+		return "", errMissingArguments
+	case len(args) > 1:
+		return "", errExtraArguments
+	case args[0] == "":
+		return "", errInvalidProcessGuid
+	}
+	return args[0], nil
+}
+
+// EditDesiredLRP lets the editor change the updatable fields of the desired
+// LRP, prints the changed fields and updates the desired LRP. The edited
+// file is kept when the update fails, so that the changes are not lost.
+func EditDesiredLRP(stdout, stderr io.Writer, bbsClient bbs.Client, processGuid string, editor Editor) error {
+	traceID := trace.GenerateTraceID()
+	logger := trace.LoggerWithTraceInfo(globalLogger.Session("edit-desired-lrp"), traceID)
+
+	desiredLRP, err := bbsClient.DesiredLRPByProcessGuid(logger, traceID, processGuid)
+	if err != nil {
+		return err
+	}
+
+	original := &EditableDesiredLRP{
+		Instances:  desiredLRP.Instances,
+		Routes:     desiredLRP.Routes,
+		Annotation: desiredLRP.Annotation,
+		MetricTags: desiredLRP.MetricTags,
+	}
+	contents, err := json.MarshalIndent(original, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp("", "cfdot-edit-"+processGuid+"-*.json")
+	if err != nil {
+		return err
+	}
+	path := f.Name()
+	_, err = f.Write(append(contents, '\n'))
+	if err == nil {
+		err = f.Close()
+	}
+	if err != nil {
+		os.Remove(path)
+		return err
+	}
+
+	update, err := editDesiredLRPUpdate(stdout, editor, path, original)
+	if err == errEditCancelled {
+		os.Remove(path)
+		return err
+	}
+	if err == nil {
+		err = submitDesiredLRPUpdate(bbsClient, processGuid, desiredLRP.ModificationTag, update)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "The edited desired LRP was saved to %s\n", path)
+		return err
+	}
+
+	os.Remove(path)
+	return nil
+}
+
+func editDesiredLRPUpdate(stdout io.Writer, editor Editor, path string, original *EditableDesiredLRP) (*models.DesiredLRPUpdate, error) {
+	err := editor(path)
+	if err != nil {
+		return nil, err
+	}
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(contents))
+	decoder.DisallowUnknownFields()
+	edited := &EditableDesiredLRP{}
+	err = decoder.Decode(edited)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid edited desired LRP, only instances, routes, annotation and metric_tags can be edited: %s", err)
+	}
+
+	fields, err := diffFields(original, edited)
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return nil, errEditCancelled
+	}
+
+	encoder := newOutputEncoder(stdout)
+	for _, field := range fields {
+		err = encoder.Encode(field)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = encoder.Flush()
+	if err != nil {
+		return nil, err
+	}
+
+	update := newDesiredLRPUpdate(&models.DesiredLRP{
+		Instances:  edited.Instances,
+		Routes:     edited.Routes,
+		Annotation: edited.Annotation,
+		MetricTags: edited.MetricTags,
+	}, fields)
+	return update, update.Validate()
+}
+
+// submitDesiredLRPUpdate updates the desired LRP unless its modification tag
+// is no longer the one it had when it was fetched for editing.
+func submitDesiredLRPUpdate(bbsClient bbs.Client, processGuid string, tag *models.ModificationTag, update *models.DesiredLRPUpdate) error {
+	traceID := trace.GenerateTraceID()
+	logger := trace.LoggerWithTraceInfo(globalLogger.Session("submit-desired-lrp-update"), traceID)
+
+	current, err := bbsClient.DesiredLRPByProcessGuid(logger, traceID, processGuid)
+	if err != nil {
+		return err
+	}
+
+	if !modificationTagsEqual(current.ModificationTag, tag) {
+		return fmt.Errorf("Desired LRP %s was modified while it was being edited", processGuid)
+	}
+
+	return bbsClient.UpdateDesiredLRP(logger, traceID, processGuid, update)
+}
+
+func modificationTagsEqual(a, b *models.ModificationTag) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Epoch == b.Epoch && a.Index == b.Index
+}
+
+// runEditor opens the file in $EDITOR, or vi when it is not set.
+func runEditor(path string) error {
+	editor := strings.Fields(os.Getenv("EDITOR"))
+	if len(editor) == 0 {
+		editor = []string{defaultEditor}
+	}
+
+	cmd := exec.Command(editor[0], append(editor[1:], path)...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	err := cmd.Run()
+	if err != nil {
+		return fmt.Errorf("Failed to run editor %s: %s", editor[0], err)
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package commands_test
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"regexp"
+
+	"code.cloudfoundry.org/bbs/fake_bbs"
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/cfdot/commands"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("EditDesiredLRP", func() {
+	var (
+		fakeBBSClient  *fake_bbs.FakeClient
+		stdout, stderr *gbytes.Buffer
+		desiredLRP     *models.DesiredLRP
+		opened         commands.EditableDesiredLRP
+		editedPath     string
+		edit           func(*commands.EditableDesiredLRP)
+	)
+
+	editor := func(path string) error {
+		editedPath = path
+
+		contents, err := os.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(json.Unmarshal(contents, &opened)).To(Succeed())
+
+		edited := opened
+		edit(&edited)
+		contents, err = json.Marshal(edited)
+		Expect(err).NotTo(HaveOccurred())
+		return os.WriteFile(path, contents, 0600)
+	}
+
+	savedPath := func() string {
+		matches := regexp.MustCompile(`saved to (\S+)`).FindStringSubmatch(string(stderr.Contents()))
+		Expect(matches).To(HaveLen(2))
+		return matches[1]
+	}
+
+	BeforeEach(func() {
+		fakeBBSClient = &fake_bbs.FakeClient{}
+		stdout = gbytes.NewBuffer()
+		stderr = gbytes.NewBuffer()
+		editedPath = ""
+
+		desiredLRP = &models.DesiredLRP{
+			ProcessGuid:     "process-guid",
+			RootFs:          "preloaded:cflinuxfs4",
+			Instances:       2,
+			Annotation:      "old",
+			ModificationTag: &models.ModificationTag{Epoch: "epoch", Index: 1},
+		}
+		fakeBBSClient.DesiredLRPByProcessGuidReturns(desiredLRP, nil)
+
+		edit = func(e *commands.EditableDesiredLRP) {
+			e.Instances = 5
+		}
+	})
+
+	It("opens the updatable fields in the editor", func() {
+		err := commands.EditDesiredLRP(stdout, stderr, fakeBBSClient, "process-guid", editor)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(opened).To(Equal(commands.EditableDesiredLRP{Instances: 2, Annotation: "old"}))
+	})
+
+	It("prints the changes and updates the desired lrp", func() {
+		err := commands.EditDesiredLRP(stdout, stderr, fakeBBSClient, "process-guid", editor)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(stdout).To(gbytes.Say(`{"field":"instances","current":2,"desired":5}`))
+
+		Expect(fakeBBSClient.UpdateDesiredLRPCallCount()).To(Equal(1))
+		_, _, processGuid, update := fakeBBSClient.UpdateDesiredLRPArgsForCall(0)
+		Expect(processGuid).To(Equal("process-guid"))
+		Expect(update.GetInstances()).To(BeEquivalentTo(5))
+		Expect(update.AnnotationExists()).To(BeFalse())
+
+		Expect(editedPath).NotTo(BeAnExistingFile())
+	})
+
+	Context("when nothing is changed", func() {
+		BeforeEach(func() {
+			edit = func(*commands.EditableDesiredLRP) {}
+		})
+
+		It("does not update the desired lrp", func() {
+			err := commands.EditDesiredLRP(stdout, stderr, fakeBBSClient, "process-guid", editor)
+			Expect(err).To(MatchError("Edit cancelled, no changes made"))
+			Expect(fakeBBSClient.UpdateDesiredLRPCallCount()).To(Equal(0))
+			Expect(editedPath).NotTo(BeAnExistingFile())
+		})
+	})
+
+	Context("when the desired lrp is modified while it is being edited", func() {
+		BeforeEach(func() {
+			fakeBBSClient.DesiredLRPByProcessGuidReturnsOnCall(1, &models.DesiredLRP{
+				ProcessGuid:     "process-guid",
+				ModificationTag: &models.ModificationTag{Epoch: "epoch", Index: 2},
+			}, nil)
+		})
+
+		It("refuses to update it and keeps the edited file", func() {
+			err := commands.EditDesiredLRP(stdout, stderr, fakeBBSClient, "process-guid", editor)
+			Expect(err).To(MatchError("Desired LRP process-guid was modified while it was being edited"))
+			Expect(fakeBBSClient.UpdateDesiredLRPCallCount()).To(Equal(0))
+
+			Expect(savedPath()).To(Equal(editedPath))
+			Expect(editedPath).To(BeAnExistingFile())
+			Expect(os.Remove(editedPath)).To(Succeed())
+		})
+	})
+
+	Context("when a field that cannot be updated is added", func() {
+		It("refuses to update the desired lrp", func() {
+			err := commands.EditDesiredLRP(stdout, stderr, fakeBBSClient, "process-guid", func(path string) error {
+				editedPath = path
+				return os.WriteFile(path, []byte(`{"instances":3,"rootfs":"docker:///busybox"}`), 0600)
+			})
+			Expect(err).To(MatchError(ContainSubstring("only instances, routes, annotation and metric_tags can be edited")))
+			Expect(fakeBBSClient.UpdateDesiredLRPCallCount()).To(Equal(0))
+			Expect(os.Remove(editedPath)).To(Succeed())
+		})
+	})
+
+	Context("when the edited desired lrp is invalid", func() {
+		BeforeEach(func() {
+			edit = func(e *commands.EditableDesiredLRP) {
+				e.Instances = -1
+			}
+		})
+
+		It("refuses to update the desired lrp", func() {
+			err := commands.EditDesiredLRP(stdout, stderr, fakeBBSClient, "process-guid", editor)
+			Expect(err).To(HaveOccurred())
+			Expect(fakeBBSClient.UpdateDesiredLRPCallCount()).To(Equal(0))
+			Expect(os.Remove(editedPath)).To(Succeed())
+		})
+	})
+
+	Context("when the editor fails", func() {
+		It("returns the error", func() {
+			err := commands.EditDesiredLRP(stdout, stderr, fakeBBSClient, "process-guid", func(path string) error {
+				editedPath = path
+				return errors.New("editor failed")
+			})
+			Expect(err).To(MatchError("editor failed"))
+			Expect(fakeBBSClient.UpdateDesiredLRPCallCount()).To(Equal(0))
+			Expect(os.Remove(editedPath)).To(Succeed())
+		})
+	})
+
+	Context("when the desired lrp cannot be fetched", func() {
+		BeforeEach(func() {
+			fakeBBSClient.DesiredLRPByProcessGuidReturns(nil, models.ErrResourceNotFound)
+		})
+
+		It("returns the error without opening the editor", func() {
+			err := commands.EditDesiredLRP(stdout, stderr, fakeBBSClient, "process-guid", editor)
+			Expect(err).To(Equal(models.ErrResourceNotFound))
+			Expect(editedPath).To(BeEmpty())
+		})
+	})
+
+	Describe("ValidateEditDesiredLRPArguments", func() {
+		It("rejects missing, extra and empty arguments", func() {
+			_, err := commands.ValidateEditDesiredLRPArguments([]string{})
+			Expect(err).To(MatchError("Missing arguments"))
+
+			_, err = commands.ValidateEditDesiredLRPArguments([]string{"a", "b"})
+			Expect(err).To(MatchError("Too many arguments specified"))
+
+			_, err = commands.ValidateEditDesiredLRPArguments([]string{""})
+			Expect(err).To(MatchError("Process guid should be non empty string"))
+		})
+	})
+})
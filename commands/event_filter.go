@@ -0,0 +1,206 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"code.cloudfoundry.org/bbs/models"
+)
+
+var lrpEventTypes = []string{
+	models.EventTypeDesiredLRPCreated,
+	models.EventTypeDesiredLRPChanged,
+	models.EventTypeDesiredLRPRemoved,
+	//lint:ignore SA1019 - cfdot needs to process deprecated ActualLRP data until it is removed from BBS
+	models.EventTypeActualLRPCreated,
+	//lint:ignore SA1019 - cfdot needs to process deprecated ActualLRP data until it is removed from BBS
+	models.EventTypeActualLRPChanged,
+	//lint:ignore SA1019 - cfdot needs to process deprecated ActualLRP data until it is removed from BBS
+	models.EventTypeActualLRPRemoved,
+	models.EventTypeActualLRPCrashed,
+	models.EventTypeActualLRPInstanceCreated,
+	models.EventTypeActualLRPInstanceChanged,
+	models.EventTypeActualLRPInstanceRemoved,
+}
+
+var actualLRPGroupEventTypes = []string{
+	//lint:ignore SA1019 - cfdot needs to process deprecated ActualLRP data until it is removed from BBS
+	models.EventTypeActualLRPCreated,
+	//lint:ignore SA1019 - cfdot needs to process deprecated ActualLRP data until it is removed from BBS
+	models.EventTypeActualLRPChanged,
+	//lint:ignore SA1019 - cfdot needs to process deprecated ActualLRP data until it is removed from BBS
+	models.EventTypeActualLRPRemoved,
+}
+
+var taskEventTypes = []string{
+	models.EventTypeTaskCreated,
+	models.EventTypeTaskChanged,
+	models.EventTypeTaskRemoved,
+}
+
+var taskStates = []models.Task_State{
+	models.Task_Pending,
+	models.Task_Running,
+	models.Task_Completed,
+	models.Task_Resolving,
+}
+
+// EventFilter selects the events printed by lrp-events and task-events. Empty
+// fields match every event. States only match task events.
+type EventFilter struct {
+	ProcessGuids []string
+	Domain       string
+	Types        []string
+	States       []models.Task_State
+}
+
+// flags
+var (
+	eventFilterProcessGuidsFlag []string
+	eventFilterDomainFlag       string
+	eventFilterTypesFlag        []string
+	eventFilterStatesFlag       []string
+)
+
+// newEventFilter returns the filter configured by the flags, or nil when no
+// filter flag is set.
+func newEventFilter(validTypes []string) (*EventFilter, error) {
+	for _, eventType := range eventFilterTypesFlag {
+		if !contains(validTypes, eventType) {
+			return nil, fmt.Errorf("'%s' is not a valid event type. Please specify one of: %s", eventType, strings.Join(validTypes, ", "))
+		}
+	}
+
+	states := []models.Task_State{}
+	for _, name := range eventFilterStatesFlag {
+		state, ok := parseTaskState(name)
+		if !ok {
+			names := []string{}
+			for _, state := range taskStates {
+				names = append(names, state.String())
+			}
+			return nil, fmt.Errorf("'%s' is not a valid task state. Please specify one of: %s", name, strings.Join(names, ", "))
+		}
+		states = append(states, state)
+	}
+
+	if len(eventFilterProcessGuidsFlag) == 0 && eventFilterDomainFlag == "" && len(eventFilterTypesFlag) == 0 && len(states) == 0 {
+		return nil, nil
+	}
+
+	return &EventFilter{
+		ProcessGuids: eventFilterProcessGuidsFlag,
+		Domain:       eventFilterDomainFlag,
+		Types:        eventFilterTypesFlag,
+		States:       states,
+	}, nil
+}
+
+func parseTaskState(name string) (models.Task_State, bool) {
+	for _, state := range taskStates {
+		if strings.EqualFold(name, state.String()) {
+			return state, true
+		}
+	}
+	return models.Task_Invalid, false
+}
+
+// Matches reports whether the event passes the filter. A nil filter matches
+// every event.
+func (f *EventFilter) Matches(event models.Event) bool {
+	if f == nil {
+		return true
+	}
+
+	if len(f.Types) > 0 && !contains(f.Types, event.EventType()) {
+		return false
+	}
+
+	key, task := eventSubject(event)
+	domain := key.Domain
+	if task != nil {
+		domain = task.Domain
+	}
+	if f.Domain != "" && domain != f.Domain {
+		return false
+	}
+
+	if len(f.ProcessGuids) > 0 && (task != nil || !contains(f.ProcessGuids, key.ProcessGuid)) {
+		return false
+	}
+
+	if len(f.States) > 0 {
+		if task == nil {
+			return false
+		}
+		for _, state := range f.States {
+			if task.State == state {
+				return true
+			}
+		}
+		return false
+	}
+
+	return true
+}
+
+// eventSubject returns the key of the LRP or the task an event is about. For
+// changed events it is the value after the change.
+func eventSubject(event models.Event) (models.ActualLRPKey, *models.Task) {
+	switch e := event.(type) {
+	case *models.DesiredLRPCreatedEvent:
+		return desiredLRPKey(e.DesiredLrp), nil
+	case *models.DesiredLRPChangedEvent:
+		return desiredLRPKey(e.After), nil
+	case *models.DesiredLRPRemovedEvent:
+		return desiredLRPKey(e.DesiredLrp), nil
+	//lint:ignore SA1019 - cfdot needs to process deprecated ActualLRP data until it is removed from BBS
+	case *models.ActualLRPCreatedEvent:
+		return actualLRPGroupKey(e.ActualLrpGroup), nil
+	//lint:ignore SA1019 - cfdot needs to process deprecated ActualLRP data until it is removed from BBS
+	case *models.ActualLRPChangedEvent:
+		return actualLRPGroupKey(e.After), nil
+	//lint:ignore SA1019 - cfdot needs to process deprecated ActualLRP data until it is removed from BBS
+	case *models.ActualLRPRemovedEvent:
+		return actualLRPGroupKey(e.ActualLrpGroup), nil
+	case *models.ActualLRPCrashedEvent:
+		return e.ActualLRPKey, nil
+	case *models.ActualLRPInstanceCreatedEvent:
+		return actualLRPKey(e.ActualLrp), nil
+	case *models.ActualLRPInstanceChangedEvent:
+		return e.ActualLRPKey, nil
+	case *models.ActualLRPInstanceRemovedEvent:
+		return actualLRPKey(e.ActualLrp), nil
+	case *models.TaskCreatedEvent:
+		return models.ActualLRPKey{}, e.Task
+	case *models.TaskChangedEvent:
+		return models.ActualLRPKey{}, e.After
+	case *models.TaskRemovedEvent:
+		return models.ActualLRPKey{}, e.Task
+	}
+	return models.ActualLRPKey{}, nil
+}
+
+func desiredLRPKey(desiredLRP *models.DesiredLRP) models.ActualLRPKey {
+	if desiredLRP == nil {
+		return models.ActualLRPKey{}
+	}
+	return models.ActualLRPKey{ProcessGuid: desiredLRP.ProcessGuid, Domain: desiredLRP.Domain}
+}
+
+func actualLRPGroupKey(group *models.ActualLRPGroup) models.ActualLRPKey {
+	if group == nil {
+		return models.ActualLRPKey{}
+	}
+	if group.Instance != nil {
+		return group.Instance.ActualLRPKey
+	}
+	return actualLRPKey(group.Evacuating)
+}
+
+func actualLRPKey(actualLRP *models.ActualLRP) models.ActualLRPKey {
+	if actualLRP == nil {
+		return models.ActualLRPKey{}
+	}
+	return actualLRP.ActualLRPKey
+}
@@ -0,0 +1,74 @@
+package commands_test
+
+import (
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/cfdot/commands"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("EventFilter", func() {
+	var (
+		filter     *commands.EventFilter
+		desiredLRP *models.DesiredLRP
+		actualLRP  *models.ActualLRP
+		task       *models.Task
+	)
+
+	BeforeEach(func() {
+		filter = &commands.EventFilter{}
+		desiredLRP = &models.DesiredLRP{ProcessGuid: "process-guid", Domain: "cf-apps"}
+		actualLRP = &models.ActualLRP{ActualLRPKey: models.NewActualLRPKey("process-guid", 0, "cf-apps")}
+		task = &models.Task{TaskGuid: "task-guid", Domain: "cf-tasks", State: models.Task_Running}
+	})
+
+	It("matches every event when it is nil or empty", func() {
+		var nilFilter *commands.EventFilter
+		Expect(nilFilter.Matches(models.NewTaskCreatedEvent(task))).To(BeTrue())
+		Expect(filter.Matches(models.NewDesiredLRPRemovedEvent(desiredLRP, "trace-id"))).To(BeTrue())
+	})
+
+	It("matches the process guid and domain of desired and actual lrp events", func() {
+		filter.ProcessGuids = []string{"other-guid", "process-guid"}
+		filter.Domain = "cf-apps"
+
+		Expect(filter.Matches(models.NewDesiredLRPCreatedEvent(desiredLRP, "trace-id"))).To(BeTrue())
+		Expect(filter.Matches(models.NewDesiredLRPChangedEvent(desiredLRP, desiredLRP, "trace-id"))).To(BeTrue())
+		Expect(filter.Matches(models.NewActualLRPInstanceCreatedEvent(actualLRP, "trace-id"))).To(BeTrue())
+		Expect(filter.Matches(models.NewActualLRPInstanceChangedEvent(actualLRP, actualLRP, "trace-id"))).To(BeTrue())
+		Expect(filter.Matches(models.NewActualLRPCrashedEvent(actualLRP, actualLRP))).To(BeTrue())
+		//lint:ignore SA1019 - cfdot needs to process deprecated ActualLRP data until it is removed from BBS
+		Expect(filter.Matches(models.NewActualLRPCreatedEvent(&models.ActualLRPGroup{Evacuating: actualLRP}))).To(BeTrue())
+		Expect(filter.Matches(models.NewTaskCreatedEvent(task))).To(BeFalse())
+
+		filter.Domain = "other-domain"
+		Expect(filter.Matches(models.NewActualLRPInstanceRemovedEvent(actualLRP, "trace-id"))).To(BeFalse())
+
+		filter.Domain = ""
+		filter.ProcessGuids = []string{"other-guid"}
+		Expect(filter.Matches(models.NewDesiredLRPRemovedEvent(desiredLRP, "trace-id"))).To(BeFalse())
+	})
+
+	It("matches the event type", func() {
+		filter.Types = []string{models.EventTypeActualLRPCrashed, models.EventTypeActualLRPInstanceRemoved}
+
+		Expect(filter.Matches(models.NewActualLRPCrashedEvent(actualLRP, actualLRP))).To(BeTrue())
+		Expect(filter.Matches(models.NewActualLRPInstanceRemovedEvent(actualLRP, "trace-id"))).To(BeTrue())
+		Expect(filter.Matches(models.NewActualLRPInstanceCreatedEvent(actualLRP, "trace-id"))).To(BeFalse())
+	})
+
+	It("matches the domain and the state of tasks after the change", func() {
+		filter.Domain = "cf-tasks"
+		filter.States = []models.Task_State{models.Task_Running, models.Task_Completed}
+
+		pending := &models.Task{TaskGuid: "task-guid", Domain: "cf-tasks", State: models.Task_Pending}
+		Expect(filter.Matches(models.NewTaskChangedEvent(pending, task))).To(BeTrue())
+		Expect(filter.Matches(models.NewTaskChangedEvent(task, pending))).To(BeFalse())
+		Expect(filter.Matches(models.NewTaskRemovedEvent(task))).To(BeTrue())
+		Expect(filter.Matches(models.NewDesiredLRPCreatedEvent(desiredLRP, "trace-id"))).To(BeFalse())
+
+		filter.Domain = "cf-apps"
+		Expect(filter.Matches(models.NewTaskCreatedEvent(task))).To(BeFalse())
+	})
+})
@@ -0,0 +1,297 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/bbs/events"
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/lager/v3"
+)
+
+// RecordedEvent is a line of a file written by --record. Stream reconnected
+// markers are recorded without data.
+type RecordedEvent struct {
+	Timestamp time.Time       `json:"timestamp"`
+	Type      string          `json:"type"`
+	Data      json.RawMessage `json:"data,omitempty"`
+}
+
+var recordedEventTypes = map[string]func() models.Event{
+	models.EventTypeDesiredLRPCreated: func() models.Event { return &models.DesiredLRPCreatedEvent{} },
+	models.EventTypeDesiredLRPChanged: func() models.Event { return &models.DesiredLRPChangedEvent{} },
+	models.EventTypeDesiredLRPRemoved: func() models.Event { return &models.DesiredLRPRemovedEvent{} },
+	//lint:ignore SA1019 - cfdot needs to process deprecated ActualLRP data until it is removed from BBS
+	models.EventTypeActualLRPCreated: func() models.Event { return &models.ActualLRPCreatedEvent{} },
+	//lint:ignore SA1019 - cfdot needs to process deprecated ActualLRP data until it is removed from BBS
+	models.EventTypeActualLRPChanged: func() models.Event { return &models.ActualLRPChangedEvent{} },
+	//lint:ignore SA1019 - cfdot needs to process deprecated ActualLRP data until it is removed from BBS
+	models.EventTypeActualLRPRemoved:         func() models.Event { return &models.ActualLRPRemovedEvent{} },
+	models.EventTypeActualLRPCrashed:         func() models.Event { return &models.ActualLRPCrashedEvent{} },
+	models.EventTypeActualLRPInstanceCreated: func() models.Event { return &models.ActualLRPInstanceCreatedEvent{} },
+	models.EventTypeActualLRPInstanceChanged: func() models.Event { return &models.ActualLRPInstanceChangedEvent{} },
+	models.EventTypeActualLRPInstanceRemoved: func() models.Event { return &models.ActualLRPInstanceRemovedEvent{} },
+	models.EventTypeTaskCreated:              func() models.Event { return &models.TaskCreatedEvent{} },
+	models.EventTypeTaskChanged:              func() models.Event { return &models.TaskChangedEvent{} },
+	models.EventTypeTaskRemoved:              func() models.Event { return &models.TaskRemovedEvent{} },
+}
+
+// Event decodes the recorded event. It returns nil for stream reconnected
+// markers.
+func (e *RecordedEvent) Event() (models.Event, error) {
+	if e.Type == EventTypeStreamReconnected {
+		return nil, nil
+	}
+
+	newEvent, ok := recordedEventTypes[e.Type]
+	if !ok {
+		return nil, fmt.Errorf("Unknown event type '%s'", e.Type)
+	}
+	event := newEvent()
+	err := json.Unmarshal(e.Data, event)
+	if err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// EventRecorder writes the events printed by lrp-events and task-events to
+// the file given with --record.
+type EventRecorder struct {
+	file *os.File
+	err  error
+}
+
+func CreateEventRecorder(path string) (*EventRecorder, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to create recording '%s': %s", path, err)
+	}
+	return &EventRecorder{file: file}, nil
+}
+
+// Record appends the event with the current time. The first error is kept
+// and returned by every later call.
+func (r *EventRecorder) Record(event LRPEvent) error {
+	if r.err == nil {
+		r.err = r.record(event)
+	}
+	return r.err
+}
+
+func (r *EventRecorder) record(event LRPEvent) error {
+	recorded := RecordedEvent{Timestamp: time.Now().UTC(), Type: event.Type}
+	if event.Data != nil {
+		data, err := json.Marshal(event.Data)
+		if err != nil {
+			return err
+		}
+		recorded.Data = data
+	}
+
+	line, err := json.Marshal(recorded)
+	if err != nil {
+		return err
+	}
+	_, err = r.file.Write(append(line, '\n'))
+	if err != nil {
+		return fmt.Errorf("Failed to record event to '%s': %s", r.file.Name(), err)
+	}
+	return nil
+}
+
+// Close is a no-op on a nil recorder, which is used when --record is not set.
+func (r *EventRecorder) Close() error {
+	if r == nil {
+		return nil
+	}
+	return r.file.Close()
+}
+
+// recordingEncoder records every event before printing it. Recording errors
+// are returned by Flush so that they end the stream instead of only being
+// logged like encoding errors.
+type recordingEncoder struct {
+	Encoder
+	recorder *EventRecorder
+}
+
+func newEventEncoder(stdout io.Writer, recorder *EventRecorder) Encoder {
+	encoder := newOutputEncoder(stdout)
+	if recorder == nil {
+		return encoder
+	}
+	return &recordingEncoder{Encoder: encoder, recorder: recorder}
+}
+
+func (e *recordingEncoder) Encode(v interface{}) error {
+	if event, ok := v.(LRPEvent); ok {
+		_ = e.recorder.Record(event)
+	}
+	return e.Encoder.Encode(v)
+}
+
+func (e *recordingEncoder) EncodeUnfiltered(v interface{}) error {
+	if event, ok := v.(LRPEvent); ok {
+		_ = e.recorder.Record(event)
+	}
+	return encodeUnfiltered(e.Encoder, v)
+}
+
+func (e *recordingEncoder) Flush() error {
+	if e.recorder.err != nil {
+		return e.recorder.err
+	}
+	return e.Encoder.Flush()
+}
+
+// EventRecording reads a file written by --record.
+type EventRecording struct {
+	file    *os.File
+	decoder *json.Decoder
+	count   int
+}
+
+func OpenEventRecording(path string) (*EventRecording, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to open recording '%s': %s", path, err)
+	}
+	return &EventRecording{file: file, decoder: json.NewDecoder(file)}, nil
+}
+
+// Next returns the next recorded event and the event decoded from it, which
+// is nil for stream reconnected markers. It returns io.EOF after the last
+// recorded event.
+func (r *EventRecording) Next() (*RecordedEvent, models.Event, error) {
+	if !r.decoder.More() {
+		return nil, nil, io.EOF
+	}
+
+	r.count++
+	recorded := &RecordedEvent{}
+	err := r.decoder.Decode(recorded)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Invalid event %d in recording '%s': %s", r.count, r.file.Name(), err)
+	}
+
+	event, err := recorded.Event()
+	if err != nil {
+		return nil, nil, fmt.Errorf("Invalid event %d in recording '%s': %s", r.count, r.file.Name(), err)
+	}
+	return recorded, event, nil
+}
+
+func (r *EventRecording) Close() error {
+	return r.file.Close()
+}
+
+// replayEventSource is an events.EventSource that returns the events of the
+// given types from a recording, as fast as they can be read. With a cell id
+// it only returns the actual LRP events of instances on that cell.
+type replayEventSource struct {
+	recording *EventRecording
+	types     []string
+	cellID    string
+}
+
+func (s *replayEventSource) Next() (models.Event, error) {
+	for {
+		recorded, event, err := s.recording.Next()
+		if err != nil {
+			return nil, err
+		}
+		if !contains(s.types, recorded.Type) {
+			continue
+		}
+		if s.cellID != "" && !actualLRPEventOnCell(event, s.cellID) {
+			continue
+		}
+		return event, nil
+	}
+}
+
+func (s *replayEventSource) Close() error {
+	return s.recording.Close()
+}
+
+// actualLRPEventOnCell reports whether the event is about an instance on the
+// cell, like the BBS does for subscriptions by cell id. Desired LRP events
+// are not about a cell.
+func actualLRPEventOnCell(event models.Event, cellID string) bool {
+	switch e := event.(type) {
+	//lint:ignore SA1019 - cfdot needs to process deprecated ActualLRP data until it is removed from BBS
+	case *models.ActualLRPCreatedEvent:
+		return actualLRPGroupOnCell(e.ActualLrpGroup, cellID)
+	//lint:ignore SA1019 - cfdot needs to process deprecated ActualLRP data until it is removed from BBS
+	case *models.ActualLRPChangedEvent:
+		return actualLRPGroupOnCell(e.Before, cellID) || actualLRPGroupOnCell(e.After, cellID)
+	//lint:ignore SA1019 - cfdot needs to process deprecated ActualLRP data until it is removed from BBS
+	case *models.ActualLRPRemovedEvent:
+		return actualLRPGroupOnCell(e.ActualLrpGroup, cellID)
+	case *models.ActualLRPCrashedEvent:
+		return e.CellId == cellID
+	case *models.ActualLRPInstanceCreatedEvent:
+		return e.ActualLrp != nil && e.ActualLrp.CellId == cellID
+	case *models.ActualLRPInstanceChangedEvent:
+		return e.CellId == cellID
+	case *models.ActualLRPInstanceRemovedEvent:
+		return e.ActualLrp != nil && e.ActualLrp.CellId == cellID
+	}
+	return false
+}
+
+func actualLRPGroupOnCell(group *models.ActualLRPGroup, cellID string) bool {
+	if group == nil {
+		return false
+	}
+	return (group.Instance != nil && group.Instance.CellId == cellID) ||
+		(group.Evacuating != nil && group.Evacuating.CellId == cellID)
+}
+
+// ReplayBBSClient serves the event subscriptions of analysis commands from a
+// recording instead of the BBS. It only implements the subscriptions, as
+// there is no BBS behind it.
+type ReplayBBSClient struct {
+	path string
+}
+
+func NewReplayBBSClient(path string) (*ReplayBBSClient, error) {
+	recording, err := OpenEventRecording(path)
+	if err != nil {
+		return nil, err
+	}
+	recording.Close()
+	return &ReplayBBSClient{path: path}, nil
+}
+
+func (c *ReplayBBSClient) subscribe(types []string, cellID string) (events.EventSource, error) {
+	recording, err := OpenEventRecording(c.path)
+	if err != nil {
+		return nil, err
+	}
+	return &replayEventSource{recording: recording, types: types, cellID: cellID}, nil
+}
+
+// SubscribeToInstanceEventsByCellID replays the events of the instance event
+// stream, which are the LRP events that are not deprecated.
+func (c *ReplayBBSClient) SubscribeToInstanceEventsByCellID(logger lager.Logger, cellID string) (events.EventSource, error) {
+	types := []string{}
+	for _, eventType := range lrpEventTypes {
+		if !contains(actualLRPGroupEventTypes, eventType) {
+			types = append(types, eventType)
+		}
+	}
+	return c.subscribe(types, cellID)
+}
+
+func (c *ReplayBBSClient) SubscribeToEventsByCellID(logger lager.Logger, cellID string) (events.EventSource, error) {
+	return c.subscribe(actualLRPGroupEventTypes, cellID)
+}
+
+func (c *ReplayBBSClient) SubscribeToTaskEvents(logger lager.Logger) (events.EventSource, error) {
+	return c.subscribe(taskEventTypes, "")
+}
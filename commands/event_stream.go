@@ -0,0 +1,222 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/bbs/events"
+	"code.cloudfoundry.org/bbs/models"
+	"github.com/spf13/cobra"
+)
+
+// EventTypeStreamReconnected is the type of the marker printed after an event
+// stream was resubscribed. Events may have been missed before the marker.
+const EventTypeStreamReconnected = "stream_reconnected"
+
+const eventStreamInitialBackoff = time.Second
+
+// EventStreamBackoff is the exponential backoff used to resubscribe to an
+// event stream that failed. The wait starts at Initial and doubles after
+// every failed attempt up to Max.
+type EventStreamBackoff struct {
+	Initial time.Duration
+	Max     time.Duration
+}
+
+var errInvalidReconnectMaxBackoff = errors.New("--reconnect-max-backoff must be a positive duration")
+
+// flags
+var (
+	eventStreamReconnectFlag           bool
+	eventStreamReconnectMaxBackoffFlag time.Duration
+	eventStreamRecordFlag              string
+)
+
+func AddEventStreamFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&eventStreamReconnectFlag, "reconnect", false, "resubscribe with exponential backoff when the event stream fails instead of exiting")
+	cmd.Flags().DurationVar(&eventStreamReconnectMaxBackoffFlag, "reconnect-max-backoff", 30*time.Second, "maximum time to wait between attempts to resubscribe")
+	cmd.Flags().StringVar(&eventStreamRecordFlag, "record", "", "also write the printed events with their time to the given file, which can be replayed with the replay command")
+}
+
+// eventStreamBackoff returns the backoff configured by the flags, or nil when
+// --reconnect is not set.
+func eventStreamBackoff() (*EventStreamBackoff, error) {
+	if !eventStreamReconnectFlag {
+		return nil, nil
+	}
+	if eventStreamReconnectMaxBackoffFlag <= 0 {
+		return nil, errInvalidReconnectMaxBackoff
+	}
+	initial := eventStreamInitialBackoff
+	if initial > eventStreamReconnectMaxBackoffFlag {
+		initial = eventStreamReconnectMaxBackoffFlag
+	}
+	return &EventStreamBackoff{Initial: initial, Max: eventStreamReconnectMaxBackoffFlag}, nil
+}
+
+// eventStreamRecorder returns the recorder configured by --record, or nil when
+// it is not set.
+func eventStreamRecorder() (*EventRecorder, error) {
+	if eventStreamRecordFlag == "" {
+		return nil, nil
+	}
+	return CreateEventRecorder(eventStreamRecordFlag)
+}
+
+// eventStreamReconnector resubscribes to a single event stream. The backoff
+// grows while attempts keep failing and is reset once the new stream
+// delivers an event.
+type eventStreamReconnector struct {
+	stderr    io.Writer
+	backoff   EventStreamBackoff
+	subscribe func() (events.EventSource, error)
+	wait      time.Duration
+}
+
+func newEventStreamReconnector(stderr io.Writer, backoff EventStreamBackoff, subscribe func() (events.EventSource, error)) *eventStreamReconnector {
+	return &eventStreamReconnector{stderr: stderr, backoff: backoff, subscribe: subscribe}
+}
+
+// reconnect waits for the backoff and subscribes again until it succeeds.
+// streamErr is the error that ended the previous stream. It returns nil once
+// done is closed.
+func (r *eventStreamReconnector) reconnect(streamErr error, done <-chan struct{}) events.EventSource {
+	reason := fmt.Sprintf("Event stream failed: %s", streamErr)
+	if streamErr == io.EOF {
+		reason = "Event stream was closed"
+	}
+
+	for {
+		r.nextWait()
+		fmt.Fprintf(r.stderr, "%s, resubscribing in %s\n", reason, r.wait)
+		select {
+		case <-time.After(r.wait):
+		case <-done:
+			return nil
+		}
+
+		es, err := r.subscribe()
+		if err == nil {
+			return es
+		}
+		reason = fmt.Sprintf("Failed to resubscribe to event stream: %s", models.ConvertError(err))
+	}
+}
+
+func (r *eventStreamReconnector) nextWait() {
+	switch {
+	case r.wait == 0:
+		r.wait = r.backoff.Initial
+	case r.wait*2 > r.backoff.Max:
+		r.wait = r.backoff.Max
+	default:
+		r.wait *= 2
+	}
+}
+
+func (r *eventStreamReconnector) reset() {
+	r.wait = 0
+}
+
+// eventStreamReader reads an event stream in its own goroutine, so that a
+// stream waiting to be resubscribed does not hold up the other streams of a
+// command. Without a reconnector the error that ends the stream is sent to
+// errs. With one the stream is resubscribed and a nil event is sent to
+// events once it is, to mark the events that may have been missed.
+type eventStreamReader struct {
+	events chan models.Event
+	errs   chan error
+
+	reconnector *eventStreamReconnector
+	done        chan struct{}
+
+	lock sync.Mutex
+	es   events.EventSource
+}
+
+func newEventStreamReader(es events.EventSource, reconnector *eventStreamReconnector) *eventStreamReader {
+	r := &eventStreamReader{
+		events:      make(chan models.Event),
+		errs:        make(chan error),
+		reconnector: reconnector,
+		done:        make(chan struct{}),
+		es:          es,
+	}
+	go r.run()
+	return r
+}
+
+func (r *eventStreamReader) run() {
+	for {
+		r.lock.Lock()
+		es := r.es
+		r.lock.Unlock()
+
+		event, err := es.Next()
+		switch {
+		case err == nil:
+			if r.reconnector != nil {
+				r.reconnector.reset()
+			}
+		case r.reconnector == nil:
+			select {
+			case r.errs <- err:
+			case <-r.done:
+			}
+			return
+		default:
+			es = r.reconnector.reconnect(err, r.done)
+			if es == nil || !r.replace(es) {
+				return
+			}
+			event = nil
+		}
+
+		select {
+		case r.events <- event:
+		case <-r.done:
+			return
+		}
+	}
+}
+
+// replace closes the failed stream and reads from es instead. It returns
+// false, and closes es, when the reader was closed in the meantime.
+func (r *eventStreamReader) replace(es events.EventSource) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	select {
+	case <-r.done:
+		es.Close()
+		return false
+	default:
+	}
+
+	r.es.Close()
+	r.es = es
+	return true
+}
+
+// Close stops the reader and closes the stream it is reading.
+func (r *eventStreamReader) Close() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	close(r.done)
+	r.es.Close()
+}
+
+// printStreamReconnected prints the marker with the encoder of the events,
+// in the selected output format, but past --where and --fields so that they
+// cannot drop it.
+func printStreamReconnected(encoder Encoder) error {
+	err := encodeUnfiltered(encoder, LRPEvent{Type: EventTypeStreamReconnected})
+	if err != nil {
+		return err
+	}
+	return encoder.Flush()
+}
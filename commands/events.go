@@ -0,0 +1,277 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sort"
+	"time"
+
+	"code.cloudfoundry.org/bbs"
+	"code.cloudfoundry.org/bbs/events"
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/cfdot/commands/helpers"
+	locketmodels "code.cloudfoundry.org/locket/models"
+	"github.com/spf13/cobra"
+)
+
+const (
+	EventSourceLRP    = "lrp"
+	EventSourceTask   = "task"
+	EventSourceLocket = "locket"
+)
+
+// UnifiedEvent is a line printed by the events command. Timestamp is the time
+// cfdot received the event, which orders the lines of all the sources.
+type UnifiedEvent struct {
+	Timestamp time.Time   `json:"timestamp"`
+	Source    string      `json:"source"`
+	Type      string      `json:"type"`
+	Data      interface{} `json:"data"`
+}
+
+// LocketResourceChange is the data of a locket event. Before is nil for
+// created resources and After is nil for removed ones.
+type LocketResourceChange struct {
+	Before *locketmodels.Resource `json:"before,omitempty"`
+	After  *locketmodels.Resource `json:"after,omitempty"`
+}
+
+var errInvalidLocketPollInterval = errors.New("--locket-poll-interval must be a positive duration")
+
+// flags
+var (
+	eventsLocketPollIntervalFlag time.Duration
+)
+
+var eventsCmd = &cobra.Command{
+	Use:   "events",
+	Short: "Subscribe to BBS LRP and task events and Locket changes",
+	Long:  "Print the BBS actual LRP instance and task events and the Locket lock and presence changes, found by polling Locket, as one stream ordered by the time they were received. The source field of every line is lrp, task or locket",
+	RunE:  eventsRunE,
+}
+
+func init() {
+	AddBBSAndLocketFlags(eventsCmd)
+	eventsCmd.Flags().DurationVar(&eventsLocketPollIntervalFlag, "locket-poll-interval", 5*time.Second, "interval at which the locks and presences are fetched from Locket")
+	RootCmd.AddCommand(eventsCmd)
+}
+
+func eventsRunE(cmd *cobra.Command, args []string) error {
+	err := ValidateEventsArguments(args)
+	if err != nil {
+		return NewCFDotValidationError(cmd, err)
+	}
+
+	bbsClient, err := helpers.NewBBSClient(cmd, Config)
+	if err != nil {
+		return NewCFDotError(cmd, err)
+	}
+
+	logger := globalLogger.Session("locket-client")
+	locketClient, err := helpers.NewLocketClient(logger, cmd, Config)
+	if err != nil {
+		return NewCFDotComponentError(cmd, err)
+	}
+
+	err = Events(cmd.OutOrStdout(), cmd.OutOrStderr(), bbsClient, locketClient, eventsLocketPollIntervalFlag)
+	if err != nil {
+		return NewCFDotError(cmd, err)
+	}
+	return nil
+}
+
+func ValidateEventsArguments(args []string) error {
+	switch {
+	case len(args) > 0:
+		return errExtraArguments
+	case eventsLocketPollIntervalFlag <= 0:
+		return errInvalidLocketPollInterval
+	}
+	return nil
+}
+
+// Events merges the instance event stream, the task event stream and the
+// changes between consecutive Locket polls. The resources found by the first
+// poll are not printed. It returns once both event streams have ended.
+func Events(stdout, stderr io.Writer, bbsClient bbs.Client, locketClient locketmodels.LocketClient, pollInterval time.Duration) error {
+	logger := globalLogger.Session("events")
+
+	resources, err := fetchLocketResources(locketClient)
+	if err != nil {
+		return err
+	}
+
+	instanceES, err := bbsClient.SubscribeToInstanceEventsByCellID(logger, "")
+	if err != nil {
+		return models.ConvertError(err)
+	}
+	defer instanceES.Close()
+
+	taskES, err := bbsClient.SubscribeToTaskEvents(logger)
+	if err != nil {
+		return models.ConvertError(err)
+	}
+	defer taskES.Close()
+
+	eventChan := make(chan *UnifiedEvent)
+	bbsErrChan := make(chan error)
+	locketErrChan := make(chan error)
+	done := make(chan struct{})
+	defer close(done)
+
+	send := func(event *UnifiedEvent) bool {
+		select {
+		case eventChan <- event:
+			return true
+		case <-done:
+			return false
+		}
+	}
+
+	readEvents := func(source string, es events.EventSource) {
+		for {
+			event, err := es.Next()
+			if err != nil {
+				select {
+				case bbsErrChan <- err:
+				case <-done:
+				}
+				return
+			}
+			if !send(&UnifiedEvent{Source: source, Type: event.EventType(), Data: event}) {
+				return
+			}
+		}
+	}
+
+	pollLocket := func() {
+		for {
+			select {
+			case <-time.After(pollInterval):
+			case <-done:
+				return
+			}
+
+			current, err := fetchLocketResources(locketClient)
+			if err != nil {
+				select {
+				case locketErrChan <- err:
+				case <-done:
+				}
+				return
+			}
+
+			for _, event := range diffLocketResources(resources, current) {
+				if !send(event) {
+					return
+				}
+			}
+			resources = current
+		}
+	}
+
+	go readEvents(EventSourceLRP, instanceES)
+	go readEvents(EventSourceTask, taskES)
+	go pollLocket()
+
+	encoder := newOutputEncoder(stdout)
+	endedStreams := 0
+	for {
+		select {
+		case event := <-eventChan:
+			event.Timestamp = time.Now().UTC()
+			err = encoder.Encode(event)
+			if err != nil {
+				logger.Error("failed-to-marshal", err)
+				continue
+			}
+			err = encoder.Flush()
+			if err != nil {
+				return err
+			}
+		case err = <-bbsErrChan:
+			if err != io.EOF {
+				return err
+			}
+			endedStreams++
+			if endedStreams == 2 {
+				return nil
+			}
+		case err = <-locketErrChan:
+			return err
+		}
+	}
+}
+
+// locketResourceKey identifies a Locket resource. A lock and a presence can
+// share a key, so the type code is part of it.
+type locketResourceKey struct {
+	key      string
+	typeCode locketmodels.TypeCode
+}
+
+func fetchLocketResources(locketClient locketmodels.LocketClient) (map[locketResourceKey]*locketmodels.Resource, error) {
+	resources := map[locketResourceKey]*locketmodels.Resource{}
+	for _, typeCode := range []locketmodels.TypeCode{locketmodels.LOCK, locketmodels.PRESENCE} {
+		resp, err := locketClient.FetchAll(context.Background(), &locketmodels.FetchAllRequest{TypeCode: typeCode})
+		if err != nil {
+			return nil, err
+		}
+		for _, resource := range resp.Resources {
+			resources[locketResourceKey{key: resource.Key, typeCode: typeCode}] = resource
+		}
+	}
+	return resources, nil
+}
+
+// diffLocketResources returns an event for every resource that was created,
+// changed or removed, ordered by key and then by type code.
+func diffLocketResources(before, after map[locketResourceKey]*locketmodels.Resource) []*UnifiedEvent {
+	keys := []locketResourceKey{}
+	for key := range before {
+		keys = append(keys, key)
+	}
+	for key := range after {
+		if _, ok := before[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].key != keys[j].key {
+			return keys[i].key < keys[j].key
+		}
+		return keys[i].typeCode < keys[j].typeCode
+	})
+
+	changes := []*UnifiedEvent{}
+	for _, key := range keys {
+		old, current := before[key], after[key]
+		var resource *locketmodels.Resource
+		var change string
+		switch {
+		case old == nil:
+			resource, change = current, "created"
+		case current == nil:
+			resource, change = old, "removed"
+		case old.Owner != current.Owner || old.Value != current.Value:
+			resource, change = current, "changed"
+		default:
+			continue
+		}
+
+		changes = append(changes, &UnifiedEvent{
+			Source: EventSourceLocket,
+			Type:   locketResourceKind(resource) + "_" + change,
+			Data:   &LocketResourceChange{Before: old, After: current},
+		})
+	}
+	return changes
+}
+
+func locketResourceKind(resource *locketmodels.Resource) string {
+	if resource.TypeCode == locketmodels.PRESENCE {
+		return "presence"
+	}
+	return "lock"
+}
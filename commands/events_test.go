@@ -0,0 +1,201 @@
+package commands_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+
+	"code.cloudfoundry.org/bbs/events/eventfakes"
+	"code.cloudfoundry.org/bbs/fake_bbs"
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/cfdot/commands"
+	locketmodels "code.cloudfoundry.org/locket/models"
+	"code.cloudfoundry.org/locket/models/modelsfakes"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("Events", func() {
+	var (
+		fakeBBSClient           *fake_bbs.FakeClient
+		fakeInstanceEventSource *eventfakes.FakeEventSource
+		fakeTaskEventSource     *eventfakes.FakeEventSource
+		fakeLocketClient        *modelsfakes.FakeLocketClient
+		stdout, stderr          *gbytes.Buffer
+		polls                   [][]*locketmodels.Resource
+	)
+
+	type printedEvent struct {
+		Timestamp time.Time       `json:"timestamp"`
+		Source    string          `json:"source"`
+		Type      string          `json:"type"`
+		Data      json.RawMessage `json:"data"`
+	}
+
+	printedEvents := func() []printedEvent {
+		printed := []printedEvent{}
+		decoder := json.NewDecoder(gbytes.BufferWithBytes(stdout.Contents()))
+		for decoder.More() {
+			var event printedEvent
+			Expect(decoder.Decode(&event)).To(Succeed())
+			printed = append(printed, event)
+		}
+		return printed
+	}
+
+	BeforeEach(func() {
+		stdout = gbytes.NewBuffer()
+		stderr = gbytes.NewBuffer()
+		fakeBBSClient = &fake_bbs.FakeClient{}
+		fakeInstanceEventSource = &eventfakes.FakeEventSource{}
+		fakeTaskEventSource = &eventfakes.FakeEventSource{}
+		fakeLocketClient = &modelsfakes.FakeLocketClient{}
+		fakeBBSClient.SubscribeToInstanceEventsByCellIDReturns(fakeInstanceEventSource, nil)
+		fakeBBSClient.SubscribeToTaskEventsReturns(fakeTaskEventSource, nil)
+
+		// Every poll fetches the locks and then the presences. The event
+		// streams end once the third poll started, after the changes found by
+		// the second poll were printed.
+		polls = [][]*locketmodels.Resource{
+			{
+				{Key: "auctioneer", Owner: "owner-1", TypeCode: locketmodels.LOCK},
+				{Key: "cell-1", Owner: "cell-1", TypeCode: locketmodels.PRESENCE},
+			},
+			{
+				{Key: "auctioneer", Owner: "owner-2", TypeCode: locketmodels.LOCK},
+				{Key: "cell-2", Owner: "cell-2", TypeCode: locketmodels.PRESENCE},
+			},
+		}
+		ended := make(chan struct{})
+		fetches := 0
+		fakeLocketClient.FetchAllStub = func(ctx context.Context, req *locketmodels.FetchAllRequest, opts ...locketmodels.CallOption) (*locketmodels.FetchAllResponse, error) {
+			poll := fetches / 2
+			fetches++
+			if poll == 2 && req.TypeCode == locketmodels.LOCK {
+				close(ended)
+			}
+			if poll >= len(polls) {
+				poll = len(polls) - 1
+			}
+
+			resp := &locketmodels.FetchAllResponse{}
+			for _, resource := range polls[poll] {
+				if resource.TypeCode == req.TypeCode {
+					resp.Resources = append(resp.Resources, resource)
+				}
+			}
+			return resp, nil
+		}
+
+		eventsUntilEnded := func(event models.Event) func() (models.Event, error) {
+			calls := 0
+			return func() (models.Event, error) {
+				calls++
+				if calls == 1 {
+					return event, nil
+				}
+				<-ended
+				return nil, io.EOF
+			}
+		}
+		actualLRP := &models.ActualLRP{ActualLRPKey: models.NewActualLRPKey("process-guid", 0, "cf-apps")}
+		fakeInstanceEventSource.NextStub = eventsUntilEnded(models.NewActualLRPInstanceCreatedEvent(actualLRP, "trace-id"))
+		fakeTaskEventSource.NextStub = eventsUntilEnded(models.NewTaskCreatedEvent(&models.Task{TaskGuid: "task-guid"}))
+	})
+
+	It("merges the bbs events and the locket changes into one stream", func() {
+		err := commands.Events(stdout, stderr, fakeBBSClient, fakeLocketClient, time.Millisecond)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, cellID := fakeBBSClient.SubscribeToInstanceEventsByCellIDArgsForCall(0)
+		Expect(cellID).To(BeEmpty())
+		Expect(fakeInstanceEventSource.CloseCallCount()).To(Equal(1))
+		Expect(fakeTaskEventSource.CloseCallCount()).To(Equal(1))
+
+		printed := printedEvents()
+		types := map[string][]string{}
+		for i, event := range printed {
+			types[event.Source] = append(types[event.Source], event.Type)
+			if i > 0 {
+				Expect(event.Timestamp).NotTo(BeTemporally("<", printed[i-1].Timestamp))
+			}
+		}
+		Expect(types).To(Equal(map[string][]string{
+			"lrp":    {"actual_lrp_instance_created"},
+			"task":   {"task_created"},
+			"locket": {"lock_changed", "presence_removed", "presence_created"},
+		}))
+
+		for _, event := range printed {
+			if event.Type == "lock_changed" {
+				Expect(event.Data).To(MatchJSON(`{"before":{"key":"auctioneer","owner":"owner-1","type_code":1},"after":{"key":"auctioneer","owner":"owner-2","type_code":1}}`))
+			}
+		}
+	})
+
+	Context("when a lock and a presence share a key", func() {
+		BeforeEach(func() {
+			polls = [][]*locketmodels.Resource{
+				{
+					{Key: "cell-1", Owner: "owner-1", TypeCode: locketmodels.LOCK},
+					{Key: "cell-1", Owner: "cell-1", TypeCode: locketmodels.PRESENCE},
+				},
+				{
+					{Key: "cell-1", Owner: "owner-2", TypeCode: locketmodels.LOCK},
+					{Key: "cell-1", Owner: "cell-1", TypeCode: locketmodels.PRESENCE},
+				},
+			}
+		})
+
+		It("tracks them separately", func() {
+			err := commands.Events(stdout, stderr, fakeBBSClient, fakeLocketClient, time.Millisecond)
+			Expect(err).NotTo(HaveOccurred())
+
+			locketEvents := []printedEvent{}
+			for _, event := range printedEvents() {
+				if event.Source == "locket" {
+					locketEvents = append(locketEvents, event)
+				}
+			}
+			Expect(locketEvents).To(HaveLen(1))
+			Expect(locketEvents[0].Type).To(Equal("lock_changed"))
+			Expect(locketEvents[0].Data).To(MatchJSON(`{"before":{"key":"cell-1","owner":"owner-1","type_code":1},"after":{"key":"cell-1","owner":"owner-2","type_code":1}}`))
+		})
+	})
+
+	Context("when locket cannot be polled", func() {
+		BeforeEach(func() {
+			fakeLocketClient.FetchAllStub = nil
+			fakeLocketClient.FetchAllReturns(nil, errors.New("boom"))
+		})
+
+		It("returns the error without subscribing", func() {
+			err := commands.Events(stdout, stderr, fakeBBSClient, fakeLocketClient, time.Millisecond)
+			Expect(err).To(MatchError("boom"))
+			Expect(fakeBBSClient.SubscribeToInstanceEventsByCellIDCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("when an event stream fails", func() {
+		BeforeEach(func() {
+			fakeTaskEventSource.NextStub = nil
+			fakeTaskEventSource.NextReturns(nil, errors.New("boom"))
+		})
+
+		It("returns the error", func() {
+			err := commands.Events(stdout, stderr, fakeBBSClient, fakeLocketClient, time.Millisecond)
+			Expect(err).To(MatchError("boom"))
+		})
+	})
+
+	Describe("ValidateEventsArguments", func() {
+		It("rejects arguments", func() {
+			Expect(commands.ValidateEventsArguments([]string{"extra"})).To(MatchError("Too many arguments specified"))
+			Expect(commands.ValidateEventsArguments([]string{})).To(Succeed())
+		})
+	})
+})
@@ -0,0 +1,175 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"code.cloudfoundry.org/bbs"
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/bbs/trace"
+	"code.cloudfoundry.org/cfdot/commands/helpers"
+	"github.com/spf13/cobra"
+)
+
+// SnapshotVersion is the version of the snapshot format written by export.
+// It is increased whenever a change to the format cannot be read by older
+// versions of cfdot.
+const SnapshotVersion = 1
+
+// Snapshot is a point in time copy of the desired state of the BBS.
+type Snapshot struct {
+	Version     int                  `json:"version"`
+	CreatedAt   time.Time            `json:"created_at"`
+	Domains     []string             `json:"domains"`
+	DesiredLRPs []*models.DesiredLRP `json:"desired_lrps"`
+	Tasks       []*models.Task       `json:"tasks"`
+}
+
+// ExportSummary is printed once the snapshot file has been written.
+type ExportSummary struct {
+	File        string `json:"file"`
+	Version     int    `json:"version"`
+	Domains     int    `json:"domains"`
+	DesiredLRPs int    `json:"desired_lrps"`
+	Tasks       int    `json:"tasks"`
+}
+
+// errors
+var (
+	errMissingExportFile = errors.New("No export file given")
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export FILE",
+	Short: "Export a snapshot of the desired state",
+	Long:  "Write the fresh domains, the desired LRPs and the pending and running tasks of the BBS to a versioned JSON snapshot file",
+	RunE:  export,
+}
+
+func init() {
+	AddBBSAndTimeoutFlags(exportCmd)
+	RootCmd.AddCommand(exportCmd)
+}
+
+func export(cmd *cobra.Command, args []string) error {
+	path, err := ValidateExportArguments(args)
+	if err != nil {
+		return NewCFDotValidationError(cmd, err)
+	}
+
+	bbsClient, err := helpers.NewBBSClient(cmd, Config)
+	if err != nil {
+		return NewCFDotError(cmd, err)
+	}
+
+	err = Export(cmd.OutOrStdout(), cmd.OutOrStderr(), bbsClient, path)
+	if err != nil {
+		return NewCFDotError(cmd, err)
+	}
+
+	return nil
+}
+
+func ValidateExportArguments(args []string) (string, error) {
+	switch {
+	case len(args) < 1 || args[0] == "":
+		return "", errMissingExportFile
+	case len(args) > 1:
+		return "", errExtraArguments
+	}
+	return args[0], nil
+}
+
+func Export(stdout, stderr io.Writer, bbsClient bbs.Client, path string) error {
+	snapshot, err := FetchSnapshot(bbsClient)
+	if err != nil {
+		return err
+	}
+
+	err = writeSnapshot(path, snapshot)
+	if err != nil {
+		return err
+	}
+
+	encoder := newOutputEncoder(stdout)
+	err = encoder.Encode(&ExportSummary{
+		File:        path,
+		Version:     snapshot.Version,
+		Domains:     len(snapshot.Domains),
+		DesiredLRPs: len(snapshot.DesiredLRPs),
+		Tasks:       len(snapshot.Tasks),
+	})
+	if err != nil {
+		return err
+	}
+
+	return encoder.Flush()
+}
+
+// FetchSnapshot reads the domains, the desired LRPs and the tasks that are
+// not completed yet from the BBS.
+func FetchSnapshot(bbsClient bbs.Client) (*Snapshot, error) {
+	traceID := trace.GenerateTraceID()
+	logger := trace.LoggerWithTraceInfo(globalLogger.Session("export"), traceID)
+
+	snapshot := &Snapshot{
+		Version:     SnapshotVersion,
+		CreatedAt:   time.Now().UTC(),
+		Domains:     []string{},
+		DesiredLRPs: []*models.DesiredLRP{},
+		Tasks:       []*models.Task{},
+	}
+
+	domains, err := bbsClient.Domains(logger, traceID)
+	if err != nil {
+		return nil, err
+	}
+	snapshot.Domains = append(snapshot.Domains, domains...)
+
+	desiredLRPs, err := bbsClient.DesiredLRPs(logger, traceID, models.DesiredLRPFilter{})
+	if err != nil {
+		return nil, err
+	}
+	snapshot.DesiredLRPs = append(snapshot.DesiredLRPs, desiredLRPs...)
+
+	tasks, err := bbsClient.Tasks(logger, traceID)
+	if err != nil {
+		return nil, err
+	}
+	for _, task := range tasks {
+		if task.State == models.Task_Pending || task.State == models.Task_Running {
+			snapshot.Tasks = append(snapshot.Tasks, task)
+		}
+	}
+
+	return snapshot, nil
+}
+
+// writeSnapshot writes the snapshot next to path and renames it into place,
+// so that an interrupted export never leaves a truncated file behind.
+func writeSnapshot(path string, snapshot *Snapshot) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	encoder := json.NewEncoder(f)
+	encoder.SetIndent("", "  ")
+	err = encoder.Encode(snapshot)
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
@@ -0,0 +1,145 @@
+package commands_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"code.cloudfoundry.org/bbs/fake_bbs"
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/cfdot/commands"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("Export", func() {
+	var (
+		fakeBBSClient  *fake_bbs.FakeClient
+		stdout, stderr *gbytes.Buffer
+		dir, path      string
+	)
+
+	BeforeEach(func() {
+		fakeBBSClient = &fake_bbs.FakeClient{}
+		stdout = gbytes.NewBuffer()
+		stderr = gbytes.NewBuffer()
+
+		var err error
+		dir, err = os.MkdirTemp("", "export")
+		Expect(err).NotTo(HaveOccurred())
+		path = filepath.Join(dir, "snapshot.json")
+
+		fakeBBSClient.DomainsReturns([]string{"cf-apps", "cf-tasks"}, nil)
+		fakeBBSClient.DesiredLRPsReturns([]*models.DesiredLRP{
+			{ProcessGuid: "process-guid", Domain: "cf-apps", Instances: 2},
+		}, nil)
+		fakeBBSClient.TasksReturns([]*models.Task{
+			{TaskGuid: "pending", State: models.Task_Pending},
+			{TaskGuid: "running", State: models.Task_Running},
+			{TaskGuid: "completed", State: models.Task_Completed},
+			{TaskGuid: "resolving", State: models.Task_Resolving},
+		}, nil)
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	readSnapshot := func() commands.Snapshot {
+		contents, err := os.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+
+		var snapshot commands.Snapshot
+		Expect(json.Unmarshal(contents, &snapshot)).To(Succeed())
+		return snapshot
+	}
+
+	It("writes the domains, desired lrps and non-terminal tasks to the file", func() {
+		err := commands.Export(stdout, stderr, fakeBBSClient, path)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, _, filter := fakeBBSClient.DesiredLRPsArgsForCall(0)
+		Expect(filter).To(Equal(models.DesiredLRPFilter{}))
+
+		snapshot := readSnapshot()
+		Expect(snapshot.Version).To(Equal(commands.SnapshotVersion))
+		Expect(snapshot.CreatedAt.IsZero()).To(BeFalse())
+		Expect(snapshot.Domains).To(Equal([]string{"cf-apps", "cf-tasks"}))
+		Expect(snapshot.DesiredLRPs).To(HaveLen(1))
+		Expect(snapshot.DesiredLRPs[0].ProcessGuid).To(Equal("process-guid"))
+		Expect(snapshot.Tasks).To(HaveLen(2))
+		Expect(snapshot.Tasks[0].TaskGuid).To(Equal("pending"))
+		Expect(snapshot.Tasks[1].TaskGuid).To(Equal("running"))
+	})
+
+	It("prints a summary of the snapshot", func() {
+		err := commands.Export(stdout, stderr, fakeBBSClient, path)
+		Expect(err).NotTo(HaveOccurred())
+
+		var summary commands.ExportSummary
+		Expect(json.Unmarshal(stdout.Contents(), &summary)).To(Succeed())
+		Expect(summary).To(Equal(commands.ExportSummary{
+			File:        path,
+			Version:     commands.SnapshotVersion,
+			Domains:     2,
+			DesiredLRPs: 1,
+			Tasks:       2,
+		}))
+	})
+
+	It("writes empty lists when there is nothing to export", func() {
+		fakeBBSClient.DomainsReturns(nil, nil)
+		fakeBBSClient.DesiredLRPsReturns(nil, nil)
+		fakeBBSClient.TasksReturns(nil, nil)
+
+		err := commands.Export(stdout, stderr, fakeBBSClient, path)
+		Expect(err).NotTo(HaveOccurred())
+
+		contents, err := os.ReadFile(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(contents)).To(ContainSubstring(`"domains": []`))
+		Expect(string(contents)).To(ContainSubstring(`"desired_lrps": []`))
+		Expect(string(contents)).To(ContainSubstring(`"tasks": []`))
+	})
+
+	Context("when the bbs errors", func() {
+		BeforeEach(func() {
+			fakeBBSClient.TasksReturns(nil, models.ErrUnknownError)
+		})
+
+		It("does not write the file", func() {
+			err := commands.Export(stdout, stderr, fakeBBSClient, path)
+			Expect(err).To(Equal(models.ErrUnknownError))
+			Expect(path).NotTo(BeAnExistingFile())
+
+			entries, err := os.ReadDir(dir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(entries).To(BeEmpty())
+		})
+	})
+
+	Context("when the file cannot be written", func() {
+		It("returns an error", func() {
+			err := commands.Export(stdout, stderr, fakeBBSClient, filepath.Join(dir, "missing", "snapshot.json"))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("ValidateExportArguments", func() {
+		It("returns the file", func() {
+			file, err := commands.ValidateExportArguments([]string{"snapshot.json"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(file).To(Equal("snapshot.json"))
+		})
+
+		It("rejects missing and extra arguments", func() {
+			_, err := commands.ValidateExportArguments([]string{})
+			Expect(err).To(MatchError("No export file given"))
+
+			_, err = commands.ValidateExportArguments([]string{"a", "b"})
+			Expect(err).To(MatchError("Too many arguments specified"))
+		})
+	})
+})
@@ -2,7 +2,6 @@ package commands
 
 import (
 	"errors"
-	"os"
 
 	"github.com/spf13/cobra"
 )
@@ -18,10 +17,22 @@ var (
 
 func AddLocketFlags(cmd *cobra.Command) {
 	AddTLSFlags(cmd)
-	cmd.Flags().StringVar(&locketApiLocation, "locketAPILocation", "", "Hostname:Port of Locket server to target [environment variable equivalent: LOCKET_API_LOCATION]")
+	addLocketAPILocationFlag(cmd)
 	cmd.PreRunE = LocketPrehook
 }
 
+// AddBBSAndLocketFlags is used by commands that talk to both the BBS and
+// Locket, which share the TLS flags.
+func AddBBSAndLocketFlags(cmd *cobra.Command) {
+	AddBBSFlags(cmd)
+	addLocketAPILocationFlag(cmd)
+	cmd.PreRunE = BBSAndLocketPrehook
+}
+
+func addLocketAPILocationFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&locketApiLocation, "locketAPILocation", "", "Hostname:Port of Locket server to target [environment variable equivalent: LOCKET_API_LOCATION]")
+}
+
 func LocketPrehook(cmd *cobra.Command, args []string) error {
 	if err := setLocketFlags(cmd, args); err != nil {
 		return err
@@ -29,9 +40,23 @@ func LocketPrehook(cmd *cobra.Command, args []string) error {
 	return tlsPreHook(cmd, args)
 }
 
+func BBSAndLocketPrehook(cmd *cobra.Command, args []string) error {
+	if err := setBBSFlags(cmd, args); err != nil {
+		return err
+	}
+	if err := setLocketFlags(cmd, args); err != nil {
+		return err
+	}
+	return tlsPreHook(cmd, args)
+}
+
 func setLocketFlags(cmd *cobra.Command, args []string) error {
-	if locketApiLocation == "" {
-		locketApiLocation = os.Getenv("LOCKET_API_LOCATION")
+	if !setFromFlagOrEnv(cmd, &locketApiLocation, "locketAPILocation", "LOCKET_API_LOCATION") {
+		profile, err := activeProfile(cmd)
+		if err != nil {
+			return err
+		}
+		locketApiLocation = profile.LocketAPILocation
 	}
 
 	Config.LocketApiLocation = locketApiLocation
@@ -89,4 +89,54 @@ var _ = Describe("Locket Flags", func() {
 			})
 		})
 	})
+
+	Describe("AddBBSAndLocketFlags", func() {
+		BeforeEach(func() {
+			dummyCmd = &cobra.Command{
+				Use: "dummy",
+				Run: func(cmd *cobra.Command, args []string) {},
+			}
+			commands.AddBBSAndLocketFlags(dummyCmd)
+			dummyCmd.SetOutput(output)
+
+			validTLSFlags["--bbsURL"] = "https://example.com"
+		})
+
+		Context("when both locations are given", func() {
+			BeforeEach(func() {
+				parseFlagsErr := dummyCmd.ParseFlags(buildArgList(validTLSFlags))
+				Expect(parseFlagsErr).NotTo(HaveOccurred())
+			})
+
+			It("does not error", func() {
+				Expect(err).NotTo(HaveOccurred())
+			})
+		})
+
+		Context("when the --bbsURL isn't given", func() {
+			BeforeEach(func() {
+				parseFlagsErr := dummyCmd.ParseFlags(removeFlag(validTLSFlags, "--bbsURL"))
+				Expect(parseFlagsErr).NotTo(HaveOccurred())
+			})
+
+			It("returns an error message", func() {
+				Expect(err).To(MatchError(
+					"BBS URL not set. Please specify one with the '--bbsURL' flag or the 'BBS_URL' environment variable.",
+				))
+			})
+		})
+
+		Context("when the --locketAPILocation isn't given", func() {
+			BeforeEach(func() {
+				parseFlagsErr := dummyCmd.ParseFlags(removeFlag(validTLSFlags, "--locketAPILocation"))
+				Expect(parseFlagsErr).NotTo(HaveOccurred())
+			})
+
+			It("returns an error message", func() {
+				Expect(err).To(MatchError(
+					"Locket API Location not set. Please specify one with the '--locketAPILocation' flag or the 'LOCKET_API_LOCATION' environment variable.",
+				))
+			})
+		})
+	})
 })
@@ -1,7 +1,6 @@
 package commands
 
 import (
-	"encoding/json"
 	"io"
 
 	"code.cloudfoundry.org/bbs"
@@ -26,14 +25,18 @@ var lrpEventsCmd = &cobra.Command{
 
 type LRPEvent struct {
 	Type string      `json:"type"`
-	Data interface{} `json:"data"`
+	Data interface{} `json:"data,omitempty"`
 }
 
 func init() {
 	AddBBSFlags(lrpEventsCmd)
+	AddEventStreamFlags(lrpEventsCmd)
 
 	lrpEventsCmd.Flags().StringVarP(&lrpEventsCellIdFlag, "cell-id", "c", "", "retrieve only events for the given cell id")
 	lrpEventsCmd.Flags().BoolVarP(&lrpEventsExcludeActualLRPGroups, "exclude-actual-lrp-groups", "x", false, "exclude actual lrp group events")
+	lrpEventsCmd.Flags().StringSliceVar(&eventFilterProcessGuidsFlag, "process-guid", nil, "retrieve only events for the given process guid, can be repeated")
+	lrpEventsCmd.Flags().StringVar(&eventFilterDomainFlag, "domain", "", "retrieve only events for the given domain")
+	lrpEventsCmd.Flags().StringSliceVar(&eventFilterTypesFlag, "type", nil, "retrieve only events of the given type, e.g. actual_lrp_crashed, can be repeated")
 
 	RootCmd.AddCommand(lrpEventsCmd)
 }
@@ -44,6 +47,16 @@ func lrpEvents(cmd *cobra.Command, args []string) error {
 		return NewCFDotValidationError(cmd, err)
 	}
 
+	reconnect, err := eventStreamBackoff()
+	if err != nil {
+		return NewCFDotValidationError(cmd, err)
+	}
+
+	filter, err := newEventFilter(lrpEventTypes)
+	if err != nil {
+		return NewCFDotValidationError(cmd, err)
+	}
+
 	if !lrpEventsExcludeActualLRPGroups {
 		err = printLRPGroupEventsWarning(cmd.OutOrStderr())
 		if err != nil {
@@ -51,12 +64,18 @@ func lrpEvents(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	recorder, err := eventStreamRecorder()
+	if err != nil {
+		return NewCFDotError(cmd, err)
+	}
+	defer recorder.Close()
+
 	bbsClient, err := helpers.NewBBSClient(cmd, Config)
 	if err != nil {
 		return NewCFDotError(cmd, err)
 	}
 
-	err = LRPEvents(cmd.OutOrStdout(), cmd.OutOrStderr(), bbsClient, lrpEventsCellIdFlag, lrpEventsExcludeActualLRPGroups)
+	err = LRPEvents(cmd.OutOrStdout(), cmd.OutOrStderr(), bbsClient, lrpEventsCellIdFlag, lrpEventsExcludeActualLRPGroups, reconnect, filter, recorder)
 	if err != nil {
 		return NewCFDotError(cmd, err)
 	}
@@ -70,48 +89,53 @@ func validateLRPEventsArguments(args []string) error {
 	return nil
 }
 
-func LRPEvents(stdout, stderr io.Writer, bbsClient bbs.Client, cellID string, excludeActualLRPGroups bool) error {
+func LRPEvents(stdout, stderr io.Writer, bbsClient bbs.Client, cellID string, excludeActualLRPGroups bool, reconnect *EventStreamBackoff, filter *EventFilter, recorder *EventRecorder) error {
 	logger := globalLogger.Session("lrp-events")
 
-	oldEventStream := make(chan models.Event)
-	newEventStream := make(chan models.Event)
-	oldErrChan := make(chan error)
-	newErrChan := make(chan error)
-
-	readEvent := func(es events.EventSource, eventStreamChan chan models.Event, errChan chan error) {
-		for {
-			event, err := es.Next()
-			if err != nil {
-				errChan <- err
-				return
-			}
-			eventStreamChan <- event
+	subscribeOld := func() (events.EventSource, error) {
+		//lint:ignore SA1019 - if this flag is set, we're intentionally using this deprecated behavior in conjunction with the new behavior
+		return bbsClient.SubscribeToEventsByCellID(logger, cellID)
+	}
+	subscribeInstances := func() (events.EventSource, error) {
+		return bbsClient.SubscribeToInstanceEventsByCellID(logger, cellID)
+	}
+
+	newReconnector := func(subscribe func() (events.EventSource, error)) *eventStreamReconnector {
+		if reconnect == nil {
+			return nil
 		}
+		return newEventStreamReconnector(stderr, *reconnect, subscribe)
 	}
 
-	encoder := json.NewEncoder(stdout)
+	encoder := newEventEncoder(stdout, recorder)
 	eventStreamCount := 1
 
+	// The channels of a stream that was not subscribed stay nil and are
+	// never selected.
+	var oldEventStream, newEventStream chan models.Event
+	var oldErrChan, newErrChan chan error
+
 	if !excludeActualLRPGroups {
-		//lint:ignore SA1019 - if this flag is set, we're intentionally using this deprecated behavior in conjunction with the new behavior
-		oldES, err := bbsClient.SubscribeToEventsByCellID(logger, cellID)
+		oldES, err := subscribeOld()
 		if err != nil {
 			return models.ConvertError(err)
 		}
-		defer oldES.Close()
 
 		eventStreamCount += 1
 
-		go readEvent(oldES, oldEventStream, oldErrChan)
+		oldReader := newEventStreamReader(oldES, newReconnector(subscribeOld))
+		defer oldReader.Close()
+		oldEventStream, oldErrChan = oldReader.events, oldReader.errs
 	}
 
-	instanceES, err := bbsClient.SubscribeToInstanceEventsByCellID(logger, cellID)
+	instanceES, err := subscribeInstances()
 	if err != nil {
 		return models.ConvertError(err)
 	}
-	defer instanceES.Close()
 
-	go readEvent(instanceES, newEventStream, newErrChan)
+	instanceReader := newEventStreamReader(instanceES, newReconnector(subscribeInstances))
+	defer instanceReader.Close()
+	newEventStream, newErrChan = instanceReader.events, instanceReader.errs
 
 	ret := &multierror.Error{}
 	var event models.Event
@@ -120,6 +144,13 @@ func LRPEvents(stdout, stderr io.Writer, bbsClient bbs.Client, cellID string, ex
 		var err error
 		select {
 		case e := <-oldEventStream:
+			if e == nil {
+				err = printStreamReconnected(encoder)
+				if err != nil {
+					return err
+				}
+				continue
+			}
 			switch e.EventType() {
 			//lint:ignore SA1019 - cfdot needs to process deprecated ActualLRP data until it is removed from BBS
 			case models.EventTypeActualLRPCreated, models.EventTypeActualLRPChanged, models.EventTypeActualLRPRemoved:
@@ -128,6 +159,13 @@ func LRPEvents(stdout, stderr io.Writer, bbsClient bbs.Client, cellID string, ex
 				continue
 			}
 		case event = <-newEventStream:
+			if event == nil {
+				err = printStreamReconnected(encoder)
+				if err != nil {
+					return err
+				}
+				continue
+			}
 		case err = <-oldErrChan:
 			multierror.Append(ret, err)
 		case err = <-newErrChan:
@@ -143,7 +181,7 @@ func LRPEvents(stdout, stderr io.Writer, bbsClient bbs.Client, cellID string, ex
 			return nil
 		}
 
-		if err != nil {
+		if err != nil || !filter.Matches(event) {
 			continue
 		}
 
@@ -152,6 +190,11 @@ func LRPEvents(stdout, stderr io.Writer, bbsClient bbs.Client, cellID string, ex
 		err = encoder.Encode(lrpEvent)
 		if err != nil {
 			logger.Error("failed-to-marshal", err)
+			continue
+		}
+		err = encoder.Flush()
+		if err != nil {
+			return err
 		}
 	}
 }
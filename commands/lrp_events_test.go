@@ -5,12 +5,15 @@ import (
 	"errors"
 	"io"
 	"strings"
+	"time"
 
+	"code.cloudfoundry.org/bbs/events"
 	"code.cloudfoundry.org/bbs/events/eventfakes"
 	"code.cloudfoundry.org/bbs/fake_bbs"
 	"code.cloudfoundry.org/bbs/models"
 	"code.cloudfoundry.org/bbs/models/test/model_helpers"
 	"code.cloudfoundry.org/cfdot/commands"
+	"code.cloudfoundry.org/lager/v3"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	"github.com/onsi/gomega/gbytes"
@@ -86,7 +89,7 @@ var _ = Describe("LRP Events", func() {
 			eventString(models.NewActualLRPInstanceRemovedEvent(actualLRP, "some-trace-id")),
 		}
 
-		err := commands.LRPEvents(stdout, stderr, fakeBBSClient, "", false)
+		err := commands.LRPEvents(stdout, stderr, fakeBBSClient, "", false, nil, nil, nil)
 		Expect(err).NotTo(HaveOccurred())
 
 		stdoutData := strings.TrimSpace(string(stdout.Contents()))
@@ -103,7 +106,7 @@ var _ = Describe("LRP Events", func() {
 				eventString(models.NewActualLRPInstanceRemovedEvent(actualLRP, "some-trace-id")),
 			}
 
-			err := commands.LRPEvents(stdout, stderr, fakeBBSClient, "", true)
+			err := commands.LRPEvents(stdout, stderr, fakeBBSClient, "", true, nil, nil, nil)
 			Expect(err).NotTo(HaveOccurred())
 
 			stdoutData := strings.TrimSpace(string(stdout.Contents()))
@@ -130,7 +133,7 @@ var _ = Describe("LRP Events", func() {
 			})
 
 			It("dedups them in the output", func() {
-				err := commands.LRPEvents(stdout, stderr, fakeBBSClient, "", false)
+				err := commands.LRPEvents(stdout, stderr, fakeBBSClient, "", false, nil, nil, nil)
 				Expect(err).NotTo(HaveOccurred())
 
 				desiredLRPEvent := commands.LRPEvent{
@@ -152,7 +155,7 @@ var _ = Describe("LRP Events", func() {
 			})
 
 			It("dedups them in the output", func() {
-				err := commands.LRPEvents(stdout, stderr, fakeBBSClient, "", false)
+				err := commands.LRPEvents(stdout, stderr, fakeBBSClient, "", false, nil, nil, nil)
 				Expect(err).NotTo(HaveOccurred())
 
 				desiredLRPEvent := commands.LRPEvent{
@@ -174,7 +177,7 @@ var _ = Describe("LRP Events", func() {
 			})
 
 			It("dedups them in the output", func() {
-				err := commands.LRPEvents(stdout, stderr, fakeBBSClient, "", false)
+				err := commands.LRPEvents(stdout, stderr, fakeBBSClient, "", false, nil, nil, nil)
 				Expect(err).NotTo(HaveOccurred())
 
 				desiredLRPEvent := commands.LRPEvent{
@@ -192,7 +195,7 @@ var _ = Describe("LRP Events", func() {
 	})
 
 	It("closes the event streams", func() {
-		err := commands.LRPEvents(stdout, stderr, fakeBBSClient, "", false)
+		err := commands.LRPEvents(stdout, stderr, fakeBBSClient, "", false, nil, nil, nil)
 		Expect(err).NotTo(HaveOccurred())
 
 		Expect(fakeEventSource.CloseCallCount()).To(Equal(1))
@@ -205,7 +208,7 @@ var _ = Describe("LRP Events", func() {
 		})
 
 		It("returns an error", func() {
-			err := commands.LRPEvents(stdout, stderr, fakeBBSClient, "", false)
+			err := commands.LRPEvents(stdout, stderr, fakeBBSClient, "", false, nil, nil, nil)
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(ContainSubstring("failed to connect"))
 		})
@@ -218,9 +221,151 @@ var _ = Describe("LRP Events", func() {
 		})
 
 		It("returns an error", func() {
-			err := commands.LRPEvents(stdout, stderr, fakeBBSClient, "", false)
+			err := commands.LRPEvents(stdout, stderr, fakeBBSClient, "", false, nil, nil, nil)
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(ContainSubstring("boom"))
 		})
 	})
+
+	Context("when reconnecting", func() {
+		var blocked chan struct{}
+
+		BeforeEach(func() {
+			// the third stream blocks, so that nothing is resubscribed after
+			// the output was closed
+			blocked = make(chan struct{})
+			calls := 0
+			fakeInstanceEventSource.NextStub = func() (models.Event, error) {
+				calls++
+				switch calls {
+				case 1:
+					return models.NewActualLRPInstanceCreatedEvent(actualLRP, "some-trace-id"), nil
+				case 2:
+					return nil, errors.New("boom")
+				case 3:
+					return nil, io.EOF
+				default:
+					<-blocked
+					return nil, io.EOF
+				}
+			}
+		})
+
+		AfterEach(func() {
+			close(blocked)
+		})
+
+		It("resubscribes to the failed stream and marks the reconnect", func() {
+			output := &limitedWriter{Writer: stdout, limit: 2}
+			reconnect := &commands.EventStreamBackoff{Initial: time.Millisecond, Max: time.Millisecond}
+
+			err := commands.LRPEvents(output, stderr, fakeBBSClient, "some-cell-id", true, reconnect, nil, nil)
+			Expect(err).To(Equal(errOutputClosed))
+
+			Expect(fakeBBSClient.SubscribeToInstanceEventsByCellIDCallCount()).To(Equal(3))
+			_, cellID := fakeBBSClient.SubscribeToInstanceEventsByCellIDArgsForCall(2)
+			Expect(cellID).To(Equal("some-cell-id"))
+			Expect(fakeInstanceEventSource.CloseCallCount()).To(Equal(3))
+
+			Expect(stdout).To(gbytes.Say(`{"type":"actual_lrp_instance_created",`))
+			Expect(stdout).To(gbytes.Say(`{"type":"stream_reconnected"}\n`))
+			Expect(stderr).To(gbytes.Say("Event stream failed: boom, resubscribing in 1ms"))
+			Expect(stderr).To(gbytes.Say("Event stream was closed, resubscribing in 1ms"))
+		})
+
+		It("prints the marker regardless of --where and --fields", func() {
+			filter, err := commands.ParseFilter("type==actual_lrp_crashed")
+			Expect(err).NotTo(HaveOccurred())
+			commands.Output.Where = filter
+			commands.Output.Fields = []string{"data"}
+
+			output := &limitedWriter{Writer: stdout, limit: 1}
+			reconnect := &commands.EventStreamBackoff{Initial: time.Millisecond, Max: time.Millisecond}
+
+			err = commands.LRPEvents(output, stderr, fakeBBSClient, "", true, reconnect, nil, nil)
+			Expect(err).To(Equal(errOutputClosed))
+			Expect(string(stdout.Contents())).To(Equal(`{"type":"stream_reconnected"}` + "\n"))
+		})
+
+		It("prints the marker in the selected output format", func() {
+			filter, err := commands.ParseFilter("type==actual_lrp_crashed")
+			Expect(err).NotTo(HaveOccurred())
+			commands.Output.Where = filter
+			commands.Output.Template, err = commands.ParseOutputTemplate("{{.Type}}")
+			Expect(err).NotTo(HaveOccurred())
+
+			output := &limitedWriter{Writer: stdout, limit: 1}
+			reconnect := &commands.EventStreamBackoff{Initial: time.Millisecond, Max: time.Millisecond}
+
+			err = commands.LRPEvents(output, stderr, fakeBBSClient, "", true, reconnect, nil, nil)
+			Expect(err).To(Equal(errOutputClosed))
+			Expect(string(stdout.Contents())).To(Equal("stream_reconnected\n"))
+		})
+	})
+
+	Context("when one stream is waiting to be resubscribed", func() {
+		var blocked chan struct{}
+
+		BeforeEach(func() {
+			// the old stream fails and hangs while resubscribing, and only
+			// then the instance stream delivers an event and fails
+			blocked = make(chan struct{})
+			resubscribing := make(chan struct{})
+
+			fakeEventSource.NextStub = nil
+			fakeEventSource.NextReturns(nil, errors.New("boom"))
+			subscriptions := 0
+			fakeBBSClient.SubscribeToEventsByCellIDStub = func(lager.Logger, string) (events.EventSource, error) {
+				subscriptions++
+				if subscriptions > 1 {
+					close(resubscribing)
+					<-blocked
+					return nil, errors.New("unavailable")
+				}
+				return fakeEventSource, nil
+			}
+
+			calls := 0
+			fakeInstanceEventSource.NextStub = func() (models.Event, error) {
+				calls++
+				switch calls {
+				case 1:
+					<-resubscribing
+					return models.NewActualLRPInstanceCreatedEvent(actualLRP, "some-trace-id"), nil
+				case 2:
+					return nil, errors.New("boom")
+				default:
+					<-blocked
+					return nil, io.EOF
+				}
+			}
+		})
+
+		AfterEach(func() {
+			close(blocked)
+		})
+
+		It("keeps reading and resubscribing the other stream", func() {
+			output := &limitedWriter{Writer: stdout, limit: 1}
+			reconnect := &commands.EventStreamBackoff{Initial: time.Millisecond, Max: time.Millisecond}
+
+			err := commands.LRPEvents(output, stderr, fakeBBSClient, "", false, reconnect, nil, nil)
+			Expect(err).To(Equal(errOutputClosed))
+
+			Expect(stdout).To(gbytes.Say(`{"type":"actual_lrp_instance_created",`))
+			Expect(fakeBBSClient.SubscribeToInstanceEventsByCellIDCallCount()).To(Equal(2))
+		})
+	})
+
+	Context("when filtering", func() {
+		It("only prints the events matching the filter", func() {
+			filter := &commands.EventFilter{Types: []string{models.EventTypeActualLRPInstanceRemoved}}
+
+			err := commands.LRPEvents(stdout, stderr, fakeBBSClient, "", false, nil, filter, nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			stdoutData := strings.TrimSpace(string(stdout.Contents()))
+			Expect(stdoutData).To(Equal(eventString(models.NewActualLRPInstanceRemovedEvent(actualLRP, "some-trace-id"))))
+		})
+	})
 })
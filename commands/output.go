@@ -0,0 +1,504 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"code.cloudfoundry.org/bbs/models"
+	locketmodels "code.cloudfoundry.org/locket/models"
+	"code.cloudfoundry.org/rep"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// Encoder writes model objects to stdout in the format selected with the
+// --output flag. Flush must be called once all values have been encoded.
+type Encoder interface {
+	Encode(v interface{}) error
+	Flush() error
+}
+
+func NewEncoder(w io.Writer, config OutputConfig) Encoder {
+	var encoder Encoder
+	switch {
+	case config.Template != nil:
+		encoder = &templateEncoder{writer: w, template: config.Template}
+	case config.Format == OutputFormatTable:
+		encoder = newTableEncoder(w, false)
+	case config.Format == OutputFormatWide:
+		encoder = newTableEncoder(w, true)
+	case config.Format == OutputFormatYAML:
+		encoder = &yamlEncoder{writer: w}
+	default:
+		encoder = &jsonEncoder{encoder: json.NewEncoder(w)}
+	}
+
+	if config.Where != nil || len(config.Fields) > 0 {
+		return &filteringEncoder{
+			encoder: encoder,
+			filter:  config.Where,
+			fields:  config.Fields,
+		}
+	}
+
+	return encoder
+}
+
+func newOutputEncoder(stdout io.Writer) Encoder {
+	return NewEncoder(stdout, Output)
+}
+
+type jsonEncoder struct {
+	encoder *json.Encoder
+}
+
+func (e *jsonEncoder) Encode(v interface{}) error {
+	return e.encoder.Encode(v)
+}
+
+func (e *jsonEncoder) Flush() error {
+	return nil
+}
+
+type yamlEncoder struct {
+	writer    io.Writer
+	documents int
+}
+
+func (e *yamlEncoder) Encode(v interface{}) error {
+	generic, err := toGenericValue(v)
+	if err != nil {
+		return err
+	}
+
+	data, err := yaml.Marshal(generic)
+	if err != nil {
+		return err
+	}
+
+	if e.documents > 0 {
+		_, err = io.WriteString(e.writer, "---\n")
+		if err != nil {
+			return err
+		}
+	}
+	e.documents++
+
+	_, err = e.writer.Write(data)
+	return err
+}
+
+func (e *yamlEncoder) Flush() error {
+	return nil
+}
+
+type tableColumn struct {
+	header string
+	path   string
+	format func(interface{}) string
+}
+
+// tableRower is implemented by reports that are a single JSON object but
+// are easier to read as one or more tables.
+type tableRower interface {
+	TableRows() []interface{}
+}
+
+type tableEncoder struct {
+	writer  *tabwriter.Writer
+	wide    bool
+	kind    reflect.Type
+	columns []tableColumn
+}
+
+func newTableEncoder(w io.Writer, wide bool) *tableEncoder {
+	return &tableEncoder{
+		writer: tabwriter.NewWriter(w, 0, 8, 2, ' ', 0),
+		wide:   wide,
+	}
+}
+
+func (e *tableEncoder) Encode(v interface{}) error {
+	if rower, ok := v.(tableRower); ok {
+		for _, row := range rower.TableRows() {
+			err := e.Encode(row)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	kind := reflect.TypeOf(v)
+	if e.columns == nil || kind != e.kind {
+		if e.columns != nil {
+			err := e.writer.Flush()
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(e.writer)
+		}
+
+		e.kind = kind
+		e.columns = tableColumnsFor(v, e.wide)
+
+		headers := make([]string, len(e.columns))
+		for i, column := range e.columns {
+			headers[i] = column.header
+		}
+		fmt.Fprintln(e.writer, strings.Join(headers, "\t"))
+	}
+
+	cells := make([]string, len(e.columns))
+	if selected, ok := v.(selectedFields); ok {
+		for i, value := range selected.values {
+			cells[i] = formatValue(value)
+		}
+		_, err := fmt.Fprintln(e.writer, strings.Join(cells, "\t"))
+		return err
+	}
+
+	generic, err := toGenericValue(v)
+	if err != nil {
+		return err
+	}
+
+	for i, column := range e.columns {
+		value, _ := lookupPath(generic, column.path)
+		format := column.format
+		if format == nil {
+			format = formatValue
+		}
+		cells[i] = format(value)
+	}
+
+	_, err = fmt.Fprintln(e.writer, strings.Join(cells, "\t"))
+	return err
+}
+
+func (e *tableEncoder) Flush() error {
+	return e.writer.Flush()
+}
+
+func tableColumnsFor(v interface{}, wide bool) []tableColumn {
+	var columns, wideColumns []tableColumn
+
+	switch value := v.(type) {
+	case selectedFields:
+		for _, name := range value.names {
+			columns = append(columns, tableColumn{header: strings.ToUpper(name), path: name})
+		}
+	case *models.ActualLRP:
+		columns = []tableColumn{
+			{header: "PROCESS GUID", path: "process_guid"},
+			{header: "INDEX", path: "index"},
+			{header: "STATE", path: "state"},
+			{header: "CELL ID", path: "cell_id"},
+			{header: "SINCE", path: "since", format: formatTimestamp},
+		}
+		wideColumns = []tableColumn{
+			{header: "DOMAIN", path: "domain"},
+			{header: "INSTANCE GUID", path: "instance_guid"},
+			{header: "ADDRESS", path: "address"},
+			{header: "CRASH COUNT", path: "crash_count"},
+			{header: "PRESENCE", path: "presence", format: formatPresence},
+		}
+	case *models.ActualLRPGroup:
+		columns = []tableColumn{
+			{header: "PROCESS GUID", path: "instance.process_guid"},
+			{header: "INDEX", path: "instance.index"},
+			{header: "STATE", path: "instance.state"},
+			{header: "CELL ID", path: "instance.cell_id"},
+			{header: "SINCE", path: "instance.since", format: formatTimestamp},
+		}
+		wideColumns = []tableColumn{
+			{header: "DOMAIN", path: "instance.domain"},
+			{header: "INSTANCE GUID", path: "instance.instance_guid"},
+			{header: "CRASH COUNT", path: "instance.crash_count"},
+			{header: "EVACUATING CELL ID", path: "evacuating.cell_id"},
+		}
+	case *models.DesiredLRP, *models.DesiredLRPSchedulingInfo:
+		columns = []tableColumn{
+			{header: "PROCESS GUID", path: "process_guid"},
+			{header: "DOMAIN", path: "domain"},
+			{header: "INSTANCES", path: "instances"},
+			{header: "MEMORY MB", path: "memory_mb"},
+			{header: "DISK MB", path: "disk_mb"},
+		}
+		wideColumns = []tableColumn{
+			{header: "ROOTFS", path: "rootfs"},
+			{header: "LOG GUID", path: "log_guid"},
+			{header: "PLACEMENT TAGS", path: "placement_tags"},
+			{header: "ANNOTATION", path: "annotation"},
+		}
+	case *models.Task:
+		columns = []tableColumn{
+			{header: "TASK GUID", path: "task_guid"},
+			{header: "DOMAIN", path: "domain"},
+			{header: "STATE", path: "state", format: formatTaskState},
+			{header: "CELL ID", path: "cell_id"},
+			{header: "CREATED AT", path: "created_at", format: formatTimestamp},
+		}
+		wideColumns = []tableColumn{
+			{header: "UPDATED AT", path: "updated_at", format: formatTimestamp},
+			{header: "FAILED", path: "failed"},
+			{header: "FAILURE REASON", path: "failure_reason"},
+		}
+	case *models.CellPresence:
+		columns = []tableColumn{
+			{header: "CELL ID", path: "cell_id"},
+			{header: "ZONE", path: "zone"},
+			{header: "REP ADDRESS", path: "rep_address"},
+		}
+		wideColumns = []tableColumn{
+			{header: "MEMORY MB", path: "capacity.memory_mb"},
+			{header: "DISK MB", path: "capacity.disk_mb"},
+			{header: "CONTAINERS", path: "capacity.containers"},
+			{header: "PLACEMENT TAGS", path: "placement_tags"},
+			{header: "REP URL", path: "rep_url"},
+		}
+	case rep.CellState, *rep.CellState:
+		columns = []tableColumn{
+			{header: "CELL ID", path: "cell_id"},
+			{header: "ZONE", path: "Zone"},
+			{header: "AVAILABLE MEMORY MB", path: "AvailableResources.MemoryMB"},
+			{header: "AVAILABLE DISK MB", path: "AvailableResources.DiskMB"},
+			{header: "AVAILABLE CONTAINERS", path: "AvailableResources.Containers"},
+			{header: "LRPS", path: "LRPs", format: formatCount},
+			{header: "TASKS", path: "Tasks", format: formatCount},
+		}
+		wideColumns = []tableColumn{
+			{header: "TOTAL MEMORY MB", path: "TotalResources.MemoryMB"},
+			{header: "TOTAL DISK MB", path: "TotalResources.DiskMB"},
+			{header: "TOTAL CONTAINERS", path: "TotalResources.Containers"},
+			{header: "EVACUATING", path: "Evacuating"},
+			{header: "REP URL", path: "rep_url"},
+		}
+	case *locketmodels.Resource:
+		columns = []tableColumn{
+			{header: "KEY", path: "key"},
+			{header: "OWNER", path: "owner"},
+			{header: "VALUE", path: "value"},
+		}
+		wideColumns = []tableColumn{
+			{header: "TYPE", path: "type_code", format: formatTypeCode},
+		}
+	case *ProfileSummary:
+		columns = []tableColumn{
+			{header: "NAME", path: "name"},
+			{header: "CURRENT", path: "current"},
+			{header: "BBS URL", path: "bbs_url"},
+			{header: "LOCKET API LOCATION", path: "locket_api_location"},
+		}
+		wideColumns = []tableColumn{
+			{header: "CA CERT FILE", path: "ca_cert_file"},
+			{header: "CLIENT CERT FILE", path: "client_cert_file"},
+			{header: "CLIENT KEY FILE", path: "client_key_file"},
+			{header: "SKIP CERT VERIFY", path: "skip_cert_verify"},
+			{header: "TIMEOUT", path: "timeout"},
+		}
+	case LRPEvent, *LRPEvent, TaskEvent, *TaskEvent:
+		columns = []tableColumn{
+			{header: "TYPE", path: "type"},
+			{header: "DATA", path: "data"},
+		}
+	default:
+		columns = structColumns(reflect.TypeOf(v))
+		if len(columns) == 0 {
+			columns = []tableColumn{
+				{header: "VALUE", path: ""},
+			}
+		}
+	}
+
+	if wide {
+		return append(columns, wideColumns...)
+	}
+	return columns
+}
+
+// structColumns derives one column per JSON field of a struct, for types
+// that have no explicit columns above.
+func structColumns(t reflect.Type) []tableColumn {
+	if t == nil {
+		return nil
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	columns := []tableColumn{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Anonymous {
+			columns = append(columns, structColumns(field.Type)...)
+			continue
+		}
+		if field.PkgPath != "" {
+			continue
+		}
+
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		columns = append(columns, tableColumn{
+			header: strings.ToUpper(strings.ReplaceAll(name, "_", " ")),
+			path:   name,
+		})
+	}
+	return columns
+}
+
+// toGenericValue converts v into the same maps, slices and scalars that its
+// JSON encoding would produce, so that every output format sees the same
+// field names. Integers are kept as int64 to avoid losing precision on
+// nanosecond timestamps.
+func toGenericValue(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var generic interface{}
+	err = decoder.Decode(&generic)
+	if err != nil {
+		return nil, err
+	}
+
+	return normalizeNumbers(generic), nil
+}
+
+func normalizeNumbers(v interface{}) interface{} {
+	switch value := v.(type) {
+	case json.Number:
+		if i, err := value.Int64(); err == nil {
+			return i
+		}
+		f, _ := value.Float64()
+		return f
+	case map[string]interface{}:
+		for k, elem := range value {
+			value[k] = normalizeNumbers(elem)
+		}
+		return value
+	case []interface{}:
+		for i, elem := range value {
+			value[i] = normalizeNumbers(elem)
+		}
+		return value
+	default:
+		return value
+	}
+}
+
+// lookupPath resolves a dotted path such as "capacity.memory_mb" or
+// "ports.0.host_port" against a generic value. The empty path returns the
+// value itself.
+func lookupPath(v interface{}, path string) (interface{}, bool) {
+	if path == "" {
+		return v, true
+	}
+
+	current := v
+	for _, segment := range strings.Split(path, ".") {
+		switch value := current.(type) {
+		case map[string]interface{}:
+			next, ok := value[segment]
+			if !ok {
+				return nil, false
+			}
+			current = next
+		case []interface{}:
+			i, err := strconv.Atoi(segment)
+			if err != nil || i < 0 || i >= len(value) {
+				return nil, false
+			}
+			current = value[i]
+		default:
+			return nil, false
+		}
+	}
+
+	return current, true
+}
+
+func formatValue(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case int64:
+		return strconv.FormatInt(value, 10)
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(value)
+	case []interface{}:
+		elems := make([]string, len(value))
+		for i, elem := range value {
+			elems[i] = formatValue(elem)
+		}
+		return strings.Join(elems, ",")
+	default:
+		data, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Sprintf("%v", value)
+		}
+		return string(data)
+	}
+}
+
+func formatTimestamp(v interface{}) string {
+	nanos, ok := v.(int64)
+	if !ok {
+		return formatValue(v)
+	}
+	if nanos == 0 {
+		return ""
+	}
+	return time.Unix(0, nanos).UTC().Format(time.RFC3339)
+}
+
+func formatCount(v interface{}) string {
+	values, _ := v.([]interface{})
+	return strconv.Itoa(len(values))
+}
+
+func formatTaskState(v interface{}) string {
+	if state, ok := v.(int64); ok {
+		return models.Task_State(state).String()
+	}
+	return formatValue(v)
+}
+
+func formatPresence(v interface{}) string {
+	if presence, ok := v.(int64); ok {
+		return models.ActualLRP_Presence(presence).String()
+	}
+	return formatValue(v)
+}
+
+func formatTypeCode(v interface{}) string {
+	if typeCode, ok := v.(int64); ok {
+		return locketmodels.TypeCode(typeCode).String()
+	}
+	return formatValue(v)
+}
@@ -0,0 +1,310 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"code.cloudfoundry.org/bbs/models"
+	locketmodels "code.cloudfoundry.org/locket/models"
+)
+
+var filterOperators = []string{"==", "!=", ">=", "<=", ">", "<"}
+
+// filterEnumNames names the enums that encode as numbers, by the last
+// segment of their path, so that == and != can compare them by the name the
+// table output prints, e.g. state==Running for tasks.
+var filterEnumNames = map[string]func(int64) string{
+	"state":     func(n int64) string { return models.Task_State(n).String() },
+	"presence":  func(n int64) string { return models.ActualLRP_Presence(n).String() },
+	"type_code": func(n int64) string { return locketmodels.TypeCode(n).String() },
+}
+
+// errors
+var (
+	errUnterminatedFilterQuote = errors.New("Invalid filter: unterminated quote")
+)
+
+// Filter is a parsed --where expression: conditions of the form
+// PATH OPERATOR VALUE joined with && and ||, where && binds tighter. Values
+// may be quoted with ' or " to contain spaces, && or ||.
+type Filter struct {
+	alternatives [][]filterCondition
+}
+
+type filterCondition struct {
+	path     string
+	operator string
+	value    string
+}
+
+func ParseFilter(expression string) (*Filter, error) {
+	if strings.TrimSpace(expression) == "" {
+		return nil, nil
+	}
+
+	alternatives, err := splitFilterExpression(expression, "||")
+	if err != nil {
+		return nil, err
+	}
+
+	filter := &Filter{}
+	for _, alternative := range alternatives {
+		terms, err := splitFilterExpression(alternative, "&&")
+		if err != nil {
+			return nil, err
+		}
+
+		conditions := []filterCondition{}
+		for _, term := range terms {
+			condition, err := parseFilterCondition(term)
+			if err != nil {
+				return nil, err
+			}
+			conditions = append(conditions, condition)
+		}
+		filter.alternatives = append(filter.alternatives, conditions)
+	}
+
+	return filter, nil
+}
+
+// splitFilterExpression splits the expression on the separators that are not
+// inside a quoted value.
+func splitFilterExpression(expression, separator string) ([]string, error) {
+	parts := []string{}
+	var quote byte
+	start := 0
+	for i := 0; i < len(expression); i++ {
+		switch c := expression[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case strings.HasPrefix(expression[i:], separator):
+			parts = append(parts, expression[start:i])
+			start = i + len(separator)
+			i += len(separator) - 1
+		}
+	}
+	if quote != 0 {
+		return nil, errUnterminatedFilterQuote
+	}
+	return append(parts, expression[start:]), nil
+}
+
+func parseFilterCondition(term string) (filterCondition, error) {
+	term = strings.TrimSpace(term)
+
+	for i := 0; i < len(term); i++ {
+		for _, operator := range filterOperators {
+			if !strings.HasPrefix(term[i:], operator) {
+				continue
+			}
+
+			path := strings.TrimSpace(term[:i])
+			if path == "" {
+				return filterCondition{}, fmt.Errorf("Invalid filter condition '%s': missing field", term)
+			}
+
+			return filterCondition{
+				path:     path,
+				operator: operator,
+				value:    unquote(strings.TrimSpace(term[i+len(operator):])),
+			}, nil
+		}
+	}
+
+	return filterCondition{}, fmt.Errorf("Invalid filter condition '%s': expected one of %s", term, strings.Join(filterOperators, ", "))
+}
+
+func unquote(value string) string {
+	if len(value) >= 2 {
+		first, last := value[0], value[len(value)-1]
+		if first == last && (first == '"' || first == '\'') {
+			return value[1 : len(value)-1]
+		}
+	}
+	return value
+}
+
+// Match reports whether the generic value, as returned by toGenericValue,
+// satisfies the filter.
+func (f *Filter) Match(v interface{}) bool {
+	for _, conditions := range f.alternatives {
+		matched := true
+		for _, condition := range conditions {
+			if !condition.match(v) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+func (c filterCondition) match(v interface{}) bool {
+	actual, found := lookupPath(v, c.path)
+
+	switch c.operator {
+	case "==":
+		return found && c.equal(actual)
+	case "!=":
+		return !found || !c.equal(actual)
+	}
+
+	if !found {
+		return false
+	}
+
+	var comparison int
+	actualNumber, actualIsNumber := toFloat(actual)
+	expectedNumber, err := strconv.ParseFloat(c.value, 64)
+	if actualIsNumber && err == nil {
+		switch {
+		case actualNumber < expectedNumber:
+			comparison = -1
+		case actualNumber > expectedNumber:
+			comparison = 1
+		}
+	} else {
+		comparison = strings.Compare(formatValue(actual), c.value)
+	}
+
+	switch c.operator {
+	case ">":
+		return comparison > 0
+	case ">=":
+		return comparison >= 0
+	case "<":
+		return comparison < 0
+	default:
+		return comparison <= 0
+	}
+}
+
+func (c filterCondition) equal(actual interface{}) bool {
+	if actualNumber, ok := toFloat(actual); ok {
+		if expectedNumber, err := strconv.ParseFloat(c.value, 64); err == nil {
+			return actualNumber == expectedNumber
+		}
+	}
+
+	if number, ok := actual.(int64); ok {
+		segments := strings.Split(c.path, ".")
+		if name, ok := filterEnumNames[segments[len(segments)-1]]; ok {
+			return strings.EqualFold(name(number), c.value)
+		}
+	}
+
+	return formatValue(actual) == c.value
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch value := v.(type) {
+	case int64:
+		return float64(value), true
+	case float64:
+		return value, true
+	default:
+		return 0, false
+	}
+}
+
+// selectedFields is the projection of a value onto the --fields paths. It
+// encodes as a JSON object whose keys keep the order the fields were given in.
+type selectedFields struct {
+	names  []string
+	values []interface{}
+}
+
+func selectFields(v interface{}, fields []string) selectedFields {
+	selected := selectedFields{
+		names:  fields,
+		values: make([]interface{}, len(fields)),
+	}
+	for i, field := range fields {
+		selected.values[i], _ = lookupPath(v, field)
+	}
+	return selected
+}
+
+func (s selectedFields) MarshalJSON() ([]byte, error) {
+	var buffer bytes.Buffer
+	buffer.WriteByte('{')
+	for i, name := range s.names {
+		if i > 0 {
+			buffer.WriteByte(',')
+		}
+
+		key, err := json.Marshal(name)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(s.values[i])
+		if err != nil {
+			return nil, err
+		}
+
+		buffer.Write(key)
+		buffer.WriteByte(':')
+		buffer.Write(value)
+	}
+	buffer.WriteByte('}')
+	return buffer.Bytes(), nil
+}
+
+// filteringEncoder applies --where and --fields to each value before handing
+// it to the encoder for the selected output format.
+type filteringEncoder struct {
+	encoder Encoder
+	filter  *Filter
+	fields  []string
+}
+
+func (e *filteringEncoder) Encode(v interface{}) error {
+	generic, err := toGenericValue(v)
+	if err != nil {
+		return err
+	}
+
+	if e.filter != nil && !e.filter.Match(generic) {
+		return nil
+	}
+
+	if len(e.fields) > 0 {
+		return e.encoder.Encode(selectFields(generic, e.fields))
+	}
+
+	return e.encoder.Encode(v)
+}
+
+func (e *filteringEncoder) Flush() error {
+	return e.encoder.Flush()
+}
+
+// EncodeUnfiltered encodes v without applying --where and --fields.
+func (e *filteringEncoder) EncodeUnfiltered(v interface{}) error {
+	return e.encoder.Encode(v)
+}
+
+// unfilteredEncoder is implemented by encoders that can skip --where and
+// --fields for values that must always be printed.
+type unfilteredEncoder interface {
+	EncodeUnfiltered(v interface{}) error
+}
+
+func encodeUnfiltered(encoder Encoder, v interface{}) error {
+	if unfiltered, ok := encoder.(unfilteredEncoder); ok {
+		return unfiltered.EncodeUnfiltered(v)
+	}
+	return encoder.Encode(v)
+}
@@ -0,0 +1,158 @@
+package commands_test
+
+import (
+	"strings"
+
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/cfdot/commands"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("Output Filtering", func() {
+	var (
+		stdout     *gbytes.Buffer
+		actualLRPs []interface{}
+	)
+
+	BeforeEach(func() {
+		stdout = gbytes.NewBuffer()
+
+		actualLRPs = []interface{}{
+			&models.ActualLRP{
+				ActualLRPKey:         models.ActualLRPKey{ProcessGuid: "process-guid-1", Index: 0, Domain: "cf-apps"},
+				ActualLRPInstanceKey: models.ActualLRPInstanceKey{InstanceGuid: "instance-guid-1", CellId: "cell-1"},
+				State:                models.ActualLRPStateRunning,
+			},
+			&models.ActualLRP{
+				ActualLRPKey: models.ActualLRPKey{ProcessGuid: "process-guid-2", Index: 1, Domain: "cf-apps"},
+				State:        models.ActualLRPStateCrashed,
+				CrashCount:   5,
+			},
+			&models.ActualLRP{
+				ActualLRPKey: models.ActualLRPKey{ProcessGuid: "process-guid-3", Index: 2, Domain: "other"},
+				State:        models.ActualLRPStateCrashed,
+				CrashCount:   1,
+			},
+		}
+	})
+
+	encodeAll := func(config commands.OutputConfig) []string {
+		encoder := commands.NewEncoder(stdout, config)
+		for _, v := range actualLRPs {
+			Expect(encoder.Encode(v)).To(Succeed())
+		}
+		Expect(encoder.Flush()).To(Succeed())
+
+		output := strings.TrimRight(string(stdout.Contents()), "\n")
+		if output == "" {
+			return []string{}
+		}
+		return strings.Split(output, "\n")
+	}
+
+	mustParse := func(expression string) *commands.Filter {
+		filter, err := commands.ParseFilter(expression)
+		Expect(err).NotTo(HaveOccurred())
+		return filter
+	}
+
+	Describe("ParseFilter", func() {
+		It("returns nil for an empty expression", func() {
+			Expect(mustParse("  ")).To(BeNil())
+		})
+
+		It("returns an error when a condition has no operator", func() {
+			_, err := commands.ParseFilter("state==CRASHED && crash_count")
+			Expect(err).To(MatchError("Invalid filter condition 'crash_count': expected one of ==, !=, >=, <=, >, <"))
+		})
+
+		It("returns an error when a condition has no field", func() {
+			_, err := commands.ParseFilter("==CRASHED")
+			Expect(err).To(MatchError("Invalid filter condition '==CRASHED': missing field"))
+		})
+
+		It("returns an error for an unterminated quote", func() {
+			_, err := commands.ParseFilter("domain=='cf-apps")
+			Expect(err).To(MatchError("Invalid filter: unterminated quote"))
+		})
+	})
+
+	Describe("--where", func() {
+		It("only outputs matching values", func() {
+			output := encodeAll(commands.OutputConfig{Where: mustParse("state==CRASHED && crash_count>3")})
+			Expect(output).To(HaveLen(1))
+			Expect(output[0]).To(ContainSubstring(`"process_guid":"process-guid-2"`))
+		})
+
+		It("supports alternatives", func() {
+			output := encodeAll(commands.OutputConfig{Where: mustParse("index==0 || domain=='other'")})
+			Expect(output).To(HaveLen(2))
+			Expect(output[0]).To(ContainSubstring(`"process_guid":"process-guid-1"`))
+			Expect(output[1]).To(ContainSubstring(`"process_guid":"process-guid-3"`))
+		})
+
+		It("compares numbers numerically", func() {
+			output := encodeAll(commands.OutputConfig{Where: mustParse("index>=1 && index<2")})
+			Expect(output).To(HaveLen(1))
+			Expect(output[0]).To(ContainSubstring(`"process_guid":"process-guid-2"`))
+		})
+
+		It("does not split quoted values", func() {
+			actualLRPs[2].(*models.ActualLRP).Domain = "a && b || c"
+
+			output := encodeAll(commands.OutputConfig{Where: mustParse(`domain=="a && b || c" || domain=='x||y'`)})
+			Expect(output).To(HaveLen(1))
+			Expect(output[0]).To(ContainSubstring(`"process_guid":"process-guid-3"`))
+		})
+
+		It("compares numeric enums by name", func() {
+			actualLRPs = []interface{}{
+				&models.Task{TaskGuid: "task-1", State: models.Task_Running},
+				&models.Task{TaskGuid: "task-2", State: models.Task_Pending},
+			}
+
+			output := encodeAll(commands.OutputConfig{Where: mustParse("state==Running")})
+			Expect(output).To(HaveLen(1))
+			Expect(output[0]).To(ContainSubstring(`"task_guid":"task-1"`))
+		})
+
+		It("treats missing fields as not equal", func() {
+			output := encodeAll(commands.OutputConfig{Where: mustParse("missing!=1")})
+			Expect(output).To(HaveLen(3))
+
+			stdout = gbytes.NewBuffer()
+			output = encodeAll(commands.OutputConfig{Where: mustParse("modification_tag.missing==1")})
+			Expect(output).To(BeEmpty())
+		})
+	})
+
+	Describe("--fields", func() {
+		It("outputs the selected fields in order as json", func() {
+			output := encodeAll(commands.OutputConfig{Fields: []string{"process_guid", "index", "missing"}})
+			Expect(output).To(Equal([]string{
+				`{"process_guid":"process-guid-1","index":0,"missing":null}`,
+				`{"process_guid":"process-guid-2","index":1,"missing":null}`,
+				`{"process_guid":"process-guid-3","index":2,"missing":null}`,
+			}))
+		})
+
+		It("uses the fields as table columns", func() {
+			output := encodeAll(commands.OutputConfig{
+				Format: "table",
+				Fields: []string{"process_guid", "crash_count"},
+				Where:  mustParse("state==CRASHED"),
+			})
+			Expect(output).To(HaveLen(3))
+			Expect(strings.Fields(output[0])).To(Equal([]string{"PROCESS_GUID", "CRASH_COUNT"}))
+			Expect(strings.Fields(output[1])).To(Equal([]string{"process-guid-2", "5"}))
+			Expect(strings.Fields(output[2])).To(Equal([]string{"process-guid-3", "1"}))
+		})
+
+		It("outputs the selected fields as yaml", func() {
+			encodeAll(commands.OutputConfig{Format: "yaml", Fields: []string{"process_guid", "state"}})
+			Expect(string(stdout.Contents())).To(ContainSubstring("process_guid: process-guid-1\nstate: RUNNING\n"))
+		})
+	})
+})
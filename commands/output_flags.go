@@ -0,0 +1,102 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	OutputFormatJSON  = "json"
+	OutputFormatTable = "table"
+	OutputFormatWide  = "wide"
+	OutputFormatYAML  = "yaml"
+)
+
+var validOutputFormats = []string{
+	OutputFormatJSON,
+	OutputFormatTable,
+	OutputFormatWide,
+	OutputFormatYAML,
+}
+
+type OutputConfig struct {
+	Format   string
+	Fields   []string
+	Where    *Filter
+	Template *template.Template
+}
+
+var (
+	Output OutputConfig
+
+	outputWhereFlag    string
+	outputTemplateFlag string
+)
+
+var (
+	errTemplateWithFields = errors.New("--template cannot be combined with --fields")
+	errTemplateWithOutput = errors.New("--template cannot be combined with --output")
+)
+
+func init() {
+	AddOutputFlags(RootCmd)
+}
+
+func AddOutputFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().StringVar(&Output.Format, "output", OutputFormatJSON, fmt.Sprintf("output format, one of: %s [environment variable equivalent: CFDOT_OUTPUT]", strings.Join(validOutputFormats, ", ")))
+	cmd.PersistentFlags().StringSliceVar(&Output.Fields, "fields", nil, "comma-separated list of dotted JSON paths to output, e.g. process_guid,index,state")
+	cmd.PersistentFlags().StringVar(&outputWhereFlag, "where", "", "only output values matching the expression, e.g. 'state==CRASHED && crash_count>3'")
+	cmd.PersistentFlags().StringVar(&outputTemplateFlag, "template", "", "render each value with a Go template instead of --output, e.g. '{{.ProcessGuid}} {{.Index}} {{.State}}'")
+	cmd.PersistentPreRunE = OutputPrehook
+}
+
+func OutputPrehook(cmd *cobra.Command, args []string) error {
+	if !cmd.Flags().Changed("output") && os.Getenv("CFDOT_OUTPUT") != "" {
+		Output.Format = os.Getenv("CFDOT_OUTPUT")
+	}
+
+	err := ValidateOutputFormat(cmd, Output.Format)
+	if err != nil {
+		return err
+	}
+
+	Output.Where, err = ParseFilter(outputWhereFlag)
+	if err != nil {
+		return NewCFDotValidationError(cmd, err)
+	}
+
+	Output.Template = nil
+	if outputTemplateFlag != "" {
+		if len(Output.Fields) > 0 {
+			return NewCFDotValidationError(cmd, errTemplateWithFields)
+		}
+		if cmd.Flags().Changed("output") && Output.Format != OutputFormatJSON {
+			return NewCFDotValidationError(cmd, errTemplateWithOutput)
+		}
+
+		Output.Template, err = ParseOutputTemplate(outputTemplateFlag)
+		if err != nil {
+			return NewCFDotValidationError(cmd, err)
+		}
+	}
+
+	return nil
+}
+
+func ValidateOutputFormat(cmd *cobra.Command, format string) error {
+	for _, valid := range validOutputFormats {
+		if format == valid {
+			return nil
+		}
+	}
+
+	return NewCFDotValidationError(
+		cmd,
+		fmt.Errorf("'%s' is not a valid output format. Please specify one of: %s", format, strings.Join(validOutputFormats, ", ")),
+	)
+}
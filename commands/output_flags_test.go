@@ -0,0 +1,154 @@
+package commands_test
+
+import (
+	"os"
+
+	"code.cloudfoundry.org/cfdot/commands"
+	"github.com/spf13/cobra"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Output Flags", func() {
+	var (
+		dummyCmd *cobra.Command
+		args     []string
+		err      error
+	)
+
+	BeforeEach(func() {
+		dummyCmd = &cobra.Command{
+			Use: "dummy",
+			Run: func(cmd *cobra.Command, args []string) {},
+		}
+		commands.AddOutputFlags(dummyCmd)
+		args = []string{}
+	})
+
+	JustBeforeEach(func() {
+		parseFlagsErr := dummyCmd.ParseFlags(args)
+		Expect(parseFlagsErr).NotTo(HaveOccurred())
+		err = dummyCmd.PersistentPreRunE(dummyCmd, dummyCmd.Flags().Args())
+	})
+
+	It("defaults to json", func() {
+		Expect(err).NotTo(HaveOccurred())
+		Expect(commands.Output.Format).To(Equal("json"))
+	})
+
+	Context("when a valid format is passed", func() {
+		BeforeEach(func() {
+			args = []string{"--output", "table"}
+		})
+
+		It("sets the format", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(commands.Output.Format).To(Equal("table"))
+		})
+	})
+
+	Context("when an invalid format is passed", func() {
+		BeforeEach(func() {
+			args = []string{"--output", "xml"}
+		})
+
+		It("returns a validation error", func() {
+			Expect(err).To(MatchError("'xml' is not a valid output format. Please specify one of: json, table, wide, yaml"))
+			Expect(err.(commands.CFDotError).ExitCode()).To(Equal(3))
+		})
+	})
+
+	Context("when --fields and --where are passed", func() {
+		BeforeEach(func() {
+			args = []string{"--fields", "process_guid,index", "--where", "state==CRASHED"}
+		})
+
+		It("sets the fields and parses the filter", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(commands.Output.Fields).To(Equal([]string{"process_guid", "index"}))
+			Expect(commands.Output.Where).NotTo(BeNil())
+		})
+	})
+
+	Context("when an invalid --where expression is passed", func() {
+		BeforeEach(func() {
+			args = []string{"--where", "state"}
+		})
+
+		It("returns a validation error", func() {
+			Expect(err).To(MatchError("Invalid filter condition 'state': expected one of ==, !=, >=, <=, >, <"))
+			Expect(err.(commands.CFDotError).ExitCode()).To(Equal(3))
+		})
+	})
+
+	Context("when --template is passed", func() {
+		BeforeEach(func() {
+			args = []string{"--template", "{{.ProcessGuid}}"}
+		})
+
+		It("parses the template", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(commands.Output.Template).NotTo(BeNil())
+		})
+
+		Context("and the template is invalid", func() {
+			BeforeEach(func() {
+				args = []string{"--template", "{{.ProcessGuid"}
+			})
+
+			It("returns a validation error", func() {
+				Expect(err).To(HaveOccurred())
+				Expect(err.(commands.CFDotError).ExitCode()).To(Equal(3))
+			})
+		})
+
+		Context("and --fields is passed", func() {
+			BeforeEach(func() {
+				args = append(args, "--fields", "process_guid")
+			})
+
+			It("returns a validation error", func() {
+				Expect(err).To(MatchError("--template cannot be combined with --fields"))
+				Expect(err.(commands.CFDotError).ExitCode()).To(Equal(3))
+			})
+		})
+
+		Context("and another --output format is passed", func() {
+			BeforeEach(func() {
+				args = append(args, "--output", "table")
+			})
+
+			It("returns a validation error", func() {
+				Expect(err).To(MatchError("--template cannot be combined with --output"))
+				Expect(err.(commands.CFDotError).ExitCode()).To(Equal(3))
+			})
+		})
+	})
+
+	Context("when CFDOT_OUTPUT is set", func() {
+		BeforeEach(func() {
+			os.Setenv("CFDOT_OUTPUT", "yaml")
+		})
+
+		AfterEach(func() {
+			os.Unsetenv("CFDOT_OUTPUT")
+		})
+
+		It("uses the environment variable", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(commands.Output.Format).To(Equal("yaml"))
+		})
+
+		Context("and the flag is passed", func() {
+			BeforeEach(func() {
+				args = []string{"--output", "wide"}
+			})
+
+			It("prefers the flag", func() {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(commands.Output.Format).To(Equal("wide"))
+			})
+		})
+	})
+})
@@ -0,0 +1,145 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"text/template"
+	"time"
+)
+
+var templateFuncs = template.FuncMap{
+	"timestamp": templateTimestamp,
+	"since":     templateSince,
+	"duration":  templateDuration,
+	"bytes":     templateBytes,
+	"megabytes": templateMegabytes,
+	"json":      templateJSON,
+	"join":      strings.Join,
+	"upper":     strings.ToUpper,
+	"lower":     strings.ToLower,
+}
+
+func ParseOutputTemplate(text string) (*template.Template, error) {
+	return template.New("output").Funcs(templateFuncs).Parse(text)
+}
+
+// templateEncoder renders each value through a text/template, one value per
+// line, in the same way as docker --format.
+type templateEncoder struct {
+	writer   io.Writer
+	template *template.Template
+}
+
+func (e *templateEncoder) Encode(v interface{}) error {
+	var builder strings.Builder
+	err := e.template.Execute(&builder, v)
+	if err != nil {
+		return err
+	}
+
+	output := builder.String()
+	if !strings.HasSuffix(output, "\n") {
+		output += "\n"
+	}
+
+	_, err = io.WriteString(e.writer, output)
+	return err
+}
+
+func (e *templateEncoder) Flush() error {
+	return nil
+}
+
+func templateInt(v interface{}) (int64, error) {
+	value := reflect.ValueOf(v)
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return value.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(value.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return int64(value.Float()), nil
+	default:
+		return 0, fmt.Errorf("expected a number, got %T", v)
+	}
+}
+
+// templateTimestamp formats a nanosecond timestamp such as Since or
+// CreatedAt as RFC3339. An optional Go time layout overrides the format.
+func templateTimestamp(v interface{}, layout ...string) (string, error) {
+	nanos, err := templateInt(v)
+	if err != nil {
+		return "", err
+	}
+
+	format := time.RFC3339
+	if len(layout) > 0 {
+		format = layout[0]
+	}
+	return time.Unix(0, nanos).UTC().Format(format), nil
+}
+
+// templateSince returns the time elapsed since a nanosecond timestamp,
+// rounded to the second.
+func templateSince(v interface{}) (string, error) {
+	nanos, err := templateInt(v)
+	if err != nil {
+		return "", err
+	}
+	return time.Since(time.Unix(0, nanos)).Round(time.Second).String(), nil
+}
+
+// templateDuration formats a number of nanoseconds, or a time.Duration, as
+// a duration.
+func templateDuration(v interface{}) (string, error) {
+	nanos, err := templateInt(v)
+	if err != nil {
+		return "", err
+	}
+	return time.Duration(nanos).String(), nil
+}
+
+func templateBytes(v interface{}) (string, error) {
+	bytes, err := templateInt(v)
+	if err != nil {
+		return "", err
+	}
+	return formatBytes(bytes), nil
+}
+
+// templateMegabytes formats fields such as MemoryMb and DiskMb, which are
+// expressed in megabytes.
+func templateMegabytes(v interface{}) (string, error) {
+	megabytes, err := templateInt(v)
+	if err != nil {
+		return "", err
+	}
+	return formatBytes(megabytes * 1024 * 1024), nil
+}
+
+func formatBytes(bytes int64) string {
+	const unit = 1024
+	if bytes < unit && bytes > -unit {
+		return fmt.Sprintf("%dB", bytes)
+	}
+
+	value := float64(bytes)
+	suffixes := []string{"KiB", "MiB", "GiB", "TiB", "PiB", "EiB"}
+	i := -1
+	for (value >= unit || value <= -unit) && i < len(suffixes)-1 {
+		value /= unit
+		i++
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(fmt.Sprintf("%.1f", value), "0"), ".") + suffixes[i]
+}
+
+func templateJSON(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
@@ -0,0 +1,98 @@
+package commands_test
+
+import (
+	"time"
+
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/cfdot/commands"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("Output Template", func() {
+	var (
+		stdout *gbytes.Buffer
+		config commands.OutputConfig
+	)
+
+	BeforeEach(func() {
+		stdout = gbytes.NewBuffer()
+		config = commands.OutputConfig{}
+	})
+
+	render := func(text string, values ...interface{}) error {
+		var err error
+		config.Template, err = commands.ParseOutputTemplate(text)
+		Expect(err).NotTo(HaveOccurred())
+
+		encoder := commands.NewEncoder(stdout, config)
+		for _, v := range values {
+			err = encoder.Encode(v)
+			if err != nil {
+				return err
+			}
+		}
+		return encoder.Flush()
+	}
+
+	It("renders each value on its own line", func() {
+		actualLRPs := []interface{}{
+			&models.ActualLRP{ActualLRPKey: models.ActualLRPKey{ProcessGuid: "process-guid-1", Index: 0}, State: models.ActualLRPStateRunning},
+			&models.ActualLRP{ActualLRPKey: models.ActualLRPKey{ProcessGuid: "process-guid-2", Index: 1}, State: models.ActualLRPStateCrashed},
+		}
+
+		Expect(render("{{.ProcessGuid}} {{.Index}} {{.State}}", actualLRPs...)).To(Succeed())
+		Expect(string(stdout.Contents())).To(Equal("process-guid-1 0 RUNNING\nprocess-guid-2 1 CRASHED\n"))
+	})
+
+	It("applies --where before rendering", func() {
+		var err error
+		config.Where, err = commands.ParseFilter("state==CRASHED")
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(render("{{.ProcessGuid}}",
+			&models.ActualLRP{ActualLRPKey: models.ActualLRPKey{ProcessGuid: "process-guid-1"}, State: models.ActualLRPStateRunning},
+			&models.ActualLRP{ActualLRPKey: models.ActualLRPKey{ProcessGuid: "process-guid-2"}, State: models.ActualLRPStateCrashed},
+		)).To(Succeed())
+		Expect(string(stdout.Contents())).To(Equal("process-guid-2\n"))
+	})
+
+	It("returns an error when the template cannot be executed", func() {
+		Expect(render("{{.Missing}}", &models.ActualLRP{})).NotTo(Succeed())
+	})
+
+	Describe("helpers", func() {
+		It("formats nanosecond timestamps", func() {
+			actualLRP := &models.ActualLRP{Since: 1500000000000000000}
+			Expect(render(`{{timestamp .Since}} {{timestamp .Since "2006-01-02"}}`, actualLRP)).To(Succeed())
+			Expect(string(stdout.Contents())).To(Equal("2017-07-14T02:40:00Z 2017-07-14\n"))
+		})
+
+		It("formats the time elapsed since a timestamp", func() {
+			actualLRP := &models.ActualLRP{Since: time.Now().Add(-90 * time.Second).UnixNano()}
+			Expect(render(`{{since .Since}}`, actualLRP)).To(Succeed())
+			Expect(string(stdout.Contents())).To(Equal("1m30s\n"))
+		})
+
+		It("formats durations", func() {
+			Expect(render(`{{duration .}}`, 1500*time.Millisecond)).To(Succeed())
+			Expect(string(stdout.Contents())).To(Equal("1.5s\n"))
+		})
+
+		It("formats byte sizes", func() {
+			desiredLRP := &models.DesiredLRP{MemoryMb: 1024, DiskMb: 1536}
+			Expect(render(`{{bytes 512}} {{bytes 2048}} {{megabytes .MemoryMb}} {{megabytes .DiskMb}}`, desiredLRP)).To(Succeed())
+			Expect(string(stdout.Contents())).To(Equal("512B 2KiB 1GiB 1.5GiB\n"))
+		})
+
+		It("encodes values as json", func() {
+			Expect(render(`{{json .}}`, []string{"a", "b"})).To(Succeed())
+			Expect(string(stdout.Contents())).To(Equal("[\"a\",\"b\"]\n"))
+		})
+
+		It("returns an error for non-numeric values", func() {
+			Expect(render(`{{bytes .}}`, "not-a-number")).NotTo(Succeed())
+		})
+	})
+})
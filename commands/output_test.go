@@ -0,0 +1,120 @@
+package commands_test
+
+import (
+	"strings"
+
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/cfdot/commands"
+	locketmodels "code.cloudfoundry.org/locket/models"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("Output", func() {
+	var (
+		stdout     *gbytes.Buffer
+		actualLRPs []*models.ActualLRP
+	)
+
+	BeforeEach(func() {
+		stdout = gbytes.NewBuffer()
+
+		actualLRPs = []*models.ActualLRP{
+			{
+				ActualLRPKey:         models.ActualLRPKey{ProcessGuid: "process-guid-1", Index: 0, Domain: "cf-apps"},
+				ActualLRPInstanceKey: models.ActualLRPInstanceKey{InstanceGuid: "instance-guid-1", CellId: "cell-1"},
+				State:                models.ActualLRPStateRunning,
+				Since:                1500000000000000000,
+			},
+			{
+				ActualLRPKey: models.ActualLRPKey{ProcessGuid: "process-guid-2", Index: 3, Domain: "cf-apps"},
+				State:        models.ActualLRPStateUnclaimed,
+				CrashCount:   2,
+			},
+		}
+	})
+
+	encodeAll := func(encoder commands.Encoder, values ...interface{}) {
+		for _, v := range values {
+			Expect(encoder.Encode(v)).To(Succeed())
+		}
+		Expect(encoder.Flush()).To(Succeed())
+	}
+
+	lines := func() []string {
+		return strings.Split(strings.TrimRight(string(stdout.Contents()), "\n"), "\n")
+	}
+
+	Context("json", func() {
+		It("writes one json value per line", func() {
+			encoder := commands.NewEncoder(stdout, commands.OutputConfig{Format: "json"})
+			encodeAll(encoder, actualLRPs[0], actualLRPs[1])
+
+			Expect(lines()).To(HaveLen(2))
+			Expect(lines()[0]).To(ContainSubstring(`"process_guid":"process-guid-1"`))
+			Expect(lines()[1]).To(ContainSubstring(`"process_guid":"process-guid-2"`))
+		})
+
+		It("is the default format", func() {
+			encoder := commands.NewEncoder(stdout, commands.OutputConfig{})
+			encodeAll(encoder, actualLRPs[0])
+
+			Expect(lines()).To(HaveLen(1))
+			Expect(lines()[0]).To(HavePrefix("{"))
+		})
+	})
+
+	Context("table", func() {
+		It("prints a header and one aligned row per actual lrp", func() {
+			encoder := commands.NewEncoder(stdout, commands.OutputConfig{Format: "table"})
+			encodeAll(encoder, actualLRPs[0], actualLRPs[1])
+
+			Expect(lines()).To(HaveLen(3))
+			Expect(strings.Fields(lines()[0])).To(Equal([]string{"PROCESS", "GUID", "INDEX", "STATE", "CELL", "ID", "SINCE"}))
+			Expect(strings.Fields(lines()[1])).To(Equal([]string{"process-guid-1", "0", "RUNNING", "cell-1", "2017-07-14T02:40:00Z"}))
+			Expect(strings.Fields(lines()[2])).To(Equal([]string{"process-guid-2", "3", "UNCLAIMED"}))
+			Expect(strings.Index(lines()[0], "STATE")).To(Equal(strings.Index(lines()[1], "RUNNING")))
+		})
+
+		It("prints a new header when the model type changes", func() {
+			encoder := commands.NewEncoder(stdout, commands.OutputConfig{Format: "table"})
+			encodeAll(encoder, actualLRPs[0], &locketmodels.Resource{Key: "some-key", Owner: "some-owner"})
+
+			Expect(lines()).To(HaveLen(5))
+			Expect(lines()[2]).To(BeEmpty())
+			Expect(strings.Fields(lines()[3])).To(Equal([]string{"KEY", "OWNER", "VALUE"}))
+			Expect(strings.Fields(lines()[4])).To(Equal([]string{"some-key", "some-owner"}))
+		})
+
+		It("falls back to a single value column for other types", func() {
+			encoder := commands.NewEncoder(stdout, commands.OutputConfig{Format: "table"})
+			encodeAll(encoder, "domain-1", "domain-2")
+
+			Expect(lines()).To(Equal([]string{"VALUE", "domain-1", "domain-2"}))
+		})
+	})
+
+	Context("wide", func() {
+		It("includes the additional columns", func() {
+			encoder := commands.NewEncoder(stdout, commands.OutputConfig{Format: "wide"})
+			encodeAll(encoder, actualLRPs[1])
+
+			Expect(lines()[0]).To(ContainSubstring("INSTANCE GUID"))
+			Expect(lines()[0]).To(ContainSubstring("CRASH COUNT"))
+			Expect(lines()[1]).To(ContainSubstring("cf-apps"))
+		})
+	})
+
+	Context("yaml", func() {
+		It("writes one yaml document per value using the json field names", func() {
+			encoder := commands.NewEncoder(stdout, commands.OutputConfig{Format: "yaml"})
+			encodeAll(encoder, actualLRPs[0], actualLRPs[1])
+
+			Expect(string(stdout.Contents())).To(ContainSubstring("process_guid: process-guid-1\n"))
+			Expect(string(stdout.Contents())).To(ContainSubstring("since: 1500000000000000000\n"))
+			Expect(string(stdout.Contents())).To(ContainSubstring("---\n"))
+			Expect(string(stdout.Contents())).To(ContainSubstring("process_guid: process-guid-2\n"))
+		})
+	})
+})
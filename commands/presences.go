@@ -2,7 +2,6 @@ package commands
 
 import (
 	"context"
-	"encoding/json"
 	"io"
 
 	"code.cloudfoundry.org/cfdot/commands/helpers"
@@ -57,7 +56,7 @@ func ValidatePresencesArguments(args []string) error {
 func Presences(stdout, stderr io.Writer, locketClient models.LocketClient) error {
 	logger := globalLogger.Session("presences")
 
-	encoder := json.NewEncoder(stdout)
+	encoder := newOutputEncoder(stdout)
 
 	req := &models.FetchAllRequest{TypeCode: models.PRESENCE}
 	resp, err := locketClient.FetchAll(context.Background(), req)
@@ -73,5 +72,5 @@ func Presences(stdout, stderr io.Writer, locketClient models.LocketClient) error
 		}
 	}
 
-	return nil
+	return encoder.Flush()
 }
@@ -0,0 +1,200 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/spf13/cobra"
+	yaml "gopkg.in/yaml.v3"
+)
+
+var (
+	errMissingProfileName = errors.New("No profile name given")
+)
+
+type ProfileSummary struct {
+	Name    string `json:"name"`
+	Current bool   `json:"current"`
+	Profile
+}
+
+var profileCmd = &cobra.Command{
+	Use:   "profile",
+	Short: "Manage cfdot config file profiles",
+	Long:  "Manage the named profiles in the cfdot config file (~/.cfdot/config.yml or CFDOT_CONFIG)",
+}
+
+var profileUseCmd = &cobra.Command{
+	Use:   "use PROFILE",
+	Short: "Set the default profile",
+	Long:  "Set the profile used when neither --profile nor CFDOT_PROFILE is given",
+	RunE:  profileUse,
+}
+
+var profileListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List profiles",
+	Long:  "List the profiles in the cfdot config file",
+	RunE:  profileList,
+}
+
+func init() {
+	profileCmd.AddCommand(profileUseCmd)
+	profileCmd.AddCommand(profileListCmd)
+	RootCmd.AddCommand(profileCmd)
+}
+
+func profileUse(cmd *cobra.Command, args []string) error {
+	name, err := ValidateProfileUseArguments(args)
+	if err != nil {
+		return NewCFDotValidationError(cmd, err)
+	}
+
+	path, err := ConfigFilePath()
+	if err != nil {
+		return NewCFDotValidationError(cmd, err)
+	}
+
+	err = ProfileUse(cmd.OutOrStdout(), cmd.OutOrStderr(), path, name)
+	if err != nil {
+		return NewCFDotError(cmd, err)
+	}
+
+	return nil
+}
+
+func profileList(cmd *cobra.Command, args []string) error {
+	if len(args) > 0 {
+		return NewCFDotValidationError(cmd, errExtraArguments)
+	}
+
+	path, err := ConfigFilePath()
+	if err != nil {
+		return NewCFDotValidationError(cmd, err)
+	}
+
+	err = ProfileList(cmd.OutOrStdout(), cmd.OutOrStderr(), path)
+	if err != nil {
+		return NewCFDotError(cmd, err)
+	}
+
+	return nil
+}
+
+func ValidateProfileUseArguments(args []string) (string, error) {
+	if len(args) < 1 {
+		return "", errMissingArguments
+	}
+
+	if len(args) > 1 {
+		return "", errExtraArguments
+	}
+
+	if args[0] == "" {
+		return "", errMissingProfileName
+	}
+
+	return args[0], nil
+}
+
+// ProfileUse sets current_profile in the config file at path. The rest of
+// the file, including comments, is left as it is.
+func ProfileUse(stdout, stderr io.Writer, path, name string) error {
+	config, err := LoadProfileConfig(path)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := config.Profiles[name]; !ok {
+		return fmt.Errorf("Profile '%s' not found in cfdot config file '%s'.", name, path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var document yaml.Node
+	err = yaml.Unmarshal(data, &document)
+	if err != nil {
+		return err
+	}
+
+	if len(document.Content) == 0 || document.Content[0].Kind != yaml.MappingNode {
+		return fmt.Errorf("Unable to parse cfdot config file '%s': expected a mapping", path)
+	}
+	setMappingValue(document.Content[0], "current_profile", name)
+
+	var buffer bytes.Buffer
+	encoder := yaml.NewEncoder(&buffer)
+	encoder.SetIndent(2)
+	err = encoder.Encode(&document)
+	if err != nil {
+		return err
+	}
+	err = encoder.Close()
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	err = os.WriteFile(path, buffer.Bytes(), info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "Using profile '%s' from %s\n", name, filepath.Clean(path))
+	return nil
+}
+
+func setMappingValue(mapping *yaml.Node, key, value string) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			mapping.Content[i+1].SetString(value)
+			return
+		}
+	}
+
+	valueNode := &yaml.Node{}
+	valueNode.SetString(value)
+	mapping.Content = append(
+		[]*yaml.Node{{Kind: yaml.ScalarNode, Value: key}, valueNode},
+		mapping.Content...,
+	)
+}
+
+func ProfileList(stdout, stderr io.Writer, path string) error {
+	config, err := LoadProfileConfig(path)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(config.Profiles))
+	for name := range config.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	encoder := newOutputEncoder(stdout)
+	for _, name := range names {
+		err = encoder.Encode(&ProfileSummary{
+			Name:    name,
+			Current: name == config.CurrentProfile,
+			Profile: config.Profiles[name],
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return encoder.Flush()
+}
@@ -0,0 +1,152 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// Profile holds the targeting flags for one Diego deployment. Values are
+// only used when neither the flag nor its environment variable is set.
+type Profile struct {
+	BBSURL            string `yaml:"bbs_url,omitempty" json:"bbs_url,omitempty"`
+	LocketAPILocation string `yaml:"locket_api_location,omitempty" json:"locket_api_location,omitempty"`
+	CACertFile        string `yaml:"ca_cert_file,omitempty" json:"ca_cert_file,omitempty"`
+	ClientCertFile    string `yaml:"client_cert_file,omitempty" json:"client_cert_file,omitempty"`
+	ClientKeyFile     string `yaml:"client_key_file,omitempty" json:"client_key_file,omitempty"`
+	SkipCertVerify    *bool  `yaml:"skip_cert_verify,omitempty" json:"skip_cert_verify,omitempty"`
+	Timeout           int    `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+}
+
+type ProfileConfig struct {
+	CurrentProfile string             `yaml:"current_profile,omitempty"`
+	Profiles       map[string]Profile `yaml:"profiles,omitempty"`
+}
+
+var (
+	profileName string
+)
+
+// errors
+var (
+	errMissingHomeDir = errors.New("Unable to determine the cfdot config file location. Please specify one with the 'CFDOT_CONFIG' environment variable.")
+)
+
+func init() {
+	AddProfileFlags(RootCmd)
+}
+
+func AddProfileFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().StringVar(&profileName, "profile", "", "name of the profile in the cfdot config file to target [environment variable equivalent: CFDOT_PROFILE]")
+}
+
+// ConfigFilePath returns the location of the cfdot config file, which
+// defaults to ~/.cfdot/config.yml.
+func ConfigFilePath() (string, error) {
+	if path := os.Getenv("CFDOT_CONFIG"); path != "" {
+		return path, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", errMissingHomeDir
+	}
+
+	return filepath.Join(home, ".cfdot", "config.yml"), nil
+}
+
+// LoadProfileConfig reads the config file at path. A missing file is
+// treated as an empty config.
+func LoadProfileConfig(path string) (ProfileConfig, error) {
+	config := ProfileConfig{}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return config, nil
+	}
+	if err != nil {
+		return config, err
+	}
+
+	err = yaml.Unmarshal(data, &config)
+	if err != nil {
+		return config, fmt.Errorf("Unable to parse cfdot config file '%s': %s", path, err.Error())
+	}
+
+	return config, nil
+}
+
+func selectedProfileName() string {
+	if profileName != "" {
+		return profileName
+	}
+	return os.Getenv("CFDOT_PROFILE")
+}
+
+// activeProfile returns the profile selected with --profile, CFDOT_PROFILE
+// or the config file's current_profile, in that order. It returns an empty
+// profile when none is selected. A config file that cannot be read or does
+// not contain the profile is only an error when the profile was selected
+// with --profile or CFDOT_PROFILE.
+func activeProfile(cmd *cobra.Command) (Profile, error) {
+	name := selectedProfileName()
+
+	path, err := ConfigFilePath()
+	if err != nil {
+		if name == "" {
+			return Profile{}, nil
+		}
+		return Profile{}, NewCFDotValidationError(cmd, err)
+	}
+
+	config, err := LoadProfileConfig(path)
+	if err != nil {
+		if name == "" {
+			return Profile{}, nil
+		}
+		return Profile{}, NewCFDotValidationError(cmd, err)
+	}
+
+	explicit := name != ""
+	if !explicit {
+		name = config.CurrentProfile
+	}
+	if name == "" {
+		return Profile{}, nil
+	}
+
+	profile, ok := config.Profiles[name]
+	if !ok {
+		if !explicit {
+			return Profile{}, nil
+		}
+		return Profile{}, NewCFDotValidationError(
+			cmd,
+			fmt.Errorf("Profile '%s' not found in cfdot config file '%s'.", name, path),
+		)
+	}
+
+	profile.CACertFile = expandHomeDir(profile.CACertFile)
+	profile.ClientCertFile = expandHomeDir(profile.ClientCertFile)
+	profile.ClientKeyFile = expandHomeDir(profile.ClientKeyFile)
+
+	return profile, nil
+}
+
+func expandHomeDir(path string) string {
+	if !strings.HasPrefix(path, "~/") {
+		return path
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+
+	return filepath.Join(home, path[2:])
+}
@@ -0,0 +1,222 @@
+package commands_test
+
+import (
+	"os"
+
+	"code.cloudfoundry.org/cfdot/commands"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/spf13/cobra"
+)
+
+var _ = Describe("Profile Flags", func() {
+	var (
+		dummyCmd   *cobra.Command
+		configPath string
+		args       []string
+		err        error
+	)
+
+	BeforeEach(func() {
+		configPath = os.Getenv("CFDOT_CONFIG")
+		Expect(os.WriteFile(configPath, []byte(`current_profile: staging
+profiles:
+  staging:
+    bbs_url: https://staging.example.com
+    locket_api_location: staging.example.com:8891
+    ca_cert_file: fixtures/bbsCACert.crt
+    client_cert_file: fixtures/bbsClient.crt
+    client_key_file: fixtures/bbsClient.key
+    timeout: 7
+  prod-us:
+    bbs_url: https://prod-us.example.com
+    locket_api_location: prod-us.example.com:8891
+    skip_cert_verify: true
+    client_cert_file: fixtures/bbsClient.crt
+    client_key_file: fixtures/bbsClient.key
+`), 0600)).To(Succeed())
+
+		dummyCmd = &cobra.Command{
+			Use: "dummy",
+			Run: func(cmd *cobra.Command, args []string) {},
+		}
+		commands.AddBBSAndTimeoutFlags(dummyCmd)
+		commands.AddProfileFlags(dummyCmd)
+		args = []string{}
+	})
+
+	JustBeforeEach(func() {
+		parseFlagsErr := dummyCmd.ParseFlags(args)
+		Expect(parseFlagsErr).NotTo(HaveOccurred())
+		err = dummyCmd.PreRunE(dummyCmd, dummyCmd.Flags().Args())
+	})
+
+	It("uses the current profile from the config file", func() {
+		Expect(err).NotTo(HaveOccurred())
+		Expect(commands.Config.BBSUrl).To(Equal("https://staging.example.com"))
+		Expect(commands.Config.CACertFile).To(Equal("fixtures/bbsCACert.crt"))
+		Expect(commands.Config.CertFile).To(Equal("fixtures/bbsClient.crt"))
+		Expect(commands.Config.KeyFile).To(Equal("fixtures/bbsClient.key"))
+		Expect(commands.Config.SkipCertVerify).To(BeFalse())
+		Expect(commands.Config.Timeout).To(Equal(7))
+	})
+
+	Context("when --profile is passed", func() {
+		BeforeEach(func() {
+			args = []string{"--profile", "prod-us"}
+		})
+
+		It("uses the named profile", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(commands.Config.BBSUrl).To(Equal("https://prod-us.example.com"))
+			Expect(commands.Config.SkipCertVerify).To(BeTrue())
+			Expect(commands.Config.Timeout).To(Equal(0))
+		})
+
+		Context("and the profile does not exist", func() {
+			BeforeEach(func() {
+				args = []string{"--profile", "missing"}
+			})
+
+			It("returns a validation error", func() {
+				Expect(err).To(MatchError("Profile 'missing' not found in cfdot config file '" + configPath + "'."))
+				Expect(err.(commands.CFDotError).ExitCode()).To(Equal(3))
+			})
+		})
+	})
+
+	Context("when CFDOT_PROFILE is set", func() {
+		BeforeEach(func() {
+			os.Setenv("CFDOT_PROFILE", "prod-us")
+		})
+
+		AfterEach(func() {
+			os.Unsetenv("CFDOT_PROFILE")
+		})
+
+		It("uses the named profile", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(commands.Config.BBSUrl).To(Equal("https://prod-us.example.com"))
+		})
+
+		Context("and --profile is passed", func() {
+			BeforeEach(func() {
+				args = []string{"--profile", "staging"}
+			})
+
+			It("uses the flag", func() {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(commands.Config.BBSUrl).To(Equal("https://staging.example.com"))
+			})
+		})
+	})
+
+	Context("when environment variables are set", func() {
+		BeforeEach(func() {
+			os.Setenv("BBS_URL", "https://env.example.com")
+			os.Setenv("CFDOT_TIMEOUT", "3")
+		})
+
+		AfterEach(func() {
+			os.Unsetenv("BBS_URL")
+			os.Unsetenv("CFDOT_TIMEOUT")
+		})
+
+		It("prefers them over the profile", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(commands.Config.BBSUrl).To(Equal("https://env.example.com"))
+			Expect(commands.Config.Timeout).To(Equal(3))
+			Expect(commands.Config.CACertFile).To(Equal("fixtures/bbsCACert.crt"))
+		})
+
+		Context("and flags are passed", func() {
+			BeforeEach(func() {
+				args = []string{"--bbsURL", "https://flag.example.com", "--timeout", "1"}
+			})
+
+			It("prefers the flags", func() {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(commands.Config.BBSUrl).To(Equal("https://flag.example.com"))
+				Expect(commands.Config.Timeout).To(Equal(1))
+			})
+		})
+	})
+
+	Context("when --timeout 0 is passed", func() {
+		BeforeEach(func() {
+			args = []string{"--timeout", "0"}
+		})
+
+		It("disables the timeout of the profile", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(commands.Config.Timeout).To(Equal(0))
+		})
+	})
+
+	Context("when the config file cannot be parsed", func() {
+		BeforeEach(func() {
+			Expect(os.WriteFile(configPath, []byte("profiles: ["), 0600)).To(Succeed())
+		})
+
+		It("ignores it when no profile is selected", func() {
+			Expect(err).To(MatchError("BBS URL not set. Please specify one with the '--bbsURL' flag or the 'BBS_URL' environment variable."))
+		})
+
+		Context("and all the settings are passed as flags", func() {
+			BeforeEach(func() {
+				args = []string{
+					"--bbsURL", "https://flag.example.com",
+					"--timeout", "1",
+					"--caCertFile", "fixtures/bbsCACert.crt",
+					"--clientCertFile", "fixtures/bbsClient.crt",
+					"--clientKeyFile", "fixtures/bbsClient.key",
+				}
+			})
+
+			It("does not error", func() {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(commands.Config.BBSUrl).To(Equal("https://flag.example.com"))
+			})
+		})
+
+		Context("and --profile is passed", func() {
+			BeforeEach(func() {
+				args = []string{"--profile", "staging"}
+			})
+
+			It("returns a validation error", func() {
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(HavePrefix("Unable to parse cfdot config file '" + configPath + "'"))
+				Expect(err.(commands.CFDotError).ExitCode()).To(Equal(3))
+			})
+		})
+	})
+
+	Context("when the current profile does not exist", func() {
+		BeforeEach(func() {
+			Expect(os.WriteFile(configPath, []byte("current_profile: missing\n"), 0600)).To(Succeed())
+			args = []string{"--bbsURL", "https://flag.example.com", "--skipCertVerify", "--clientCertFile", "fixtures/bbsClient.crt", "--clientKeyFile", "fixtures/bbsClient.key"}
+		})
+
+		It("ignores it", func() {
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+
+	Context("for locket commands", func() {
+		BeforeEach(func() {
+			dummyCmd = &cobra.Command{
+				Use: "dummy",
+				Run: func(cmd *cobra.Command, args []string) {},
+			}
+			commands.AddLocketFlags(dummyCmd)
+			commands.AddProfileFlags(dummyCmd)
+		})
+
+		It("uses the profile's locket api location", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(commands.Config.LocketApiLocation).To(Equal("staging.example.com:8891"))
+		})
+	})
+})
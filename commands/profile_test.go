@@ -0,0 +1,106 @@
+package commands_test
+
+import (
+	"os"
+
+	"code.cloudfoundry.org/cfdot/commands"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("profile", func() {
+	var (
+		stdout, stderr *gbytes.Buffer
+		configPath     string
+	)
+
+	BeforeEach(func() {
+		stdout = gbytes.NewBuffer()
+		stderr = gbytes.NewBuffer()
+
+		configPath = os.Getenv("CFDOT_CONFIG")
+		Expect(os.WriteFile(configPath, []byte(`# managed by ops
+current_profile: staging
+profiles:
+  staging:
+    bbs_url: https://staging.example.com
+  prod-us:
+    # primary foundation
+    bbs_url: https://prod-us.example.com
+`), 0640)).To(Succeed())
+	})
+
+	Describe("ValidateProfileUseArguments", func() {
+		It("requires exactly one non-empty profile name", func() {
+			_, err := commands.ValidateProfileUseArguments([]string{})
+			Expect(err).To(MatchError("Missing arguments"))
+
+			_, err = commands.ValidateProfileUseArguments([]string{"a", "b"})
+			Expect(err).To(MatchError("Too many arguments specified"))
+
+			_, err = commands.ValidateProfileUseArguments([]string{""})
+			Expect(err).To(MatchError("No profile name given"))
+
+			name, err := commands.ValidateProfileUseArguments([]string{"prod-us"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(name).To(Equal("prod-us"))
+		})
+	})
+
+	Describe("ProfileUse", func() {
+		It("sets the current profile and keeps the rest of the file", func() {
+			err := commands.ProfileUse(stdout, stderr, configPath, "prod-us")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stdout).To(gbytes.Say("Using profile 'prod-us'"))
+
+			config, err := commands.LoadProfileConfig(configPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(config.CurrentProfile).To(Equal("prod-us"))
+			Expect(config.Profiles).To(HaveLen(2))
+
+			data, err := os.ReadFile(configPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(data)).To(ContainSubstring("# managed by ops"))
+			Expect(string(data)).To(ContainSubstring("# primary foundation"))
+
+			info, err := os.Stat(configPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(info.Mode().Perm()).To(Equal(os.FileMode(0640)))
+		})
+
+		It("adds current_profile when the file has none", func() {
+			Expect(os.WriteFile(configPath, []byte("profiles:\n  staging:\n    bbs_url: https://staging.example.com\n"), 0600)).To(Succeed())
+
+			err := commands.ProfileUse(stdout, stderr, configPath, "staging")
+			Expect(err).NotTo(HaveOccurred())
+
+			config, err := commands.LoadProfileConfig(configPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(config.CurrentProfile).To(Equal("staging"))
+		})
+
+		It("returns an error when the profile does not exist", func() {
+			err := commands.ProfileUse(stdout, stderr, configPath, "missing")
+			Expect(err).To(MatchError("Profile 'missing' not found in cfdot config file '" + configPath + "'."))
+		})
+	})
+
+	Describe("ProfileList", func() {
+		It("lists the profiles sorted by name", func() {
+			err := commands.ProfileList(stdout, stderr, configPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stdout).To(gbytes.Say(`{"name":"prod-us","current":false,"bbs_url":"https://prod-us.example.com"}\n`))
+			Expect(stdout).To(gbytes.Say(`{"name":"staging","current":true,"bbs_url":"https://staging.example.com"}\n`))
+		})
+
+		It("outputs nothing when there is no config file", func() {
+			Expect(os.Remove(configPath)).To(Succeed())
+
+			err := commands.ProfileList(stdout, stderr, configPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stdout.Contents()).To(BeEmpty())
+		})
+	})
+})
@@ -19,6 +19,7 @@ var releaseLockCmd = &cobra.Command{
 
 func init() {
 	AddLocketFlags(releaseLockCmd)
+	AddDryRunFlag(releaseLockCmd)
 	releaseLockCmd.Flags().StringVarP(&lockKey, "key", "k", "", "the key of the lock being releaseed")
 	releaseLockCmd.Flags().StringVarP(&lockOwner, "owner", "o", "", "the lock owner")
 	RootCmd.AddCommand(releaseLockCmd)
@@ -36,6 +37,8 @@ func releaseLock(cmd *cobra.Command, args []string) error {
 		return NewCFDotComponentError(cmd, err)
 	}
 
+	locketClient = mutatingLocketClient(cmd, locketClient)
+
 	err = ReleaseLock(
 		cmd.OutOrStdout(),
 		cmd.OutOrStderr(),
@@ -0,0 +1,120 @@
+package commands
+
+import (
+	"errors"
+	"io"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// errors
+var (
+	errMissingRecording = errors.New("No recording given")
+	errInvalidSpeed     = errors.New("--speed must not be negative")
+)
+
+// flags
+var (
+	replaySpeedFlag float64
+)
+
+var replayCmd = &cobra.Command{
+	Use:   "replay FILE",
+	Short: "Replay recorded BBS events",
+	Long:  "Print the events recorded by lrp-events or task-events with --record in the format of those commands, with the original time between them divided by --speed",
+	RunE:  replay,
+}
+
+func init() {
+	replayCmd.Flags().Float64Var(&replaySpeedFlag, "speed", 1, "speed up the replay by this factor, where 0 prints the events without waiting")
+	replayCmd.Flags().StringSliceVar(&eventFilterProcessGuidsFlag, "process-guid", nil, "replay only events for the given process guid, can be repeated")
+	replayCmd.Flags().StringVar(&eventFilterDomainFlag, "domain", "", "replay only events for the given domain")
+	replayCmd.Flags().StringSliceVar(&eventFilterTypesFlag, "type", nil, "replay only events of the given type, e.g. actual_lrp_crashed, can be repeated")
+	replayCmd.Flags().StringSliceVar(&eventFilterStatesFlag, "state", nil, "replay only events for tasks in the given state, e.g. running, can be repeated")
+
+	RootCmd.AddCommand(replayCmd)
+}
+
+func replay(cmd *cobra.Command, args []string) error {
+	path, err := ValidateReplayArguments(args)
+	if err != nil {
+		return NewCFDotValidationError(cmd, err)
+	}
+
+	filter, err := newEventFilter(append(append([]string{}, lrpEventTypes...), taskEventTypes...))
+	if err != nil {
+		return NewCFDotValidationError(cmd, err)
+	}
+
+	recording, err := OpenEventRecording(path)
+	if err != nil {
+		return NewCFDotValidationError(cmd, err)
+	}
+	defer recording.Close()
+
+	err = Replay(cmd.OutOrStdout(), cmd.OutOrStderr(), recording, replaySpeedFlag, filter)
+	if err != nil {
+		return NewCFDotError(cmd, err)
+	}
+
+	return nil
+}
+
+func ValidateReplayArguments(args []string) (string, error) {
+	switch {
+	case len(args) < 1 || args[0] == "":
+		return "", errMissingRecording
+	case len(args) > 1:
+		return "", errExtraArguments
+	case replaySpeedFlag < 0:
+		return "", errInvalidSpeed
+	}
+	return args[0], nil
+}
+
+// Replay prints the recorded events that match the filter. Every event is
+// printed once the time since the first event, divided by speed, has passed.
+// Stream reconnected markers are always printed.
+func Replay(stdout, stderr io.Writer, recording *EventRecording, speed float64, filter *EventFilter) error {
+	encoder := newOutputEncoder(stdout)
+
+	var start time.Time
+	var first *RecordedEvent
+	for {
+		recorded, event, err := recording.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if event != nil && !filter.Matches(event) {
+			continue
+		}
+
+		if first == nil {
+			start, first = time.Now(), recorded
+		} else if speed > 0 {
+			offset := time.Duration(float64(recorded.Timestamp.Sub(first.Timestamp)) / speed)
+			time.Sleep(time.Until(start.Add(offset)))
+		}
+
+		if event == nil {
+			err = printStreamReconnected(encoder)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		err = encoder.Encode(LRPEvent{Type: recorded.Type, Data: event})
+		if err != nil {
+			return err
+		}
+		err = encoder.Flush()
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package commands_test
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/bbs/events/eventfakes"
+	"code.cloudfoundry.org/bbs/fake_bbs"
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/cfdot/commands"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("Replay", func() {
+	var (
+		stdout, stderr *gbytes.Buffer
+		path           string
+	)
+
+	write := func(contents string) {
+		Expect(os.WriteFile(path, []byte(contents), 0600)).To(Succeed())
+	}
+
+	replay := func(speed float64, filter *commands.EventFilter) error {
+		recording, err := commands.OpenEventRecording(path)
+		Expect(err).NotTo(HaveOccurred())
+		defer recording.Close()
+		return commands.Replay(stdout, stderr, recording, speed, filter)
+	}
+
+	BeforeEach(func() {
+		stdout = gbytes.NewBuffer()
+		stderr = gbytes.NewBuffer()
+		path = filepath.Join(GinkgoT().TempDir(), "events.jsonl")
+
+		write(`{"timestamp":"2026-10-16T09:00:00Z","type":"actual_lrp_instance_created","data":{"actual_lrp":{"process_guid":"process-guid","index":0,"domain":"cf-apps","state":"UNCLAIMED"},"trace_id":"trace-id"}}
+{"timestamp":"2026-10-16T09:00:00.02Z","type":"stream_reconnected"}
+{"timestamp":"2026-10-16T09:00:00.04Z","type":"task_created","data":{"task":{"task_guid":"task-guid","domain":"cf-tasks","state":1}}}
+`)
+	})
+
+	Describe("recording with lrp-events", func() {
+		It("writes the printed events with their time", func() {
+			fakeBBSClient := &fake_bbs.FakeClient{}
+			fakeEventSource := &eventfakes.FakeEventSource{}
+			fakeBBSClient.SubscribeToInstanceEventsByCellIDReturns(fakeEventSource, nil)
+			actualLRP := &models.ActualLRP{ActualLRPKey: models.NewActualLRPKey("process-guid", 0, "cf-apps"), State: "RUNNING"}
+			fakeEventSource.NextReturnsOnCall(0, models.NewActualLRPInstanceCreatedEvent(actualLRP, "trace-id"), nil)
+			fakeEventSource.NextReturnsOnCall(1, nil, io.EOF)
+
+			recorder, err := commands.CreateEventRecorder(path)
+			Expect(err).NotTo(HaveOccurred())
+			err = commands.LRPEvents(stdout, stderr, fakeBBSClient, "", true, nil, nil, recorder)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(recorder.Close()).To(Succeed())
+
+			recording, err := commands.OpenEventRecording(path)
+			Expect(err).NotTo(HaveOccurred())
+			defer recording.Close()
+
+			recorded, event, err := recording.Next()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(recorded.Timestamp).To(BeTemporally("~", time.Now(), time.Minute))
+			Expect(recorded.Type).To(Equal("actual_lrp_instance_created"))
+			Expect(event).To(Equal(models.NewActualLRPInstanceCreatedEvent(actualLRP, "trace-id")))
+
+			_, _, err = recording.Next()
+			Expect(err).To(Equal(io.EOF))
+		})
+	})
+
+	It("prints the recorded events in the format of the event commands", func() {
+		err := replay(0, nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		lines := strings.Split(strings.TrimSpace(string(stdout.Contents())), "\n")
+		Expect(lines).To(HaveLen(3))
+		Expect(lines[0]).To(HavePrefix(`{"type":"actual_lrp_instance_created","data":{"actual_lrp":{"process_guid":"process-guid","index":0,"domain":"cf-apps"`))
+		Expect(lines[1]).To(Equal(`{"type":"stream_reconnected"}`))
+		Expect(lines[2]).To(HavePrefix(`{"type":"task_created","data":{"task":{"task_guid":"task-guid"`))
+	})
+
+	It("waits for the recorded time between the events divided by the speed", func() {
+		start := time.Now()
+		err := replay(1, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(time.Since(start)).To(BeNumerically(">=", 40*time.Millisecond))
+
+		start = time.Now()
+		err = replay(0.5, &commands.EventFilter{Types: []string{"actual_lrp_instance_created"}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(time.Since(start)).To(BeNumerically(">=", 40*time.Millisecond))
+	})
+
+	It("only prints the events matching the filter and the markers", func() {
+		err := replay(0, &commands.EventFilter{Domain: "cf-tasks"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stdout).To(gbytes.Say(`{"type":"stream_reconnected"}\n{"type":"task_created"`))
+		Expect(string(stdout.Contents())).NotTo(ContainSubstring("actual_lrp_instance_created"))
+	})
+
+	It("fails on invalid events", func() {
+		write(`{"timestamp":"2026-10-16T09:00:00Z","type":"task_created","data":{}}
+{"timestamp":"2026-10-16T09:00:01Z","type":"cell_disappeared","data":{}}
+`)
+		err := replay(0, nil)
+		Expect(err).To(MatchError("Invalid event 2 in recording '" + path + "': Unknown event type 'cell_disappeared'"))
+	})
+
+	Describe("ReplayBBSClient", func() {
+		It("serves the instance events to analysis commands", func() {
+			write(`{"timestamp":"2026-10-16T09:00:00Z","type":"actual_lrp_crashed","data":{"process_guid":"process-guid","index":0,"domain":"cf-apps","instance_guid":"instance-guid","cell_id":"cell-1","crash_count":1,"crash_reason":"oom","since":1000000000}}
+{"timestamp":"2026-10-16T09:00:00Z","type":"task_created","data":{"task":{"task_guid":"task-guid"}}}
+{"timestamp":"2026-10-16T09:00:30Z","type":"actual_lrp_crashed","data":{"process_guid":"process-guid","index":0,"domain":"cf-apps","instance_guid":"instance-guid","cell_id":"cell-1","crash_count":2,"crash_reason":"oom","since":31000000000}}
+`)
+			bbsClient, err := commands.NewReplayBBSClient(path)
+			Expect(err).NotTo(HaveOccurred())
+
+			err = commands.WatchCrashes(stdout, stderr, bbsClient, "", "", time.Minute, 2)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stdout).To(gbytes.Say(`{"process_guid":"process-guid","index":0,"domain":"cf-apps","cell_id":"cell-1","crash_count":2,"crashes_in_window":2,"window":"1m0s","crash_reason":"oom","since":31000000000}`))
+		})
+
+		It("only serves the events of the subscribed cell", func() {
+			write(`{"timestamp":"2026-10-16T09:00:00Z","type":"actual_lrp_crashed","data":{"process_guid":"process-guid","index":0,"domain":"cf-apps","instance_guid":"instance-guid","cell_id":"cell-1","crash_count":1,"since":1000000000}}
+{"timestamp":"2026-10-16T09:00:00Z","type":"desired_lrp_removed","data":{"desired_lrp":{"process_guid":"process-guid"}}}
+{"timestamp":"2026-10-16T09:00:30Z","type":"actual_lrp_crashed","data":{"process_guid":"process-guid","index":0,"domain":"cf-apps","instance_guid":"instance-guid","cell_id":"cell-2","crash_count":2,"since":31000000000}}
+`)
+			bbsClient, err := commands.NewReplayBBSClient(path)
+			Expect(err).NotTo(HaveOccurred())
+
+			es, err := bbsClient.SubscribeToInstanceEventsByCellID(nil, "cell-2")
+			Expect(err).NotTo(HaveOccurred())
+			defer es.Close()
+
+			event, err := es.Next()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(event.(*models.ActualLRPCrashedEvent).CrashCount).To(BeEquivalentTo(2))
+
+			_, err = es.Next()
+			Expect(err).To(Equal(io.EOF))
+		})
+
+		It("fails when the recording cannot be opened", func() {
+			_, err := commands.NewReplayBBSClient(filepath.Join(filepath.Dir(path), "missing.jsonl"))
+			Expect(err).To(MatchError(HavePrefix("Unable to open recording")))
+		})
+	})
+
+	Describe("ValidateReplayArguments", func() {
+		It("requires exactly one recording", func() {
+			_, err := commands.ValidateReplayArguments([]string{})
+			Expect(err).To(MatchError("No recording given"))
+
+			_, err = commands.ValidateReplayArguments([]string{"a", "b"})
+			Expect(err).To(MatchError("Too many arguments specified"))
+
+			path, err := commands.ValidateReplayArguments([]string{"events.jsonl"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(path).To(Equal("events.jsonl"))
+		})
+	})
+})
@@ -0,0 +1,290 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"code.cloudfoundry.org/bbs"
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/bbs/trace"
+	"code.cloudfoundry.org/cfdot/commands/helpers"
+	"code.cloudfoundry.org/lager/v3"
+	"github.com/spf13/cobra"
+)
+
+const (
+	InstanceEventRetired     = "retired"
+	InstanceEventMissing     = "missing"
+	InstanceEventRunning     = "running"
+	InstanceEventRescheduled = "rescheduled"
+	// InstanceEventWouldRetire replaces InstanceEventRetired with --dry-run.
+	InstanceEventWouldRetire = "would_retire"
+)
+
+// InstanceProgress is printed by restart-lrp and drain-cell when an instance
+// is retired or found missing and when its replacement is running.
+type InstanceProgress struct {
+	Event        string `json:"event"`
+	ProcessGuid  string `json:"process_guid"`
+	Index        int32  `json:"index"`
+	InstanceGuid string `json:"instance_guid"`
+	CellID       string `json:"cell_id"`
+}
+
+// errors
+var (
+	errInvalidMaxInFlight  = errors.New("--max-in-flight must be a positive integer")
+	errInvalidPollInterval = errors.New("--poll-interval must be a positive duration")
+	errInvalidWaitTimeout  = errors.New("--wait-timeout must be a positive duration")
+)
+
+// flags
+var (
+	restartLRPMaxInFlightFlag  int
+	restartLRPPollIntervalFlag time.Duration
+	restartLRPWaitTimeoutFlag  time.Duration
+)
+
+var restartLRPCmd = &cobra.Command{
+	Use:   "restart-lrp PROCESS_GUID",
+	Short: "Restart the instances of a desired LRP",
+	Long:  "Retire the actual LRPs of the given process guid in batches, waiting for the replacements of each batch to be running before retiring the next one. Stops when a replacement crashes",
+	RunE:  restartLRP,
+}
+
+func init() {
+	AddBBSAndTimeoutFlags(restartLRPCmd)
+	AddDryRunFlag(restartLRPCmd)
+
+	restartLRPCmd.Flags().IntVar(&restartLRPMaxInFlightFlag, "max-in-flight", 1, "number of instances restarted at the same time")
+	restartLRPCmd.Flags().DurationVar(&restartLRPPollIntervalFlag, "poll-interval", 2*time.Second, "interval at which the actual lrps are polled while waiting for replacements")
+	restartLRPCmd.Flags().DurationVar(&restartLRPWaitTimeoutFlag, "wait-timeout", 5*time.Minute, "maximum time to wait for the replacements of a batch to be running")
+
+	RootCmd.AddCommand(restartLRPCmd)
+}
+
+func restartLRP(cmd *cobra.Command, args []string) error {
+	processGuid, err := ValidateRestartLRPArguments(args)
+	if err != nil {
+		return NewCFDotValidationError(cmd, err)
+	}
+
+	bbsClient, err := helpers.NewBBSClient(cmd, Config)
+	if err != nil {
+		return NewCFDotError(cmd, err)
+	}
+
+	bbsClient = mutatingBBSClient(cmd, bbsClient)
+
+	err = RestartLRP(
+		cmd.OutOrStdout(),
+		cmd.OutOrStderr(),
+		bbsClient,
+		processGuid,
+		restartLRPMaxInFlightFlag,
+		restartLRPPollIntervalFlag,
+		restartLRPWaitTimeoutFlag,
+		DryRun,
+	)
+	if err != nil {
+		return NewCFDotError(cmd, err)
+	}
+
+	return nil
+}
+
+func ValidateRestartLRPArguments(args []string) (string, error) {
+	switch {
+	case len(args) < 1:
+		return "", errMissingArguments
+	case len(args) > 1:
+		return "", errExtraArguments
+	case args[0] == "":
+		return "", errInvalidProcessGuid
+	case restartLRPMaxInFlightFlag < 1:
+		return "", errInvalidMaxInFlight
+	case restartLRPPollIntervalFlag <= 0:
+		return "", errInvalidPollInterval
+	case restartLRPWaitTimeoutFlag <= 0:
+		return "", errInvalidWaitTimeout
+	}
+	return args[0], nil
+}
+
+// RestartLRP retires the instances of the desired LRP maxInFlight indices at
+// a time and waits for the replacements of each batch to be RUNNING before
+// retiring the next batch. A CRASHED replacement stops the restart. Nothing
+// is retired with dryRun, so the instances are reported as would_retire and
+// there is nothing to wait for.
+func RestartLRP(stdout, stderr io.Writer, bbsClient bbs.Client, processGuid string, maxInFlight int, pollInterval, waitTimeout time.Duration, dryRun bool) error {
+	traceID := trace.GenerateTraceID()
+	logger := trace.LoggerWithTraceInfo(globalLogger.Session("restart-lrp"), traceID)
+
+	desiredLRP, err := bbsClient.DesiredLRPByProcessGuid(logger, traceID, processGuid)
+	if err != nil {
+		return err
+	}
+
+	retiredEvent := InstanceEventRetired
+	if dryRun {
+		retiredEvent = InstanceEventWouldRetire
+	}
+
+	encoder := newOutputEncoder(stdout)
+	report := func(event string, actualLRP *models.ActualLRP) error {
+		err := encoder.Encode(newInstanceProgress(event, actualLRP))
+		if err != nil {
+			return err
+		}
+		return encoder.Flush()
+	}
+
+	for first := int32(0); first < desiredLRP.Instances; first += int32(maxInFlight) {
+		last := first + int32(maxInFlight)
+		if last > desiredLRP.Instances {
+			last = desiredLRP.Instances
+		}
+
+		actualLRPs, err := bbsClient.ActualLRPs(logger, traceID, models.ActualLRPFilter{ProcessGuid: processGuid})
+		if err != nil {
+			return err
+		}
+		instances := ordinaryActualLRPsByIndex(actualLRPs)
+
+		pending := []*pendingReplacement{}
+		for index := first; index < last; index++ {
+			key := models.NewActualLRPKey(processGuid, index, desiredLRP.Domain)
+
+			// There is nothing to retire for a missing instance, so only
+			// wait for the BBS to create it.
+			actualLRP, ok := instances[index]
+			if !ok {
+				pending = append(pending, &pendingReplacement{key: key})
+				err = report(InstanceEventMissing, &models.ActualLRP{ActualLRPKey: key})
+				if err != nil {
+					return err
+				}
+				continue
+			}
+
+			err = bbsClient.RetireActualLRP(logger, traceID, &key)
+			if err != nil {
+				return err
+			}
+			pending = append(pending, &pendingReplacement{key: key, retired: actualLRP})
+
+			err = report(retiredEvent, actualLRP)
+			if err != nil {
+				return err
+			}
+		}
+
+		if dryRun {
+			continue
+		}
+
+		err = waitForReplacements(logger, traceID, bbsClient, pending, pollInterval, waitTimeout, func(p *pendingReplacement, replacement *models.ActualLRP) (bool, error) {
+			if replacement.State != models.ActualLRPStateRunning {
+				return false, nil
+			}
+			return true, report(InstanceEventRunning, replacement)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func newInstanceProgress(event string, actualLRP *models.ActualLRP) *InstanceProgress {
+	return &InstanceProgress{
+		Event:        event,
+		ProcessGuid:  actualLRP.ProcessGuid,
+		Index:        actualLRP.Index,
+		InstanceGuid: actualLRP.InstanceGuid,
+		CellID:       actualLRP.CellId,
+	}
+}
+
+// pendingReplacement is an instance whose replacement has not been accepted
+// yet. retired is nil when the instance was missing, and may be updated to
+// wait for another replacement.
+type pendingReplacement struct {
+	key      models.ActualLRPKey
+	retired  *models.ActualLRP
+	attempts int
+}
+
+// waitForReplacements polls the actual LRPs of the pending replacements
+// until accept has accepted all of them. accept is only called with actual
+// LRPs that are not the retired ones, as told by the instance guid and the
+// since timestamp, or with any actual LRP for missing instances. A CRASHED
+// replacement is an error.
+func waitForReplacements(
+	logger lager.Logger,
+	traceID string,
+	bbsClient bbs.Client,
+	pending []*pendingReplacement,
+	pollInterval, waitTimeout time.Duration,
+	accept func(*pendingReplacement, *models.ActualLRP) (bool, error),
+) error {
+	deadline := time.After(waitTimeout)
+
+	for len(pending) > 0 {
+		select {
+		case <-deadline:
+			return fmt.Errorf("Timed out after %s waiting for %d replacement instances to be running", waitTimeout, len(pending))
+		case <-time.After(pollInterval):
+		}
+
+		instances := map[string]map[int32]*models.ActualLRP{}
+		for _, p := range pending {
+			if _, ok := instances[p.key.ProcessGuid]; ok {
+				continue
+			}
+			actualLRPs, err := bbsClient.ActualLRPs(logger, traceID, models.ActualLRPFilter{ProcessGuid: p.key.ProcessGuid})
+			if err != nil {
+				return err
+			}
+			instances[p.key.ProcessGuid] = ordinaryActualLRPsByIndex(actualLRPs)
+		}
+
+		remaining := []*pendingReplacement{}
+		for _, p := range pending {
+			replacement, ok := instances[p.key.ProcessGuid][p.key.Index]
+			if !ok || (p.retired != nil && replacement.InstanceGuid == p.retired.InstanceGuid && replacement.Since == p.retired.Since) {
+				remaining = append(remaining, p)
+				continue
+			}
+
+			if replacement.State == models.ActualLRPStateCrashed {
+				return fmt.Errorf("Replacement for index %d of %s crashed: %s", replacement.Index, replacement.ProcessGuid, replacement.CrashReason)
+			}
+
+			accepted, err := accept(p, replacement)
+			if err != nil {
+				return err
+			}
+			if !accepted {
+				remaining = append(remaining, p)
+			}
+		}
+		pending = remaining
+	}
+
+	return nil
+}
+
+func ordinaryActualLRPsByIndex(actualLRPs []*models.ActualLRP) map[int32]*models.ActualLRP {
+	instances := map[int32]*models.ActualLRP{}
+	for _, actualLRP := range actualLRPs {
+		if actualLRP.Presence == models.ActualLRP_Evacuating {
+			continue
+		}
+		instances[actualLRP.Index] = actualLRP
+	}
+	return instances
+}
@@ -0,0 +1,238 @@
+package commands_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/bbs/fake_bbs"
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/cfdot/commands"
+	"code.cloudfoundry.org/lager/v3"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("RestartLRP", func() {
+	var (
+		fakeBBSClient      *fake_bbs.FakeClient
+		stdout, stderr     *gbytes.Buffer
+		lock               sync.Mutex
+		instances          map[int32]*models.ActualLRP
+		replacementState   string
+		runningWhenRetired []int
+	)
+
+	actualLRP := func(index int32, instanceGuid, state string, since int64) *models.ActualLRP {
+		return &models.ActualLRP{
+			ActualLRPKey:         models.NewActualLRPKey("process-guid", index, "domain"),
+			ActualLRPInstanceKey: models.ActualLRPInstanceKey{InstanceGuid: instanceGuid, CellId: "cell-1"},
+			State:                state,
+			Since:                since,
+			CrashReason:          "oom",
+		}
+	}
+
+	BeforeEach(func() {
+		stdout = gbytes.NewBuffer()
+		stderr = gbytes.NewBuffer()
+		replacementState = models.ActualLRPStateRunning
+		runningWhenRetired = []int{}
+
+		instances = map[int32]*models.ActualLRP{}
+		for i := int32(0); i < 3; i++ {
+			instances[i] = actualLRP(i, fmt.Sprintf("old-%d", i), models.ActualLRPStateRunning, 1)
+		}
+
+		fakeBBSClient = &fake_bbs.FakeClient{}
+		fakeBBSClient.DesiredLRPByProcessGuidReturns(&models.DesiredLRP{ProcessGuid: "process-guid", Domain: "domain", Instances: 3}, nil)
+
+		// retired instances are replaced by an UNCLAIMED instance, which
+		// moves to replacementState on the next poll
+		fakeBBSClient.RetireActualLRPStub = func(_ lager.Logger, _ string, key *models.ActualLRPKey) error {
+			lock.Lock()
+			defer lock.Unlock()
+
+			running := 0
+			for _, instance := range instances {
+				if instance.State == models.ActualLRPStateRunning && strings.HasPrefix(instance.InstanceGuid, "new-") {
+					running++
+				}
+			}
+			runningWhenRetired = append(runningWhenRetired, running)
+
+			instances[key.Index] = actualLRP(key.Index, "", models.ActualLRPStateUnclaimed, 2)
+			return nil
+		}
+		fakeBBSClient.ActualLRPsStub = func(_ lager.Logger, _ string, filter models.ActualLRPFilter) ([]*models.ActualLRP, error) {
+			lock.Lock()
+			defer lock.Unlock()
+
+			actualLRPs := []*models.ActualLRP{}
+			for index, instance := range instances {
+				actualLRPs = append(actualLRPs, instance)
+				if instance.State == models.ActualLRPStateUnclaimed {
+					instances[index] = actualLRP(index, fmt.Sprintf("new-%d", index), replacementState, 3)
+				}
+			}
+			return actualLRPs, nil
+		}
+	})
+
+	progress := func() []commands.InstanceProgress {
+		events := []commands.InstanceProgress{}
+		decoder := json.NewDecoder(stdout)
+		for decoder.More() {
+			var event commands.InstanceProgress
+			Expect(decoder.Decode(&event)).To(Succeed())
+			events = append(events, event)
+		}
+		return events
+	}
+
+	It("restarts one instance at a time", func() {
+		err := commands.RestartLRP(stdout, stderr, fakeBBSClient, "process-guid", 1, time.Millisecond, time.Second, false)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(fakeBBSClient.RetireActualLRPCallCount()).To(Equal(3))
+		Expect(runningWhenRetired).To(Equal([]int{0, 1, 2}))
+
+		_, _, filter := fakeBBSClient.ActualLRPsArgsForCall(0)
+		Expect(filter).To(Equal(models.ActualLRPFilter{ProcessGuid: "process-guid"}))
+
+		Expect(progress()).To(Equal([]commands.InstanceProgress{
+			{Event: "retired", ProcessGuid: "process-guid", Index: 0, InstanceGuid: "old-0", CellID: "cell-1"},
+			{Event: "running", ProcessGuid: "process-guid", Index: 0, InstanceGuid: "new-0", CellID: "cell-1"},
+			{Event: "retired", ProcessGuid: "process-guid", Index: 1, InstanceGuid: "old-1", CellID: "cell-1"},
+			{Event: "running", ProcessGuid: "process-guid", Index: 1, InstanceGuid: "new-1", CellID: "cell-1"},
+			{Event: "retired", ProcessGuid: "process-guid", Index: 2, InstanceGuid: "old-2", CellID: "cell-1"},
+			{Event: "running", ProcessGuid: "process-guid", Index: 2, InstanceGuid: "new-2", CellID: "cell-1"},
+		}))
+	})
+
+	It("restarts up to max-in-flight instances at a time", func() {
+		err := commands.RestartLRP(stdout, stderr, fakeBBSClient, "process-guid", 2, time.Millisecond, time.Second, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(runningWhenRetired).To(Equal([]int{0, 0, 2}))
+	})
+
+	Context("when an instance is missing", func() {
+		BeforeEach(func() {
+			delete(instances, 1)
+
+			// the BBS creates the missing instance while the restart waits
+			// for it
+			listActualLRPs := fakeBBSClient.ActualLRPsStub
+			calls := 0
+			fakeBBSClient.ActualLRPsStub = func(logger lager.Logger, traceID string, filter models.ActualLRPFilter) ([]*models.ActualLRP, error) {
+				calls++
+				if calls == 5 {
+					lock.Lock()
+					instances[1] = actualLRP(1, "", models.ActualLRPStateUnclaimed, 2)
+					lock.Unlock()
+				}
+				return listActualLRPs(logger, traceID, filter)
+			}
+		})
+
+		It("reports it as missing and waits for it to be running", func() {
+			err := commands.RestartLRP(stdout, stderr, fakeBBSClient, "process-guid", 1, time.Millisecond, time.Second, false)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fakeBBSClient.RetireActualLRPCallCount()).To(Equal(2))
+			Expect(progress()).To(Equal([]commands.InstanceProgress{
+				{Event: "retired", ProcessGuid: "process-guid", Index: 0, InstanceGuid: "old-0", CellID: "cell-1"},
+				{Event: "running", ProcessGuid: "process-guid", Index: 0, InstanceGuid: "new-0", CellID: "cell-1"},
+				{Event: "missing", ProcessGuid: "process-guid", Index: 1},
+				{Event: "running", ProcessGuid: "process-guid", Index: 1, InstanceGuid: "new-1", CellID: "cell-1"},
+				{Event: "retired", ProcessGuid: "process-guid", Index: 2, InstanceGuid: "old-2", CellID: "cell-1"},
+				{Event: "running", ProcessGuid: "process-guid", Index: 2, InstanceGuid: "new-2", CellID: "cell-1"},
+			}))
+		})
+	})
+
+	Context("with dry-run", func() {
+		It("reports the instances that would be retired without waiting", func() {
+			bbsClient := commands.NewDryRunBBSClient(gbytes.NewBuffer(), fakeBBSClient, "https://bbs.example.com:8889")
+			err := commands.RestartLRP(stdout, stderr, bbsClient, "process-guid", 1, time.Millisecond, 20*time.Millisecond, true)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fakeBBSClient.RetireActualLRPCallCount()).To(Equal(0))
+
+			Expect(progress()).To(Equal([]commands.InstanceProgress{
+				{Event: "would_retire", ProcessGuid: "process-guid", Index: 0, InstanceGuid: "old-0", CellID: "cell-1"},
+				{Event: "would_retire", ProcessGuid: "process-guid", Index: 1, InstanceGuid: "old-1", CellID: "cell-1"},
+				{Event: "would_retire", ProcessGuid: "process-guid", Index: 2, InstanceGuid: "old-2", CellID: "cell-1"},
+			}))
+		})
+	})
+
+	Context("when a replacement crashes", func() {
+		BeforeEach(func() {
+			replacementState = models.ActualLRPStateCrashed
+		})
+
+		It("stops the restart", func() {
+			err := commands.RestartLRP(stdout, stderr, fakeBBSClient, "process-guid", 1, time.Millisecond, time.Second, false)
+			Expect(err).To(MatchError("Replacement for index 0 of process-guid crashed: oom"))
+			Expect(fakeBBSClient.RetireActualLRPCallCount()).To(Equal(1))
+		})
+	})
+
+	Context("when a replacement does not start in time", func() {
+		BeforeEach(func() {
+			replacementState = models.ActualLRPStateClaimed
+		})
+
+		It("times out", func() {
+			err := commands.RestartLRP(stdout, stderr, fakeBBSClient, "process-guid", 1, time.Millisecond, 20*time.Millisecond, false)
+			Expect(err).To(MatchError("Timed out after 20ms waiting for 1 replacement instances to be running"))
+		})
+	})
+
+	Context("when the desired lrp cannot be fetched", func() {
+		BeforeEach(func() {
+			fakeBBSClient.DesiredLRPByProcessGuidReturns(nil, models.ErrResourceNotFound)
+		})
+
+		It("returns the error", func() {
+			err := commands.RestartLRP(stdout, stderr, fakeBBSClient, "process-guid", 1, time.Millisecond, time.Second, false)
+			Expect(err).To(Equal(models.ErrResourceNotFound))
+			Expect(fakeBBSClient.RetireActualLRPCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("when retiring fails", func() {
+		BeforeEach(func() {
+			fakeBBSClient.RetireActualLRPStub = nil
+			fakeBBSClient.RetireActualLRPReturns(models.ErrUnknownError)
+		})
+
+		It("returns the error", func() {
+			err := commands.RestartLRP(stdout, stderr, fakeBBSClient, "process-guid", 1, time.Millisecond, time.Second, false)
+			Expect(err).To(Equal(models.ErrUnknownError))
+		})
+	})
+
+	Describe("ValidateRestartLRPArguments", func() {
+		It("returns the process guid", func() {
+			processGuid, err := commands.ValidateRestartLRPArguments([]string{"process-guid"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(processGuid).To(Equal("process-guid"))
+		})
+
+		It("rejects missing, extra and empty arguments", func() {
+			_, err := commands.ValidateRestartLRPArguments([]string{})
+			Expect(err).To(MatchError("Missing arguments"))
+
+			_, err = commands.ValidateRestartLRPArguments([]string{"a", "b"})
+			Expect(err).To(MatchError("Too many arguments specified"))
+
+			_, err = commands.ValidateRestartLRPArguments([]string{""})
+			Expect(err).To(MatchError("Process guid should be non empty string"))
+		})
+	})
+})
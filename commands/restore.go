@@ -0,0 +1,323 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"code.cloudfoundry.org/bbs"
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/bbs/trace"
+	"code.cloudfoundry.org/cfdot/commands/helpers"
+	"code.cloudfoundry.org/lager/v3"
+	"github.com/spf13/cobra"
+)
+
+const (
+	RestoreKindDomain = "domain"
+
+	RestoreActionCreated  = "created"
+	RestoreActionSkipped  = "skipped"
+	RestoreActionConflict = "conflict"
+	RestoreActionFailed   = "failed"
+	// RestoreActionWouldCreate replaces RestoreActionCreated with --dry-run.
+	RestoreActionWouldCreate = "would_create"
+)
+
+// RestoreRecord is a domain, a desired LRP or a task read from the output of
+// the domains, desired-lrps and tasks commands.
+type RestoreRecord struct {
+	Domain     string
+	DesiredLRP *models.DesiredLRP
+	Task       *models.Task
+}
+
+// RestoreResult is printed by restore for every record. Fields lists the
+// fields of a conflicting record that differ from the existing one.
+type RestoreResult struct {
+	Kind   string   `json:"kind"`
+	Guid   string   `json:"guid"`
+	Action string   `json:"action"`
+	Fields []string `json:"fields,omitempty"`
+	Reason string   `json:"reason,omitempty"`
+}
+
+// errors
+var (
+	errMissingRestoreFile = errors.New("No restore file given")
+	errInvalidDomainTTL   = errors.New("--domain-ttl must not be negative")
+)
+
+// flags
+var (
+	restoreDomainTTLFlag time.Duration
+)
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore FILE",
+	Short: "Restore desired LRPs, tasks and domains",
+	Long:  "Recreate the domains, desired LRPs and pending and running tasks read from FILE that are missing from the BBS. FILE holds the concatenated json output of the domains, desired-lrps and tasks commands. Existing records are skipped when they are the same and reported as conflicts otherwise",
+	RunE:  restore,
+}
+
+func init() {
+	AddBBSAndTimeoutFlags(restoreCmd)
+	AddDryRunFlag(restoreCmd)
+	restoreCmd.Flags().DurationVar(&restoreDomainTTLFlag, "domain-ttl", 0, "ttl of the restored domains, where 0 means keep fresh permanently")
+	RootCmd.AddCommand(restoreCmd)
+}
+
+func restore(cmd *cobra.Command, args []string) error {
+	path, err := ValidateRestoreArguments(args)
+	if err != nil {
+		return NewCFDotValidationError(cmd, err)
+	}
+
+	records, err := ReadRestoreRecords(path)
+	if err != nil {
+		return NewCFDotValidationError(cmd, err)
+	}
+
+	bbsClient, err := helpers.NewBBSClient(cmd, Config)
+	if err != nil {
+		return NewCFDotError(cmd, err)
+	}
+
+	bbsClient = mutatingBBSClient(cmd, bbsClient)
+
+	err = Restore(cmd.OutOrStdout(), cmd.OutOrStderr(), bbsClient, records, restoreDomainTTLFlag, DryRun)
+	if err != nil {
+		return NewCFDotError(cmd, err)
+	}
+
+	return nil
+}
+
+func ValidateRestoreArguments(args []string) (string, error) {
+	switch {
+	case len(args) < 1 || args[0] == "":
+		return "", errMissingRestoreFile
+	case len(args) > 1:
+		return "", errExtraArguments
+	case restoreDomainTTLFlag < 0:
+		return "", errInvalidDomainTTL
+	}
+	return args[0], nil
+}
+
+// ReadRestoreRecords reads the json values in the file at path. Strings are
+// domains, objects with a process_guid are desired LRPs and objects with a
+// task_guid are tasks.
+func ReadRestoreRecords(path string) ([]*RestoreRecord, error) {
+	documents, err := readSpecDocuments(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+
+	records := []*RestoreRecord{}
+	seen := map[string]int{}
+	for i, document := range documents {
+		record, err := parseRestoreRecord(document)
+		if err != nil {
+			return nil, fmt.Errorf("%s: record %d: %s", path, i+1, err)
+		}
+
+		kind, guid := record.kindAndGuid()
+		id := kind + "/" + guid
+		if other, ok := seen[id]; ok {
+			return nil, fmt.Errorf("%s: record %d: %s %s is also record %d", path, i+1, kind, guid, other)
+		}
+		seen[id] = i + 1
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+func parseRestoreRecord(document json.RawMessage) (*RestoreRecord, error) {
+	if bytes.HasPrefix(bytes.TrimSpace(document), []byte(`"`)) {
+		record := &RestoreRecord{}
+		err := json.Unmarshal(document, &record.Domain)
+		if err == nil && record.Domain == "" {
+			err = errMissingDomain
+		}
+		return record, err
+	}
+
+	spec, err := parseApplySpec("", document)
+	if err != nil {
+		return nil, err
+	}
+
+	// The BBS assigns a new modification tag to a desired LRP it creates.
+	if spec.DesiredLRP != nil {
+		spec.DesiredLRP.ModificationTag = nil
+	}
+	return &RestoreRecord{DesiredLRP: spec.DesiredLRP, Task: spec.Task}, nil
+}
+
+func (r *RestoreRecord) kindAndGuid() (string, string) {
+	switch {
+	case r.DesiredLRP != nil:
+		return ApplyKindDesiredLRP, r.DesiredLRP.ProcessGuid
+	case r.Task != nil:
+		return ApplyKindTask, r.Task.TaskGuid
+	}
+	return RestoreKindDomain, r.Domain
+}
+
+// Restore recreates the missing domains, then the missing desired LRPs and
+// then the missing tasks, and prints a RestoreResult for every record. A
+// record that fails to be created does not stop the restore, but the restore
+// fails once every record has been handled when any record failed or
+// conflicts with an existing one. Nothing is created with dryRun, so the
+// records that would be created are reported as would_create.
+func Restore(stdout, stderr io.Writer, bbsClient bbs.Client, records []*RestoreRecord, domainTTL time.Duration, dryRun bool) error {
+	traceID := trace.GenerateTraceID()
+	logger := trace.LoggerWithTraceInfo(globalLogger.Session("restore"), traceID)
+
+	freshDomains, err := bbsClient.Domains(logger, traceID)
+	if err != nil {
+		return err
+	}
+	fresh := map[string]bool{}
+	for _, domain := range freshDomains {
+		fresh[domain] = true
+	}
+
+	encoder := newOutputEncoder(stdout)
+	conflicts, failures := 0, 0
+
+	for _, kind := range []string{RestoreKindDomain, ApplyKindDesiredLRP, ApplyKindTask} {
+		for _, record := range records {
+			recordKind, guid := record.kindAndGuid()
+			if recordKind != kind {
+				continue
+			}
+
+			result := &RestoreResult{Kind: kind, Guid: guid}
+			switch kind {
+			case RestoreKindDomain:
+				restoreDomain(logger, traceID, bbsClient, fresh[guid], guid, domainTTL, result)
+			case ApplyKindDesiredLRP:
+				err = restoreDesiredLRP(logger, traceID, bbsClient, record.DesiredLRP, result)
+			case ApplyKindTask:
+				err = restoreTask(logger, traceID, bbsClient, record.Task, result)
+			}
+			if err != nil {
+				return err
+			}
+
+			if dryRun && result.Action == RestoreActionCreated {
+				result.Action = RestoreActionWouldCreate
+			}
+
+			switch result.Action {
+			case RestoreActionConflict:
+				conflicts++
+			case RestoreActionFailed:
+				failures++
+			}
+
+			err = encoder.Encode(result)
+			if err == nil {
+				err = encoder.Flush()
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if conflicts > 0 || failures > 0 {
+		return fmt.Errorf("Restore finished with %d conflicts and %d failures", conflicts, failures)
+	}
+	return nil
+}
+
+func restoreDomain(logger lager.Logger, traceID string, bbsClient bbs.Client, fresh bool, domain string, ttl time.Duration, result *RestoreResult) {
+	if fresh {
+		result.Action = RestoreActionSkipped
+		result.Reason = "Domain is already fresh"
+		return
+	}
+
+	setRestoreOutcome(result, bbsClient.UpsertDomain(logger, traceID, domain, ttl))
+}
+
+func restoreDesiredLRP(logger lager.Logger, traceID string, bbsClient bbs.Client, desiredLRP *models.DesiredLRP, result *RestoreResult) error {
+	current, err := bbsClient.DesiredLRPByProcessGuid(logger, traceID, desiredLRP.ProcessGuid)
+	if isResourceNotFound(err) {
+		setRestoreOutcome(result, bbsClient.DesireLRP(logger, traceID, desiredLRP))
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	fields, err := diffFields(current, desiredLRP)
+	if err != nil {
+		return err
+	}
+	for _, field := range fields {
+		if !desiredLRPServerFields[field.Field] {
+			result.Fields = append(result.Fields, field.Field)
+		}
+	}
+	setExistingRecordOutcome(result, "Desired LRP")
+	return nil
+}
+
+// restoreTask only recreates pending and running tasks. Tasks that already
+// completed would run a second time.
+func restoreTask(logger lager.Logger, traceID string, bbsClient bbs.Client, task *models.Task, result *RestoreResult) error {
+	if task.State != models.Task_Pending && task.State != models.Task_Running {
+		result.Action = RestoreActionSkipped
+		result.Reason = fmt.Sprintf("Only pending and running tasks are restored, the task was %s", task.State.String())
+		return nil
+	}
+
+	current, err := bbsClient.TaskByGuid(logger, traceID, task.TaskGuid)
+	if isResourceNotFound(err) {
+		setRestoreOutcome(result, bbsClient.DesireTask(logger, traceID, task.TaskGuid, task.Domain, task.TaskDefinition))
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	fields, err := diffFields(current.TaskDefinition, task.TaskDefinition)
+	if err != nil {
+		return err
+	}
+	for _, field := range fields {
+		result.Fields = append(result.Fields, field.Field)
+	}
+	if current.Domain != task.Domain {
+		result.Fields = append(result.Fields, "domain")
+	}
+	setExistingRecordOutcome(result, "Task")
+	return nil
+}
+
+func setRestoreOutcome(result *RestoreResult, err error) {
+	if err != nil {
+		result.Action = RestoreActionFailed
+		result.Reason = err.Error()
+		return
+	}
+	result.Action = RestoreActionCreated
+}
+
+func setExistingRecordOutcome(result *RestoreResult, name string) {
+	if len(result.Fields) > 0 {
+		result.Action = RestoreActionConflict
+		result.Reason = name + " already exists with different fields"
+		return
+	}
+	result.Action = RestoreActionSkipped
+	result.Reason = name + " already exists"
+}
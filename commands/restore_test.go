@@ -0,0 +1,225 @@
+package commands_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"code.cloudfoundry.org/bbs/fake_bbs"
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/cfdot/commands"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("Restore", func() {
+	var (
+		fakeBBSClient  *fake_bbs.FakeClient
+		stdout, stderr *gbytes.Buffer
+		records        []*commands.RestoreRecord
+	)
+
+	results := func() []commands.RestoreResult {
+		results := []commands.RestoreResult{}
+		decoder := json.NewDecoder(stdout)
+		for decoder.More() {
+			var result commands.RestoreResult
+			Expect(decoder.Decode(&result)).To(Succeed())
+			results = append(results, result)
+		}
+		return results
+	}
+
+	BeforeEach(func() {
+		fakeBBSClient = &fake_bbs.FakeClient{}
+		stdout = gbytes.NewBuffer()
+		stderr = gbytes.NewBuffer()
+
+		fakeBBSClient.DomainsReturns([]string{"fresh-domain"}, nil)
+		fakeBBSClient.DesiredLRPByProcessGuidReturns(nil, models.ErrResourceNotFound)
+		fakeBBSClient.TaskByGuidReturns(nil, models.ErrResourceNotFound)
+
+		records = []*commands.RestoreRecord{
+			{Task: &models.Task{TaskGuid: "task-guid", Domain: "domain", State: models.Task_Pending, TaskDefinition: &models.TaskDefinition{RootFs: "preloaded:cflinuxfs4"}}},
+			{DesiredLRP: &models.DesiredLRP{ProcessGuid: "process-guid", Domain: "domain", Instances: 2}},
+			{Domain: "domain"},
+			{Domain: "fresh-domain"},
+		}
+	})
+
+	It("recreates the missing domains, then desired lrps, then tasks", func() {
+		err := commands.Restore(stdout, stderr, fakeBBSClient, records, time.Minute, false)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(fakeBBSClient.UpsertDomainCallCount()).To(Equal(1))
+		_, _, domain, ttl := fakeBBSClient.UpsertDomainArgsForCall(0)
+		Expect(domain).To(Equal("domain"))
+		Expect(ttl).To(Equal(time.Minute))
+
+		Expect(fakeBBSClient.DesireLRPCallCount()).To(Equal(1))
+		_, _, desiredLRP := fakeBBSClient.DesireLRPArgsForCall(0)
+		Expect(desiredLRP).To(Equal(records[1].DesiredLRP))
+
+		Expect(fakeBBSClient.DesireTaskCallCount()).To(Equal(1))
+		_, _, taskGuid, taskDomain, taskDefinition := fakeBBSClient.DesireTaskArgsForCall(0)
+		Expect(taskGuid).To(Equal("task-guid"))
+		Expect(taskDomain).To(Equal("domain"))
+		Expect(taskDefinition).To(Equal(records[0].Task.TaskDefinition))
+
+		Expect(results()).To(Equal([]commands.RestoreResult{
+			{Kind: "domain", Guid: "domain", Action: "created"},
+			{Kind: "domain", Guid: "fresh-domain", Action: "skipped", Reason: "Domain is already fresh"},
+			{Kind: "desired_lrp", Guid: "process-guid", Action: "created"},
+			{Kind: "task", Guid: "task-guid", Action: "created"},
+		}))
+	})
+
+	It("skips tasks that are no longer pending or running", func() {
+		records[0].Task.State = models.Task_Completed
+
+		err := commands.Restore(stdout, stderr, fakeBBSClient, records[:1], 0, false)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fakeBBSClient.TaskByGuidCallCount()).To(Equal(0))
+		Expect(fakeBBSClient.DesireTaskCallCount()).To(Equal(0))
+		Expect(stdout).To(gbytes.Say(`"action":"skipped","reason":"Only pending and running tasks are restored, the task was Completed"`))
+	})
+
+	Context("with dry-run", func() {
+		It("reports the records that would be created", func() {
+			bbsClient := commands.NewDryRunBBSClient(gbytes.NewBuffer(), fakeBBSClient, "https://bbs.example.com:8889")
+			err := commands.Restore(stdout, stderr, bbsClient, records, time.Minute, true)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(fakeBBSClient.UpsertDomainCallCount()).To(Equal(0))
+			Expect(fakeBBSClient.DesireLRPCallCount()).To(Equal(0))
+			Expect(fakeBBSClient.DesireTaskCallCount()).To(Equal(0))
+
+			Expect(results()).To(Equal([]commands.RestoreResult{
+				{Kind: "domain", Guid: "domain", Action: "would_create"},
+				{Kind: "domain", Guid: "fresh-domain", Action: "skipped", Reason: "Domain is already fresh"},
+				{Kind: "desired_lrp", Guid: "process-guid", Action: "would_create"},
+				{Kind: "task", Guid: "task-guid", Action: "would_create"},
+			}))
+		})
+	})
+
+	Context("when the records already exist", func() {
+		BeforeEach(func() {
+			fakeBBSClient.DesiredLRPByProcessGuidReturns(&models.DesiredLRP{
+				ProcessGuid:     "process-guid",
+				Domain:          "domain",
+				Instances:       2,
+				ModificationTag: &models.ModificationTag{Epoch: "epoch", Index: 3},
+			}, nil)
+			fakeBBSClient.TaskByGuidReturns(&models.Task{
+				TaskGuid:       "task-guid",
+				Domain:         "other-domain",
+				State:          models.Task_Running,
+				TaskDefinition: &models.TaskDefinition{RootFs: "docker:///busybox"},
+			}, nil)
+		})
+
+		It("skips the same ones and reports the others as conflicts", func() {
+			err := commands.Restore(stdout, stderr, fakeBBSClient, records[:2], 0, false)
+			Expect(err).To(MatchError("Restore finished with 1 conflicts and 0 failures"))
+			Expect(fakeBBSClient.DesireLRPCallCount()).To(Equal(0))
+			Expect(fakeBBSClient.DesireTaskCallCount()).To(Equal(0))
+
+			Expect(results()).To(Equal([]commands.RestoreResult{
+				{Kind: "desired_lrp", Guid: "process-guid", Action: "skipped", Reason: "Desired LRP already exists"},
+				{Kind: "task", Guid: "task-guid", Action: "conflict", Fields: []string{"rootfs", "domain"}, Reason: "Task already exists with different fields"},
+			}))
+		})
+	})
+
+	Context("when a record cannot be created", func() {
+		BeforeEach(func() {
+			fakeBBSClient.DesireLRPReturns(models.ErrResourceExists)
+		})
+
+		It("reports the failure and restores the other records", func() {
+			err := commands.Restore(stdout, stderr, fakeBBSClient, records, 0, false)
+			Expect(err).To(MatchError("Restore finished with 0 conflicts and 1 failures"))
+			Expect(fakeBBSClient.DesireTaskCallCount()).To(Equal(1))
+			Expect(stdout).To(gbytes.Say(`{"kind":"desired_lrp","guid":"process-guid","action":"failed","reason":"` + models.ErrResourceExists.Error() + `"}`))
+		})
+	})
+
+	Context("when a record cannot be looked up", func() {
+		BeforeEach(func() {
+			fakeBBSClient.DesiredLRPByProcessGuidReturns(nil, models.ErrUnknownError)
+		})
+
+		It("stops the restore", func() {
+			err := commands.Restore(stdout, stderr, fakeBBSClient, records, 0, false)
+			Expect(err).To(Equal(models.ErrUnknownError))
+			Expect(fakeBBSClient.DesireTaskCallCount()).To(Equal(0))
+		})
+	})
+
+	Describe("ReadRestoreRecords", func() {
+		var path string
+
+		write := func(contents string) {
+			Expect(os.WriteFile(path, []byte(contents), 0600)).To(Succeed())
+		}
+
+		BeforeEach(func() {
+			path = filepath.Join(GinkgoT().TempDir(), "dump.jsonl")
+		})
+
+		It("reads the concatenated output of domains, desired-lrps and tasks", func() {
+			write(`"domain"
+{"process_guid":"process-guid","domain":"domain","instances":2,"modification_tag":{"epoch":"epoch","index":3}}
+{"task_guid":"task-guid","domain":"domain","rootfs":"preloaded:cflinuxfs4","state":1}
+`)
+
+			records, err := commands.ReadRestoreRecords(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(records).To(HaveLen(3))
+			Expect(records[0].Domain).To(Equal("domain"))
+			Expect(records[1].DesiredLRP.ProcessGuid).To(Equal("process-guid"))
+			Expect(records[1].DesiredLRP.ModificationTag).To(BeNil())
+			Expect(records[2].Task.TaskGuid).To(Equal("task-guid"))
+			Expect(records[2].Task.RootFs).To(Equal("preloaded:cflinuxfs4"))
+		})
+
+		It("rejects unknown and duplicate records", func() {
+			write(`"domain"
+{"cell_id":"cell-1"}
+`)
+			_, err := commands.ReadRestoreRecords(path)
+			Expect(err).To(MatchError(path + ": record 2: spec has neither a process_guid nor a task_guid"))
+
+			write(`{"process_guid":"process-guid"}
+"domain"
+{"process_guid":"process-guid"}
+`)
+			_, err = commands.ReadRestoreRecords(path)
+			Expect(err).To(MatchError(path + ": record 3: desired_lrp process-guid is also record 1"))
+		})
+
+		It("rejects invalid json", func() {
+			write(`{"process_guid":`)
+			_, err := commands.ReadRestoreRecords(path)
+			Expect(err).To(MatchError(HavePrefix(path + ": Invalid JSON")))
+		})
+	})
+
+	Describe("ValidateRestoreArguments", func() {
+		It("requires exactly one file", func() {
+			_, err := commands.ValidateRestoreArguments([]string{})
+			Expect(err).To(MatchError("No restore file given"))
+
+			_, err = commands.ValidateRestoreArguments([]string{"a", "b"})
+			Expect(err).To(MatchError("Too many arguments specified"))
+
+			path, err := commands.ValidateRestoreArguments([]string{"dump.jsonl"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(path).To(Equal("dump.jsonl"))
+		})
+	})
+})
@@ -1,8 +1,15 @@
 package commands
 
 import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 
 	"code.cloudfoundry.org/bbs"
 	"code.cloudfoundry.org/bbs/models"
@@ -11,19 +18,78 @@ import (
 	"github.com/spf13/cobra"
 )
 
+// ActualLRPSelector selects the actual LRPs of a bulk operation. Actual LRPs
+// must match the filter and the state, if given. When Keys is not empty,
+// only the actual LRPs with those keys are selected.
+type ActualLRPSelector struct {
+	Filter models.ActualLRPFilter
+	State  string
+	Keys   []*models.ActualLRPKey
+}
+
+// PlannedRetirement is an actual LRP selected by a bulk retirement.
+type PlannedRetirement struct {
+	ProcessGuid string `json:"process_guid"`
+	Index       int32  `json:"index"`
+	Domain      string `json:"domain"`
+	CellID      string `json:"cell_id"`
+	State       string `json:"state"`
+}
+
+// RetirementError is written to stderr as one JSON object per line for every
+// actual LRP that failed to retire.
+type RetirementError struct {
+	ProcessGuid string `json:"process_guid"`
+	Index       int32  `json:"index"`
+	Domain      string `json:"domain"`
+	Error       string `json:"error"`
+}
+
+// errors
+var (
+	errBulkRetireWithArguments = errors.New("PROCESS_GUID and INDEX cannot be combined with --cell-id, --domain, --state, --process-guid or --stdin")
+	errInvalidActualLRPState   = errors.New("--state must be one of UNCLAIMED, CLAIMED, RUNNING or CRASHED")
+	errInvalidRate             = errors.New("--rate must be a positive number")
+	errStdinWithoutYes         = errors.New("--yes is required when reading keys from stdin")
+	errRetirementAborted       = errors.New("Retirement aborted")
+)
+
+// flags
+var (
+	retireActualLRPCellIdFlag, retireActualLRPDomainFlag     string
+	retireActualLRPStateFlag, retireActualLRPProcessGuidFlag string
+	retireActualLRPStdinFlag                                 bool
+	retireActualLRPYesFlag                                   bool
+	retireActualLRPRateFlag                                  float64
+)
+
 var retireActualLRPCmd = &cobra.Command{
-	Use:   "retire-actual-lrp PROCESS_GUID INDEX",
+	Use:   "retire-actual-lrp (PROCESS_GUID INDEX | [--cell-id CELL_ID] [--domain DOMAIN] [--state STATE] [--process-guid PROCESS_GUID] [--stdin])",
 	Short: "Retire actual LRP by index and process guid",
-	Long:  "Retire actual LRP by index and process guid",
+	Long:  "Retire actual LRP by index and process guid, or retire all actual LRPs matching the given selectors. With --stdin, keys are read from stdin one per line, either as 'PROCESS_GUID INDEX' or as json actual LRPs, e.g. the output of actual-lrps",
 	RunE:  retireActualLRP,
 }
 
 func init() {
 	AddBBSAndTimeoutFlags(retireActualLRPCmd)
+	AddDryRunFlag(retireActualLRPCmd)
+
+	retireActualLRPCmd.Flags().StringVarP(&retireActualLRPCellIdFlag, "cell-id", "c", "", "retire the actual lrps on the given cell id")
+	retireActualLRPCmd.Flags().StringVarP(&retireActualLRPDomainFlag, "domain", "d", "", "retire the actual lrps in the given domain")
+	retireActualLRPCmd.Flags().StringVar(&retireActualLRPStateFlag, "state", "", "retire the actual lrps in the given state, e.g. CRASHED")
+	retireActualLRPCmd.Flags().StringVarP(&retireActualLRPProcessGuidFlag, "process-guid", "p", "", "retire the actual lrps with the given process guid")
+	retireActualLRPCmd.Flags().BoolVar(&retireActualLRPStdinFlag, "stdin", false, "retire the actual lrps with the keys read from stdin")
+	retireActualLRPCmd.Flags().BoolVarP(&retireActualLRPYesFlag, "yes", "y", false, "retire the selected actual lrps without asking for confirmation")
+	retireActualLRPCmd.Flags().Float64Var(&retireActualLRPRateFlag, "rate", 5, "maximum number of actual lrps retired per second")
+
 	RootCmd.AddCommand(retireActualLRPCmd)
 }
 
 func retireActualLRP(cmd *cobra.Command, args []string) error {
+	if isBulkRetirement() {
+		return bulkRetireActualLRPs(cmd, args)
+	}
+
 	processGuid, index, err := ValidateRetireActualLRPArgs(args)
 	if err != nil {
 		return NewCFDotValidationError(cmd, err)
@@ -34,6 +100,8 @@ func retireActualLRP(cmd *cobra.Command, args []string) error {
 		return NewCFDotError(cmd, err)
 	}
 
+	bbsClient = mutatingBBSClient(cmd, bbsClient)
+
 	err = RetireActualLRP(cmd.OutOrStdout(), cmd.OutOrStderr(), bbsClient, processGuid, int32(index))
 	if err != nil {
 		return NewCFDotError(cmd, err)
@@ -80,3 +148,258 @@ func RetireActualLRP(stdout, stderr io.Writer, bbsClient bbs.Client, processGuid
 
 	return nil
 }
+
+func isBulkRetirement() bool {
+	return retireActualLRPCellIdFlag != "" ||
+		retireActualLRPDomainFlag != "" ||
+		retireActualLRPStateFlag != "" ||
+		retireActualLRPProcessGuidFlag != "" ||
+		retireActualLRPStdinFlag
+}
+
+func bulkRetireActualLRPs(cmd *cobra.Command, args []string) error {
+	err := ValidateBulkRetireActualLRPArguments(args)
+	if err != nil {
+		return NewCFDotValidationError(cmd, err)
+	}
+
+	selector := ActualLRPSelector{
+		Filter: models.ActualLRPFilter{
+			Domain:      retireActualLRPDomainFlag,
+			CellID:      retireActualLRPCellIdFlag,
+			ProcessGuid: retireActualLRPProcessGuidFlag,
+		},
+		State: strings.ToUpper(retireActualLRPStateFlag),
+	}
+	if retireActualLRPStdinFlag {
+		selector.Keys, err = ParseActualLRPKeys(cmd.InOrStdin())
+		if err != nil {
+			return NewCFDotValidationError(cmd, err)
+		}
+	}
+
+	bbsClient, err := helpers.NewBBSClient(cmd, Config)
+	if err != nil {
+		return NewCFDotError(cmd, err)
+	}
+
+	bbsClient = mutatingBBSClient(cmd, bbsClient)
+
+	return BulkRetireActualLRPs(
+		cmd,
+		cmd.OutOrStdout(),
+		cmd.OutOrStderr(),
+		cmd.InOrStdin(),
+		bbsClient,
+		selector,
+		DryRun,
+		retireActualLRPYesFlag,
+		retireActualLRPRateFlag,
+	)
+}
+
+func ValidateBulkRetireActualLRPArguments(args []string) error {
+	if len(args) > 0 {
+		return errBulkRetireWithArguments
+	}
+
+	switch strings.ToUpper(retireActualLRPStateFlag) {
+	case "", models.ActualLRPStateUnclaimed, models.ActualLRPStateClaimed, models.ActualLRPStateRunning, models.ActualLRPStateCrashed:
+	default:
+		return errInvalidActualLRPState
+	}
+
+	if retireActualLRPRateFlag <= 0 {
+		return errInvalidRate
+	}
+
+	if retireActualLRPStdinFlag && !retireActualLRPYesFlag && !DryRun {
+		return errStdinWithoutYes
+	}
+
+	return nil
+}
+
+// ParseActualLRPKeys reads one actual LRP key per line, either as
+// 'PROCESS_GUID INDEX' or as a json object with process_guid and index.
+// Empty lines are skipped.
+func ParseActualLRPKeys(r io.Reader) ([]*models.ActualLRPKey, error) {
+	keys := []*models.ActualLRPKey{}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		key := &models.ActualLRPKey{}
+		if strings.HasPrefix(text, "{") {
+			err := json.Unmarshal([]byte(text), key)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid actual LRP key on line %d: %s", line, err)
+			}
+		} else {
+			fields := strings.Fields(text)
+			if len(fields) != 2 {
+				return nil, fmt.Errorf("Invalid actual LRP key on line %d: expected PROCESS_GUID INDEX", line)
+			}
+			index, err := strconv.Atoi(fields[1])
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("Invalid actual LRP key on line %d: %s", line, errInvalidIndex)
+			}
+			key.ProcessGuid = fields[0]
+			key.Index = int32(index)
+		}
+
+		if key.ProcessGuid == "" {
+			return nil, fmt.Errorf("Invalid actual LRP key on line %d: %s", line, errInvalidProcessGuid)
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, scanner.Err()
+}
+
+// SelectActualLRPs returns the actual LRPs matching the selector, one per
+// key, sorted by process guid and index.
+func SelectActualLRPs(bbsClient bbs.Client, selector ActualLRPSelector) ([]*PlannedRetirement, error) {
+	traceID := trace.GenerateTraceID()
+	logger := trace.LoggerWithTraceInfo(globalLogger.Session("select-actual-lrps"), traceID)
+
+	filters := []models.ActualLRPFilter{selector.Filter}
+	if len(selector.Keys) > 0 {
+		filters = []models.ActualLRPFilter{}
+		for _, key := range selector.Keys {
+			if selector.Filter.ProcessGuid != "" && selector.Filter.ProcessGuid != key.ProcessGuid {
+				continue
+			}
+			index := key.Index
+			filter := selector.Filter
+			filter.ProcessGuid = key.ProcessGuid
+			filter.Index = &index
+			filters = append(filters, filter)
+		}
+	}
+
+	selected := map[models.ActualLRPKey]*PlannedRetirement{}
+	for _, filter := range filters {
+		actualLRPs, err := bbsClient.ActualLRPs(logger, traceID, filter)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, actualLRP := range actualLRPs {
+			if selector.State != "" && actualLRP.State != selector.State {
+				continue
+			}
+			if _, ok := selected[actualLRP.ActualLRPKey]; ok && actualLRP.Presence == models.ActualLRP_Evacuating {
+				continue
+			}
+			selected[actualLRP.ActualLRPKey] = &PlannedRetirement{
+				ProcessGuid: actualLRP.ProcessGuid,
+				Index:       actualLRP.Index,
+				Domain:      actualLRP.Domain,
+				CellID:      actualLRP.CellId,
+				State:       actualLRP.State,
+			}
+		}
+	}
+
+	retirements := []*PlannedRetirement{}
+	for _, retirement := range selected {
+		retirements = append(retirements, retirement)
+	}
+	sort.Slice(retirements, func(i, j int) bool {
+		if retirements[i].ProcessGuid != retirements[j].ProcessGuid {
+			return retirements[i].ProcessGuid < retirements[j].ProcessGuid
+		}
+		return retirements[i].Index < retirements[j].Index
+	})
+
+	return retirements, nil
+}
+
+// BulkRetireActualLRPs prints the actual LRPs matching the selector and,
+// unless dryRun is set, retires them at no more than rate per second after
+// asking for confirmation on stdin. Failed retirements are written to
+// stderr and do not stop the remaining ones.
+func BulkRetireActualLRPs(
+	cmd *cobra.Command,
+	stdout, stderr io.Writer,
+	stdin io.Reader,
+	bbsClient bbs.Client,
+	selector ActualLRPSelector,
+	dryRun, assumeYes bool,
+	rate float64,
+) error {
+	retirements, err := SelectActualLRPs(bbsClient, selector)
+	if err != nil {
+		return NewCFDotError(cmd, err)
+	}
+
+	encoder := newOutputEncoder(stdout)
+	for _, retirement := range retirements {
+		err = encoder.Encode(retirement)
+		if err != nil {
+			return err
+		}
+	}
+	err = encoder.Flush()
+	if err != nil {
+		return err
+	}
+
+	if dryRun || len(retirements) == 0 {
+		return nil
+	}
+
+	if !assumeYes && !confirm(stdin, stderr, fmt.Sprintf("Retire %d actual LRPs?", len(retirements))) {
+		return NewCFDotError(cmd, errRetirementAborted)
+	}
+
+	traceID := trace.GenerateTraceID()
+	logger := trace.LoggerWithTraceInfo(globalLogger.Session("retire-actual-lrps"), traceID)
+
+	interval := time.Duration(float64(time.Second) / rate)
+	if interval <= 0 {
+		interval = time.Nanosecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	errorEncoder := json.NewEncoder(stderr)
+	failed := 0
+	for i, retirement := range retirements {
+		if i > 0 {
+			<-ticker.C
+		}
+
+		key := models.NewActualLRPKey(retirement.ProcessGuid, retirement.Index, retirement.Domain)
+		err := bbsClient.RetireActualLRP(logger, traceID, &key)
+		if err != nil {
+			logger.Error("failed-to-retire-actual-lrp", err)
+			failed++
+			errorEncoder.Encode(&RetirementError{
+				ProcessGuid: retirement.ProcessGuid,
+				Index:       retirement.Index,
+				Domain:      retirement.Domain,
+				Error:       err.Error(),
+			})
+		}
+	}
+
+	if failed > 0 {
+		return NewCFDotComponentError(cmd, fmt.Errorf("BBS error: Failed to retire %d of %d actual LRPs", failed, len(retirements)))
+	}
+	return nil
+}
+
+// confirm asks the question on stderr and reads the answer from stdin.
+func confirm(stdin io.Reader, stderr io.Writer, question string) bool {
+	fmt.Fprintf(stderr, "%s [y/N] ", question)
+	answer, _ := bufio.NewReader(stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
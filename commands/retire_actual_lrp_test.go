@@ -1,6 +1,10 @@
 package commands_test
 
 import (
+	"bytes"
+	"strings"
+	"time"
+
 	"code.cloudfoundry.org/bbs/fake_bbs"
 	"code.cloudfoundry.org/bbs/models"
 	"code.cloudfoundry.org/cfdot/commands"
@@ -9,6 +13,7 @@ import (
 	. "github.com/onsi/gomega"
 	"github.com/onsi/gomega/gbytes"
 	"github.com/openzipkin/zipkin-go/model"
+	"github.com/spf13/cobra"
 )
 
 var _ = Describe("RetireActualLRP", func() {
@@ -121,4 +126,178 @@ var _ = Describe("RetireActualLRP", func() {
 			})
 		})
 	})
+
+	Context("ValidateBulkRetireActualLRPArguments", func() {
+		It("rejects positional arguments", func() {
+			err := commands.ValidateBulkRetireActualLRPArguments([]string{"guid", "1"})
+			Expect(err).To(MatchError(ContainSubstring("PROCESS_GUID and INDEX cannot be combined")))
+			Expect(commands.ValidateBulkRetireActualLRPArguments([]string{})).To(Succeed())
+		})
+	})
+
+	Context("ParseActualLRPKeys", func() {
+		It("parses plain and json keys", func() {
+			keys, err := commands.ParseActualLRPKeys(strings.NewReader(`guid-1 0
+
+{"process_guid":"guid-2","index":3,"domain":"domain","state":"CRASHED"}
+`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(keys).To(Equal([]*models.ActualLRPKey{
+				{ProcessGuid: "guid-1", Index: 0},
+				{ProcessGuid: "guid-2", Index: 3, Domain: "domain"},
+			}))
+		})
+
+		It("rejects malformed lines", func() {
+			_, err := commands.ParseActualLRPKeys(strings.NewReader("guid-1 0\nguid-2\n"))
+			Expect(err).To(MatchError("Invalid actual LRP key on line 2: expected PROCESS_GUID INDEX"))
+
+			_, err = commands.ParseActualLRPKeys(strings.NewReader("guid-1 -1\n"))
+			Expect(err).To(MatchError("Invalid actual LRP key on line 1: Index must be a non-negative integer"))
+
+			_, err = commands.ParseActualLRPKeys(strings.NewReader(`{"index":1}`))
+			Expect(err).To(MatchError("Invalid actual LRP key on line 1: Process guid should be non empty string"))
+		})
+	})
+
+	Context("BulkRetireActualLRPs", func() {
+		var (
+			cmd            *cobra.Command
+			fakeBBSClient  *fake_bbs.FakeClient
+			stdout, stderr *gbytes.Buffer
+			stdin          *bytes.Buffer
+			selector       commands.ActualLRPSelector
+		)
+
+		actualLRP := func(processGuid string, index int32, state string) *models.ActualLRP {
+			return &models.ActualLRP{
+				ActualLRPKey:         models.NewActualLRPKey(processGuid, index, "domain"),
+				ActualLRPInstanceKey: models.ActualLRPInstanceKey{CellId: "cell-1"},
+				State:                state,
+			}
+		}
+
+		BeforeEach(func() {
+			cmd = &cobra.Command{}
+			stdout = gbytes.NewBuffer()
+			stderr = gbytes.NewBuffer()
+			stdin = &bytes.Buffer{}
+
+			fakeBBSClient = &fake_bbs.FakeClient{}
+			fakeBBSClient.ActualLRPsReturns([]*models.ActualLRP{
+				actualLRP("guid-2", 0, models.ActualLRPStateCrashed),
+				actualLRP("guid-1", 1, models.ActualLRPStateRunning),
+				actualLRP("guid-1", 0, models.ActualLRPStateCrashed),
+			}, nil)
+
+			selector = commands.ActualLRPSelector{
+				Filter: models.ActualLRPFilter{CellID: "cell-1"},
+				State:  models.ActualLRPStateCrashed,
+			}
+		})
+
+		retiredKeys := func() []models.ActualLRPKey {
+			keys := []models.ActualLRPKey{}
+			for i := 0; i < fakeBBSClient.RetireActualLRPCallCount(); i++ {
+				_, _, key := fakeBBSClient.RetireActualLRPArgsForCall(i)
+				keys = append(keys, *key)
+			}
+			return keys
+		}
+
+		It("prints the planned retirements without retiring them on a dry run", func() {
+			err := commands.BulkRetireActualLRPs(cmd, stdout, stderr, stdin, fakeBBSClient, selector, true, false, 1000)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, _, filter := fakeBBSClient.ActualLRPsArgsForCall(0)
+			Expect(filter).To(Equal(models.ActualLRPFilter{CellID: "cell-1"}))
+
+			Expect(stdout).To(gbytes.Say(`{"process_guid":"guid-1","index":0,"domain":"domain","cell_id":"cell-1","state":"CRASHED"}`))
+			Expect(stdout).To(gbytes.Say(`{"process_guid":"guid-2","index":0,"domain":"domain","cell_id":"cell-1","state":"CRASHED"}`))
+			Expect(fakeBBSClient.RetireActualLRPCallCount()).To(Equal(0))
+		})
+
+		It("retires the selected actual lrps after confirmation", func() {
+			stdin.WriteString("y\n")
+
+			err := commands.BulkRetireActualLRPs(cmd, stdout, stderr, stdin, fakeBBSClient, selector, false, false, 1000)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stderr).To(gbytes.Say(`Retire 2 actual LRPs\? \[y/N\]`))
+
+			Expect(retiredKeys()).To(Equal([]models.ActualLRPKey{
+				models.NewActualLRPKey("guid-1", 0, "domain"),
+				models.NewActualLRPKey("guid-2", 0, "domain"),
+			}))
+		})
+
+		It("aborts when the retirement is not confirmed", func() {
+			stdin.WriteString("n\n")
+
+			err := commands.BulkRetireActualLRPs(cmd, stdout, stderr, stdin, fakeBBSClient, selector, false, false, 1000)
+			Expect(err).To(MatchError("Retirement aborted"))
+			Expect(fakeBBSClient.RetireActualLRPCallCount()).To(Equal(0))
+		})
+
+		It("does not ask for confirmation when told to assume yes", func() {
+			err := commands.BulkRetireActualLRPs(cmd, stdout, stderr, stdin, fakeBBSClient, selector, false, true, 1000)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stderr.Contents()).To(BeEmpty())
+			Expect(fakeBBSClient.RetireActualLRPCallCount()).To(Equal(2))
+		})
+
+		It("limits the rate of retirements", func() {
+			start := time.Now()
+			err := commands.BulkRetireActualLRPs(cmd, stdout, stderr, stdin, fakeBBSClient, selector, false, true, 20)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(time.Since(start)).To(BeNumerically(">=", 50*time.Millisecond))
+		})
+
+		It("only selects the actual lrps with the given keys", func() {
+			selector.Keys = []*models.ActualLRPKey{{ProcessGuid: "guid-1", Index: 0}}
+			fakeBBSClient.ActualLRPsReturns([]*models.ActualLRP{actualLRP("guid-1", 0, models.ActualLRPStateCrashed)}, nil)
+
+			err := commands.BulkRetireActualLRPs(cmd, stdout, stderr, stdin, fakeBBSClient, selector, false, true, 1000)
+			Expect(err).NotTo(HaveOccurred())
+
+			index := int32(0)
+			_, _, filter := fakeBBSClient.ActualLRPsArgsForCall(0)
+			Expect(filter).To(Equal(models.ActualLRPFilter{CellID: "cell-1", ProcessGuid: "guid-1", Index: &index}))
+			Expect(retiredKeys()).To(Equal([]models.ActualLRPKey{models.NewActualLRPKey("guid-1", 0, "domain")}))
+		})
+
+		It("retires an evacuating and an ordinary instance with the same key once", func() {
+			evacuating := actualLRP("guid-1", 0, models.ActualLRPStateCrashed)
+			evacuating.Presence = models.ActualLRP_Evacuating
+			fakeBBSClient.ActualLRPsReturns([]*models.ActualLRP{evacuating, actualLRP("guid-1", 0, models.ActualLRPStateCrashed)}, nil)
+
+			err := commands.BulkRetireActualLRPs(cmd, stdout, stderr, stdin, fakeBBSClient, selector, false, true, 1000)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fakeBBSClient.RetireActualLRPCallCount()).To(Equal(1))
+		})
+
+		Context("when a retirement fails", func() {
+			BeforeEach(func() {
+				fakeBBSClient.RetireActualLRPReturnsOnCall(0, models.ErrUnknownError)
+			})
+
+			It("retires the remaining actual lrps and returns an error", func() {
+				err := commands.BulkRetireActualLRPs(cmd, stdout, stderr, stdin, fakeBBSClient, selector, false, true, 1000)
+				Expect(err).To(MatchError("BBS error: Failed to retire 1 of 2 actual LRPs"))
+				Expect(fakeBBSClient.RetireActualLRPCallCount()).To(Equal(2))
+				Expect(stderr).To(gbytes.Say(`{"process_guid":"guid-1","index":0,"domain":"domain","error":`))
+			})
+		})
+
+		Context("when the actual lrps cannot be fetched", func() {
+			BeforeEach(func() {
+				fakeBBSClient.ActualLRPsReturns(nil, models.ErrUnknownError)
+			})
+
+			It("fails with a relevant error", func() {
+				err := commands.BulkRetireActualLRPs(cmd, stdout, stderr, stdin, fakeBBSClient, selector, true, false, 1000)
+				Expect(err).To(MatchError(ContainSubstring("UnknownError")))
+				Expect(err.(commands.CFDotError).ExitCode()).To(Equal(4))
+			})
+		})
+	})
 })
@@ -0,0 +1,166 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"code.cloudfoundry.org/bbs"
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/bbs/trace"
+	"code.cloudfoundry.org/cfdot/commands/helpers"
+	"github.com/spf13/cobra"
+)
+
+// ScaleProgress is printed while waiting for a scaled desired LRP, every time
+// the number of instances in a state changes.
+type ScaleProgress struct {
+	ProcessGuid string `json:"process_guid"`
+	Instances   int32  `json:"instances"`
+	Running     int    `json:"running"`
+	Starting    int    `json:"starting"`
+	Crashed     int    `json:"crashed"`
+	Extra       int    `json:"extra"`
+}
+
+// errors
+var (
+	errMissingScaleInstances = errors.New("--instances must be a non-negative integer")
+)
+
+// flags
+var (
+	scaleInstancesFlag    int
+	scaleWaitFlag         bool
+	scalePollIntervalFlag time.Duration
+	scaleWaitTimeoutFlag  time.Duration
+)
+
+var scaleCmd = &cobra.Command{
+	Use:   "scale PROCESS_GUID --instances N",
+	Short: "Scale a desired LRP",
+	Long:  "Update the number of instances of a desired LRP. With --wait, wait until exactly that many instances are running",
+	RunE:  scale,
+}
+
+func init() {
+	AddBBSAndTimeoutFlags(scaleCmd)
+	AddDryRunFlag(scaleCmd)
+
+	scaleCmd.Flags().IntVarP(&scaleInstancesFlag, "instances", "i", -1, "number of instances")
+	scaleCmd.Flags().BoolVar(&scaleWaitFlag, "wait", false, "wait until the instances are running")
+	scaleCmd.Flags().DurationVar(&scalePollIntervalFlag, "poll-interval", 2*time.Second, "interval at which the actual lrps are polled while waiting")
+	scaleCmd.Flags().DurationVar(&scaleWaitTimeoutFlag, "wait-timeout", 5*time.Minute, "maximum time to wait for the instances to be running")
+
+	RootCmd.AddCommand(scaleCmd)
+}
+
+func scale(cmd *cobra.Command, args []string) error {
+	processGuid, err := ValidateScaleArguments(args)
+	if err != nil {
+		return NewCFDotValidationError(cmd, err)
+	}
+
+	bbsClient, err := helpers.NewBBSClient(cmd, Config)
+	if err != nil {
+		return NewCFDotError(cmd, err)
+	}
+
+	bbsClient = mutatingBBSClient(cmd, bbsClient)
+
+	err = Scale(cmd.OutOrStdout(), cmd.OutOrStderr(), bbsClient, processGuid, int32(scaleInstancesFlag))
+	if err != nil {
+		return NewCFDotError(cmd, err)
+	}
+
+	if scaleWaitFlag && !DryRun {
+		err = WaitForScale(cmd.OutOrStdout(), cmd.OutOrStderr(), bbsClient, processGuid, int32(scaleInstancesFlag), scalePollIntervalFlag, scaleWaitTimeoutFlag)
+		if err != nil {
+			return NewCFDotError(cmd, err)
+		}
+	}
+
+	return nil
+}
+
+func ValidateScaleArguments(args []string) (string, error) {
+	switch {
+	case len(args) < 1:
+		return "", errMissingArguments
+	case len(args) > 1:
+		return "", errExtraArguments
+	case args[0] == "":
+		return "", errInvalidProcessGuid
+	case scaleInstancesFlag < 0:
+		return "", errMissingScaleInstances
+	case scalePollIntervalFlag <= 0:
+		return "", errInvalidPollInterval
+	case scaleWaitTimeoutFlag <= 0:
+		return "", errInvalidWaitTimeout
+	}
+	return args[0], nil
+}
+
+func Scale(stdout, stderr io.Writer, bbsClient bbs.Client, processGuid string, instances int32) error {
+	traceID := trace.GenerateTraceID()
+	logger := trace.LoggerWithTraceInfo(globalLogger.Session("scale"), traceID)
+
+	update := &models.DesiredLRPUpdate{}
+	update.SetInstances(instances)
+
+	return bbsClient.UpdateDesiredLRP(logger, traceID, processGuid, update)
+}
+
+// WaitForScale polls the actual LRPs of the desired LRP until the instances
+// with an index below instances are RUNNING and there are no others.
+func WaitForScale(stdout, stderr io.Writer, bbsClient bbs.Client, processGuid string, instances int32, pollInterval, waitTimeout time.Duration) error {
+	traceID := trace.GenerateTraceID()
+	logger := trace.LoggerWithTraceInfo(globalLogger.Session("wait-for-scale"), traceID)
+
+	encoder := newOutputEncoder(stdout)
+	deadline := time.After(waitTimeout)
+	var last *ScaleProgress
+
+	for {
+		actualLRPs, err := bbsClient.ActualLRPs(logger, traceID, models.ActualLRPFilter{ProcessGuid: processGuid})
+		if err != nil {
+			return err
+		}
+
+		progress := &ScaleProgress{ProcessGuid: processGuid, Instances: instances}
+		for _, actualLRP := range ordinaryActualLRPsByIndex(actualLRPs) {
+			switch {
+			case actualLRP.Index >= instances:
+				progress.Extra++
+			case actualLRP.State == models.ActualLRPStateRunning:
+				progress.Running++
+			case actualLRP.State == models.ActualLRPStateCrashed:
+				progress.Crashed++
+			default:
+				progress.Starting++
+			}
+		}
+
+		if last == nil || *last != *progress {
+			err = encoder.Encode(progress)
+			if err == nil {
+				err = encoder.Flush()
+			}
+			if err != nil {
+				return err
+			}
+			last = progress
+		}
+
+		if progress.Running == int(instances) && progress.Extra == 0 {
+			return nil
+		}
+
+		select {
+		case <-deadline:
+			return fmt.Errorf("Timed out after %s waiting for %d running instances of %s, %d are running", waitTimeout, instances, processGuid, progress.Running)
+		case <-time.After(pollInterval):
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package commands_test
+
+import (
+	"encoding/json"
+	"time"
+
+	"code.cloudfoundry.org/bbs/fake_bbs"
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/cfdot/commands"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("Scale", func() {
+	var (
+		fakeBBSClient  *fake_bbs.FakeClient
+		stdout, stderr *gbytes.Buffer
+	)
+
+	actualLRP := func(index int32, state string) *models.ActualLRP {
+		return &models.ActualLRP{
+			ActualLRPKey: models.NewActualLRPKey("process-guid", index, "domain"),
+			State:        state,
+		}
+	}
+
+	progress := func() []commands.ScaleProgress {
+		events := []commands.ScaleProgress{}
+		decoder := json.NewDecoder(stdout)
+		for decoder.More() {
+			var event commands.ScaleProgress
+			Expect(decoder.Decode(&event)).To(Succeed())
+			events = append(events, event)
+		}
+		return events
+	}
+
+	BeforeEach(func() {
+		fakeBBSClient = &fake_bbs.FakeClient{}
+		stdout = gbytes.NewBuffer()
+		stderr = gbytes.NewBuffer()
+	})
+
+	It("updates only the instances of the desired lrp", func() {
+		err := commands.Scale(stdout, stderr, fakeBBSClient, "process-guid", 3)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(fakeBBSClient.UpdateDesiredLRPCallCount()).To(Equal(1))
+		_, _, processGuid, update := fakeBBSClient.UpdateDesiredLRPArgsForCall(0)
+		Expect(processGuid).To(Equal("process-guid"))
+		Expect(update.GetInstances()).To(BeEquivalentTo(3))
+		Expect(update.AnnotationExists()).To(BeFalse())
+		Expect(update.Routes).To(BeNil())
+		Expect(update.MetricTags).To(BeNil())
+	})
+
+	Context("when the update fails", func() {
+		BeforeEach(func() {
+			fakeBBSClient.UpdateDesiredLRPReturns(models.ErrResourceNotFound)
+		})
+
+		It("returns the error", func() {
+			err := commands.Scale(stdout, stderr, fakeBBSClient, "process-guid", 3)
+			Expect(err).To(Equal(models.ErrResourceNotFound))
+		})
+	})
+
+	Describe("WaitForScale", func() {
+		It("waits until the instances are running and prints the progress", func() {
+			fakeBBSClient.ActualLRPsReturnsOnCall(0, []*models.ActualLRP{
+				actualLRP(0, models.ActualLRPStateRunning),
+				actualLRP(1, models.ActualLRPStateUnclaimed),
+			}, nil)
+			fakeBBSClient.ActualLRPsReturnsOnCall(1, []*models.ActualLRP{
+				actualLRP(0, models.ActualLRPStateRunning),
+				actualLRP(1, models.ActualLRPStateUnclaimed),
+			}, nil)
+			fakeBBSClient.ActualLRPsReturnsOnCall(2, []*models.ActualLRP{
+				actualLRP(0, models.ActualLRPStateRunning),
+				actualLRP(1, models.ActualLRPStateCrashed),
+				actualLRP(2, models.ActualLRPStateClaimed),
+			}, nil)
+			fakeBBSClient.ActualLRPsReturns([]*models.ActualLRP{
+				actualLRP(0, models.ActualLRPStateRunning),
+				actualLRP(1, models.ActualLRPStateRunning),
+				actualLRP(2, models.ActualLRPStateRunning),
+			}, nil)
+
+			err := commands.WaitForScale(stdout, stderr, fakeBBSClient, "process-guid", 3, time.Millisecond, time.Second)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, _, filter := fakeBBSClient.ActualLRPsArgsForCall(0)
+			Expect(filter).To(Equal(models.ActualLRPFilter{ProcessGuid: "process-guid"}))
+
+			Expect(progress()).To(Equal([]commands.ScaleProgress{
+				{ProcessGuid: "process-guid", Instances: 3, Running: 1, Starting: 1},
+				{ProcessGuid: "process-guid", Instances: 3, Running: 1, Starting: 1, Crashed: 1},
+				{ProcessGuid: "process-guid", Instances: 3, Running: 3},
+			}))
+		})
+
+		It("waits until the extra instances are gone when scaling down", func() {
+			fakeBBSClient.ActualLRPsReturnsOnCall(0, []*models.ActualLRP{
+				actualLRP(0, models.ActualLRPStateRunning),
+				actualLRP(1, models.ActualLRPStateRunning),
+			}, nil)
+			fakeBBSClient.ActualLRPsReturns([]*models.ActualLRP{
+				actualLRP(0, models.ActualLRPStateRunning),
+			}, nil)
+
+			err := commands.WaitForScale(stdout, stderr, fakeBBSClient, "process-guid", 1, time.Millisecond, time.Second)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fakeBBSClient.ActualLRPsCallCount()).To(Equal(2))
+			Expect(progress()[0].Extra).To(Equal(1))
+		})
+
+		It("times out", func() {
+			fakeBBSClient.ActualLRPsReturns([]*models.ActualLRP{
+				actualLRP(0, models.ActualLRPStateClaimed),
+			}, nil)
+
+			err := commands.WaitForScale(stdout, stderr, fakeBBSClient, "process-guid", 1, time.Millisecond, 20*time.Millisecond)
+			Expect(err).To(MatchError("Timed out after 20ms waiting for 1 running instances of process-guid, 0 are running"))
+		})
+
+		Context("when fetching the actual lrps fails", func() {
+			BeforeEach(func() {
+				fakeBBSClient.ActualLRPsReturns(nil, models.ErrUnknownError)
+			})
+
+			It("returns the error", func() {
+				err := commands.WaitForScale(stdout, stderr, fakeBBSClient, "process-guid", 1, time.Millisecond, time.Second)
+				Expect(err).To(Equal(models.ErrUnknownError))
+			})
+		})
+	})
+
+	Describe("ValidateScaleArguments", func() {
+		It("rejects missing, extra and empty arguments", func() {
+			_, err := commands.ValidateScaleArguments([]string{})
+			Expect(err).To(MatchError("Missing arguments"))
+
+			_, err = commands.ValidateScaleArguments([]string{"a", "b"})
+			Expect(err).To(MatchError("Too many arguments specified"))
+
+			_, err = commands.ValidateScaleArguments([]string{""})
+			Expect(err).To(MatchError("Process guid should be non empty string"))
+		})
+
+		It("requires --instances", func() {
+			_, err := commands.ValidateScaleArguments([]string{"process-guid"})
+			Expect(err).To(MatchError("--instances must be a non-negative integer"))
+		})
+	})
+})
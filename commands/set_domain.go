@@ -30,6 +30,7 @@ var setDomainCmd = &cobra.Command{
 
 func init() {
 	AddBBSAndTimeoutFlags(setDomainCmd)
+	AddDryRunFlag(setDomainCmd)
 	setDomainCmd.Flags().DurationVarP(&setDomainTTLFlag, "ttl", "t", 0*time.Second, "ttl of domain")
 	RootCmd.AddCommand(setDomainCmd)
 }
@@ -49,6 +50,8 @@ func setDomain(cmd *cobra.Command, args []string) error {
 		return NewCFDotError(cmd, err)
 	}
 
+	bbsClient = mutatingBBSClient(cmd, bbsClient)
+
 	err = SetDomain(cmd.OutOrStdout(), cmd.OutOrStderr(), bbsClient, domain, setDomainTTLFlag)
 	if err != nil {
 		return NewCFDotError(cmd, err)
@@ -0,0 +1,432 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"code.cloudfoundry.org/bbs"
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/bbs/trace"
+	"code.cloudfoundry.org/cfdot/commands/helpers"
+	"code.cloudfoundry.org/rep"
+	"github.com/spf13/cobra"
+)
+
+// auctionLocalityOffset is added to the score of a cell for every instance
+// of the same process it already runs, as in the auctioneer.
+const auctionLocalityOffset = 1000
+
+// AuctionWeights are the auctioneer's scoring weights. The defaults match
+// the auctioneer's defaults.
+type AuctionWeights struct {
+	StartingContainerWeight float64
+	BinPackFirstFitWeight   float64
+}
+
+type PlacementSummary struct {
+	ProcessGuid string `json:"process_guid"`
+	FirstIndex  int32  `json:"first_index"`
+	Instances   int32  `json:"instances"`
+	Placed      int32  `json:"placed"`
+	Failed      int32  `json:"failed"`
+	Fits        bool   `json:"fits"`
+	Cells       int    `json:"cells"`
+}
+
+// InstancePlacement is the cell an instance would be placed on, or the
+// reason the auction would fail to place it.
+type InstancePlacement struct {
+	Index  int32  `json:"index"`
+	CellID string `json:"cell_id,omitempty"`
+	Zone   string `json:"zone,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+type PlacementSimulation struct {
+	PlacementSummary
+	Placements []*InstancePlacement `json:"placements"`
+}
+
+// errors
+var (
+	errMissingSpecOrProcessGuid = errors.New("Either a desired LRP spec or --process-guid is required")
+	errSpecWithProcessGuid      = errors.New("A desired LRP spec cannot be combined with --process-guid")
+	errMissingInstances         = errors.New("--instances is required with --process-guid")
+	errInvalidAuctionWeight     = errors.New("Auction weights must not be negative")
+
+	errPlacementCellMismatch         = errors.New("found no compatible cell")
+	errPlacementVolumeDriverMismatch = errors.New("found no compatible cell with volume drivers")
+)
+
+// flags
+var (
+	simulatePlacementProcessGuidFlag             string
+	simulatePlacementInstancesFlag               int
+	simulatePlacementStartingContainerWeightFlag float64
+	simulatePlacementBinPackFirstFitWeightFlag   float64
+)
+
+var simulatePlacementCmd = &cobra.Command{
+	Use:   "simulate-placement [SPEC|@FILE]",
+	Short: "Simulate the placement of LRP instances",
+	Long:  "Run a local copy of the auction against the current cell states and report where the instances of a new desired LRP (given as for create-desired-lrp), or of a desired LRP scaled with --process-guid and --instances, would be placed",
+	RunE:  simulatePlacement,
+}
+
+func init() {
+	AddBBSAndTimeoutFlags(simulatePlacementCmd)
+	addCellStatesFetchFlags(simulatePlacementCmd)
+
+	simulatePlacementCmd.Flags().StringVarP(&simulatePlacementProcessGuidFlag, "process-guid", "p", "", "simulate scaling the existing desired LRP with the given process guid")
+	simulatePlacementCmd.Flags().IntVarP(&simulatePlacementInstancesFlag, "instances", "i", -1, "the number of instances, defaults to the instances in the spec")
+	simulatePlacementCmd.Flags().Float64Var(&simulatePlacementStartingContainerWeightFlag, "starting-container-weight", 0.25, "auction weight of the containers that are starting on a cell")
+	simulatePlacementCmd.Flags().Float64Var(&simulatePlacementBinPackFirstFitWeightFlag, "bin-pack-first-fit-weight", 0, "auction weight of the cell index")
+
+	RootCmd.AddCommand(simulatePlacementCmd)
+}
+
+func simulatePlacement(cmd *cobra.Command, args []string) error {
+	spec, err := ValidateSimulatePlacementArguments(args)
+	if err != nil {
+		return NewCFDotValidationError(cmd, err)
+	}
+
+	bbsClient, err := helpers.NewBBSClient(cmd, Config)
+	if err != nil {
+		return NewCFDotError(cmd, err)
+	}
+
+	repClientFactory, err := newRepClientFactory(cellStatesCellTimeoutFlag)
+	if err != nil {
+		return NewCFDotComponentError(cmd, fmt.Errorf("Failed creating rep client factory: %s", err))
+	}
+
+	return SimulatePlacement(
+		cmd,
+		cmd.OutOrStdout(),
+		cmd.OutOrStderr(),
+		repClientFactory,
+		bbsClient,
+		spec,
+		simulatePlacementProcessGuidFlag,
+		simulatePlacementInstancesFlag,
+		cellStatesConcurrencyFlag,
+		AuctionWeights{
+			StartingContainerWeight: simulatePlacementStartingContainerWeightFlag,
+			BinPackFirstFitWeight:   simulatePlacementBinPackFirstFitWeightFlag,
+		},
+	)
+}
+
+func ValidateSimulatePlacementArguments(args []string) ([]byte, error) {
+	if len(args) > 1 {
+		return nil, errExtraArguments
+	}
+
+	if simulatePlacementStartingContainerWeightFlag < 0 || simulatePlacementBinPackFirstFitWeightFlag < 0 {
+		return nil, errInvalidAuctionWeight
+	}
+
+	err := validateCellStatesFetchFlags()
+	if err != nil {
+		return nil, err
+	}
+
+	if simulatePlacementProcessGuidFlag != "" {
+		if len(args) > 0 {
+			return nil, errSpecWithProcessGuid
+		}
+		if simulatePlacementInstancesFlag < 0 {
+			return nil, errMissingInstances
+		}
+		return nil, nil
+	}
+
+	if len(args) == 0 {
+		return nil, errMissingSpecOrProcessGuid
+	}
+
+	return ValidateCreateDesiredLRPArguments(args)
+}
+
+// SimulatePlacement places the instances of the desired LRP in spec or, when
+// processGuid is given, the instances added by scaling that desired LRP.
+// instances overrides the instance count of the spec unless it is negative.
+func SimulatePlacement(
+	cmd *cobra.Command,
+	stdout, stderr io.Writer,
+	clientFactory rep.ClientFactory,
+	bbsClient bbs.Client,
+	spec []byte,
+	processGuid string,
+	instances int,
+	concurrency int,
+	weights AuctionWeights,
+) error {
+	traceID := trace.GenerateTraceID()
+	logger := trace.LoggerWithTraceInfo(globalLogger.Session("simulate-placement"), traceID)
+
+	var desiredLRP *models.DesiredLRP
+	var firstIndex, count int32
+
+	if processGuid != "" {
+		var err error
+		desiredLRP, err = bbsClient.DesiredLRPByProcessGuid(logger, traceID, processGuid)
+		if err != nil {
+			return NewCFDotError(cmd, err)
+		}
+		firstIndex = desiredLRP.Instances
+		count = int32(instances) - desiredLRP.Instances
+	} else {
+		err := json.Unmarshal(spec, &desiredLRP)
+		if err != nil {
+			return NewCFDotValidationError(cmd, fmt.Errorf("Invalid JSON: %s", err.Error()))
+		}
+		count = desiredLRP.Instances
+		if instances >= 0 {
+			count = int32(instances)
+		}
+	}
+	if count < 0 {
+		count = 0
+	}
+
+	registrations, err := bbsClient.Cells(logger, traceID)
+	if err != nil {
+		return NewCFDotComponentError(cmd, fmt.Errorf("BBS error: Failed to get cell registrations from BBS: %s", err))
+	}
+
+	states := []rep.CellState{}
+	errorEncoder := json.NewEncoder(stderr)
+	failed := 0
+	fetchCellStates(logger, clientFactory, registrations, traceID, concurrency, false, func(result cellStateResult) {
+		if result.err != nil {
+			failed++
+			errorEncoder.Encode(newCellStateError(result))
+			return
+		}
+		states = append(states, result.state)
+	})
+
+	encoder := newOutputEncoder(stdout)
+	err = encoder.Encode(SimulateAuction(desiredLRP, firstIndex, count, states, weights))
+	if err != nil {
+		return err
+	}
+
+	err = encoder.Flush()
+	if err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return NewCFDotComponentError(cmd, cellStatesFailedError(failed, len(registrations)))
+	}
+	return nil
+}
+
+// SimulateAuction places count instances of desiredLRP, starting at
+// firstIndex, one at a time the way the auctioneer's scheduler does: cells
+// are filtered by rootfs, volume drivers and placement tags, zones with
+// fewer instances of the process are preferred, and within them the cell
+// with the lowest score wins. Evacuating cells are not considered. The
+// given states are not modified.
+func SimulateAuction(desiredLRP *models.DesiredLRP, firstIndex, count int32, states []rep.CellState, weights AuctionWeights) *PlacementSimulation {
+	cells := []*rep.CellState{}
+	for i := range states {
+		if states[i].Evacuating {
+			continue
+		}
+		cell := states[i]
+		cell.LRPs = append([]rep.LRP{}, states[i].LRPs...)
+		cells = append(cells, &cell)
+	}
+	sort.Slice(cells, func(i, j int) bool { return cells[i].CellID < cells[j].CellID })
+
+	volumeDrivers := []string{}
+	for _, mount := range desiredLRP.VolumeMounts {
+		volumeDrivers = append(volumeDrivers, mount.Driver)
+	}
+
+	simulation := &PlacementSimulation{
+		PlacementSummary: PlacementSummary{
+			ProcessGuid: desiredLRP.ProcessGuid,
+			FirstIndex:  firstIndex,
+			Instances:   count,
+			Cells:       len(cells),
+		},
+		Placements: []*InstancePlacement{},
+	}
+
+	for index := firstIndex; index < firstIndex+count; index++ {
+		lrp := rep.NewLRP(
+			"",
+			models.NewActualLRPKey(desiredLRP.ProcessGuid, index, desiredLRP.Domain),
+			rep.NewResource(desiredLRP.MemoryMb, desiredLRP.DiskMb, desiredLRP.MaxPids),
+			rep.NewPlacementConstraint(desiredLRP.RootFs, desiredLRP.PlacementTags, volumeDrivers),
+		)
+
+		placement := &InstancePlacement{Index: index}
+		winner, err := scheduleLRP(cells, &lrp, weights)
+		if err != nil {
+			placement.Error = err.Error()
+			simulation.Failed++
+		} else {
+			winner.AddLRP(&lrp)
+			placement.CellID = winner.CellID
+			placement.Zone = winner.Zone
+			simulation.Placed++
+		}
+		simulation.Placements = append(simulation.Placements, placement)
+	}
+
+	simulation.Fits = simulation.Failed == 0
+	return simulation
+}
+
+func (s *PlacementSimulation) TableRows() []interface{} {
+	rows := []interface{}{&s.PlacementSummary}
+	for _, placement := range s.Placements {
+		rows = append(rows, placement)
+	}
+	return rows
+}
+
+type placementTagsMismatchError struct {
+	placementTags []string
+}
+
+func (e placementTagsMismatchError) Error() string {
+	return fmt.Sprintf("found no compatible cell with placement tags %s", strings.Join(e.placementTags, ", "))
+}
+
+type auctionZone struct {
+	cells     []*rep.CellState
+	instances int
+}
+
+func scheduleLRP(cells []*rep.CellState, lrp *rep.LRP, weights AuctionWeights) (*rep.CellState, error) {
+	zonesByName := map[string]*auctionZone{}
+	zones := []*auctionZone{}
+	for _, cell := range cells {
+		zone, ok := zonesByName[cell.Zone]
+		if !ok {
+			zone = &auctionZone{}
+			zonesByName[cell.Zone] = zone
+			zones = append(zones, zone)
+		}
+		zone.cells = append(zone.cells, cell)
+		zone.instances += lrpInstancesOnCell(cell, lrp.ProcessGuid)
+	}
+
+	filteredZones := []*auctionZone{}
+	var zoneErr error
+	for _, zone := range zones {
+		matchingCells, err := filterPlacementCells(zone.cells, lrp.PlacementConstraint)
+		if err != nil {
+			if _, ok := err.(placementTagsMismatchError); ok || zoneErr == nil {
+				zoneErr = err
+			}
+			continue
+		}
+		filteredZones = append(filteredZones, &auctionZone{cells: matchingCells, instances: zone.instances})
+	}
+	if len(filteredZones) == 0 {
+		if zoneErr == nil {
+			zoneErr = errPlacementCellMismatch
+		}
+		return nil, zoneErr
+	}
+
+	sort.SliceStable(filteredZones, func(i, j int) bool {
+		return filteredZones[i].instances < filteredZones[j].instances
+	})
+
+	var winner *rep.CellState
+	winnerScore := 1e20
+	problems := map[string]struct{}{"disk": {}, "memory": {}, "containers": {}}
+
+	for i, zone := range filteredZones {
+		for _, cell := range zone.cells {
+			score, err := auctionScore(cell, lrp, weights)
+			if err != nil {
+				if resourcesErr, ok := err.(rep.InsufficientResourcesError); ok {
+					for problem := range problems {
+						if _, ok := resourcesErr.Problems[problem]; !ok {
+							delete(problems, problem)
+						}
+					}
+				}
+				continue
+			}
+			if score < winnerScore {
+				winnerScore = score
+				winner = cell
+			}
+		}
+
+		if winner != nil && i+1 < len(filteredZones) && zone.instances < filteredZones[i+1].instances {
+			break
+		}
+	}
+
+	if winner == nil {
+		return nil, rep.InsufficientResourcesError{Problems: problems}
+	}
+	return winner, nil
+}
+
+func filterPlacementCells(cells []*rep.CellState, constraint rep.PlacementConstraint) ([]*rep.CellState, error) {
+	matchingCells := []*rep.CellState{}
+	err := errPlacementCellMismatch
+
+	for _, cell := range cells {
+		if !cell.MatchRootFS(constraint.RootFs) {
+			continue
+		}
+		if err == errPlacementCellMismatch {
+			err = errPlacementVolumeDriverMismatch
+		}
+		if !cell.MatchVolumeDrivers(constraint.VolumeDrivers) {
+			continue
+		}
+		if err == errPlacementVolumeDriverMismatch {
+			err = placementTagsMismatchError{constraint.PlacementTags}
+		}
+		if !cell.MatchPlacementTags(constraint.PlacementTags) {
+			continue
+		}
+		matchingCells = append(matchingCells, cell)
+	}
+
+	if len(matchingCells) == 0 {
+		return nil, err
+	}
+	return matchingCells, nil
+}
+
+func auctionScore(cell *rep.CellState, lrp *rep.LRP, weights AuctionWeights) (float64, error) {
+	err := cell.ResourceMatch(&lrp.Resource)
+	if err != nil {
+		return 0, err
+	}
+
+	localityScore := float64(auctionLocalityOffset * lrpInstancesOnCell(cell, lrp.ProcessGuid))
+	resourceScore := cell.ComputeScore(&lrp.Resource, weights.StartingContainerWeight)
+	indexScore := float64(cell.CellIndex) * weights.BinPackFirstFitWeight
+	return resourceScore + localityScore + indexScore, nil
+}
+
+func lrpInstancesOnCell(cell *rep.CellState, processGuid string) int {
+	instances := 0
+	for i := range cell.LRPs {
+		if cell.LRPs[i].ProcessGuid == processGuid {
+			instances++
+		}
+	}
+	return instances
+}
@@ -0,0 +1,317 @@
+package commands_test
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"code.cloudfoundry.org/bbs/fake_bbs"
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/cfdot/commands"
+	"code.cloudfoundry.org/rep"
+	"code.cloudfoundry.org/rep/repfakes"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/spf13/cobra"
+)
+
+var _ = Describe("SimulatePlacement", func() {
+	var (
+		desiredLRP *models.DesiredLRP
+		states     []rep.CellState
+		weights    commands.AuctionWeights
+	)
+
+	cellState := func(cellID, zone string) rep.CellState {
+		return rep.CellState{
+			CellID:             cellID,
+			Zone:               zone,
+			RootFSProviders:    rep.RootFSProviders{"preloaded": rep.NewFixedSetRootFSProvider("cflinuxfs4")},
+			TotalResources:     rep.NewResources(4096, 10240, 10),
+			AvailableResources: rep.NewResources(4096, 10240, 10),
+			LRPs:               []rep.LRP{},
+		}
+	}
+
+	placedCells := func(simulation *commands.PlacementSimulation) []string {
+		cellIDs := []string{}
+		for _, placement := range simulation.Placements {
+			cellIDs = append(cellIDs, placement.CellID)
+		}
+		return cellIDs
+	}
+
+	BeforeEach(func() {
+		desiredLRP = &models.DesiredLRP{
+			ProcessGuid: "process-guid",
+			Domain:      "domain",
+			RootFs:      "preloaded:cflinuxfs4",
+			Instances:   2,
+			MemoryMb:    1024,
+			DiskMb:      1024,
+		}
+		states = []rep.CellState{cellState("cell-2", "z2"), cellState("cell-1", "z1")}
+		weights = commands.AuctionWeights{StartingContainerWeight: 0.25}
+	})
+
+	Describe("SimulateAuction", func() {
+		It("spreads the instances across zones", func() {
+			simulation := commands.SimulateAuction(desiredLRP, 0, 2, states, weights)
+			Expect(simulation.PlacementSummary).To(Equal(commands.PlacementSummary{
+				ProcessGuid: "process-guid",
+				Instances:   2,
+				Placed:      2,
+				Fits:        true,
+				Cells:       2,
+			}))
+			Expect(simulation.Placements).To(Equal([]*commands.InstancePlacement{
+				{Index: 0, CellID: "cell-1", Zone: "z1"},
+				{Index: 1, CellID: "cell-2", Zone: "z2"},
+			}))
+		})
+
+		It("prefers the zone and cell without instances of the process", func() {
+			states[1].LRPs = []rep.LRP{
+				rep.NewLRP("", models.NewActualLRPKey("process-guid", 0, "domain"), rep.NewResource(1024, 1024, 0), rep.PlacementConstraint{}),
+			}
+			states = append(states, cellState("cell-3", "z1"))
+
+			simulation := commands.SimulateAuction(desiredLRP, 1, 3, states, weights)
+			Expect(placedCells(simulation)).To(Equal([]string{"cell-2", "cell-3", "cell-2"}))
+			Expect(simulation.Placements[0].Index).To(Equal(int32(1)))
+		})
+
+		It("does not modify the given cell states", func() {
+			commands.SimulateAuction(desiredLRP, 0, 2, states, weights)
+			Expect(states[0].AvailableResources).To(Equal(rep.NewResources(4096, 10240, 10)))
+			Expect(states[0].LRPs).To(BeEmpty())
+		})
+
+		It("does not place instances on evacuating cells", func() {
+			states[0].Evacuating = true
+
+			simulation := commands.SimulateAuction(desiredLRP, 0, 2, states, weights)
+			Expect(simulation.Cells).To(Equal(1))
+			Expect(placedCells(simulation)).To(Equal([]string{"cell-1", "cell-1"}))
+		})
+
+		It("reports the missing resources once the cells are full", func() {
+			for i := range states {
+				states[i].AvailableResources.Containers = 1
+			}
+
+			simulation := commands.SimulateAuction(desiredLRP, 0, 3, states, weights)
+			Expect(simulation.Placed).To(Equal(int32(2)))
+			Expect(simulation.Failed).To(Equal(int32(1)))
+			Expect(simulation.Fits).To(BeFalse())
+			Expect(simulation.Placements[2]).To(Equal(&commands.InstancePlacement{
+				Index: 2,
+				Error: "insufficient resources: containers",
+			}))
+		})
+
+		It("reports instances that are too large for any cell", func() {
+			desiredLRP.MemoryMb = 8192
+
+			simulation := commands.SimulateAuction(desiredLRP, 0, 1, states, weights)
+			Expect(simulation.Placements[0].Error).To(Equal("insufficient resources: memory"))
+		})
+
+		It("reports a rootfs that no cell provides", func() {
+			desiredLRP.RootFs = "preloaded:cflinuxfs3"
+
+			simulation := commands.SimulateAuction(desiredLRP, 0, 1, states, weights)
+			Expect(simulation.Placements[0].Error).To(Equal("found no compatible cell"))
+		})
+
+		It("reports volume drivers that no cell provides", func() {
+			desiredLRP.VolumeMounts = []*models.VolumeMount{{Driver: "nfs"}}
+
+			simulation := commands.SimulateAuction(desiredLRP, 0, 1, states, weights)
+			Expect(simulation.Placements[0].Error).To(Equal("found no compatible cell with volume drivers"))
+
+			states[1].VolumeDrivers = []string{"nfs"}
+			simulation = commands.SimulateAuction(desiredLRP, 0, 1, states, weights)
+			Expect(placedCells(simulation)).To(Equal([]string{"cell-1"}))
+		})
+
+		It("only places instances on cells with matching placement tags", func() {
+			desiredLRP.PlacementTags = []string{"isolated"}
+
+			simulation := commands.SimulateAuction(desiredLRP, 0, 1, states, weights)
+			Expect(simulation.Placements[0].Error).To(Equal("found no compatible cell with placement tags isolated"))
+
+			states[0].PlacementTags = []string{"isolated"}
+			simulation = commands.SimulateAuction(desiredLRP, 0, 2, states, weights)
+			Expect(placedCells(simulation)).To(Equal([]string{"cell-2", "cell-2"}))
+		})
+
+		It("prefers cells with a lower index when bin packing", func() {
+			states[1].Zone = "z2"
+			simulation := commands.SimulateAuction(desiredLRP, 0, 1, states, weights)
+			Expect(placedCells(simulation)).To(Equal([]string{"cell-1"}))
+
+			states[1].CellIndex = 5
+			weights.BinPackFirstFitWeight = 1
+			simulation = commands.SimulateAuction(desiredLRP, 0, 1, states, weights)
+			Expect(placedCells(simulation)).To(Equal([]string{"cell-2"}))
+		})
+	})
+
+	Describe("SimulatePlacement", func() {
+		var (
+			cmd                  *cobra.Command
+			stdout, stderr       *gbytes.Buffer
+			fakeBBSClient        *fake_bbs.FakeClient
+			fakeRepClientFactory *repfakes.FakeClientFactory
+			spec                 []byte
+		)
+
+		BeforeEach(func() {
+			cmd = &cobra.Command{}
+			stdout = gbytes.NewBuffer()
+			stderr = gbytes.NewBuffer()
+
+			fakeBBSClient = &fake_bbs.FakeClient{}
+			fakeBBSClient.CellsReturns([]*models.CellPresence{
+				{CellId: "cell-1", RepAddress: "rep-address-1"},
+				{CellId: "cell-2", RepAddress: "rep-address-2"},
+			}, nil)
+			fakeBBSClient.DesiredLRPByProcessGuidReturns(desiredLRP, nil)
+
+			fakeRepClientFactory = &repfakes.FakeClientFactory{}
+			fakeRepClientFactory.CreateClientStub = func(address, url, traceID string) (rep.Client, error) {
+				fakeRepClient := &repfakes.FakeClient{}
+				if address == "rep-address-1" {
+					fakeRepClient.StateReturns(states[1], nil)
+				} else {
+					fakeRepClient.StateReturns(states[0], nil)
+				}
+				return fakeRepClient, nil
+			}
+
+			var err error
+			spec, err = json.Marshal(desiredLRP)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		decodeSimulation := func() *commands.PlacementSimulation {
+			var simulation commands.PlacementSimulation
+			Expect(json.Unmarshal(stdout.Contents(), &simulation)).To(Succeed())
+			return &simulation
+		}
+
+		It("places the instances of the spec on the live cells", func() {
+			err := commands.SimulatePlacement(cmd, stdout, stderr, fakeRepClientFactory, fakeBBSClient, spec, "", -1, 10, weights)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fakeBBSClient.DesiredLRPByProcessGuidCallCount()).To(Equal(0))
+
+			simulation := decodeSimulation()
+			Expect(simulation.Instances).To(Equal(int32(2)))
+			Expect(placedCells(simulation)).To(Equal([]string{"cell-1", "cell-2"}))
+		})
+
+		It("overrides the instances of the spec", func() {
+			err := commands.SimulatePlacement(cmd, stdout, stderr, fakeRepClientFactory, fakeBBSClient, spec, "", 64, 10, weights)
+			Expect(err).NotTo(HaveOccurred())
+
+			simulation := decodeSimulation()
+			Expect(simulation.Instances).To(Equal(int32(64)))
+			Expect(simulation.Placed).To(Equal(int32(8)))
+			Expect(simulation.Fits).To(BeFalse())
+			Expect(simulation.Placements[8].Error).To(Equal("insufficient resources: memory"))
+		})
+
+		It("places the additional instances when scaling an existing desired LRP", func() {
+			err := commands.SimulatePlacement(cmd, stdout, stderr, fakeRepClientFactory, fakeBBSClient, nil, "process-guid", 5, 10, weights)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, _, processGuid := fakeBBSClient.DesiredLRPByProcessGuidArgsForCall(0)
+			Expect(processGuid).To(Equal("process-guid"))
+
+			simulation := decodeSimulation()
+			Expect(simulation.FirstIndex).To(Equal(int32(2)))
+			Expect(simulation.Instances).To(Equal(int32(3)))
+			Expect(simulation.Placements[0].Index).To(Equal(int32(2)))
+		})
+
+		It("places nothing when scaling down", func() {
+			err := commands.SimulatePlacement(cmd, stdout, stderr, fakeRepClientFactory, fakeBBSClient, nil, "process-guid", 1, 10, weights)
+			Expect(err).NotTo(HaveOccurred())
+
+			simulation := decodeSimulation()
+			Expect(simulation.Instances).To(Equal(int32(0)))
+			Expect(simulation.Fits).To(BeTrue())
+		})
+
+		It("prints the summary and the placements as tables", func() {
+			commands.Output.Format = "table"
+
+			err := commands.SimulatePlacement(cmd, stdout, stderr, fakeRepClientFactory, fakeBBSClient, spec, "", -1, 10, weights)
+			Expect(err).NotTo(HaveOccurred())
+
+			tables := strings.Split(strings.TrimSpace(string(stdout.Contents())), "\n\n")
+			Expect(tables).To(HaveLen(2))
+			Expect(tables[0]).To(HavePrefix("PROCESS GUID"))
+			Expect(tables[1]).To(HavePrefix("INDEX"))
+		})
+
+		Context("when the desired LRP cannot be fetched", func() {
+			BeforeEach(func() {
+				fakeBBSClient.DesiredLRPByProcessGuidReturns(nil, models.ErrResourceNotFound)
+			})
+
+			It("returns the bbs error", func() {
+				err := commands.SimulatePlacement(cmd, stdout, stderr, fakeRepClientFactory, fakeBBSClient, nil, "process-guid", 5, 10, weights)
+				Expect(err).To(MatchError(ContainSubstring("ResourceNotFound")))
+				Expect(err.(commands.CFDotError).ExitCode()).To(Equal(4))
+			})
+		})
+
+		Context("when a rep fails to respond", func() {
+			BeforeEach(func() {
+				fakeRepClientFactory.CreateClientStub = func(address, url, traceID string) (rep.Client, error) {
+					if address == "rep-address-2" {
+						return nil, errors.New("boom")
+					}
+					fakeRepClient := &repfakes.FakeClient{}
+					fakeRepClient.StateReturns(states[1], nil)
+					return fakeRepClient, nil
+				}
+			})
+
+			It("simulates with the other cells and returns an error", func() {
+				err := commands.SimulatePlacement(cmd, stdout, stderr, fakeRepClientFactory, fakeBBSClient, spec, "", -1, 10, weights)
+				Expect(err).To(MatchError("Rep error: Failed to get cell state for 1 of 2 cells"))
+				Expect(stderr).To(gbytes.Say(`"cell_id":"cell-2"`))
+
+				simulation := decodeSimulation()
+				Expect(placedCells(simulation)).To(Equal([]string{"cell-1", "cell-1"}))
+			})
+		})
+	})
+
+	Describe("ValidateSimulatePlacementArguments", func() {
+		It("requires a spec or a process guid", func() {
+			_, err := commands.ValidateSimulatePlacementArguments([]string{})
+			Expect(err).To(MatchError("Either a desired LRP spec or --process-guid is required"))
+		})
+
+		It("returns the spec", func() {
+			spec, err := commands.ValidateSimulatePlacementArguments([]string{`{"process_guid":"some-guid"}`})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(spec)).To(Equal(`{"process_guid":"some-guid"}`))
+		})
+
+		It("rejects invalid json", func() {
+			_, err := commands.ValidateSimulatePlacementArguments([]string{"not-json"})
+			Expect(err).To(MatchError(ContainSubstring("Invalid JSON")))
+		})
+
+		It("rejects extra arguments", func() {
+			_, err := commands.ValidateSimulatePlacementArguments([]string{"{}", "{}"})
+			Expect(err).To(MatchError("Too many arguments specified"))
+		})
+	})
+})
@@ -0,0 +1,231 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"code.cloudfoundry.org/bbs"
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/bbs/trace"
+	"code.cloudfoundry.org/cfdot/commands/helpers"
+	"github.com/spf13/cobra"
+)
+
+type Summary struct {
+	Domains          []string       `json:"domains"`
+	DesiredLRPs      int            `json:"desired_lrps"`
+	DesiredInstances int            `json:"desired_instances"`
+	RunningInstances int            `json:"running_instances"`
+	InstancesByState map[string]int `json:"instances_by_state"`
+	Tasks            int            `json:"tasks"`
+	TasksByState     map[string]int `json:"tasks_by_state"`
+	Cells            []*SummaryCell `json:"cells"`
+}
+
+type SummaryCell struct {
+	CellID           string `json:"cell_id"`
+	Zone             string `json:"zone"`
+	Registered       bool   `json:"registered"`
+	Instances        int    `json:"instances"`
+	RunningInstances int    `json:"running_instances"`
+	Tasks            int    `json:"tasks"`
+}
+
+// SummaryCount and SummaryDomain are the rows of the summary's table form.
+type SummaryCount struct {
+	Category string `json:"category"`
+	Name     string `json:"name"`
+	Count    int    `json:"count"`
+}
+
+type SummaryDomain struct {
+	Domain string `json:"domain"`
+}
+
+var summaryCmd = &cobra.Command{
+	Use:   "summary",
+	Short: "Show a summary of the deployment",
+	Long:  "Show desired and running instance counts, instances and tasks by state, per-cell counts and fresh domains from the BBS",
+	RunE:  summary,
+}
+
+func init() {
+	AddBBSAndTimeoutFlags(summaryCmd)
+	RootCmd.AddCommand(summaryCmd)
+}
+
+func summary(cmd *cobra.Command, args []string) error {
+	err := ValidateSummaryArguments(args)
+	if err != nil {
+		return NewCFDotValidationError(cmd, err)
+	}
+
+	bbsClient, err := helpers.NewBBSClient(cmd, Config)
+	if err != nil {
+		return NewCFDotError(cmd, err)
+	}
+
+	err = PrintSummary(cmd.OutOrStdout(), cmd.OutOrStderr(), bbsClient)
+	if err != nil {
+		return NewCFDotError(cmd, err)
+	}
+
+	return nil
+}
+
+func ValidateSummaryArguments(args []string) error {
+	if len(args) > 0 {
+		return errExtraArguments
+	}
+	return nil
+}
+
+func PrintSummary(stdout, stderr io.Writer, bbsClient bbs.Client) error {
+	traceID := trace.GenerateTraceID()
+	logger := trace.LoggerWithTraceInfo(globalLogger.Session("summary"), traceID)
+
+	domains, err := bbsClient.Domains(logger, traceID)
+	if err != nil {
+		return err
+	}
+
+	schedulingInfos, err := bbsClient.DesiredLRPSchedulingInfos(logger, traceID, models.DesiredLRPFilter{})
+	if err != nil {
+		return err
+	}
+
+	actualLRPs, err := bbsClient.ActualLRPs(logger, traceID, models.ActualLRPFilter{})
+	if err != nil {
+		return err
+	}
+
+	tasks, err := bbsClient.TasksWithFilter(logger, traceID, models.TaskFilter{})
+	if err != nil {
+		return err
+	}
+
+	cellPresences, err := bbsClient.Cells(logger, traceID)
+	if err != nil {
+		return err
+	}
+
+	encoder := newOutputEncoder(stdout)
+	err = encoder.Encode(BuildSummary(domains, schedulingInfos, actualLRPs, tasks, cellPresences))
+	if err != nil {
+		return err
+	}
+
+	return encoder.Flush()
+}
+
+func BuildSummary(
+	domains []string,
+	schedulingInfos []*models.DesiredLRPSchedulingInfo,
+	actualLRPs []*models.ActualLRP,
+	tasks []*models.Task,
+	cellPresences []*models.CellPresence,
+) *Summary {
+	summary := &Summary{
+		Domains:          append([]string{}, domains...),
+		DesiredLRPs:      len(schedulingInfos),
+		InstancesByState: map[string]int{},
+		Tasks:            len(tasks),
+		TasksByState:     map[string]int{},
+		Cells:            []*SummaryCell{},
+	}
+	sort.Strings(summary.Domains)
+
+	for _, schedulingInfo := range schedulingInfos {
+		summary.DesiredInstances += int(schedulingInfo.Instances)
+	}
+
+	cells := map[string]*SummaryCell{}
+	cellFor := func(cellID string) *SummaryCell {
+		cell, ok := cells[cellID]
+		if !ok {
+			cell = &SummaryCell{CellID: cellID}
+			cells[cellID] = cell
+		}
+		return cell
+	}
+
+	for _, cellPresence := range cellPresences {
+		cell := cellFor(cellPresence.CellId)
+		cell.Zone = cellPresence.Zone
+		cell.Registered = true
+	}
+
+	// An instance that is being evacuated has two actual LRPs; it is only
+	// counted once towards the running instances.
+	running := map[string]bool{}
+	for _, actualLRP := range actualLRPs {
+		summary.InstancesByState[actualLRP.State]++
+
+		if actualLRP.State == models.ActualLRPStateRunning {
+			running[fmt.Sprintf("%s/%d", actualLRP.ProcessGuid, actualLRP.Index)] = true
+		}
+
+		if actualLRP.CellId != "" {
+			cell := cellFor(actualLRP.CellId)
+			cell.Instances++
+			if actualLRP.State == models.ActualLRPStateRunning {
+				cell.RunningInstances++
+			}
+		}
+	}
+	summary.RunningInstances = len(running)
+
+	for _, task := range tasks {
+		summary.TasksByState[task.State.String()]++
+
+		if task.CellId != "" {
+			cellFor(task.CellId).Tasks++
+		}
+	}
+
+	for _, cell := range cells {
+		summary.Cells = append(summary.Cells, cell)
+	}
+	sort.Slice(summary.Cells, func(i, j int) bool {
+		return summary.Cells[i].CellID < summary.Cells[j].CellID
+	})
+
+	return summary
+}
+
+func (s *Summary) TableRows() []interface{} {
+	rows := []interface{}{
+		&SummaryCount{Category: "lrps", Name: "desired", Count: s.DesiredLRPs},
+		&SummaryCount{Category: "instances", Name: "desired", Count: s.DesiredInstances},
+		&SummaryCount{Category: "instances", Name: "running", Count: s.RunningInstances},
+	}
+
+	for _, state := range sortedKeys(s.InstancesByState) {
+		rows = append(rows, &SummaryCount{Category: "instances by state", Name: state, Count: s.InstancesByState[state]})
+	}
+
+	rows = append(rows, &SummaryCount{Category: "tasks", Name: "total", Count: s.Tasks})
+	for _, state := range sortedKeys(s.TasksByState) {
+		rows = append(rows, &SummaryCount{Category: "tasks by state", Name: state, Count: s.TasksByState[state]})
+	}
+
+	for _, cell := range s.Cells {
+		rows = append(rows, cell)
+	}
+
+	for _, domain := range s.Domains {
+		rows = append(rows, &SummaryDomain{Domain: domain})
+	}
+
+	return rows
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,126 @@
+package commands_test
+
+import (
+	"encoding/json"
+	"strings"
+
+	"code.cloudfoundry.org/bbs/fake_bbs"
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/cfdot/commands"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("Summary", func() {
+	var (
+		fakeBBSClient  *fake_bbs.FakeClient
+		stdout, stderr *gbytes.Buffer
+	)
+
+	BeforeEach(func() {
+		stdout = gbytes.NewBuffer()
+		stderr = gbytes.NewBuffer()
+		fakeBBSClient = &fake_bbs.FakeClient{}
+
+		fakeBBSClient.DomainsReturns([]string{"domain-2", "domain-1"}, nil)
+		fakeBBSClient.DesiredLRPSchedulingInfosReturns([]*models.DesiredLRPSchedulingInfo{
+			{DesiredLRPKey: models.DesiredLRPKey{ProcessGuid: "process-guid-1"}, Instances: 2},
+			{DesiredLRPKey: models.DesiredLRPKey{ProcessGuid: "process-guid-2"}, Instances: 1},
+		}, nil)
+		fakeBBSClient.ActualLRPsReturns([]*models.ActualLRP{
+			{
+				ActualLRPKey:         models.NewActualLRPKey("process-guid-1", 0, "domain-1"),
+				ActualLRPInstanceKey: models.ActualLRPInstanceKey{CellId: "cell-1"},
+				State:                models.ActualLRPStateRunning,
+			},
+			{
+				ActualLRPKey:         models.NewActualLRPKey("process-guid-1", 0, "domain-1"),
+				ActualLRPInstanceKey: models.ActualLRPInstanceKey{CellId: "cell-2"},
+				State:                models.ActualLRPStateRunning,
+				Presence:             models.ActualLRP_Evacuating,
+			},
+			{
+				ActualLRPKey: models.NewActualLRPKey("process-guid-1", 1, "domain-1"),
+				State:        models.ActualLRPStateUnclaimed,
+			},
+			{
+				ActualLRPKey:         models.NewActualLRPKey("process-guid-2", 0, "domain-2"),
+				ActualLRPInstanceKey: models.ActualLRPInstanceKey{CellId: "cell-3"},
+				State:                models.ActualLRPStateCrashed,
+			},
+		}, nil)
+		fakeBBSClient.TasksWithFilterReturns([]*models.Task{
+			{TaskGuid: "task-1", State: models.Task_Running, CellId: "cell-1"},
+			{TaskGuid: "task-2", State: models.Task_Pending},
+		}, nil)
+		fakeBBSClient.CellsReturns([]*models.CellPresence{
+			{CellId: "cell-1", Zone: "z1"},
+			{CellId: "cell-2", Zone: "z2"},
+		}, nil)
+	})
+
+	It("prints a json summary of the deployment", func() {
+		err := commands.PrintSummary(stdout, stderr, fakeBBSClient)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(fakeBBSClient.ActualLRPsCallCount()).To(Equal(1))
+		_, _, filter := fakeBBSClient.ActualLRPsArgsForCall(0)
+		Expect(filter).To(Equal(models.ActualLRPFilter{}))
+		_, _, taskFilter := fakeBBSClient.TasksWithFilterArgsForCall(0)
+		Expect(taskFilter).To(Equal(models.TaskFilter{}))
+
+		var summary commands.Summary
+		Expect(json.Unmarshal(stdout.Contents(), &summary)).To(Succeed())
+
+		Expect(summary).To(Equal(commands.Summary{
+			Domains:          []string{"domain-1", "domain-2"},
+			DesiredLRPs:      2,
+			DesiredInstances: 3,
+			RunningInstances: 1,
+			InstancesByState: map[string]int{"RUNNING": 2, "UNCLAIMED": 1, "CRASHED": 1},
+			Tasks:            2,
+			TasksByState:     map[string]int{"Running": 1, "Pending": 1},
+			Cells: []*commands.SummaryCell{
+				{CellID: "cell-1", Zone: "z1", Registered: true, Instances: 1, RunningInstances: 1, Tasks: 1},
+				{CellID: "cell-2", Zone: "z2", Registered: true, Instances: 1, RunningInstances: 1},
+				{CellID: "cell-3", Instances: 1},
+			},
+		}))
+	})
+
+	It("prints the summary as tables", func() {
+		commands.Output.Format = "table"
+
+		err := commands.PrintSummary(stdout, stderr, fakeBBSClient)
+		Expect(err).NotTo(HaveOccurred())
+
+		tables := strings.Split(strings.TrimSpace(string(stdout.Contents())), "\n\n")
+		Expect(tables).To(HaveLen(3))
+
+		counts := strings.Split(tables[0], "\n")
+		Expect(strings.Fields(counts[0])).To(Equal([]string{"CATEGORY", "NAME", "COUNT"}))
+		Expect(counts).To(ContainElement(MatchRegexp(`^instances\s+running\s+1\s*$`)))
+		Expect(counts).To(ContainElement(MatchRegexp(`^instances by state\s+CRASHED\s+1\s*$`)))
+		Expect(counts).To(ContainElement(MatchRegexp(`^tasks by state\s+Pending\s+1\s*$`)))
+
+		cells := strings.Split(tables[1], "\n")
+		Expect(strings.Fields(cells[0])).To(Equal([]string{"CELL", "ID", "ZONE", "REGISTERED", "INSTANCES", "RUNNING", "INSTANCES", "TASKS"}))
+		Expect(strings.Fields(cells[1])).To(Equal([]string{"cell-1", "z1", "true", "1", "1", "1"}))
+
+		Expect(strings.Split(tables[2], "\n")).To(Equal([]string{"DOMAIN", "domain-1", "domain-2"}))
+	})
+
+	Context("when the bbs errors", func() {
+		BeforeEach(func() {
+			fakeBBSClient.TasksWithFilterReturns(nil, models.ErrUnknownError)
+		})
+
+		It("fails with a relevant error", func() {
+			err := commands.PrintSummary(stdout, stderr, fakeBBSClient)
+			Expect(err).To(Equal(models.ErrUnknownError))
+			Expect(stdout.Contents()).To(BeEmpty())
+		})
+	})
+})
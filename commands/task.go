@@ -1,7 +1,6 @@
 package commands
 
 import (
-	"encoding/json"
 	"io"
 
 	"code.cloudfoundry.org/bbs"
@@ -49,13 +48,13 @@ func TaskByGuid(stdout, _ io.Writer, bbsClient bbs.Client, taskGuid string) erro
 		return err
 	}
 
-	encoder := json.NewEncoder(stdout)
+	encoder := newOutputEncoder(stdout)
 	err = encoder.Encode(task)
 	if err != nil {
 		logger.Error("failed-to-marshal", err)
 	}
 
-	return nil
+	return encoder.Flush()
 }
 
 func ValidateTaskArgs(args []string) (string, error) {
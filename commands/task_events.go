@@ -1,10 +1,10 @@
 package commands
 
 import (
-	"encoding/json"
 	"io"
 
 	"code.cloudfoundry.org/bbs"
+	"code.cloudfoundry.org/bbs/events"
 	"code.cloudfoundry.org/bbs/models"
 	"code.cloudfoundry.org/cfdot/commands/helpers"
 	"github.com/spf13/cobra"
@@ -23,11 +23,18 @@ var taskEventsCmd = &cobra.Command{
 
 type TaskEvent struct {
 	Type string      `json:"type"`
-	Data interface{} `json:"data"`
+	Data interface{} `json:"data,omitempty"`
 }
 
 func init() {
 	AddBBSFlags(taskEventsCmd)
+	AddEventStreamFlags(taskEventsCmd)
+
+	taskEventsCmd.Flags().StringVarP(&taskEventsCellIdFlag, "cell-id", "c", "", "retrieve only events for tasks placed on the given cell id, which excludes the creation and removal of pending tasks")
+	taskEventsCmd.Flags().StringVar(&eventFilterDomainFlag, "domain", "", "retrieve only events for the given domain")
+	taskEventsCmd.Flags().StringSliceVar(&eventFilterTypesFlag, "type", nil, "retrieve only events of the given type, e.g. task_changed, can be repeated")
+	taskEventsCmd.Flags().StringSliceVar(&eventFilterStatesFlag, "state", nil, "retrieve only events for tasks in the given state, e.g. running, can be repeated")
+
 	RootCmd.AddCommand(taskEventsCmd)
 }
 
@@ -37,43 +44,103 @@ func taskEvents(cmd *cobra.Command, args []string) error {
 		return NewCFDotValidationError(cmd, err)
 	}
 
+	reconnect, err := eventStreamBackoff()
+	if err != nil {
+		return NewCFDotValidationError(cmd, err)
+	}
+
+	filter, err := newEventFilter(taskEventTypes)
+	if err != nil {
+		return NewCFDotValidationError(cmd, err)
+	}
+
+	recorder, err := eventStreamRecorder()
+	if err != nil {
+		return NewCFDotError(cmd, err)
+	}
+	defer recorder.Close()
+
 	bbsClient, err := helpers.NewBBSClient(cmd, Config)
 	if err != nil {
 		return NewCFDotError(cmd, err)
 	}
 
-	err = TaskEvents(cmd.OutOrStdout(), cmd.OutOrStderr(), bbsClient, taskEventsCellIdFlag)
+	err = TaskEvents(cmd.OutOrStdout(), cmd.OutOrStderr(), bbsClient, taskEventsCellIdFlag, reconnect, filter, recorder)
 	if err != nil {
 		return NewCFDotError(cmd, err)
 	}
 	return nil
 }
 
-func TaskEvents(stdout, stderr io.Writer, bbsClient bbs.Client, cellID string) error {
-	logger := globalLogger.Session("lrp-events")
+func TaskEvents(stdout, stderr io.Writer, bbsClient bbs.Client, cellID string, reconnect *EventStreamBackoff, filter *EventFilter, recorder *EventRecorder) error {
+	logger := globalLogger.Session("task-events")
 
-	es, err := bbsClient.SubscribeToTaskEvents(logger)
+	subscribe := func() (events.EventSource, error) {
+		return bbsClient.SubscribeToTaskEvents(logger)
+	}
+
+	es, err := subscribe()
 	if err != nil {
 		return models.ConvertError(err)
 	}
-	defer es.Close()
-	encoder := json.NewEncoder(stdout)
+	defer func() {
+		es.Close()
+	}()
+	encoder := newEventEncoder(stdout, recorder)
+
+	var reconnector *eventStreamReconnector
+	if reconnect != nil {
+		reconnector = newEventStreamReconnector(stderr, *reconnect, subscribe)
+	}
 
 	var taskEvents LRPEvent
 	for {
 		event, err := es.Next()
-		switch err {
-		case nil:
+		switch {
+		case err == nil:
+			if reconnector != nil {
+				reconnector.reset()
+			}
+			if !taskEventOnCell(event, cellID) || !filter.Matches(event) {
+				continue
+			}
 			taskEvents.Type = event.EventType()
 			taskEvents.Data = event
 			err = encoder.Encode(taskEvents)
 			if err != nil {
 				logger.Error("failed-to-marshal", err)
+				continue
+			}
+			err = encoder.Flush()
+			if err != nil {
+				return err
+			}
+		case reconnector != nil:
+			es.Close()
+			es = reconnector.reconnect(err, nil)
+			err = printStreamReconnected(encoder)
+			if err != nil {
+				return err
 			}
-		case io.EOF:
+		case err == io.EOF:
 			return nil
 		default:
 			return err
 		}
 	}
 }
+
+// taskEventOnCell matches the events of tasks placed on the cell. A
+// TaskChangedEvent matches when the task was or is on the cell, so that
+// tasks being placed on or leaving the cell are seen. Pending tasks are on no
+// cell, so their creation and removal never match.
+func taskEventOnCell(event models.Event, cellID string) bool {
+	if cellID == "" {
+		return true
+	}
+	if changed, ok := event.(*models.TaskChangedEvent); ok {
+		return changed.Before.GetCellId() == cellID || changed.After.GetCellId() == cellID
+	}
+	_, task := eventSubject(event)
+	return task != nil && task.CellId == cellID
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"io"
+	"time"
 
 	"code.cloudfoundry.org/bbs/events/eventfakes"
 	"code.cloudfoundry.org/bbs/fake_bbs"
@@ -55,7 +56,7 @@ var _ = Describe("Task Events", func() {
 
 		expectedLines := []string{string(data), string(data)}
 
-		err = commands.TaskEvents(stdout, stderr, fakeBBSClient, "")
+		err = commands.TaskEvents(stdout, stderr, fakeBBSClient, "", nil, nil, nil)
 		Expect(err).NotTo(HaveOccurred())
 
 		stdoutData := stdout.Contents()
@@ -68,7 +69,7 @@ var _ = Describe("Task Events", func() {
 	})
 
 	It("closes the event stream", func() {
-		err := commands.TaskEvents(stdout, stderr, fakeBBSClient, "")
+		err := commands.TaskEvents(stdout, stderr, fakeBBSClient, "", nil, nil, nil)
 		Expect(err).NotTo(HaveOccurred())
 
 		Expect(fakeEventSource.CloseCallCount()).To(Equal(1))
@@ -80,7 +81,7 @@ var _ = Describe("Task Events", func() {
 		})
 
 		It("returns an error", func() {
-			err := commands.TaskEvents(stdout, stderr, fakeBBSClient, "")
+			err := commands.TaskEvents(stdout, stderr, fakeBBSClient, "", nil, nil, nil)
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(ContainSubstring("failed to connect"))
 		})
@@ -93,9 +94,98 @@ var _ = Describe("Task Events", func() {
 		})
 
 		It("returns an error", func() {
-			err := commands.TaskEvents(stdout, stderr, fakeBBSClient, "")
+			err := commands.TaskEvents(stdout, stderr, fakeBBSClient, "", nil, nil, nil)
 			Expect(err).To(HaveOccurred())
 			Expect(err.Error()).To(ContainSubstring("boom"))
 		})
 	})
+
+	Context("when reconnecting", func() {
+		var reconnect *commands.EventStreamBackoff
+
+		BeforeEach(func() {
+			reconnect = &commands.EventStreamBackoff{Initial: time.Millisecond, Max: 2 * time.Millisecond}
+
+			failingEventSource := &eventfakes.FakeEventSource{}
+			failingEventSource.NextReturnsOnCall(0, models.NewTaskCreatedEvent(task), nil)
+			failingEventSource.NextReturnsOnCall(1, nil, errors.New("boom"))
+
+			closingEventSource := &eventfakes.FakeEventSource{}
+			closingEventSource.NextReturnsOnCall(0, models.NewTaskRemovedEvent(task), nil)
+			closingEventSource.NextReturnsOnCall(1, nil, io.EOF)
+
+			fakeBBSClient.SubscribeToTaskEventsReturnsOnCall(0, failingEventSource, nil)
+			fakeBBSClient.SubscribeToTaskEventsReturnsOnCall(1, nil, errors.New("unavailable"))
+			fakeBBSClient.SubscribeToTaskEventsReturnsOnCall(2, closingEventSource, nil)
+		})
+
+		It("resubscribes with backoff and marks every reconnect", func() {
+			output := &limitedWriter{Writer: stdout, limit: 3}
+
+			err := commands.TaskEvents(output, stderr, fakeBBSClient, "", reconnect, nil, nil)
+			Expect(err).To(Equal(errOutputClosed))
+			Expect(fakeBBSClient.SubscribeToTaskEventsCallCount()).To(Equal(4))
+
+			Expect(stdout).To(gbytes.Say(`{"type":"task_created","data":{"task":{"task_guid":"some-task"`))
+			Expect(stdout).To(gbytes.Say(`{"type":"stream_reconnected"}\n`))
+			Expect(stdout).To(gbytes.Say(`{"type":"task_removed","data":{"task":{"task_guid":"some-task"`))
+
+			Expect(stderr).To(gbytes.Say("Event stream failed: boom, resubscribing in 1ms"))
+			Expect(stderr).To(gbytes.Say("Failed to resubscribe to event stream: unavailable, resubscribing in 2ms"))
+			Expect(stderr).To(gbytes.Say("Event stream was closed, resubscribing in 1ms"))
+		})
+	})
+
+	Context("when filtering", func() {
+		BeforeEach(func() {
+			pending := &models.Task{TaskGuid: "pending-task", Domain: "cf-tasks", State: models.Task_Pending}
+			running := &models.Task{TaskGuid: "running-task", Domain: "cf-tasks", State: models.Task_Running, CellId: "cell-1"}
+			other := &models.Task{TaskGuid: "other-task", Domain: "cf-tasks", State: models.Task_Running, CellId: "cell-2"}
+
+			fakeEventSource.NextStub = nil
+			fakeEventSource.NextReturnsOnCall(0, models.NewTaskCreatedEvent(pending), nil)
+			fakeEventSource.NextReturnsOnCall(1, models.NewTaskChangedEvent(pending, running), nil)
+			fakeEventSource.NextReturnsOnCall(2, models.NewTaskChangedEvent(pending, other), nil)
+			fakeEventSource.NextReturns(nil, io.EOF)
+		})
+
+		It("only prints the events matching the filter", func() {
+			filter := &commands.EventFilter{Domain: "cf-tasks", States: []models.Task_State{models.Task_Running}}
+
+			err := commands.TaskEvents(stdout, stderr, fakeBBSClient, "", nil, filter, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stdout).To(gbytes.Say(`"running-task"`))
+			Expect(stdout).To(gbytes.Say(`"other-task"`))
+			Expect(string(stdout.Contents())).NotTo(ContainSubstring("task_created"))
+		})
+
+		It("only prints the events for tasks on the cell", func() {
+			err := commands.TaskEvents(stdout, stderr, fakeBBSClient, "cell-1", nil, nil, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(bytes.Count(stdout.Contents(), []byte("\n"))).To(Equal(1))
+			Expect(stdout).To(gbytes.Say(`"running-task"`))
+		})
+
+		It("does not print the events of pending tasks, which are on no cell", func() {
+			pending := &models.Task{TaskGuid: "pending-task", Domain: "cf-tasks", State: models.Task_Pending}
+			fakeEventSource.NextReturnsOnCall(0, models.NewTaskCreatedEvent(pending), nil)
+			fakeEventSource.NextReturnsOnCall(1, models.NewTaskRemovedEvent(pending), nil)
+			fakeEventSource.NextReturnsOnCall(2, nil, io.EOF)
+
+			err := commands.TaskEvents(stdout, stderr, fakeBBSClient, "cell-1", nil, nil, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stdout.Contents()).To(BeEmpty())
+		})
+
+		It("prints the changes of tasks leaving the cell", func() {
+			running := &models.Task{TaskGuid: "running-task", Domain: "cf-tasks", State: models.Task_Running, CellId: "cell-1"}
+			resolving := &models.Task{TaskGuid: "running-task", Domain: "cf-tasks", State: models.Task_Resolving}
+			fakeEventSource.NextReturnsOnCall(0, models.NewTaskChangedEvent(running, resolving), nil)
+			fakeEventSource.NextReturnsOnCall(1, nil, io.EOF)
+
+			err := commands.TaskEvents(stdout, stderr, fakeBBSClient, "cell-1", nil, nil, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(stdout).To(gbytes.Say(`"type":"task_changed"`))
+		})
+	})
 })
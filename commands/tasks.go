@@ -1,7 +1,6 @@
 package commands
 
 import (
-	"encoding/json"
 	"io"
 
 	"code.cloudfoundry.org/bbs"
@@ -68,7 +67,7 @@ func Tasks(stdout, _ io.Writer, bbsClient bbs.Client, domain, cellID string) err
 		return err
 	}
 
-	encoder := json.NewEncoder(stdout)
+	encoder := newOutputEncoder(stdout)
 	for _, task := range tasks {
 		err = encoder.Encode(task)
 		if err != nil {
@@ -76,7 +75,7 @@ func Tasks(stdout, _ io.Writer, bbsClient bbs.Client, domain, cellID string) err
 		}
 	}
 
-	return nil
+	return encoder.Flush()
 }
 
 func ValidateTasksArgs(args []string) error {
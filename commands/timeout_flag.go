@@ -43,13 +43,24 @@ func TimeoutPrehook(cmd *cobra.Command, args []string) error {
 }
 
 func setTimeoutFlag(cmd *cobra.Command, args []string) error {
-	if timeoutConfig.Timeout == 0 && os.Getenv("CFDOT_TIMEOUT") != "" {
+	// A --timeout 0 that was passed explicitly disables the timeout.
+	if cmd.Flags().Changed("timeout") {
+		return nil
+	}
+
+	if os.Getenv("CFDOT_TIMEOUT") != "" {
 		timeout, err := strconv.ParseInt(os.Getenv("CFDOT_TIMEOUT"), 10, 16)
 		if err != nil {
 			return err
 		}
 		timeoutConfig.Timeout = int(timeout)
+		return nil
 	}
 
+	profile, err := activeProfile(cmd)
+	if err != nil {
+		return err
+	}
+	timeoutConfig.Timeout = profile.Timeout
 	return nil
 }
@@ -47,16 +47,30 @@ func tlsPreHook(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	if Config.CACertFile == "" {
-		Config.CACertFile = os.Getenv("CA_CERT_FILE")
-	}
-
-	if Config.CertFile == "" {
-		Config.CertFile = os.Getenv("CLIENT_CERT_FILE")
-	}
+	caCertFileSet := setFromFlagOrEnv(cmd, &Config.CACertFile, "caCertFile", "CA_CERT_FILE")
+	certFileSet := setFromFlagOrEnv(cmd, &Config.CertFile, "clientCertFile", "CLIENT_CERT_FILE")
+	keyFileSet := setFromFlagOrEnv(cmd, &Config.KeyFile, "clientKeyFile", "CLIENT_KEY_FILE")
+
+	// The profile is only read when flags and environment variables leave a
+	// file unset. Its skip_cert_verify is only used in that case as well.
+	if !caCertFileSet || !certFileSet || !keyFileSet {
+		p, err := activeProfile(cmd)
+		if err != nil {
+			return err
+		}
 
-	if Config.KeyFile == "" {
-		Config.KeyFile = os.Getenv("CLIENT_KEY_FILE")
+		if !cmd.Flags().Lookup("skipCertVerify").Changed && os.Getenv("SKIP_CERT_VERIFY") == "" && p.SkipCertVerify != nil {
+			Config.SkipCertVerify = *p.SkipCertVerify
+		}
+		if !caCertFileSet {
+			Config.CACertFile = p.CACertFile
+		}
+		if !certFileSet {
+			Config.CertFile = p.ClientCertFile
+		}
+		if !keyFileSet {
+			Config.KeyFile = p.ClientKeyFile
+		}
 	}
 
 	if !Config.SkipCertVerify {
@@ -94,3 +108,15 @@ func tlsPreHook(cmd *cobra.Command, args []string) error {
 
 	return nil
 }
+
+// setFromFlagOrEnv sets value from the environment variable unless the flag
+// was passed, and reports whether either of them set it. A value passed with
+// the flag is kept even when it is empty, so that flags always take
+// precedence over environment variables and the profile.
+func setFromFlagOrEnv(cmd *cobra.Command, value *string, flag, env string) bool {
+	if cmd.Flags().Changed(flag) {
+		return true
+	}
+	*value = os.Getenv(env)
+	return *value != ""
+}
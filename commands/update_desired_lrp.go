@@ -23,6 +23,7 @@ var updateDesiredLRPCmd = &cobra.Command{
 
 func init() {
 	AddBBSAndTimeoutFlags(updateDesiredLRPCmd)
+	AddDryRunFlag(updateDesiredLRPCmd)
 	RootCmd.AddCommand(updateDesiredLRPCmd)
 }
 
@@ -41,6 +42,8 @@ func updateDesiredLRP(cmd *cobra.Command, args []string) error {
 		return NewCFDotError(cmd, err)
 	}
 
+	bbsClient = mutatingBBSClient(cmd, bbsClient)
+
 	err = UpdateDesiredLRP(cmd.OutOrStdout(), cmd.OutOrStderr(), bbsClient, processGuid, spec)
 	if err != nil {
 		return NewCFDotError(cmd, err)
@@ -0,0 +1,280 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"code.cloudfoundry.org/bbs/models"
+	"github.com/spf13/cobra"
+)
+
+const (
+	SpecKindDesiredLRP = "desired-lrp"
+	SpecKindTask       = "task"
+	SpecKindUpdate     = "update"
+)
+
+// Violation is printed by validate for every problem found in a spec. Path
+// is a JSONPath to the offending field, e.g. $.action.serial.actions[1].run.path
+type Violation struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+type validatable interface {
+	Validate() error
+}
+
+// specActionFields hold action trees, whose actions are validated one by one
+// so that violations are reported on the action they belong to.
+var specActionFields = []string{"setup", "action", "monitor"}
+
+// specListFields hold lists whose elements are validated one by one.
+var specListFields = map[string]func() validatable{
+	"env":                 func() validatable { return &models.EnvironmentVariable{} },
+	"volume_mounts":       func() validatable { return &models.VolumeMount{} },
+	"image_layers":        func() validatable { return &models.ImageLayer{} },
+	"cached_dependencies": func() validatable { return &models.CachedDependency{} },
+	"egress_rules":        func() validatable { return &models.SecurityGroupRule{} },
+}
+
+// errors
+var (
+	errInvalidSpecKind = errors.New("Kind must be one of desired-lrp, task or update")
+)
+
+var validateCmd = &cobra.Command{
+	Use:   "validate (desired-lrp|task|update) (SPEC|@FILE)",
+	Short: "Validate a spec without a BBS",
+	Long:  "Validate a json encoded desired LRP, task or desired LRP update with the validation of the BBS, e.g. actions, environment variables, volume mounts, routes, image layers and resource limits. Every violation is printed with the path of the offending field. Does not connect to the BBS",
+	RunE:  validateSpec,
+}
+
+func init() {
+	RootCmd.AddCommand(validateCmd)
+}
+
+func validateSpec(cmd *cobra.Command, args []string) error {
+	kind, spec, err := ValidateSpecArguments(args)
+	if err != nil {
+		return NewCFDotValidationError(cmd, err)
+	}
+
+	violations, err := ValidateSpec(cmd.OutOrStdout(), cmd.OutOrStderr(), kind, spec)
+	if err != nil {
+		return NewCFDotError(cmd, err)
+	}
+
+	if violations > 0 {
+		cmd.SilenceUsage = true
+		return NewCFDotValidationError(cmd, fmt.Errorf("Found %d violations", violations))
+	}
+
+	return nil
+}
+
+func ValidateSpecArguments(args []string) (string, []byte, error) {
+	switch {
+	case len(args) < 2:
+		return "", nil, errMissingArguments
+	case len(args) > 2:
+		return "", nil, errExtraArguments
+	}
+
+	switch args[0] {
+	case SpecKindDesiredLRP, SpecKindTask, SpecKindUpdate:
+	default:
+		return "", nil, errInvalidSpecKind
+	}
+
+	spec := []byte(args[1])
+	if strings.HasPrefix(args[1], "@") {
+		var err error
+		spec, err = os.ReadFile(args[1][1:])
+		if err != nil {
+			return "", nil, err
+		}
+	}
+
+	return args[0], spec, nil
+}
+
+// ValidateSpec prints the violations found in the spec and returns how many
+// there are.
+func ValidateSpec(stdout, stderr io.Writer, kind string, spec []byte) (int, error) {
+	violations, err := FindSpecViolations(kind, spec)
+	if err != nil {
+		return 0, err
+	}
+
+	encoder := newOutputEncoder(stdout)
+	for _, violation := range violations {
+		err = encoder.Encode(violation)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return len(violations), encoder.Flush()
+}
+
+// FindSpecViolations decodes the spec as the given kind and runs the BBS
+// validation on it.
+func FindSpecViolations(kind string, spec []byte) ([]*Violation, error) {
+	var model validatable
+	switch kind {
+	case SpecKindDesiredLRP:
+		model = &models.DesiredLRP{}
+	case SpecKindTask:
+		model = &models.Task{}
+	case SpecKindUpdate:
+		model = &models.DesiredLRPUpdate{}
+	default:
+		return nil, errInvalidSpecKind
+	}
+
+	var fields map[string]interface{}
+	err := json.Unmarshal(spec, &fields)
+	if err != nil {
+		return []*Violation{{Path: "$", Error: fmt.Sprintf("Invalid JSON: %s", err)}}, nil
+	}
+
+	err = json.Unmarshal(spec, model)
+	if err != nil {
+		if typeErr, ok := err.(*json.UnmarshalTypeError); ok && typeErr.Field != "" {
+			return []*Violation{{Path: "$." + typeErr.Field, Error: typeErr.Error()}}, nil
+		}
+		return []*Violation{{Path: "$", Error: err.Error()}}, nil
+	}
+
+	return validateSpecNode("$", fields, model), nil
+}
+
+type specNode struct {
+	path   string
+	fields map[string]interface{}
+	model  validatable
+}
+
+// validateSpecNode reports the violations of the node that are not reported
+// by one of its children on the node itself, and the violations of the
+// children on the children.
+func validateSpecNode(path string, fields map[string]interface{}, model validatable) []*Violation {
+	own := flattenValidationError(model.Validate())
+	childViolations := []*Violation{}
+
+	for _, child := range specNodeChildren(path, fields) {
+		for _, childErr := range flattenValidationError(child.model.Validate()) {
+			for i, err := range own {
+				if err.Error() == childErr.Error() {
+					own = append(own[:i], own[i+1:]...)
+					break
+				}
+			}
+		}
+		childViolations = append(childViolations, validateSpecNode(child.path, child.fields, child.model)...)
+	}
+
+	violations := []*Violation{}
+	for _, err := range own {
+		violations = append(violations, newViolation(path, err))
+	}
+	return append(violations, childViolations...)
+}
+
+func specNodeChildren(path string, fields map[string]interface{}) []specNode {
+	children := []specNode{}
+
+	for _, name := range specActionFields {
+		if action, ok := fields[name].(map[string]interface{}); ok {
+			children = append(children, newActionNode(path+"."+name, action))
+		}
+	}
+	if actions, ok := fields["actions"].([]interface{}); ok {
+		for i, value := range actions {
+			if action, ok := value.(map[string]interface{}); ok {
+				children = append(children, newActionNode(fmt.Sprintf("%s.actions[%d]", path, i), action))
+			}
+		}
+	}
+
+	names := []string{}
+	for name := range specListFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		elements, ok := fields[name].([]interface{})
+		if !ok {
+			continue
+		}
+		for i, value := range elements {
+			element, ok := value.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			model := specListFields[name]()
+			if decodeSpecNode(element, model) == nil {
+				children = append(children, specNode{path: fmt.Sprintf("%s.%s[%d]", path, name, i), fields: element, model: model})
+			}
+		}
+	}
+
+	return children
+}
+
+// newActionNode returns the node of an action, e.g. {"run":{...}}. Its path
+// and fields are the ones of the inner action, so that the violations of
+// the inner action are reported on its fields.
+func newActionNode(path string, action map[string]interface{}) specNode {
+	node := specNode{path: path, fields: action, model: &models.Action{}}
+	decodeSpecNode(action, node.model)
+
+	if len(action) == 1 {
+		for name, value := range action {
+			if inner, ok := value.(map[string]interface{}); ok {
+				node.path = path + "." + name
+				node.fields = inner
+			}
+		}
+	}
+	return node
+}
+
+func decodeSpecNode(fields map[string]interface{}, model validatable) error {
+	encoded, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(encoded, model)
+}
+
+func flattenValidationError(err error) []error {
+	switch err := err.(type) {
+	case nil:
+		return nil
+	case models.ValidationError:
+		errs := []error{}
+		for _, e := range err {
+			errs = append(errs, flattenValidationError(e)...)
+		}
+		return errs
+	default:
+		return []error{err}
+	}
+}
+
+func newViolation(path string, err error) *Violation {
+	switch fieldErr := err.(type) {
+	case models.ErrInvalidField:
+		path = path + "." + fieldErr.Field
+	case *models.ErrInvalidField:
+		path = path + "." + fieldErr.Field
+	}
+	return &Violation{Path: path, Error: err.Error()}
+}
@@ -0,0 +1,144 @@
+package commands_test
+
+import (
+	"encoding/json"
+	"os"
+
+	"code.cloudfoundry.org/cfdot/commands"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("Validate", func() {
+	validDesiredLRP := `{
+		"process_guid": "process-guid",
+		"domain": "domain",
+		"rootfs": "preloaded:cflinuxfs4",
+		"instances": 1,
+		"action": {"run": {"path": "/bin/app", "user": "vcap"}}
+	}`
+
+	Describe("FindSpecViolations", func() {
+		It("finds no violations in a valid spec", func() {
+			violations, err := commands.FindSpecViolations("desired-lrp", []byte(validDesiredLRP))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(violations).To(BeEmpty())
+		})
+
+		It("reports top level fields with their path", func() {
+			violations, err := commands.FindSpecViolations("desired-lrp", []byte(`{"process_guid":"guid","instances":-1,"action":{"run":{"path":"/bin/app","user":"vcap"}}}`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(violations).To(Equal([]*commands.Violation{
+				{Path: "$.domain", Error: "Invalid field: domain"},
+				{Path: "$.rootfs", Error: "Invalid field: rootfs"},
+				{Path: "$.instances", Error: "Invalid field: instances"},
+			}))
+		})
+
+		It("reports violations in action trees on the offending action", func() {
+			violations, err := commands.FindSpecViolations("task", []byte(`{
+				"task_guid": "task-guid",
+				"domain": "domain",
+				"rootfs": "preloaded:cflinuxfs4",
+				"action": {"serial": {"actions": [
+					{"run": {"path": "/bin/setup", "user": "vcap"}},
+					{"timeout": {"timeout_ms": 1000, "action": {"run": {"user": "vcap", "env": [{"value": "1"}]}}}}
+				]}}
+			}`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(violations).To(Equal([]*commands.Violation{
+				{Path: "$.action.serial.actions[1].timeout.action.run.path", Error: "Invalid field: path"},
+				{Path: "$.action.serial.actions[1].timeout.action.run.env[0].name", Error: "Invalid field: name"},
+			}))
+		})
+
+		It("reports violations in lists on the offending element", func() {
+			var spec map[string]interface{}
+			Expect(json.Unmarshal([]byte(validDesiredLRP), &spec)).To(Succeed())
+			spec["volume_mounts"] = []interface{}{
+				map[string]interface{}{"driver": "nfs", "container_dir": "/data"},
+				map[string]interface{}{"driver": "nfs"},
+			}
+			spec["image_layers"] = []interface{}{map[string]interface{}{"url": "https://example.com/layer.tgz"}}
+			encoded, err := json.Marshal(spec)
+			Expect(err).NotTo(HaveOccurred())
+
+			violations, err := commands.FindSpecViolations("desired-lrp", encoded)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(violations).To(Equal([]*commands.Violation{
+				{Path: "$.image_layers[0].destination_path", Error: "Invalid field: destination_path"},
+				{Path: "$.volume_mounts[1].container_dir", Error: "Invalid field: container_dir"},
+			}))
+		})
+
+		It("validates desired lrp updates", func() {
+			violations, err := commands.FindSpecViolations("update", []byte(`{"instances":-2}`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(violations).To(Equal([]*commands.Violation{
+				{Path: "$.instances", Error: "Invalid field: instances"},
+			}))
+		})
+
+		It("reports fields of the wrong type", func() {
+			violations, err := commands.FindSpecViolations("desired-lrp", []byte(`{"instances":"two"}`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(violations).To(HaveLen(1))
+			Expect(violations[0].Path).To(Equal("$.instances"))
+		})
+
+		It("reports invalid json", func() {
+			violations, err := commands.FindSpecViolations("task", []byte(`{`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(violations).To(HaveLen(1))
+			Expect(violations[0].Path).To(Equal("$"))
+			Expect(violations[0].Error).To(HavePrefix("Invalid JSON"))
+		})
+	})
+
+	Describe("ValidateSpec", func() {
+		var stdout, stderr *gbytes.Buffer
+
+		BeforeEach(func() {
+			stdout = gbytes.NewBuffer()
+			stderr = gbytes.NewBuffer()
+		})
+
+		It("prints the violations and returns how many there are", func() {
+			violations, err := commands.ValidateSpec(stdout, stderr, "update", []byte(`{"instances":-2}`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(violations).To(Equal(1))
+			Expect(stdout).To(gbytes.Say(`{"path":"\$.instances","error":"Invalid field: instances"}`))
+		})
+	})
+
+	Describe("ValidateSpecArguments", func() {
+		It("reads the spec from a file", func() {
+			f, err := os.CreateTemp("", "spec_file")
+			Expect(err).NotTo(HaveOccurred())
+			defer os.Remove(f.Name())
+			_, err = f.WriteString(validDesiredLRP)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(f.Close()).To(Succeed())
+
+			kind, spec, err := commands.ValidateSpecArguments([]string{"desired-lrp", "@" + f.Name()})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(kind).To(Equal("desired-lrp"))
+			Expect(string(spec)).To(Equal(validDesiredLRP))
+		})
+
+		It("rejects unknown kinds", func() {
+			_, _, err := commands.ValidateSpecArguments([]string{"actual-lrp", "{}"})
+			Expect(err).To(MatchError("Kind must be one of desired-lrp, task or update"))
+		})
+
+		It("rejects missing and extra arguments", func() {
+			_, _, err := commands.ValidateSpecArguments([]string{"task"})
+			Expect(err).To(MatchError("Missing arguments"))
+
+			_, _, err = commands.ValidateSpecArguments([]string{"task", "{}", "{}"})
+			Expect(err).To(MatchError("Too many arguments specified"))
+		})
+	})
+})
@@ -50,6 +50,23 @@ var _ = Describe("actual-lrps", func() {
 			Expect(sess.Out).To(gbytes.Say(`"state":"running"`))
 		})
 
+		Context("when the output flag is set to table", func() {
+			It("prints a table of the actual lrps", func() {
+				sess := RunCFDot("actual-lrps", "--output", "table")
+				Eventually(sess).Should(gexec.Exit(0))
+				Expect(sess.Out).To(gbytes.Say(`PROCESS GUID\s+INDEX\s+STATE\s+CELL ID\s+SINCE`))
+				Expect(sess.Out).To(gbytes.Say(`running`))
+			})
+		})
+
+		Context("when the output flag is invalid", func() {
+			It("exits with status code of 3", func() {
+				sess := RunCFDot("actual-lrps", "--output", "xml")
+				Eventually(sess).Should(gexec.Exit(3))
+				Expect(sess.Err).To(gbytes.Say(`'xml' is not a valid output format`))
+			})
+		})
+
 		Context("when timeout flag is present", func() {
 			Context("when request exceeds timeout", func() {
 				BeforeEach(func() {
@@ -0,0 +1,83 @@
+package integration_test
+
+import (
+	"os"
+	"path/filepath"
+
+	"code.cloudfoundry.org/bbs/models"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/onsi/gomega/gexec"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("apply", func() {
+	var dir string
+
+	BeforeEach(func() {
+		dir = GinkgoT().TempDir()
+		Expect(os.WriteFile(filepath.Join(dir, "canary.yml"), []byte("process_guid: canary\ndomain: canary\nrootfs: preloaded:cflinuxfs4\ninstances: 1\naction:\n  run:\n    path: /bin/canary\n    user: vcap\n"), 0600)).To(Succeed())
+	})
+
+	itValidatesBBSFlags("apply", "-f", ".")
+
+	Context("when the desired lrp does not exist", func() {
+		BeforeEach(func() {
+			bbsServer.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", "/v1/desired_lrps/get_by_process_guid.r3"),
+					ghttp.RespondWithProto(200, &models.DesiredLRPResponse{
+						Error: models.ErrResourceNotFound,
+					}),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", "/v1/desired_lrp/desire.r2"),
+					ghttp.RespondWithProto(200, &models.DesiredLRPLifecycleResponse{}),
+				),
+			)
+		})
+
+		It("creates it", func() {
+			sess := RunCFDot("apply", "-f", dir, "--yes")
+			Eventually(sess).Should(gexec.Exit(0))
+			Expect(sess.Out).To(gbytes.Say(`"guid":"canary".*"action":"create"`))
+			Expect(bbsServer.ReceivedRequests()).To(HaveLen(2))
+		})
+	})
+
+	Context("when the confirmation is declined", func() {
+		BeforeEach(func() {
+			bbsServer.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", "/v1/desired_lrps/get_by_process_guid.r3"),
+					ghttp.RespondWithProto(200, &models.DesiredLRPResponse{
+						Error: models.ErrResourceNotFound,
+					}),
+				),
+			)
+		})
+
+		It("exits with status code of 5", func() {
+			sess := RunCFDot("apply", "-f", dir)
+			Eventually(sess).Should(gexec.Exit(5))
+			Expect(sess.Err).To(gbytes.Say("Apply aborted"))
+		})
+	})
+
+	Context("when invalid arguments are passed", func() {
+		It("exits with status code of 3 without a filename", func() {
+			sess := RunCFDot("apply")
+			Eventually(sess).Should(gexec.Exit(3))
+			Expect(sess.Err).To(gbytes.Say("-f/--filename is required"))
+		})
+
+		It("exits with status code of 3 for an invalid spec", func() {
+			Expect(os.WriteFile(filepath.Join(dir, "invalid.json"), []byte("{"), 0600)).To(Succeed())
+			sess := RunCFDot("apply", "-f", dir)
+			Eventually(sess).Should(gexec.Exit(3))
+			Expect(sess.Err).To(gbytes.Say("Invalid JSON"))
+		})
+	})
+})
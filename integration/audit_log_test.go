@@ -0,0 +1,74 @@
+package integration_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"code.cloudfoundry.org/bbs/models"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/onsi/gomega/gexec"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("audit-log", func() {
+	runAuditLog := func(args ...string) *gexec.Session {
+		sess, err := gexec.Start(exec.Command(cfdotPath, append([]string{"audit-log"}, args...)...), GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		return sess
+	}
+
+	BeforeEach(func() {
+		bbsServer.AppendHandlers(
+			ghttp.CombineHandlers(
+				ghttp.VerifyRequest("POST", "/v1/domains/upsert"),
+				ghttp.RespondWithProto(200, &models.UpsertDomainResponse{}),
+			),
+		)
+	})
+
+	It("lists the requests sent by mutating commands", func() {
+		sess := RunCFDot("set-domain", "any-domain", "--ttl", "40s")
+		Eventually(sess).Should(gexec.Exit(0))
+
+		sess = runAuditLog("--command", "set-domain")
+		Eventually(sess).Should(gexec.Exit(0))
+		Expect(sess.Out).To(gbytes.Say(`"command":"set-domain","command_line":\[.*"set-domain","any-domain","--ttl","40s"\],"trace_id":"\w+","target":"` + bbsServer.URL() + `","method":"UpsertDomain","request":{"domain":"any-domain","ttl":40},"outcome":"success"}`))
+	})
+
+	It("does not record dry runs", func() {
+		bbsServer.SetHandler(0, ghttp.CombineHandlers(
+			ghttp.VerifyRequest("POST", "/v1/domains/list"),
+			ghttp.RespondWithProto(200, &models.DomainsResponse{}),
+		))
+
+		sess := RunCFDot("set-domain", "any-domain", "--dry-run")
+		Eventually(sess).Should(gexec.Exit(0))
+
+		_, err := os.Stat(os.Getenv("CFDOT_AUDIT_LOG"))
+		Expect(os.IsNotExist(err)).To(BeTrue())
+	})
+
+	It("writes the audit log to --audit-log", func() {
+		path := filepath.Join(GinkgoT().TempDir(), "audit.jsonl")
+
+		sess := RunCFDot("set-domain", "any-domain", "--audit-log", path)
+		Eventually(sess).Should(gexec.Exit(0))
+
+		sess = runAuditLog("--audit-log", path)
+		Eventually(sess).Should(gexec.Exit(0))
+		Expect(sess.Out).To(gbytes.Say(`"method":"UpsertDomain"`))
+	})
+
+	It("warns and runs the command when the audit log cannot be written", func() {
+		path := GinkgoT().TempDir()
+
+		sess := RunCFDot("set-domain", "any-domain", "--audit-log", path)
+		Eventually(sess).Should(gexec.Exit(0))
+		Expect(sess.Err).To(gbytes.Say("Warning: the requests of this command are not recorded in the audit log"))
+		Expect(bbsServer.ReceivedRequests()).To(HaveLen(1))
+	})
+})
@@ -0,0 +1,10 @@
+package integration_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+)
+
+var _ = Describe("cell-capacity", func() {
+	itValidatesBBSFlags("cell-capacity")
+	itHasNoArgs("cell-capacity", false)
+})
@@ -293,9 +293,9 @@ var _ = Describe("cell-state", func() {
 				It("exits with status code of 4", func() {
 					sess := RunCFDot("cell-states")
 					Eventually(sess).Should(gexec.Exit(4))
-					Expect(sess.Err).To(gbytes.Say("Rep error"))
-					Expect(sess.Err).To(gbytes.Say("Failed to get cell state for cell cell-1"))
-					Expect(sess.Err).To(gbytes.Say("Failed to get cell state for cell cell-2"))
+					Expect(sess.Err).To(gbytes.Say(`"cell_id":"cell-1"`))
+					Expect(sess.Err).To(gbytes.Say(`"cell_id":"cell-2"`))
+					Expect(sess.Err).To(gbytes.Say("Rep error: Failed to get cell state for 2 of 2 cells"))
 				})
 			})
 		})
@@ -313,5 +313,21 @@ var _ = Describe("cell-state", func() {
 				})
 			})
 		})
+
+		Context("when cell-states is called with an invalid concurrency", func() {
+			It("exits with status code of 3", func() {
+				sess := RunCFDot("cell-states", "--concurrency", "0")
+				Eventually(sess).Should(gexec.Exit(3))
+				Expect(sess.Err).To(gbytes.Say("--concurrency must be a positive integer"))
+			})
+		})
+
+		Context("when cell-states is called with an invalid cell timeout", func() {
+			It("exits with status code of 3", func() {
+				sess := RunCFDot("cell-states", "--cell-timeout", "0s")
+				Eventually(sess).Should(gexec.Exit(3))
+				Expect(sess.Err).To(gbytes.Say("--cell-timeout must be a positive duration"))
+			})
+		})
 	})
 })
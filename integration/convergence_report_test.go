@@ -0,0 +1,58 @@
+package integration_test
+
+import (
+	"code.cloudfoundry.org/bbs/models"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/onsi/gomega/gexec"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("convergence-report", func() {
+	itValidatesBBSFlags("convergence-report")
+	itHasNoArgs("convergence-report", false)
+
+	Context("when the bbs responds", func() {
+		BeforeEach(func() {
+			bbsServer.RouteToHandler("POST", "/v1/desired_lrp_scheduling_infos/list",
+				ghttp.RespondWithProto(200, &models.DesiredLRPSchedulingInfosResponse{
+					DesiredLrpSchedulingInfos: []*models.DesiredLRPSchedulingInfo{
+						{DesiredLRPKey: models.DesiredLRPKey{ProcessGuid: "process-guid-1", Domain: "domain-1"}, Instances: 2},
+					},
+				}),
+			)
+			bbsServer.RouteToHandler("POST", "/v1/actual_lrps/list",
+				ghttp.RespondWithProto(200, &models.ActualLRPsResponse{
+					ActualLrps: []*models.ActualLRP{
+						{
+							ActualLRPKey: models.NewActualLRPKey("process-guid-1", 0, "domain-1"),
+							State:        models.ActualLRPStateRunning,
+						},
+					},
+				}),
+			)
+		})
+
+		It("prints a json stream of the convergence issues", func() {
+			sess := RunCFDot("convergence-report", "--domain", "domain-1")
+			Eventually(sess).Should(gexec.Exit(0))
+			Expect(sess.Out).To(gbytes.Say(`{"type":"under_running","process_guid":"process-guid-1","domain":"domain-1","desired_instances":2,"running_instances":1}\n`))
+			Expect(sess.Out).To(gbytes.Say(`{"type":"missing_indices","process_guid":"process-guid-1","domain":"domain-1","desired_instances":2,"running_instances":1,"indices":\[1\]}\n`))
+		})
+	})
+
+	Context("when the bbs errors", func() {
+		BeforeEach(func() {
+			bbsServer.RouteToHandler("POST", "/v1/desired_lrp_scheduling_infos/list",
+				ghttp.RespondWith(500, []byte{}),
+			)
+		})
+
+		It("exits with status code 4", func() {
+			sess := RunCFDot("convergence-report")
+			Eventually(sess).Should(gexec.Exit(4))
+		})
+	})
+})
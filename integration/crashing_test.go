@@ -0,0 +1,88 @@
+package integration_test
+
+import (
+	"os"
+
+	"code.cloudfoundry.org/bbs/events"
+	"code.cloudfoundry.org/bbs/models"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/onsi/gomega/gexec"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("crashing", func() {
+	itValidatesBBSFlags("crashing")
+	itHasNoArgs("crashing", false)
+
+	Context("when the bbs responds with actual lrps", func() {
+		BeforeEach(func() {
+			bbsServer.RouteToHandler("POST", "/v1/actual_lrps/list",
+				ghttp.RespondWithProto(200, &models.ActualLRPsResponse{
+					ActualLrps: []*models.ActualLRP{
+						{
+							ActualLRPKey: models.NewActualLRPKey("process-guid-1", 0, "domain-1"),
+							State:        models.ActualLRPStateCrashed,
+							CrashCount:   3,
+							CrashReason:  "oom",
+						},
+					},
+				}),
+			)
+		})
+
+		It("prints the ranked crash report", func() {
+			sess := RunCFDot("crashing")
+			Eventually(sess).Should(gexec.Exit(0))
+			Expect(sess.Out).To(gbytes.Say(`"process_guid":"process-guid-1","domain":"domain-1","crashed_instances":1,"crashing_instances":0,"total_crash_count":3,"max_crash_count":3,"last_crash_reason":"oom"`))
+		})
+	})
+
+	Context("when --watch is used with --timeout", func() {
+		It("exits with status code 3", func() {
+			sess := RunCFDot("crashing", "--watch", "--timeout", "5")
+			Eventually(sess).Should(gexec.Exit(3))
+			Expect(sess.Err).To(gbytes.Say("--timeout cannot be used with --watch"))
+		})
+	})
+
+	Context("when a timeout is set through the environment", func() {
+		BeforeEach(func() {
+			os.Setenv("CFDOT_TIMEOUT", "5")
+
+			crashedEvent, err := events.NewEventFromModelEvent(1, &models.ActualLRPCrashedEvent{
+				ActualLRPKey:         models.NewActualLRPKey("process-guid-1", 0, "domain-1"),
+				ActualLRPInstanceKey: models.NewActualLRPInstanceKey("instance-guid", "cell-1"),
+				CrashCount:           1,
+				Since:                1000000000,
+			})
+			Expect(err).ToNot(HaveOccurred())
+
+			bbsServer.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", "/v1/events/lrp_instances.r1"),
+					ghttp.RespondWith(200, crashedEvent.Encode()),
+				),
+			)
+		})
+
+		AfterEach(func() {
+			os.Unsetenv("CFDOT_TIMEOUT")
+		})
+
+		It("watches the event stream without the timeout", func() {
+			sess := RunCFDot("crashing", "--watch", "--min-crashes", "1")
+			Eventually(sess).Should(gexec.Exit(0))
+			Expect(sess.Out).To(gbytes.Say(`"process_guid":"process-guid-1","index":0,"domain":"domain-1","cell_id":"cell-1","crash_count":1,"crashes_in_window":1`))
+		})
+	})
+
+	Context("when --min-crash-count is invalid", func() {
+		It("exits with status code 3", func() {
+			sess := RunCFDot("crashing", "--min-crash-count", "0")
+			Eventually(sess).Should(gexec.Exit(3))
+		})
+	})
+})
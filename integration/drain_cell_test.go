@@ -0,0 +1,71 @@
+package integration_test
+
+import (
+	"code.cloudfoundry.org/bbs/models"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/onsi/gomega/gexec"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("drain-cell", func() {
+	itValidatesBBSFlags("drain-cell", "cell-1")
+
+	Context("when the cell has no actual lrps", func() {
+		BeforeEach(func() {
+			bbsServer.RouteToHandler("POST", "/v1/cells/list.r1",
+				ghttp.RespondWithProto(200, &models.CellsResponse{
+					Cells: []*models.CellPresence{{CellId: "cell-1"}},
+				}),
+			)
+			bbsServer.RouteToHandler("POST", "/v1/actual_lrps/list",
+				ghttp.CombineHandlers(
+					ghttp.VerifyProtoRepresenting(&models.ActualLRPsRequest{CellId: "cell-1"}),
+					ghttp.RespondWithProto(200, &models.ActualLRPsResponse{}),
+				),
+			)
+			bbsServer.RouteToHandler("POST", "/v1/tasks/list.r3",
+				ghttp.RespondWithProto(200, &models.TasksResponse{
+					Tasks: []*models.Task{
+						{TaskGuid: "task-guid", Domain: "domain", State: models.Task_Running, CellId: "cell-1"},
+					},
+				}),
+			)
+		})
+
+		It("reports the tasks running on the cell", func() {
+			sess := RunCFDot("drain-cell", "cell-1")
+			Eventually(sess).Should(gexec.Exit(0))
+			Expect(sess.Out).To(gbytes.Say(`"event":"task_running","task_guid":"task-guid"`))
+		})
+	})
+
+	Context("when the cell is not registered", func() {
+		BeforeEach(func() {
+			bbsServer.RouteToHandler("POST", "/v1/cells/list.r1",
+				ghttp.RespondWithProto(200, &models.CellsResponse{}),
+			)
+		})
+
+		It("exits with status code of 5", func() {
+			sess := RunCFDot("drain-cell", "cell-1")
+			Eventually(sess).Should(gexec.Exit(5))
+			Expect(sess.Err).To(gbytes.Say("Cell not found"))
+		})
+	})
+
+	Context("when invalid arguments are passed", func() {
+		It("exits with status code of 3 without a cell id", func() {
+			sess := RunCFDot("drain-cell")
+			Eventually(sess).Should(gexec.Exit(3))
+		})
+
+		It("exits with status code of 3 for invalid max retries", func() {
+			sess := RunCFDot("drain-cell", "--max-retries", "-1", "cell-1")
+			Eventually(sess).Should(gexec.Exit(3))
+			Expect(sess.Err).To(gbytes.Say("--max-retries must not be negative"))
+		})
+	})
+})
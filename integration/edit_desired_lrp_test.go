@@ -0,0 +1,70 @@
+package integration_test
+
+import (
+	"os"
+
+	"code.cloudfoundry.org/bbs/models"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/onsi/gomega/gexec"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("edit-desired-lrp", func() {
+	BeforeEach(func() {
+		os.Setenv("EDITOR", "true")
+	})
+
+	AfterEach(func() {
+		os.Unsetenv("EDITOR")
+	})
+
+	itValidatesBBSFlags("edit-desired-lrp", "test-guid")
+
+	Context("when the desired lrp is not changed in the editor", func() {
+		BeforeEach(func() {
+			bbsServer.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", "/v1/desired_lrps/get_by_process_guid.r3"),
+					ghttp.RespondWithProto(200, &models.DesiredLRPResponse{
+						DesiredLrp: &models.DesiredLRP{ProcessGuid: "test-guid", Instances: 1},
+					}),
+				),
+			)
+		})
+
+		It("exits with status code of 0 without updating it", func() {
+			sess := RunCFDot("edit-desired-lrp", "test-guid")
+			Eventually(sess).Should(gexec.Exit(0))
+			Expect(sess.Err).To(gbytes.Say("Edit cancelled, no changes made"))
+			Expect(bbsServer.ReceivedRequests()).To(HaveLen(1))
+		})
+	})
+
+	Context("when the bbs returns an error", func() {
+		BeforeEach(func() {
+			bbsServer.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", "/v1/desired_lrps/get_by_process_guid.r3"),
+					ghttp.RespondWithProto(200, &models.DesiredLRPResponse{
+						Error: models.ErrResourceNotFound,
+					}),
+				),
+			)
+		})
+
+		It("exits with status code of 4", func() {
+			sess := RunCFDot("edit-desired-lrp", "test-guid")
+			Eventually(sess).Should(gexec.Exit(4))
+		})
+	})
+
+	Context("when no process guid is given", func() {
+		It("exits with status code of 3", func() {
+			sess := RunCFDot("edit-desired-lrp")
+			Eventually(sess).Should(gexec.Exit(3))
+		})
+	})
+})
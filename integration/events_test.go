@@ -0,0 +1,64 @@
+package integration_test
+
+import (
+	"code.cloudfoundry.org/bbs/events"
+	"code.cloudfoundry.org/bbs/models"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/onsi/gomega/gexec"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("events", func() {
+	itValidatesBBSFlags("events", "--locketAPILocation", "localhost:1")
+	itValidatesLocketFlags("events", "--bbsURL", "http://localhost:1")
+
+	runEvents := func(args ...string) *gexec.Session {
+		return RunCFDot(append([]string{"--locketAPILocation", locketAPILocation, "events"}, args...)...)
+	}
+
+	Context("when the BBS responds with events", func() {
+		BeforeEach(func() {
+			actualLRP := &models.ActualLRP{ActualLRPKey: models.NewActualLRPKey("some-process-guid", 0, "cf-apps")}
+			lrpEvent, err := events.NewEventFromModelEvent(1, models.NewActualLRPInstanceCreatedEvent(actualLRP, "some-trace-id"))
+			Expect(err).ToNot(HaveOccurred())
+			taskEvent, err := events.NewEventFromModelEvent(1, models.NewTaskRemovedEvent(&models.Task{TaskGuid: "some-task-guid"}))
+			Expect(err).ToNot(HaveOccurred())
+
+			bbsServer.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", "/v1/events/lrp_instances.r1"),
+					ghttp.RespondWith(200, lrpEvent.Encode()),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", "/v1/events/tasks.r1"),
+					ghttp.RespondWith(200, taskEvent.Encode()),
+				),
+			)
+		})
+
+		It("prints both event streams with their source", func() {
+			sess := runEvents("--locket-poll-interval", "10ms")
+			Eventually(sess).Should(gexec.Exit(0))
+			Expect(string(sess.Out.Contents())).To(ContainSubstring(`"source":"lrp","type":"actual_lrp_instance_created"`))
+			Expect(string(sess.Out.Contents())).To(ContainSubstring(`"source":"task","type":"task_removed"`))
+		})
+	})
+
+	Context("when the --locket-poll-interval is not positive", func() {
+		It("exits with status code of 3", func() {
+			sess := runEvents("--locket-poll-interval", "0s")
+			Eventually(sess).Should(gexec.Exit(3))
+			Expect(sess.Err).To(gbytes.Say("--locket-poll-interval must be a positive duration"))
+		})
+	})
+
+	Context("when arguments are passed", func() {
+		It("exits with status code of 3", func() {
+			sess := runEvents("extra-arg")
+			Eventually(sess).Should(gexec.Exit(3))
+		})
+	})
+})
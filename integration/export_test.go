@@ -0,0 +1,83 @@
+package integration_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"code.cloudfoundry.org/bbs/models"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/onsi/gomega/gexec"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("export", func() {
+	var path string
+
+	BeforeEach(func() {
+		path = filepath.Join(GinkgoT().TempDir(), "snapshot.json")
+	})
+
+	itValidatesBBSFlags("export", "snapshot.json")
+
+	Context("when the bbs responds", func() {
+		BeforeEach(func() {
+			bbsServer.RouteToHandler("POST", "/v1/domains/list",
+				ghttp.RespondWithProto(200, &models.DomainsResponse{
+					Domains: []string{"cf-apps"},
+				}),
+			)
+			bbsServer.RouteToHandler("POST", "/v1/desired_lrps/list.r3",
+				ghttp.RespondWithProto(200, &models.DesiredLRPsResponse{
+					DesiredLrps: []*models.DesiredLRP{{ProcessGuid: "process-guid", Domain: "cf-apps"}},
+				}),
+			)
+			bbsServer.RouteToHandler("POST", "/v1/tasks/list.r3",
+				ghttp.RespondWithProto(200, &models.TasksResponse{
+					Tasks: []*models.Task{{TaskGuid: "task-guid", State: models.Task_Running}},
+				}),
+			)
+		})
+
+		It("writes the snapshot file", func() {
+			sess := RunCFDot("export", path)
+			Eventually(sess).Should(gexec.Exit(0))
+			Expect(sess.Out).To(gbytes.Say(`"desired_lrps":1`))
+
+			contents, err := os.ReadFile(path)
+			Expect(err).NotTo(HaveOccurred())
+
+			var snapshot map[string]interface{}
+			Expect(json.Unmarshal(contents, &snapshot)).To(Succeed())
+			Expect(snapshot).To(HaveKeyWithValue("version", BeEquivalentTo(1)))
+			Expect(snapshot).To(HaveKeyWithValue("domains", ConsistOf("cf-apps")))
+		})
+	})
+
+	Context("when the bbs returns an error", func() {
+		BeforeEach(func() {
+			bbsServer.RouteToHandler("POST", "/v1/domains/list",
+				ghttp.RespondWithProto(200, &models.DomainsResponse{
+					Error: models.ErrUnknownError,
+				}),
+			)
+		})
+
+		It("exits with status code of 4", func() {
+			sess := RunCFDot("export", path)
+			Eventually(sess).Should(gexec.Exit(4))
+			Expect(path).NotTo(BeAnExistingFile())
+		})
+	})
+
+	Context("when no file is given", func() {
+		It("exits with status code of 3", func() {
+			sess := RunCFDot("export")
+			Eventually(sess).Should(gexec.Exit(3))
+			Expect(sess.Err).To(gbytes.Say("No export file given"))
+		})
+	})
+})
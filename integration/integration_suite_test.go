@@ -6,6 +6,7 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -67,6 +68,9 @@ var _ = SynchronizedAfterSuite(func() {
 })
 
 var _ = BeforeEach(func() {
+	os.Setenv("CFDOT_CONFIG", filepath.Join(GinkgoT().TempDir(), "config.yml"))
+	os.Setenv("CFDOT_AUDIT_LOG", filepath.Join(GinkgoT().TempDir(), "audit.jsonl"))
+
 	bbsServer = ghttp.NewUnstartedServer()
 	defer bbsServer.HTTPTestServer.StartTLS()
 
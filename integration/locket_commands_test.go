@@ -78,6 +78,25 @@ var _ = Describe("Locket commands", func() {
 			_, err = locketClient.Fetch(context.Background(), &models.FetchRequest{Key: "test-key"})
 			Expect(err).To(HaveOccurred())
 		})
+
+		It("does not release the lock on a dry run", func() {
+			cfdotCmd := exec.Command(cfdotPath,
+				"--locketAPILocation", locketAPILocation,
+				"release-lock",
+				"--key", "test-key",
+				"--owner", "test-owner",
+				"--dry-run",
+			)
+
+			sess, err := gexec.Start(cfdotCmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(sess).Should(gexec.Exit(0))
+			Expect(sess.Out).To(gbytes.Say(`"method":"Release"`))
+			Expect(sess.Out).To(gbytes.Say(`"current":{"key":"test-key","owner":"test-owner","value":"test-value"`))
+
+			_, err = locketClient.Fetch(context.Background(), &models.FetchRequest{Key: "test-key"})
+			Expect(err).NotTo(HaveOccurred())
+		})
 	})
 
 	Describe("claim-locks", func() {
@@ -250,4 +250,35 @@ var _ = Describe("lrp-events", func() {
 			Eventually(sess).Should(gexec.Exit(4))
 		})
 	})
+
+	Context("when filtering by process guid", func() {
+		BeforeEach(func() {
+			sseEvent1, err := events.NewEventFromModelEvent(1, models.NewActualLRPInstanceCreatedEvent(model_helpers.NewValidActualLRP("other-process-guid", 0), "some-trace-id"))
+			Expect(err).ToNot(HaveOccurred())
+			sseEvent2, err := events.NewEventFromModelEvent(2, models.NewActualLRPInstanceCreatedEvent(model_helpers.NewValidActualLRP("some-process-guid", 0), "some-trace-id"))
+			Expect(err).ToNot(HaveOccurred())
+
+			bbsServer.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", "/v1/events/lrp_instances.r1"),
+					ghttp.RespondWith(200, append(sseEvent1.Encode(), sseEvent2.Encode()...)),
+				),
+			)
+		})
+
+		It("only prints the events of the process guid", func() {
+			sess := RunCFDot("lrp-events", "--exclude-actual-lrp-groups", "--process-guid", "some-process-guid")
+			Eventually(sess).Should(gexec.Exit(0))
+			Expect(sess.Out).To(gbytes.Say("some-process-guid"))
+			Expect(string(sess.Out.Contents())).NotTo(ContainSubstring("other-process-guid"))
+		})
+	})
+
+	Context("when the event type is invalid", func() {
+		It("exits with status code of 3", func() {
+			sess := RunCFDot("lrp-events", "--type", "actual_lrp_instance_crashed")
+			Eventually(sess).Should(gexec.Exit(3))
+			Expect(sess.Err).To(gbytes.Say("'actual_lrp_instance_crashed' is not a valid event type"))
+		})
+	})
 })
@@ -0,0 +1,72 @@
+package integration_test
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+
+	"code.cloudfoundry.org/bbs/models"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/onsi/gomega/gexec"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("profile", func() {
+	BeforeEach(func() {
+		config := fmt.Sprintf(`profiles:
+  local:
+    bbs_url: %s
+    ca_cert_file: %s
+    client_cert_file: %s
+    client_key_file: %s
+  unreachable:
+    bbs_url: https://127.0.0.1:1
+    skip_cert_verify: true
+    client_cert_file: %s
+    client_key_file: %s
+`, bbsServer.URL(), locketCACertFile, locketClientCertFile, locketClientKeyFile, locketClientCertFile, locketClientKeyFile)
+		Expect(os.WriteFile(os.Getenv("CFDOT_CONFIG"), []byte(config), 0600)).To(Succeed())
+
+		bbsServer.RouteToHandler("POST", "/v1/domains/list",
+			ghttp.RespondWithProto(200, &models.DomainsResponse{
+				Domains: []string{"domain-1"},
+			}),
+		)
+	})
+
+	runCFDot := func(args ...string) *gexec.Session {
+		sess, err := gexec.Start(exec.Command(cfdotPath, args...), GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		return sess
+	}
+
+	It("targets the deployment from the --profile flag", func() {
+		sess := runCFDot("domains", "--profile", "local")
+		Eventually(sess).Should(gexec.Exit(0))
+		Expect(sess.Out).To(gbytes.Say(`"domain-1"\n`))
+	})
+
+	It("targets the deployment from the profile set with profile use", func() {
+		sess := runCFDot("profile", "use", "local")
+		Eventually(sess).Should(gexec.Exit(0))
+
+		sess = runCFDot("domains")
+		Eventually(sess).Should(gexec.Exit(0))
+		Expect(sess.Out).To(gbytes.Say(`"domain-1"\n`))
+	})
+
+	It("prefers flags over the profile", func() {
+		sess := runCFDot("domains", "--profile", "unreachable", "--bbsURL", bbsServer.URL(), "--caCertFile", locketCACertFile)
+		Eventually(sess).Should(gexec.Exit(0))
+		Expect(sess.Out).To(gbytes.Say(`"domain-1"\n`))
+	})
+
+	It("exits with status code 3 when the profile does not exist", func() {
+		sess := runCFDot("domains", "--profile", "missing")
+		Eventually(sess).Should(gexec.Exit(3))
+		Expect(sess.Err).To(gbytes.Say("Profile 'missing' not found"))
+	})
+})
@@ -0,0 +1,83 @@
+package integration_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"code.cloudfoundry.org/bbs/events"
+	"code.cloudfoundry.org/bbs/models"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/onsi/gomega/gexec"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("replay", func() {
+	var path string
+
+	// replay and crashing --replay need no BBS, so they run without the BBS
+	// flags of RunCFDot.
+	runOffline := func(args ...string) *gexec.Session {
+		sess, err := gexec.Start(exec.Command(cfdotPath, args...), GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		return sess
+	}
+
+	BeforeEach(func() {
+		path = filepath.Join(GinkgoT().TempDir(), "events.jsonl")
+	})
+
+	Context("when task-events recorded the events", func() {
+		BeforeEach(func() {
+			sseEvent, err := events.NewEventFromModelEvent(1, models.NewTaskRemovedEvent(&models.Task{TaskGuid: "some-guid"}))
+			Expect(err).ToNot(HaveOccurred())
+
+			bbsServer.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", "/v1/events/tasks.r1"),
+					ghttp.RespondWith(200, sseEvent.Encode()),
+				),
+			)
+
+			sess := RunCFDot("task-events", "--record", path)
+			Eventually(sess).Should(gexec.Exit(0))
+		})
+
+		It("prints the recorded events", func() {
+			sess := runOffline("replay", path, "--speed", "0")
+			Eventually(sess).Should(gexec.Exit(0))
+			Expect(sess.Out).To(gbytes.Say(`{"type":"task_removed","data":{"task":{"task_guid":"some-guid"`))
+		})
+	})
+
+	Context("when crashing replays the recording", func() {
+		BeforeEach(func() {
+			Expect(os.WriteFile(path, []byte(`{"timestamp":"2026-10-16T09:00:00Z","type":"actual_lrp_crashed","data":{"process_guid":"process-guid","index":0,"domain":"cf-apps","cell_id":"cell-1","crash_count":1,"since":1000000000}}
+{"timestamp":"2026-10-16T09:00:30Z","type":"actual_lrp_crashed","data":{"process_guid":"process-guid","index":0,"domain":"cf-apps","cell_id":"cell-1","crash_count":2,"since":31000000000}}
+`), 0600)).To(Succeed())
+		})
+
+		It("reports the crash loops without a BBS", func() {
+			sess := runOffline("crashing", "--watch", "--replay", path)
+			Eventually(sess).Should(gexec.Exit(0))
+			Expect(sess.Out).To(gbytes.Say(`"process_guid":"process-guid","index":0,"domain":"cf-apps","cell_id":"cell-1","crash_count":2,"crashes_in_window":2`))
+		})
+
+		It("requires --watch", func() {
+			sess := RunCFDot("crashing", "--replay", path)
+			Eventually(sess).Should(gexec.Exit(3))
+			Expect(sess.Err).To(gbytes.Say("--replay can only be used with --watch"))
+		})
+	})
+
+	Context("when the recording does not exist", func() {
+		It("exits with status code of 3", func() {
+			sess := runOffline("replay", path)
+			Eventually(sess).Should(gexec.Exit(3))
+			Expect(sess.Err).To(gbytes.Say("Unable to open recording"))
+		})
+	})
+})
@@ -0,0 +1,64 @@
+package integration_test
+
+import (
+	"code.cloudfoundry.org/bbs/models"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/onsi/gomega/gexec"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("restart-lrp", func() {
+	itValidatesBBSFlags("restart-lrp", "test-guid")
+
+	Context("when the desired lrp has no instances", func() {
+		BeforeEach(func() {
+			bbsServer.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", "/v1/desired_lrps/get_by_process_guid.r3"),
+					ghttp.RespondWithProto(200, &models.DesiredLRPResponse{
+						DesiredLrp: &models.DesiredLRP{ProcessGuid: "test-guid", Domain: "test-domain"},
+					}),
+				),
+			)
+		})
+
+		It("exits with status code of 0", func() {
+			sess := RunCFDot("restart-lrp", "test-guid")
+			Eventually(sess).Should(gexec.Exit(0))
+		})
+	})
+
+	Context("when the bbs returns an error", func() {
+		BeforeEach(func() {
+			bbsServer.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", "/v1/desired_lrps/get_by_process_guid.r3"),
+					ghttp.RespondWithProto(200, &models.DesiredLRPResponse{
+						Error: models.ErrResourceNotFound,
+					}),
+				),
+			)
+		})
+
+		It("exits with status code of 4", func() {
+			sess := RunCFDot("restart-lrp", "test-guid")
+			Eventually(sess).Should(gexec.Exit(4))
+		})
+	})
+
+	Context("when invalid arguments are passed", func() {
+		It("exits with status code of 3 without a process guid", func() {
+			sess := RunCFDot("restart-lrp")
+			Eventually(sess).Should(gexec.Exit(3))
+		})
+
+		It("exits with status code of 3 for an invalid max in flight", func() {
+			sess := RunCFDot("restart-lrp", "--max-in-flight", "0", "test-guid")
+			Eventually(sess).Should(gexec.Exit(3))
+			Expect(sess.Err).To(gbytes.Say("--max-in-flight must be a positive integer"))
+		})
+	})
+})
@@ -0,0 +1,88 @@
+package integration_test
+
+import (
+	"os"
+	"path/filepath"
+
+	"code.cloudfoundry.org/bbs/models"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/onsi/gomega/gexec"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("restore", func() {
+	var path string
+
+	BeforeEach(func() {
+		path = filepath.Join(GinkgoT().TempDir(), "dump.jsonl")
+		Expect(os.WriteFile(path, []byte(`"cf-apps"
+{"process_guid":"process-guid","domain":"cf-apps","rootfs":"preloaded:cflinuxfs4","instances":1}
+{"process_guid":"existing-guid","domain":"cf-apps","rootfs":"preloaded:cflinuxfs4","instances":1}
+`), 0600)).To(Succeed())
+	})
+
+	itValidatesBBSFlags("restore", "dump.jsonl")
+
+	Context("when the bbs responds", func() {
+		BeforeEach(func() {
+			bbsServer.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", "/v1/domains/list"),
+					ghttp.RespondWithProto(200, &models.DomainsResponse{}),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", "/v1/domains/upsert"),
+					ghttp.RespondWithProto(200, &models.UpsertDomainResponse{}),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", "/v1/desired_lrps/get_by_process_guid.r3"),
+					ghttp.RespondWithProto(200, &models.DesiredLRPResponse{Error: models.ErrResourceNotFound}),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", "/v1/desired_lrp/desire.r2"),
+					ghttp.RespondWithProto(200, &models.DesiredLRPLifecycleResponse{}),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", "/v1/desired_lrps/get_by_process_guid.r3"),
+					ghttp.RespondWithProto(200, &models.DesiredLRPResponse{
+						DesiredLrp: &models.DesiredLRP{ProcessGuid: "existing-guid", Domain: "cf-apps", RootFs: "preloaded:cflinuxfs3", Instances: 1},
+					}),
+				),
+			)
+		})
+
+		It("recreates the missing records and reports the conflicts", func() {
+			sess := RunCFDot("restore", path)
+			Eventually(sess).Should(gexec.Exit(5))
+			Expect(sess.Out).To(gbytes.Say(`{"kind":"domain","guid":"cf-apps","action":"created"}`))
+			Expect(sess.Out).To(gbytes.Say(`{"kind":"desired_lrp","guid":"process-guid","action":"created"}`))
+			Expect(sess.Out).To(gbytes.Say(`{"kind":"desired_lrp","guid":"existing-guid","action":"conflict","fields":\["rootfs"\]`))
+			Expect(sess.Err).To(gbytes.Say("Restore finished with 1 conflicts and 0 failures"))
+			Expect(bbsServer.ReceivedRequests()).To(HaveLen(5))
+		})
+	})
+
+	Context("when the file is invalid", func() {
+		BeforeEach(func() {
+			Expect(os.WriteFile(path, []byte(`{"cell_id":"cell-1"}`), 0600)).To(Succeed())
+		})
+
+		It("exits with status code of 3 without contacting the bbs", func() {
+			sess := RunCFDot("restore", path)
+			Eventually(sess).Should(gexec.Exit(3))
+			Expect(sess.Err).To(gbytes.Say("record 1: spec has neither a process_guid nor a task_guid"))
+			Expect(bbsServer.ReceivedRequests()).To(BeEmpty())
+		})
+	})
+
+	Context("when no file is given", func() {
+		It("exits with status code of 3", func() {
+			sess := RunCFDot("restore")
+			Eventually(sess).Should(gexec.Exit(3))
+			Expect(sess.Err).To(gbytes.Say("No restore file given"))
+		})
+	})
+})
@@ -99,4 +99,68 @@ var _ = Describe("retire-actual-lrp", func() {
 			Eventually(session).Should(gexec.Exit(3))
 		})
 	})
+
+	Context("when retiring actual lrps by selector", func() {
+		BeforeEach(func() {
+			bbsServer.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", "/v1/actual_lrps/list"),
+					ghttp.RespondWithProto(200, &models.ActualLRPsResponse{
+						ActualLrps: []*models.ActualLRP{
+							{
+								ActualLRPKey:         models.NewActualLRPKey("test-process-guid", 1, "test-domain"),
+								ActualLRPInstanceKey: models.NewActualLRPInstanceKey("instance-guid", "cell-1"),
+								State:                models.ActualLRPStateCrashed,
+							},
+							{
+								ActualLRPKey:         models.NewActualLRPKey("test-process-guid", 2, "test-domain"),
+								ActualLRPInstanceKey: models.NewActualLRPInstanceKey("instance-guid", "cell-1"),
+								State:                models.ActualLRPStateRunning,
+							},
+						},
+					}),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", "/v1/actual_lrps/retire"),
+					ghttp.VerifyProtoRepresenting(&models.RetireActualLRPRequest{
+						ActualLrpKey: &models.ActualLRPKey{ProcessGuid: "test-process-guid", Index: 1, Domain: "test-domain"},
+					}),
+					ghttp.RespondWithProto(200, &models.ActualLRPLifecycleResponse{}),
+				),
+			)
+		})
+
+		It("prints the planned retirements on a dry run", func() {
+			session := RunCFDot("retire-actual-lrp", "--cell-id", "cell-1", "--state", "crashed", "--dry-run")
+			Eventually(session).Should(gexec.Exit(0))
+			Expect(session.Out).To(gbytes.Say(`"process_guid":"test-process-guid","index":1`))
+			Expect(session.Out).NotTo(gbytes.Say(`"index":2`))
+			Expect(bbsServer.ReceivedRequests()).To(HaveLen(1))
+		})
+
+		It("retires the selected actual lrps", func() {
+			session := RunCFDot("retire-actual-lrp", "--cell-id", "cell-1", "--state", "CRASHED", "--yes")
+			Eventually(session).Should(gexec.Exit(0))
+			Expect(bbsServer.ReceivedRequests()).To(HaveLen(2))
+		})
+	})
+
+	Context("when invalid selectors are passed", func() {
+		It("exits with exit code 3 for an unknown state", func() {
+			session := RunCFDot("retire-actual-lrp", "--state", "bogus")
+			Eventually(session).Should(gexec.Exit(3))
+			Expect(session.Err).To(gbytes.Say("--state must be one of"))
+		})
+
+		It("exits with exit code 3 when reading keys from stdin without --yes", func() {
+			session := RunCFDot("retire-actual-lrp", "--stdin")
+			Eventually(session).Should(gexec.Exit(3))
+			Expect(session.Err).To(gbytes.Say("--yes is required when reading keys from stdin"))
+		})
+
+		It("exits with exit code 3 when combined with positional arguments", func() {
+			session := RunCFDot("retire-actual-lrp", "--cell-id", "cell-1", "test-process-guid", "1")
+			Eventually(session).Should(gexec.Exit(3))
+		})
+	})
 })
@@ -0,0 +1,66 @@
+package integration_test
+
+import (
+	"code.cloudfoundry.org/bbs/models"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/onsi/gomega/gexec"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("scale", func() {
+	itValidatesBBSFlags("scale", "test-guid", "--instances", "2")
+
+	Context("when waiting for the instances", func() {
+		BeforeEach(func() {
+			bbsServer.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", "/v1/desired_lrp/update"),
+					ghttp.RespondWithProto(200, &models.DesiredLRPLifecycleResponse{}),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", "/v1/actual_lrps/list"),
+					ghttp.RespondWithProto(200, &models.ActualLRPsResponse{
+						ActualLrps: []*models.ActualLRP{
+							{ActualLRPKey: models.NewActualLRPKey("test-guid", 0, "domain"), State: models.ActualLRPStateRunning},
+						},
+					}),
+				),
+			)
+		})
+
+		It("exits with status code of 0 once they are running", func() {
+			sess := RunCFDot("scale", "test-guid", "--instances", "1", "--wait")
+			Eventually(sess).Should(gexec.Exit(0))
+			Expect(sess.Out).To(gbytes.Say(`"running":1`))
+		})
+	})
+
+	Context("when the bbs returns an error", func() {
+		BeforeEach(func() {
+			bbsServer.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", "/v1/desired_lrp/update"),
+					ghttp.RespondWithProto(200, &models.DesiredLRPLifecycleResponse{
+						Error: models.ErrResourceNotFound,
+					}),
+				),
+			)
+		})
+
+		It("exits with status code of 4", func() {
+			sess := RunCFDot("scale", "test-guid", "--instances", "1")
+			Eventually(sess).Should(gexec.Exit(4))
+		})
+	})
+
+	Context("when --instances is missing", func() {
+		It("exits with status code of 3", func() {
+			sess := RunCFDot("scale", "test-guid")
+			Eventually(sess).Should(gexec.Exit(3))
+			Expect(sess.Err).To(gbytes.Say("--instances must be a non-negative integer"))
+		})
+	})
+})
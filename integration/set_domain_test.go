@@ -95,6 +95,24 @@ var _ = Describe("set-domain", func() {
 		})
 	})
 
+	Context("when --dry-run is given", func() {
+		BeforeEach(func() {
+			bbsServer.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", "/v1/domains/list"),
+					ghttp.RespondWithProto(200, &models.DomainsResponse{Domains: []string{"other-domain"}}),
+				),
+			)
+		})
+
+		It("prints the upsert request without sending it", func() {
+			sess := RunCFDot("set-domain", "any-domain", "--ttl", "40s", "--dry-run")
+			Eventually(sess).Should(gexec.Exit(0))
+			Expect(sess.Out).To(gbytes.Say(`"method":"UpsertDomain","request":{"domain":"any-domain","ttl":40},"current":\["other-domain"\]`))
+			Expect(bbsServer.ReceivedRequests()).To(HaveLen(1))
+		})
+	})
+
 	Context("when the server does not respond for set-domain", func() {
 		BeforeEach(func() {
 			bbsServer.RouteToHandler("POST", "/v1/domains/upsert",
@@ -0,0 +1,36 @@
+package integration_test
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/onsi/gomega/gexec"
+)
+
+var _ = Describe("simulate-placement", func() {
+	itValidatesBBSFlags("simulate-placement", `{"process_guid":"test-guid"}`)
+
+	Context("when neither a spec nor a process guid is given", func() {
+		It("exits with status code of 3", func() {
+			sess := RunCFDot("simulate-placement")
+			Eventually(sess).Should(gexec.Exit(3))
+			Expect(sess.Err).To(gbytes.Say("Either a desired LRP spec or --process-guid is required"))
+		})
+	})
+
+	Context("when both a spec and a process guid are given", func() {
+		It("exits with status code of 3", func() {
+			sess := RunCFDot("simulate-placement", "--process-guid", "test-guid", "--instances", "2", `{"process_guid":"test-guid"}`)
+			Eventually(sess).Should(gexec.Exit(3))
+			Expect(sess.Err).To(gbytes.Say("A desired LRP spec cannot be combined with --process-guid"))
+		})
+	})
+
+	Context("when a process guid is given without instances", func() {
+		It("exits with status code of 3", func() {
+			sess := RunCFDot("simulate-placement", "--process-guid", "test-guid")
+			Eventually(sess).Should(gexec.Exit(3))
+			Expect(sess.Err).To(gbytes.Say("--instances is required with --process-guid"))
+		})
+	})
+})
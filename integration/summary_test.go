@@ -0,0 +1,72 @@
+package integration_test
+
+import (
+	"code.cloudfoundry.org/bbs/models"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/onsi/gomega/gexec"
+	"github.com/onsi/gomega/ghttp"
+)
+
+var _ = Describe("summary", func() {
+	itValidatesBBSFlags("summary")
+	itHasNoArgs("summary", false)
+
+	Context("when the bbs responds", func() {
+		BeforeEach(func() {
+			bbsServer.RouteToHandler("POST", "/v1/domains/list",
+				ghttp.RespondWithProto(200, &models.DomainsResponse{
+					Domains: []string{"domain-1"},
+				}),
+			)
+			bbsServer.RouteToHandler("POST", "/v1/desired_lrp_scheduling_infos/list",
+				ghttp.RespondWithProto(200, &models.DesiredLRPSchedulingInfosResponse{
+					DesiredLrpSchedulingInfos: []*models.DesiredLRPSchedulingInfo{
+						{DesiredLRPKey: models.DesiredLRPKey{ProcessGuid: "process-guid-1"}, Instances: 2},
+					},
+				}),
+			)
+			bbsServer.RouteToHandler("POST", "/v1/actual_lrps/list",
+				ghttp.RespondWithProto(200, &models.ActualLRPsResponse{
+					ActualLrps: []*models.ActualLRP{
+						{
+							ActualLRPKey:         models.NewActualLRPKey("process-guid-1", 0, "domain-1"),
+							ActualLRPInstanceKey: models.NewActualLRPInstanceKey("instance-guid-1", "cell-1"),
+							State:                models.ActualLRPStateRunning,
+						},
+					},
+				}),
+			)
+			bbsServer.RouteToHandler("POST", "/v1/tasks/list.r3",
+				ghttp.RespondWithProto(200, &models.TasksResponse{}),
+			)
+			bbsServer.RouteToHandler("POST", "/v1/cells/list.r1",
+				ghttp.RespondWithProto(200, &models.CellsResponse{
+					Cells: []*models.CellPresence{{CellId: "cell-1", Zone: "z1"}},
+				}),
+			)
+		})
+
+		It("prints a json summary", func() {
+			sess := RunCFDot("summary")
+			Eventually(sess).Should(gexec.Exit(0))
+			Expect(sess.Out).To(gbytes.Say(`"desired_instances":2,"running_instances":1`))
+			Expect(sess.Out).To(gbytes.Say(`"cell_id":"cell-1","zone":"z1","registered":true,"instances":1`))
+		})
+	})
+
+	Context("when the bbs errors", func() {
+		BeforeEach(func() {
+			bbsServer.RouteToHandler("POST", "/v1/domains/list",
+				ghttp.RespondWith(500, []byte{}),
+			)
+		})
+
+		It("exits with status code 4", func() {
+			sess := RunCFDot("summary")
+			Eventually(sess).Should(gexec.Exit(4))
+		})
+	})
+})
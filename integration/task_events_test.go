@@ -52,4 +52,49 @@ var _ = Describe("task-events", func() {
 			Eventually(sess).Should(gexec.Exit(4))
 		})
 	})
+
+	Context("when --reconnect is set", func() {
+		BeforeEach(func() {
+			createdEvent, err := events.NewEventFromModelEvent(1, models.NewTaskCreatedEvent(&models.Task{TaskGuid: "first-guid"}))
+			Expect(err).ToNot(HaveOccurred())
+			removedEvent, err := events.NewEventFromModelEvent(2, models.NewTaskRemovedEvent(&models.Task{TaskGuid: "second-guid"}))
+			Expect(err).ToNot(HaveOccurred())
+
+			bbsServer.AllowUnhandledRequests = true
+			bbsServer.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", "/v1/events/tasks.r1"),
+					ghttp.RespondWith(200, createdEvent.Encode()),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", "/v1/events/tasks.r1"),
+					ghttp.RespondWith(200, removedEvent.Encode()),
+				),
+			)
+		})
+
+		It("resubscribes when the event stream ends", func() {
+			sess := RunCFDot("task-events", "--reconnect", "--reconnect-max-backoff", "10ms")
+			defer sess.Kill()
+
+			Eventually(sess.Out).Should(gbytes.Say("first-guid"))
+			Eventually(sess.Out).Should(gbytes.Say(`{"type":"stream_reconnected"}`))
+			Eventually(sess.Out).Should(gbytes.Say("second-guid"))
+			Eventually(sess.Err).Should(gbytes.Say("Event stream was closed, resubscribing in 10ms"))
+		})
+
+		It("validates --reconnect-max-backoff", func() {
+			sess := RunCFDot("task-events", "--reconnect", "--reconnect-max-backoff", "0s")
+			Eventually(sess).Should(gexec.Exit(3))
+			Expect(sess.Err).To(gbytes.Say("--reconnect-max-backoff must be a positive duration"))
+		})
+	})
+
+	Context("when the task state is invalid", func() {
+		It("exits with status code of 3", func() {
+			sess := RunCFDot("task-events", "--state", "crashed")
+			Eventually(sess).Should(gexec.Exit(3))
+			Expect(sess.Err).To(gbytes.Say("'crashed' is not a valid task state. Please specify one of: Pending, Running, Completed, Resolving"))
+		})
+	})
 })
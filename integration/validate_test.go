@@ -0,0 +1,38 @@
+package integration_test
+
+import (
+	"os/exec"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/onsi/gomega/gexec"
+)
+
+var _ = Describe("validate", func() {
+	runValidate := func(args ...string) *gexec.Session {
+		cfdotCmd := exec.Command(cfdotPath, append([]string{"validate"}, args...)...)
+		sess, err := gexec.Start(cfdotCmd, GinkgoWriter, GinkgoWriter)
+		Expect(err).NotTo(HaveOccurred())
+		return sess
+	}
+
+	It("exits with status code of 0 for a valid spec without a bbs", func() {
+		sess := runValidate("update", `{"instances":2}`)
+		Eventually(sess).Should(gexec.Exit(0))
+		Expect(bbsServer.ReceivedRequests()).To(BeEmpty())
+	})
+
+	It("exits with status code of 3 and prints the violations for an invalid spec", func() {
+		sess := runValidate("update", `{"instances":-1}`)
+		Eventually(sess).Should(gexec.Exit(3))
+		Expect(sess.Out).To(gbytes.Say(`"path":"\$.instances"`))
+		Expect(sess.Err).To(gbytes.Say("Found 1 violations"))
+	})
+
+	It("exits with status code of 3 for an unknown kind", func() {
+		sess := runValidate("actual-lrp", `{}`)
+		Eventually(sess).Should(gexec.Exit(3))
+		Expect(sess.Err).To(gbytes.Say("Kind must be one of desired-lrp, task or update"))
+	})
+})